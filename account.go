@@ -0,0 +1,91 @@
+package gocloudfiles
+
+import (
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// Custom account metadata is sent and returned under this header prefix.
+const accountMetaPrefix = "X-Account-Meta-"
+
+// AccountInfo is the usage and metadata of the account in a region.
+type AccountInfo struct {
+	Region     string
+	Bytes      int64
+	Objects    int64
+	Containers int64
+
+	// Metadata holds the X-Account-Meta-* values, such as "Quota-Bytes",
+	// keyed by the name after the prefix in canonical form.  It includes
+	// the Temp-Url-Key when one is set.
+	Metadata map[string]string
+
+	// Headers is the whole response, for headers without a field.
+	Headers http.Header
+}
+
+func (cf CloudFiles) GetAccountInfo(dc string) (*AccountInfo, error) {
+	/*
+		Describe the account's usage in a region from a HEAD of its
+		storage URL, to check there is room before a large copy.  Usage is
+		updated by the cluster in the background and can lag recent writes.
+		Returns a tuple of account info, error
+	*/
+	headers, err := cf.headAccount(dc)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &AccountInfo{Region: dc, Metadata: map[string]string{}, Headers: headers}
+	info.Bytes, _ = headerInt(headers, "X-Account-Bytes-Used")
+	info.Objects, _ = headerInt(headers, "X-Account-Object-Count")
+	info.Containers, _ = headerInt(headers, "X-Account-Container-Count")
+	for key := range headers {
+		if strings.HasPrefix(key, accountMetaPrefix) {
+			info.Metadata[strings.TrimPrefix(key, accountMetaPrefix)] = headers.Get(key)
+		}
+	}
+	return info, nil
+}
+
+func (cf CloudFiles) ForAccount(account string) *CloudFiles {
+	/*
+		A client for another account of the same cluster, such as
+		AUTH_<tenant> with a reseller admin token, for the calls made with
+		it: cf.ForAccount("AUTH_other").ListObjects(...).  The last segment
+		of every storage URL is replaced by account, the token, its renewal
+		and every other setting stay shared with cf.  An empty account is
+		the token's own.
+	*/
+	cf.account = account
+	return &cf
+}
+
+func (cf CloudFiles) Account(dc string) (string, error) {
+	/*
+		The account requests to a region are sent to, the last segment of
+		its storage URL.
+		Returns a tuple of account, error
+	*/
+	endpoint, err := cf.endpoint(dc)
+	if err != nil {
+		return "", err
+	}
+	account, err := neturl.PathUnescape(endpoint[strings.LastIndex(endpoint, "/")+1:])
+	if err != nil {
+		return "", err
+	}
+	return account, nil
+}
+
+func (cf CloudFiles) onAccount(endpoint string) string {
+	/*
+		A storage URL moved to the ForAccount account, when there is one.
+	*/
+	if cf.account == "" || endpoint == "" {
+		return endpoint
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	return endpoint[:strings.LastIndex(endpoint, "/")+1] + neturl.PathEscape(cf.account)
+}
@@ -0,0 +1,69 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestGetAccountInfo(t *testing.T) {
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.headers[""] = http.Header{
+		"X-Account-Bytes-Used":       {"5000000000000"},
+		"X-Account-Object-Count":     {"120"},
+		"X-Account-Container-Count":  {"4"},
+		"X-Account-Meta-Quota-Bytes": {"10000000000000"},
+	}
+
+	info, err := cf.GetAccountInfo("TEST")
+	if err != nil {
+		t.Fatalf("Could not get account info: %s", err)
+	}
+	if info.Region != "TEST" || info.Bytes != 5000000000000 || info.Objects != 120 || info.Containers != 4 {
+		t.Fatalf("Unexpected usage %+v", info)
+	}
+	if len(info.Metadata) != 1 || info.Metadata["Quota-Bytes"] != "10000000000000" {
+		t.Fatalf("Unexpected metadata %v", info.Metadata)
+	}
+
+	if _, err := cf.GetAccountInfo("ORD"); err == nil {
+		t.Fatalf("Expected an error for a region not in the catalog.")
+	}
+}
+
+func TestForAccount(t *testing.T) {
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.dcs["TEST"] = fs.server.URL + "/v1/AUTH_admin"
+
+	other := cf.ForAccount("AUTH_other")
+	if _, err := other.PutFile("TEST", "bucket", "file", bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatalf("Could not write to the other account: %s", err)
+	}
+	if data, found := fs.get("v1/AUTH_other/bucket/file"); !found || string(data) != "data" {
+		t.Fatalf("Object was not written to the other account: %q", data)
+	}
+	if _, found := fs.get("v1/AUTH_admin/bucket/file"); found {
+		t.Fatalf("Object was written to the token's own account.")
+	}
+
+	if account, err := other.Account("TEST"); err != nil || account != "AUTH_other" {
+		t.Fatalf("Unexpected account %q, %v", account, err)
+	}
+	if account, err := cf.Account("TEST"); err != nil || account != "AUTH_admin" {
+		t.Fatalf("Override leaked to the original client: %q, %v", account, err)
+	}
+
+	url, err := other.PublicURL("TEST", "bucket", "file")
+	if err != nil || url != fs.server.URL+"/v1/AUTH_other/bucket/file" {
+		t.Fatalf("Unexpected public URL %s, %v", url, err)
+	}
+	location, err := other.ParseObjectURL(url)
+	if err != nil || location.Container != "bucket" || location.Object != "file" {
+		t.Fatalf("Could not parse %s back: %+v, %v", url, location, err)
+	}
+}
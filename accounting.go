@@ -0,0 +1,163 @@
+package gocloudfiles
+
+import (
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync"
+)
+
+// A Usage reports the bytes one request moved.  Ingress is data sent into
+// Cloud Files, such as uploads; Egress is data read out of it, which
+// Rackspace bills as bandwidth.
+type Usage struct {
+	Region    string
+	Container string
+	Op        string
+	Requests  int64
+	Ingress   int64
+	Egress    int64
+}
+
+func (cf *CloudFiles) SetAccounting(report func(Usage)) {
+	/*
+		Call report once for every storage request, after its response
+		body is closed, tagged by region, container and operation.  The
+		function may be called from many goroutines at once.  A nil report
+		turns accounting off.
+	*/
+	cf.accounting = report
+}
+
+func requestOp(req *http.Request, object string) string {
+	/*
+		Classify a request the way usage is reported.
+	*/
+	switch {
+	case req.Header.Get("X-Copy-From") != "":
+		return "copy"
+	case req.URL.Query().Get("multipart-manifest") == "put":
+		return "manifest"
+	}
+
+	container := object == ""
+	switch req.Method {
+	case "GET":
+		if container {
+			return "list"
+		}
+		return "download"
+	case "PUT":
+		if container {
+			return "create"
+		}
+		return "upload"
+	case "HEAD":
+		return "head"
+	case "DELETE":
+		return "delete"
+	case "POST":
+		return "update"
+	}
+	return strings.ToLower(req.Method)
+}
+
+func (cf CloudFiles) requestTarget(dc string, req *http.Request) (string, string) {
+	/*
+		Work out the container and object of a storage request from its
+		path below the region's endpoint.
+	*/
+	path := req.URL.Path
+	if endpoint, err := cf.endpoint(dc); err == nil {
+		if u, err := neturl.Parse(endpoint); err == nil {
+			path = strings.TrimPrefix(path, u.Path)
+		}
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+func (cf CloudFiles) usageFor(dc string, req *http.Request) Usage {
+	container, object := cf.requestTarget(dc, req)
+	return Usage{Region: dc, Container: container, Op: requestOp(req, object), Requests: 1}
+}
+
+type countingBody struct {
+	body  io.ReadCloser
+	count int64
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.body.Read(p)
+	cb.count += int64(n)
+	return n, err
+}
+
+func (cb *countingBody) Close() error {
+	return cb.body.Close()
+}
+
+// reportingBody reports a request's usage once its response is closed.
+type reportingBody struct {
+	countingBody
+	once   sync.Once
+	usage  Usage
+	sent   *countingBody
+	report func(Usage)
+}
+
+func (rb *reportingBody) Close() error {
+	err := rb.countingBody.Close()
+	rb.once.Do(func() {
+		rb.usage.Egress = rb.count
+		if rb.sent != nil {
+			rb.usage.Ingress = rb.sent.count
+		}
+		rb.report(rb.usage)
+	})
+	return err
+}
+
+// A UsageMeter adds up Usage reports per region, container and operation.
+// Pass its Record method to SetAccounting.
+type UsageMeter struct {
+	mutex  sync.Mutex
+	totals map[string]Usage
+}
+
+func NewUsageMeter() *UsageMeter {
+	return &UsageMeter{totals: make(map[string]Usage)}
+}
+
+func (um *UsageMeter) Record(usage Usage) {
+	um.mutex.Lock()
+	defer um.mutex.Unlock()
+
+	key := usage.Region + "/" + usage.Container + "/" + usage.Op
+	total := um.totals[key]
+	total.Region, total.Container, total.Op = usage.Region, usage.Container, usage.Op
+	total.Requests += usage.Requests
+	total.Ingress += usage.Ingress
+	total.Egress += usage.Egress
+	um.totals[key] = total
+}
+
+func (um *UsageMeter) Totals() []Usage {
+	/*
+		The usage recorded so far, one entry per region, container and
+		operation.
+	*/
+	um.mutex.Lock()
+	defer um.mutex.Unlock()
+
+	totals := make([]Usage, 0, len(um.totals))
+	for _, total := range um.totals {
+		totals = append(totals, total)
+	}
+	return totals
+}
@@ -0,0 +1,45 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestAccounting(t *testing.T) {
+	// Test uploads and downloads are reported by region, container and operation.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	meter := NewUsageMeter()
+	cf.SetAccounting(meter.Record)
+
+	_, err := cf.PutFile("TEST", "testing", "file.bin", bytes.NewReader(make([]byte, 100)))
+	if err != nil {
+		t.Fatalf("Could not put file: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, _, err = cf.GetChunk("TEST", "testing", "file.bin", ioutil.Discard, 0, 40)
+		if err != nil {
+			t.Fatalf("Could not get chunk: %s", err)
+		}
+	}
+
+	totals := make(map[string]Usage)
+	for _, total := range meter.Totals() {
+		if total.Region != "TEST" || total.Container != "testing" {
+			t.Fatalf("Unexpected tags: %+v", total)
+		}
+		totals[total.Op] = total
+	}
+
+	if upload := totals["upload"]; upload.Requests != 1 || upload.Ingress != 100 || upload.Egress != 0 {
+		t.Fatalf("Unexpected upload usage: %+v", upload)
+	}
+
+	if download := totals["download"]; download.Requests != 2 || download.Egress != 80 {
+		t.Fatalf("Unexpected download usage: %+v", download)
+	}
+}
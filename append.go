@@ -0,0 +1,205 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Swift refuses manifests that list more segments than this by default.
+const maxManifestSegments = 1000
+
+// ErrAppendConflict is matched by errors.Is when an object changed while
+// data was being appended to it.
+var ErrAppendConflict = errors.New("Object changed during append.")
+
+// AppendConflictError is returned by AppendObject when another writer changed
+// the object before its new manifest was written.
+type AppendConflictError struct {
+	Region string
+	Bucket string
+	Name   string
+}
+
+func (e *AppendConflictError) Error() string {
+	return fmt.Sprintf("Object %s/%s in region %s changed during append.", e.Bucket, e.Name, e.Region)
+}
+
+func (e *AppendConflictError) Is(target error) bool {
+	return target == ErrAppendConflict
+}
+
+func (cf CloudFiles) AppendObject(dc, bucket, filename string, data io.Reader) (int64, error) {
+	/*
+		Append data to an object without rewriting what it already holds.
+		The data is uploaded as new segments, each checked against its MD5,
+		then the object's manifest is replaced by one listing its current
+		segments followed by the new ones, so readers see the object either
+		before or after the append.  A plain object is first copied server
+		side into a segment of its own and a missing one is created.
+		Appending nothing leaves the object alone.
+
+		When another writer changes the object meanwhile the new segments
+		are removed and an error matching ErrAppendConflict is returned.
+		The check is made just before the manifest is written, writers that
+		append at the same moment should take a Lock.
+		Returns a tuple of bytes appended, error
+	*/
+	transferID := NewTransferID()
+
+	headers, err := cf.headObject(dc, bucket, filename)
+	if err != nil && !errors.Is(err, ErrObjectMissing) {
+		return 0, err
+	}
+
+	existing := newObjectInfo(filename, headers)
+
+	var items manifestList
+	var written []string
+
+	// Segments of a failed append are left for nobody to reference.
+	cleanup := func() {
+		for _, name := range written {
+			cf.deleteObject(dc, bucket, name)
+		}
+	}
+
+	switch {
+	case headers == nil:
+	case existing.StaticLargeObject:
+		segments, err := cf.getManifest(dc, bucket, filename)
+		if err != nil {
+			return 0, err
+		}
+		for _, segment := range segments {
+			items = append(items, segment.item())
+		}
+	case existing.Bytes > 0:
+		segment := segmentName(filename, transferID, 0, DefaultSegmentDigits)
+		etag, err := cf.serverCopy(dc, bucket, filename, bucket, segment, nil)
+		if err != nil {
+			return 0, err
+		}
+		written = append(written, segment)
+		items = append(items, manifestItem{
+			Path: fmt.Sprintf("%s/%s", bucket, segment),
+			ETag: etag,
+			Size: existing.Bytes,
+		})
+	}
+
+	appended := int64(0)
+	for index := int64(len(written)); ; index++ {
+		if len(items) >= maxManifestSegments {
+			cleanup()
+			return 0, fmt.Errorf("Could not append to %s, it would have more than %d segments.",
+				filename, maxManifestSegments)
+		}
+
+		item, size, err := cf.appendSegment(dc, bucket, filename, transferID, index, data)
+		if err != nil {
+			cleanup()
+			return 0, err
+		}
+		if item == nil {
+			break
+		}
+
+		written = append(written, strings.TrimPrefix(item.Path, bucket+"/"))
+		items = append(items, *item)
+		appended += size
+
+		if size < defaultChunkSize {
+			break
+		}
+	}
+
+	if appended == 0 {
+		cleanup()
+		return 0, nil
+	}
+
+	current, err := cf.headObject(dc, bucket, filename)
+	if err != nil && !errors.Is(err, ErrObjectMissing) {
+		cleanup()
+		return 0, err
+	}
+	changed := current != nil && !sameETag(current.Get("Etag"), existing.ETag)
+	if (current == nil) != (headers == nil) || changed {
+		cleanup()
+		return 0, &AppendConflictError{Region: dc, Bucket: bucket, Name: filename}
+	}
+
+	err = cf.putManifest(dc, bucket, filename, items, keptHeaders(headers))
+	if err != nil {
+		cleanup()
+		return 0, err
+	}
+
+	return appended, nil
+}
+
+func (cf CloudFiles) appendSegment(dc, bucket, filename, transferID string, index int64,
+	data io.Reader) (*manifestItem, int64, error) {
+	/*
+		Stage the next segment of appended data and upload it with its
+		MD5.  Returns no item once the data is exhausted.
+	*/
+	tmpFile, err := ioutil.TempFile("", "")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	size, err := io.CopyN(tmpFile, data, defaultChunkSize)
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+
+	if size == 0 {
+		return nil, 0, nil
+	}
+
+	tmpFile.Seek(0, io.SeekStart)
+
+	expected, err := md5Of(tmpFile)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	segment := segmentName(filename, transferID, index, DefaultSegmentDigits)
+	etag, err := cf.putObject(dc, bucket, segment, tmpFile, expected, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &manifestItem{
+		Path: fmt.Sprintf("%s/%s", bucket, segment),
+		ETag: etag,
+		Size: size,
+	}, size, nil
+}
+
+func keptHeaders(headers http.Header) *WriteOptions {
+	/*
+		The content type and metadata an object keeps when its manifest is
+		rewritten.  A new object gets the type of plain data.
+	*/
+	options := &WriteOptions{Headers: map[string]string{}}
+	for key := range headers {
+		if strings.HasPrefix(key, "X-Object-Meta-") || key == "Content-Encoding" {
+			options.Headers[key] = headers.Get(key)
+		}
+	}
+	options.ContentType = headers.Get("Content-Type")
+	if options.ContentType == "" {
+		options.ContentType = "application/octet-stream"
+	}
+	return options
+}
@@ -0,0 +1,88 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// A reader that overwrites an object the first time it is read, standing in
+// for another writer.
+type interferingReader struct {
+	io.Reader
+	fs   *fakeSwift
+	path string
+	done bool
+}
+
+func (r *interferingReader) Read(p []byte) (int, error) {
+	if !r.done {
+		r.done = true
+		r.fs.put(r.path, []byte("replaced"))
+	}
+	return r.Reader.Read(p)
+}
+
+func TestAppendObject(t *testing.T) {
+	// Test appends create, convert and extend an object.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	if _, err := cf.AppendObject("TEST", "testing", "log.txt", strings.NewReader("one\n")); err != nil {
+		t.Fatalf("Could not create object: %s", err)
+	}
+	if size, err := cf.AppendObject("TEST", "testing", "log.txt", strings.NewReader("two\n")); err != nil || size != 4 {
+		t.Fatalf("Could not append: %d %v", size, err)
+	}
+	if stored, _ := fs.get("testing/log.txt"); string(stored) != "one\ntwo\n" {
+		t.Fatalf("Unexpected object %q", stored)
+	}
+	if segments := fs.manifests["testing/log.txt"]; len(segments) != 2 {
+		t.Fatalf("Expected 2 segments: %+v", segments)
+	}
+
+	// A plain object keeps its data and metadata.
+	fs.put("testing/plain.txt", []byte("head "))
+	fs.headers["testing/plain.txt"] = http.Header{"X-Object-Meta-Owner": {"ops"}, "Content-Type": {"text/plain"}}
+	if _, err := cf.AppendObject("TEST", "testing", "plain.txt", strings.NewReader("tail")); err != nil {
+		t.Fatalf("Could not append to plain object: %s", err)
+	}
+	if stored, _ := fs.get("testing/plain.txt"); string(stored) != "head tail" {
+		t.Fatalf("Unexpected object %q", stored)
+	}
+	if header := fs.headers["testing/plain.txt"]; header.Get("X-Object-Meta-Owner") != "ops" ||
+		header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("Metadata lost: %v", header)
+	}
+
+	if size, err := cf.AppendObject("TEST", "testing", "plain.txt", strings.NewReader("")); err != nil || size != 0 {
+		t.Fatalf("Empty append should do nothing: %d %v", size, err)
+	}
+}
+
+func TestAppendObjectConflict(t *testing.T) {
+	// Test an object changed during an append is left to the other writer.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("testing/log.txt", []byte("one\n"))
+	data := &interferingReader{Reader: bytes.NewBufferString("two\n"), fs: fs, path: "testing/log.txt"}
+
+	_, err := cf.AppendObject("TEST", "testing", "log.txt", data)
+	if !errors.Is(err, ErrAppendConflict) {
+		t.Fatalf("Expected a conflict: %v", err)
+	}
+	if stored, _ := fs.get("testing/log.txt"); string(stored) != "replaced" {
+		t.Fatalf("Other writer's object was changed: %q", stored)
+	}
+	for path := range fs.objects {
+		if isSegment(path) {
+			t.Fatalf("Segment %s left behind", path)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// An AuditEntry is one line of the audit log, written for every mutating
+// request the client makes.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Region    string    `json:"region"`
+	Container string    `json:"container"`
+	Object    string    `json:"object,omitempty"`
+	Op        string    `json:"op"`
+	Status    int       `json:"status"`
+	TransID   string    `json:"trans_id,omitempty"`
+}
+
+type auditLog struct {
+	dc        string
+	container string
+	clientID  string
+	mutex     sync.Mutex
+	hour      time.Time
+	lines     bytes.Buffer
+	now       func() time.Time
+
+	// Finished hours not written yet, kept until an upload succeeds.
+	pending map[time.Time][]byte
+	writing bool
+}
+
+func (cf *CloudFiles) SetAuditLog(dc, container string) {
+	/*
+		Log every mutating request (uploads, manifests, copies, deletes and
+		metadata updates) as JSON lines in objects of the given container,
+		one per hour and client, named "<yyyy>/<mm>/<dd>/<hh>-<client>.jsonl".
+		An hour's object is written when the first request of the next
+		hour is made and by FlushAuditLog.  An hour whose write fails is
+		kept and written again with the next request, and FlushAuditLog
+		reports it.  Requests to the audit container itself are not logged.
+		An empty container turns the log off.
+	*/
+	if container == "" {
+		cf.audit = nil
+		return
+	}
+
+	cf.audit = &auditLog{
+		dc:        dc,
+		container: container,
+		clientID:  NewTransferID(),
+		now:       time.Now,
+		pending:   make(map[time.Time][]byte),
+	}
+}
+
+func (al *auditLog) objectName(hour time.Time) string {
+	return fmt.Sprintf("%s-%s.jsonl", hour.Format("2006/01/02/15"), al.clientID)
+}
+
+func mutating(method string) bool {
+	return method == "PUT" || method == "POST" || method == "DELETE" || method == "COPY"
+}
+
+func (cf CloudFiles) auditRequest(dc string, req *http.Request, resp *http.Response) {
+	/*
+		Record a finished request in the audit log if it changed anything.
+	*/
+	if cf.audit == nil || !mutating(req.Method) {
+		return
+	}
+
+	container, object := cf.requestTarget(dc, req)
+	if dc == cf.audit.dc && container == cf.audit.container {
+		return
+	}
+
+	entry := AuditEntry{
+		Region:    dc,
+		Container: container,
+		Object:    object,
+		Op:        requestOp(req, object),
+		Status:    resp.StatusCode,
+		TransID:   resp.Header.Get("X-Trans-Id"),
+	}
+
+	cf.audit.mutex.Lock()
+	entry.Time = cf.audit.now().UTC()
+	hour := entry.Time.Truncate(time.Hour)
+
+	// A new hour starts a new object, the finished one waits to be
+	// written.
+	if !cf.audit.hour.IsZero() && !hour.Equal(cf.audit.hour) && cf.audit.lines.Len() > 0 {
+		finished := cf.audit.pending[cf.audit.hour]
+		cf.audit.pending[cf.audit.hour] = append(finished, cf.audit.lines.Bytes()...)
+		cf.audit.lines.Reset()
+	}
+	cf.audit.hour = hour
+
+	line, _ := json.Marshal(&entry)
+	cf.audit.lines.Write(line)
+	cf.audit.lines.WriteByte('\n')
+
+	// One request at a time writes the finished hours, the others go on.
+	write := len(cf.audit.pending) > 0 && !cf.audit.writing
+	cf.audit.writing = cf.audit.writing || write
+	cf.audit.mutex.Unlock()
+
+	if write {
+		cf.writeAuditHours()
+		cf.audit.mutex.Lock()
+		cf.audit.writing = false
+		cf.audit.mutex.Unlock()
+	}
+}
+
+func (cf CloudFiles) writeAuditHours() error {
+	/*
+		Write every finished hour not written yet, keeping those that fail
+		for the next try.  The error is the first write's that failed.
+	*/
+	cf.audit.mutex.Lock()
+	hours := make(map[time.Time][]byte, len(cf.audit.pending))
+	for hour, lines := range cf.audit.pending {
+		hours[hour] = lines
+	}
+	cf.audit.mutex.Unlock()
+
+	var first error
+	for hour, lines := range hours {
+		_, err := cf.PutFile(cf.audit.dc, cf.audit.container, cf.audit.objectName(hour), bytes.NewReader(lines))
+		if err != nil {
+			if first == nil {
+				first = fmt.Errorf("Could not write audit log of %s: %w", hour.Format("2006-01-02 15h"), err)
+			}
+			continue
+		}
+
+		// Lines added to the hour meanwhile, after the clock went back,
+		// are written with the next try.
+		cf.audit.mutex.Lock()
+		if len(cf.audit.pending[hour]) == len(lines) {
+			delete(cf.audit.pending, hour)
+		}
+		cf.audit.mutex.Unlock()
+	}
+	return first
+}
+
+func (cf CloudFiles) FlushAuditLog() error {
+	/*
+		Write the current hour's audit object with every entry so far, and
+		any finished hour whose write failed.  Call it before exiting; later
+		entries of the same hour rewrite the object with the earlier lines
+		kept.
+	*/
+	if cf.audit == nil {
+		return nil
+	}
+
+	if err := cf.writeAuditHours(); err != nil {
+		return err
+	}
+
+	cf.audit.mutex.Lock()
+	lines := append([]byte(nil), cf.audit.lines.Bytes()...)
+	hour := cf.audit.hour
+	cf.audit.mutex.Unlock()
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	_, err := cf.PutFile(cf.audit.dc, cf.audit.container, cf.audit.objectName(hour), bytes.NewReader(lines))
+	if err != nil {
+		return fmt.Errorf("Could not write audit log: %w", err)
+	}
+
+	return nil
+}
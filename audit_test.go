@@ -0,0 +1,86 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	// Test mutating requests are logged in one object per hour.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	cf.SetAuditLog("TEST", "_audit")
+	now := time.Date(2016, 1, 1, 10, 59, 0, 0, time.UTC)
+	cf.audit.now = func() time.Time { return now }
+
+	cf.PutFile("TEST", "testing", "a.txt", bytes.NewReader([]byte("a")))
+	cf.GetFileSize("TEST", "testing", "a.txt")
+	cf.deleteObject("TEST", "testing", "a.txt")
+
+	now = now.Add(2 * time.Minute)
+	cf.PutFile("TEST", "testing", "b.txt", bytes.NewReader([]byte("b")))
+
+	first, ok := fs.get("_audit/" + cf.audit.objectName(time.Date(2016, 1, 1, 10, 0, 0, 0, time.UTC)))
+	if !ok {
+		t.Fatalf("The finished hour should be written.")
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(first)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected the upload and delete to be logged: %q", first)
+	}
+
+	var entry AuditEntry
+	json.Unmarshal([]byte(lines[1]), &entry)
+	if entry.Op != "delete" || entry.Container != "testing" || entry.Object != "a.txt" || entry.Status != 204 {
+		t.Fatalf("Unexpected entry: %+v", entry)
+	}
+
+	if err := cf.FlushAuditLog(); err != nil {
+		t.Fatalf("Could not flush audit log: %s", err)
+	}
+
+	second, _ := fs.get("_audit/" + cf.audit.objectName(time.Date(2016, 1, 1, 11, 0, 0, 0, time.UTC)))
+	if !strings.Contains(string(second), `"object":"b.txt"`) {
+		t.Fatalf("The current hour should be flushed: %q", second)
+	}
+}
+
+func TestAuditLogKeepsFailedHours(t *testing.T) {
+	// Test an hour whose write fails is kept, reported by FlushAuditLog and
+	// written with a later request.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	cf.SetAuditLog("TEST", "_audit")
+	now := time.Date(2016, 1, 1, 10, 59, 0, 0, time.UTC)
+	cf.audit.now = func() time.Time { return now }
+	path := "_audit/" + cf.audit.objectName(time.Date(2016, 1, 1, 10, 0, 0, 0, time.UTC))
+	fs.fail(path, 2)
+
+	cf.PutFile("TEST", "testing", "a.txt", bytes.NewReader([]byte("a")))
+	now = now.Add(2 * time.Minute)
+	cf.PutFile("TEST", "testing", "b.txt", bytes.NewReader([]byte("b")))
+
+	if _, ok := fs.get(path); ok {
+		t.Fatalf("The failed write should not have written the hour.")
+	}
+	if err := cf.FlushAuditLog(); err == nil {
+		t.Fatalf("FlushAuditLog should report the hour it could not write.")
+	}
+
+	cf.PutFile("TEST", "testing", "c.txt", bytes.NewReader([]byte("c")))
+	first, ok := fs.get(path)
+	if !ok || !strings.Contains(string(first), `"object":"a.txt"`) || strings.Contains(string(first), "b.txt") {
+		t.Fatalf("The failed hour should be written by a later request: %q", first)
+	}
+	if err := cf.FlushAuditLog(); err != nil {
+		t.Fatalf("Could not flush audit log: %s", err)
+	}
+}
@@ -0,0 +1,174 @@
+package gocloudfiles
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Identity services of Rackspace, for NewCloudFilesWithEndpoint.  The US
+// one is used when no endpoint is given.
+const (
+	DefaultIdentityEndpoint = "https://identity.api.rackspacecloud.com/v2.0"
+	UKIdentityEndpoint      = "https://lon.identity.api.rackspacecloud.com/v2.0"
+)
+
+// A token is renewed before requests once it expires within this long, so
+// it never lapses in the middle of a transfer.
+const tokenRefreshMargin = 5 * time.Minute
+
+// The token shared by every copy of a CloudFiles, replaced when the
+// identity service is asked for a new one after it expired.
+type authState struct {
+	// refresh serializes re-authentication, mutex guards token and
+	// expires.
+	refresh sync.Mutex
+	mutex   sync.Mutex
+	token   string
+	// expires is zero when the identity service did not say.
+	expires time.Time
+}
+
+func newAuthState(token string) *authState {
+	return &authState{token: token}
+}
+
+func (cf *CloudFiles) SetIdentityEndpoint(endpoint string) {
+	/*
+		Authenticate against another identity service than Rackspace's US
+		one, given by its v2.0 URL.  An empty endpoint restores the
+		default.
+	*/
+	cf.apiEndpoint = strings.TrimSuffix(endpoint, "/")
+}
+
+func (cf CloudFiles) IdentityEndpoint() string {
+	/*
+		The identity service Authorize and RefreshCatalog talk to.
+	*/
+	if cf.apiEndpoint != "" {
+		return cf.apiEndpoint
+	}
+	return DefaultIdentityEndpoint
+}
+
+func (cf CloudFiles) token() string {
+	/*
+		The current auth token, the newest one when it was renewed after
+		this copy of the client was made.
+	*/
+	if cf.auth == nil {
+		return cf.authToken
+	}
+
+	cf.auth.mutex.Lock()
+	defer cf.auth.mutex.Unlock()
+	return cf.auth.token
+}
+
+func (cf *CloudFiles) setToken(token string, expires time.Time) {
+	cf.authToken = token
+	if cf.auth != nil {
+		cf.auth.mutex.Lock()
+		cf.auth.token = token
+		cf.auth.expires = expires
+		cf.auth.mutex.Unlock()
+	}
+}
+
+func (t tokenData) expiresAt() time.Time {
+	/*
+		When the token expires, zero when the response does not say.
+	*/
+	expires, err := time.Parse(time.RFC3339, t.Expires)
+	if err != nil {
+		return time.Time{}
+	}
+	return expires
+}
+
+func (cf CloudFiles) TokenExpiresAt() time.Time {
+	/*
+		When the auth token expires, as the identity service reported when
+		it was issued.  Zero when that is not known, such as for an
+		impersonation token, and before Authorize.
+	*/
+	if cf.auth == nil {
+		return time.Time{}
+	}
+
+	cf.auth.mutex.Lock()
+	defer cf.auth.mutex.Unlock()
+	return cf.auth.expires
+}
+
+func (cf CloudFiles) tokenExpiring() bool {
+	/*
+		Whether the token should be renewed before the next request.
+	*/
+	expires := cf.TokenExpiresAt()
+	return cf.canReauthorize() && !expires.IsZero() && time.Now().Add(tokenRefreshMargin).After(expires)
+}
+
+func (cf CloudFiles) canReauthorize() bool {
+	/*
+		Whether an expired token can be replaced, which needs the username
+		and api key or Keystone v3 credentials, an impersonation token cannot
+		be renewed.
+	*/
+	return cf.auth != nil && (cf.keystone != nil || cf.userName != "" && cf.apiKey != "")
+}
+
+func (cf CloudFiles) reauthorize(rejected string) error {
+	/*
+		Authorize again after the server rejected a token, or before it
+		expires.  Only the first of the requests that found the same token
+		stale asks the identity service, the others wait for it and use its
+		token.
+	*/
+	cf.auth.refresh.Lock()
+	defer cf.auth.refresh.Unlock()
+
+	if cf.token() != rejected {
+		return nil
+	}
+
+	// Only the token is replaced, the endpoints others are reading stay.
+	resp, err := cf.requestToken()
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	access, err := cf.readAccess(resp)
+	if err != nil {
+		return err
+	}
+
+	cf.setToken(access.Access.Token.Id, access.Access.Token.expiresAt())
+	return nil
+}
+
+func replayable(req *http.Request) (*http.Request, bool) {
+	/*
+		A copy of a request that can be sent again, with a fresh body.
+		Requests streaming a body that cannot be read again, such as a
+		download relayed to an upload, cannot be.
+	*/
+	retry := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return retry, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	retry.Body = body
+	return retry, true
+}
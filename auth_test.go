@@ -0,0 +1,215 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeIdentity issues tokens numbered from 1, with a catalog naming the
+// fake Swift as region TEST.
+type fakeIdentity struct {
+	mutex  sync.Mutex
+	issued int
+	// Tokens expire this long after they are issued, a day when zero.
+	lifetime time.Duration
+	server   *httptest.Server
+}
+
+func newFakeIdentity(fs *fakeSwift) *fakeIdentity {
+	fi := &fakeIdentity{}
+	fi.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fi.mutex.Lock()
+		fi.issued++
+		token := fmt.Sprintf("token%d", fi.issued)
+		lifetime := fi.lifetime
+		fi.mutex.Unlock()
+
+		if lifetime == 0 {
+			lifetime = 24 * time.Hour
+		}
+
+		var access accessWrapper
+		access.Access.Token.Id = token
+		access.Access.Token.Expires = time.Now().Add(lifetime).UTC().Format("2006-01-02T15:04:05.000Z")
+		access.Access.Catalog = []serviceCatalog{{
+			Name:      "cloudFiles",
+			Endpoints: []serviceEndpoints{{Region: "TEST", PublicURL: fs.server.URL, InternalURL: fs.server.URL}},
+		}}
+		json.NewEncoder(w).Encode(access)
+	}))
+	return fi
+}
+
+func (fi *fakeIdentity) tokens() int {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	return fi.issued
+}
+
+func TestReauthorizeOnExpiredToken(t *testing.T) {
+	// Test requests rejected with an expired token are retried with one new
+	// token shared by all of them.
+	fs := newFakeSwift()
+	defer fs.Close()
+	fi := newFakeIdentity(fs)
+	defer fi.server.Close()
+
+	cf := NewCloudFiles("user", "key")
+	cf.SetIdentityEndpoint(fi.server.URL)
+	if err := cf.Authorize(); err != nil {
+		t.Fatalf("Could not authorize: %s", err)
+	}
+	fs.token = "token1"
+	if _, err := cf.PutFile("TEST", "testing", "before", bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatalf("Could not put: %s", err)
+	}
+
+	fs.mutex.Lock()
+	fs.token = "token2"
+	fs.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := cf.PutFile("TEST", "testing", fmt.Sprintf("after%d", i), bytes.NewReader([]byte("data")))
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Could not put after the token expired: %s", err)
+		}
+	}
+	if fi.tokens() != 2 {
+		t.Fatalf("Expected one new token, %d were issued.", fi.tokens())
+	}
+	if _, ok := fs.get("testing/after9"); !ok {
+		t.Fatalf("Retried object not stored.")
+	}
+}
+
+func TestImpersonationTokenNotRenewed(t *testing.T) {
+	// Test an impersonation token that expired fails as before.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	fs.token = "other"
+
+	_, err := cf.PutFile("TEST", "testing", "file", bytes.NewReader([]byte("data")))
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("Expected a 401, got %v", err)
+	}
+}
+
+func TestRefreshExpiringToken(t *testing.T) {
+	// Test a token about to expire is renewed once before the requests
+	// that would use it.
+	fs := newFakeSwift()
+	defer fs.Close()
+	fi := newFakeIdentity(fs)
+	defer fi.server.Close()
+	fi.lifetime = time.Minute
+
+	cf := NewCloudFiles("user", "key")
+	cf.SetIdentityEndpoint(fi.server.URL)
+	if err := cf.Authorize(); err != nil {
+		t.Fatalf("Could not authorize: %s", err)
+	}
+	expires := cf.TokenExpiresAt()
+	if until := time.Until(expires); until <= 0 || until > time.Minute {
+		t.Fatalf("Unexpected expiry %s", expires)
+	}
+
+	fi.mutex.Lock()
+	fi.lifetime = time.Hour
+	fi.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := cf.PutFile("TEST", "testing", fmt.Sprintf("file%d", i), bytes.NewReader([]byte("data"))); err != nil {
+				t.Errorf("Could not put: %s", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if fi.tokens() != 2 || cf.token() != "token2" {
+		t.Fatalf("Expected one renewal, %d tokens were issued and %s is used.", fi.tokens(), cf.token())
+	}
+	if !cf.TokenExpiresAt().After(expires.Add(30 * time.Minute)) {
+		t.Fatalf("Expiry not renewed: %s", cf.TokenExpiresAt())
+	}
+}
+
+func TestIdentityEndpoint(t *testing.T) {
+	// Test a client authenticates against the identity service it is given
+	// and finds Swift in a catalog other than Rackspace's.
+	var path string
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		var access accessWrapper
+		access.Access.Token.Id = "private"
+		access.Access.Catalog = []serviceCatalog{{
+			Name:      "swift",
+			Type:      "object-store",
+			Endpoints: []serviceEndpoints{{Region: "RegionOne", PublicURL: "https://swift.example.com/v1/AUTH_abc"}},
+		}}
+		json.NewEncoder(w).Encode(access)
+	}))
+	defer identity.Close()
+
+	if endpoint := NewCloudFiles("user", "key").IdentityEndpoint(); endpoint != DefaultIdentityEndpoint {
+		t.Fatalf("Unexpected default endpoint %s", endpoint)
+	}
+
+	cf := NewCloudFilesWithEndpoint("user", "key", identity.URL+"/v2.0/")
+	if err := cf.Authorize(); err != nil {
+		t.Fatalf("Could not authorize: %s", err)
+	}
+	if path != "/v2.0/tokens" || cf.token() != "private" || cf.dcs["RegionOne"] != "https://swift.example.com/v1/AUTH_abc" {
+		t.Fatalf("Unexpected authorization of %s: %v", path, cf.dcs)
+	}
+}
+
+func TestCloudFilesFromToken(t *testing.T) {
+	// Test a token from elsewhere reaches the regions given, and is not
+	// renewed.
+	fs := newFakeSwift()
+	defer fs.Close()
+	fs.token = "delegated"
+
+	cf := NewCloudFilesFromToken("delegated", map[string]string{
+		"DFW": fs.server.URL + "/v1/AUTH_tenant/",
+		"ORD": fs.server.URL + "/v1/AUTH_tenant",
+	})
+	if cf.tenantId != "AUTH_tenant" || cf.canReauthorize() {
+		t.Fatalf("Unexpected client %s %v", cf.tenantId, cf.canReauthorize())
+	}
+
+	if err := cf.CopyFile("DFW", "testing", "missing", "ORD", "testing", "copy"); !errors.Is(err, ErrObjectMissing) {
+		t.Fatalf("Expected ErrObjectMissing, got %v", err)
+	}
+	if _, err := cf.PutFile("ORD", "testing", "file", bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatalf("Could not put with the token: %s", err)
+	}
+	if _, err := cf.PutFile("IAD", "testing", "file", bytes.NewReader([]byte("data"))); err == nil {
+		t.Fatalf("Put to a region without a storage URL.")
+	}
+}
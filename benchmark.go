@@ -0,0 +1,206 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BenchmarkOptions tune Benchmark.  Zero fields take the defaults noted.
+type BenchmarkOptions struct {
+	// Object sizes to try, 1MB and 16MB by default.
+	Sizes []int64
+	// Numbers of parallel streams to try, 1 and 5 by default.
+	Concurrency []int
+	// Objects moved per stream for each combination, 3 by default.
+	Rounds int
+	// Scratch objects are named below this prefix and deleted afterwards,
+	// ".benchmark/" by default.
+	Prefix string
+}
+
+// A BenchmarkResult reports one operation at one size and concurrency.
+type BenchmarkResult struct {
+	Op          string
+	Size        int64
+	Concurrency int
+	Requests    int
+	// Errors counts the requests that failed, which are left out of the
+	// bytes, throughput and latencies.
+	Errors   int
+	Bytes    int64
+	Duration time.Duration
+	// Aggregate bytes per second across every stream.
+	Throughput float64
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+func (cf CloudFiles) Benchmark(dc, bucket string, options *BenchmarkOptions) ([]BenchmarkResult, error) {
+	/*
+		Upload and download synthetic objects at each combination of size
+		and concurrency, reporting throughput and latency percentiles so
+		CopyOptions can be tuned with real numbers for a host and region.
+		The scratch objects are deleted again.  A failed upload stops the
+		benchmark, failed downloads are counted in the result's Errors.
+	*/
+	if options == nil {
+		options = &BenchmarkOptions{}
+	}
+
+	for _, size := range options.Sizes {
+		if size < 0 {
+			return nil, fmt.Errorf("Benchmark size %d is negative.", size)
+		}
+	}
+	for _, concurrency := range options.Concurrency {
+		if concurrency < 1 {
+			return nil, fmt.Errorf("Benchmark concurrency %d is not positive.", concurrency)
+		}
+	}
+	if options.Rounds < 0 {
+		return nil, fmt.Errorf("Benchmark rounds %d is negative.", options.Rounds)
+	}
+
+	sizes := options.Sizes
+	if len(sizes) == 0 {
+		sizes = []int64{1024 * 1024, 16 * 1024 * 1024}
+	}
+
+	concurrencies := options.Concurrency
+	if len(concurrencies) == 0 {
+		concurrencies = []int{1, 5}
+	}
+
+	rounds := options.Rounds
+	if rounds <= 0 {
+		rounds = 3
+	}
+
+	prefix := options.Prefix
+	if prefix == "" {
+		prefix = ".benchmark/"
+	}
+
+	results := make([]BenchmarkResult, 0, 2*len(sizes)*len(concurrencies))
+
+	for _, size := range sizes {
+		release := cf.reserveMemory(size)
+		data := make([]byte, size)
+		rand.Read(data)
+
+		for _, concurrency := range concurrencies {
+			names := make([]string, concurrency*rounds)
+			for i := range names {
+				names[i] = fmt.Sprintf("%s%d-%d-%d", prefix, size, concurrency, i)
+			}
+
+			upload, err := benchmarkRun("upload", size, concurrency, names, func(name string) error {
+				_, err := cf.PutFile(dc, bucket, name, bytes.NewReader(data))
+				return err
+			})
+
+			if err == nil {
+				results = append(results, upload)
+
+				download, _ := benchmarkRun("download", size, concurrency, names, func(name string) error {
+					_, _, err := cf.GetChunk(dc, bucket, name, ioutil.Discard, 0, 0)
+					return err
+				})
+				results = append(results, download)
+			}
+
+			for _, name := range names {
+				cf.deleteObject(dc, bucket, name)
+			}
+
+			if err != nil {
+				release()
+				return results, err
+			}
+		}
+
+		release()
+	}
+
+	return results, nil
+}
+
+func benchmarkRun(op string, size int64, concurrency int, names []string,
+	fn func(string) error) (BenchmarkResult, error) {
+	/*
+		Run fn for every name on concurrency streams and time it.  The
+		error is the first failure's; failures are counted, not timed.
+	*/
+	work := make(chan string)
+	latencies := make([]time.Duration, 0, len(names))
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var runError error
+	failures := 0
+
+	start := time.Now()
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				began := time.Now()
+				err := fn(name)
+				elapsed := time.Since(began)
+
+				mutex.Lock()
+				if err != nil {
+					if runError == nil {
+						runError = err
+					}
+					failures++
+				} else {
+					latencies = append(latencies, elapsed)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, name := range names {
+		work <- name
+	}
+	close(work)
+	wg.Wait()
+
+	result := BenchmarkResult{
+		Op:          op,
+		Size:        size,
+		Concurrency: concurrency,
+		Requests:    len(names),
+		Errors:      failures,
+		Bytes:       size * int64(len(latencies)),
+		Duration:    time.Since(start),
+	}
+
+	if result.Duration > 0 {
+		result.Throughput = float64(result.Bytes) / result.Duration.Seconds()
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.P50 = percentile(latencies, 0.5)
+	result.P90 = percentile(latencies, 0.9)
+	result.P99 = percentile(latencies, 0.99)
+
+	return result, runError
+}
@@ -0,0 +1,69 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBenchmark(t *testing.T) {
+	// Test every combination is measured and the scratch objects are removed.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	results, err := cf.Benchmark("TEST", "testing", &BenchmarkOptions{
+		Sizes:       []int64{10, 100},
+		Concurrency: []int{1, 2},
+		Rounds:      2,
+	})
+	if err != nil {
+		t.Fatalf("Could not benchmark: %s", err)
+	}
+
+	if len(results) != 8 {
+		t.Fatalf("Expected 8 results but got %d", len(results))
+	}
+
+	for _, result := range results {
+		if result.Requests != 2*result.Concurrency || result.Bytes != result.Size*int64(result.Requests) ||
+			result.Throughput <= 0 || result.P99 < result.P50 {
+			t.Fatalf("Unexpected result: %+v", result)
+		}
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if len(fs.objects) != 0 {
+		t.Fatalf("Scratch objects should be deleted: %d left", len(fs.objects))
+	}
+}
+
+func TestBenchmarkRefusesOptions(t *testing.T) {
+	// Test options out of range are refused rather than panicking.
+	cf := NewCloudFilesImpersonation("token")
+
+	for _, options := range []*BenchmarkOptions{
+		{Concurrency: []int{-1}},
+		{Concurrency: []int{0}},
+		{Rounds: -1},
+		{Sizes: []int64{-5}},
+	} {
+		if _, err := cf.Benchmark("TEST", "testing", options); err == nil {
+			t.Fatalf("Options %+v should be refused.", options)
+		}
+	}
+}
+
+func TestBenchmarkRunCountsErrors(t *testing.T) {
+	// Test failed requests are counted and left out of the measurements.
+	names := []string{"a", "b", "c", "d"}
+	result, err := benchmarkRun("download", 10, 2, names, func(name string) error {
+		if name == "b" {
+			return errors.New("Failed.")
+		}
+		return nil
+	})
+	if err == nil || result.Errors != 1 || result.Requests != 4 || result.Bytes != 30 {
+		t.Fatalf("Unexpected result %+v %v", result, err)
+	}
+}
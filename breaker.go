@@ -0,0 +1,219 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRegionUnavailable is matched by errors.Is when a request was refused
+// because the region's circuit breaker is open.
+var ErrRegionUnavailable = errors.New("Region is unavailable.")
+
+// RegionUnavailableError is returned without contacting the region while its
+// circuit breaker is open.
+type RegionUnavailableError struct {
+	Region  string
+	RetryAt time.Time
+}
+
+func (e *RegionUnavailableError) Error() string {
+	return fmt.Sprintf("Region %s is unavailable, retry after %s.",
+		e.Region, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *RegionUnavailableError) Is(target error) bool {
+	return target == ErrRegionUnavailable
+}
+
+// BreakerOptions tune the per-region circuit breaker.  A region trips once
+// at least MinRequests were made within Window and FailureRate of them
+// failed.  After Cooldown a single probe request is let through; if it
+// succeeds the region is closed again, otherwise it stays open.  Fields left
+// zero or out of range take their value from DefaultBreakerOptions.
+type BreakerOptions struct {
+	FailureRate float64
+	MinRequests int
+	Window      time.Duration
+	Cooldown    time.Duration
+}
+
+var DefaultBreakerOptions = BreakerOptions{
+	FailureRate: 0.5,
+	MinRequests: 10,
+	Window:      time.Minute,
+	Cooldown:    30 * time.Second,
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type regionBreaker struct {
+	state       breakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probing     bool
+}
+
+type circuitBreaker struct {
+	options BreakerOptions
+	mutex   sync.Mutex
+	regions map[string]*regionBreaker
+	now     func() time.Time
+}
+
+func (options BreakerOptions) withDefaults() BreakerOptions {
+	if options.FailureRate <= 0 || options.FailureRate > 1 {
+		options.FailureRate = DefaultBreakerOptions.FailureRate
+	}
+	if options.MinRequests < 1 {
+		options.MinRequests = DefaultBreakerOptions.MinRequests
+	}
+	if options.Window <= 0 {
+		options.Window = DefaultBreakerOptions.Window
+	}
+	if options.Cooldown <= 0 {
+		options.Cooldown = DefaultBreakerOptions.Cooldown
+	}
+	return options
+}
+
+func newCircuitBreaker(options BreakerOptions) *circuitBreaker {
+	options = options.withDefaults()
+	return &circuitBreaker{
+		options: options,
+		regions: make(map[string]*regionBreaker),
+		now:     time.Now,
+	}
+}
+
+func (cb *circuitBreaker) region(dc string) *regionBreaker {
+	rb, ok := cb.regions[dc]
+	if !ok {
+		rb = &regionBreaker{windowStart: cb.now()}
+		cb.regions[dc] = rb
+	}
+	return rb
+}
+
+func (cb *circuitBreaker) allow(dc string) (probe bool, err error) {
+	/*
+		Decide whether a request to the region may be sent.  probe is true
+		for the single request let through while half open; only its
+		outcome may close or reopen the breaker.
+	*/
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	rb := cb.region(dc)
+	now := cb.now()
+
+	if rb.state == breakerOpen && now.Sub(rb.openedAt) >= cb.options.Cooldown {
+		rb.state = breakerHalfOpen
+		rb.probing = false
+	}
+
+	switch rb.state {
+	case breakerOpen:
+		return false, &RegionUnavailableError{Region: dc, RetryAt: rb.openedAt.Add(cb.options.Cooldown)}
+	case breakerHalfOpen:
+		if rb.probing {
+			return false, &RegionUnavailableError{Region: dc, RetryAt: now.Add(cb.options.Cooldown)}
+		}
+		rb.probing = true
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (cb *circuitBreaker) record(dc string, probe, success bool) {
+	/*
+		Record the outcome of a request that allow() let through.  Late
+		responses to requests admitted before the region tripped are
+		ignored rather than mistaken for the probe.
+	*/
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	rb := cb.region(dc)
+	now := cb.now()
+
+	if rb.state != breakerClosed && !probe {
+		return
+	}
+
+	if probe {
+		if rb.state != breakerHalfOpen {
+			return
+		}
+		rb.probing = false
+		if success {
+			rb.state = breakerClosed
+			rb.windowStart = now
+			rb.requests = 0
+			rb.failures = 0
+		} else {
+			rb.state = breakerOpen
+			rb.openedAt = now
+		}
+		return
+	}
+
+	if now.Sub(rb.windowStart) > cb.options.Window {
+		rb.windowStart = now
+		rb.requests = 0
+		rb.failures = 0
+	}
+
+	rb.requests++
+	if !success {
+		rb.failures++
+	}
+
+	if rb.requests >= cb.options.MinRequests &&
+		float64(rb.failures) >= cb.options.FailureRate*float64(rb.requests) {
+		rb.state = breakerOpen
+		rb.openedAt = now
+	}
+}
+
+func (cb *circuitBreaker) available(dc string) bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	rb, ok := cb.regions[dc]
+	if !ok {
+		return true
+	}
+
+	return rb.state != breakerOpen || cb.now().Sub(rb.openedAt) >= cb.options.Cooldown
+}
+
+func (cf *CloudFiles) SetCircuitBreaker(options BreakerOptions) {
+	/*
+		Enable a per-region circuit breaker.  While a region is failing,
+		requests to it fail fast with ErrRegionUnavailable instead of
+		waiting on a broken DC.
+	*/
+	cf.breaker = newCircuitBreaker(options)
+}
+
+func (cf CloudFiles) RegionAvailable(dc string) bool {
+	/*
+		Report whether requests to a region would currently be attempted,
+		so multi-region callers can route around an open breaker.
+	*/
+	if cf.breaker == nil {
+		return true
+	}
+	return cf.breaker.available(dc)
+}
@@ -0,0 +1,107 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTripsAndRecovers(t *testing.T) {
+	// Test a failing region fails fast and is probed after the cooldown.
+	now := time.Now()
+	cb := newCircuitBreaker(BreakerOptions{
+		FailureRate: 0.5,
+		MinRequests: 4,
+		Window:      time.Minute,
+		Cooldown:    10 * time.Second,
+	})
+	cb.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		if _, err := cb.allow("IAD"); err != nil {
+			t.Fatalf("Breaker should be closed: %s", err)
+		}
+		cb.record("IAD", false, i%2 == 0)
+	}
+
+	_, err := cb.allow("IAD")
+	if !errors.Is(err, ErrRegionUnavailable) {
+		t.Fatalf("Expected ErrRegionUnavailable but got: %v", err)
+	}
+
+	if _, err := cb.allow("DFW"); err != nil {
+		t.Fatalf("Other regions should not be affected.")
+	}
+
+	now = now.Add(11 * time.Second)
+
+	probe, err := cb.allow("IAD")
+	if err != nil || !probe {
+		t.Fatalf("A probe should be allowed after the cooldown: %v", err)
+	}
+
+	if _, err := cb.allow("IAD"); err == nil {
+		t.Fatalf("Only one probe should be allowed while half open.")
+	}
+
+	cb.record("IAD", true, true)
+
+	if _, err := cb.allow("IAD"); err != nil {
+		t.Fatalf("Breaker should close after a successful probe: %s", err)
+	}
+}
+
+func TestCircuitBreakerZeroOptions(t *testing.T) {
+	// Test zero options fall back to the defaults instead of tripping on success.
+	cb := newCircuitBreaker(BreakerOptions{})
+
+	if cb.options != DefaultBreakerOptions {
+		t.Fatalf("Expected default options but got %+v", cb.options)
+	}
+
+	for i := 0; i < 20; i++ {
+		cb.record("IAD", false, true)
+	}
+
+	if _, err := cb.allow("IAD"); err != nil {
+		t.Fatalf("Successful requests should never trip the breaker: %s", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresLateResponses(t *testing.T) {
+	// Test a response admitted before the trip is not taken as the probe.
+	now := time.Now()
+	cb := newCircuitBreaker(BreakerOptions{
+		FailureRate: 0.5,
+		MinRequests: 2,
+		Window:      time.Minute,
+		Cooldown:    10 * time.Second,
+	})
+	cb.now = func() time.Time { return now }
+
+	// Three requests go out, two fail and trip the breaker.
+	for i := 0; i < 3; i++ {
+		cb.allow("IAD")
+	}
+	cb.record("IAD", false, false)
+	cb.record("IAD", false, false)
+
+	now = now.Add(11 * time.Second)
+	probe, err := cb.allow("IAD")
+	if err != nil || !probe {
+		t.Fatalf("A probe should be allowed after the cooldown: %v", err)
+	}
+
+	// The third request finally succeeds, which must not close the breaker.
+	cb.record("IAD", false, true)
+
+	if _, err := cb.allow("IAD"); err == nil {
+		t.Fatalf("A late response should not stand in for the probe.")
+	}
+
+	cb.record("IAD", true, false)
+
+	if cb.available("IAD") {
+		t.Fatalf("A failed probe should reopen the breaker.")
+	}
+}
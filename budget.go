@@ -0,0 +1,92 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Failures are only compared with MaxFailureRate once this many objects
+// were attempted, when FailureBudget.MinObjects is zero.
+const defaultBudgetMinObjects = 100
+
+// A FailureBudget aborts a bulk operation that keeps failing, so a systemic
+// outage ends it quickly instead of failing every remaining object in turn.
+// Zero fields are not checked.
+type FailureBudget struct {
+	// MaxFailureRate aborts once more than this fraction of the objects
+	// done so far failed, e.g. 0.05.
+	MaxFailureRate float64
+
+	// MinObjects is how many objects are done before MaxFailureRate
+	// applies, 100 when zero, so one early failure does not abort.
+	MinObjects int
+
+	// MaxConsecutive aborts after this many failures in a row.
+	MaxConsecutive int
+}
+
+// ErrBudgetExceeded is matched by errors.Is when a bulk operation was
+// aborted by its FailureBudget.
+var ErrBudgetExceeded = errors.New("Failure budget exceeded.")
+
+// BudgetExceededError is returned by a bulk operation aborted by its
+// FailureBudget.  It unwraps to the *MultiError of the failures seen.
+type BudgetExceededError struct {
+	Reason   string
+	Failures *MultiError
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("Aborted after %s: %s", e.Reason, e.Failures)
+}
+
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+func (e *BudgetExceededError) Unwrap() error {
+	return e.Failures
+}
+
+func (cf *CloudFiles) SetFailureBudget(budget FailureBudget) {
+	/*
+		Abort CopyFiles, DownloadObjects, DeletePrefix, ReplicateContainer,
+		Warm and the expiration updates once their failures exceed the
+		budget.  Objects already handed to a worker finish, the rest are
+		not attempted and the report is marked Aborted.  A zero budget turns the check off.
+	*/
+	if budget == (FailureBudget{}) {
+		cf.failureBudget = nil
+		return
+	}
+	cf.failureBudget = &budget
+}
+
+func (cf CloudFiles) newReport() *Report {
+	return &Report{budget: cf.failureBudget}
+}
+
+func (b *FailureBudget) exceeded(r *Report) string {
+	/*
+		Why the report is over budget, empty while it is not.
+	*/
+	if b == nil {
+		return ""
+	}
+
+	if b.MaxConsecutive > 0 && r.consecutive >= b.MaxConsecutive {
+		return fmt.Sprintf("%d consecutive failures", r.consecutive)
+	}
+
+	minObjects := b.MinObjects
+	if minObjects <= 0 {
+		minObjects = defaultBudgetMinObjects
+	}
+
+	total := r.Total()
+	if b.MaxFailureRate > 0 && total >= minObjects && float64(r.Failed) > b.MaxFailureRate*float64(total) {
+		return fmt.Sprintf("%d of %d objects failed", r.Failed, total)
+	}
+
+	return ""
+}
@@ -0,0 +1,56 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestFailureBudgetConsecutive(t *testing.T) {
+	// Test a bulk copy stops after too many failures in a row.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.SetFailureBudget(FailureBudget{MaxConsecutive: 3})
+
+	names := make([]string, 50)
+	for i := range names {
+		names[i] = fmt.Sprintf("missing-%d", i)
+	}
+
+	report, err := cf.CopyFiles("TEST", "testing", "TEST", "backup", names, &CopyFilesOptions{Concurrency: 1})
+	// The object handed to the worker meanwhile still runs.
+	if !errors.Is(err, ErrBudgetExceeded) || !report.Aborted || report.Failed < 3 || report.Failed > 4 {
+		t.Fatalf("Expected an abort after 3 failures: %+v %v", report, err)
+	}
+
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Failures) != report.Failed {
+		t.Fatalf("Abort should keep the failures: %v", err)
+	}
+}
+
+func TestFailureBudgetRate(t *testing.T) {
+	// Test the failure rate only applies after the minimum objects.
+	budget := &FailureBudget{MaxFailureRate: 0.05, MinObjects: 20}
+	report := &Report{budget: budget}
+
+	report.Add(Result{Status: ResultFailure})
+	for i := 0; i < 18; i++ {
+		report.Add(Result{Status: ResultSuccess})
+	}
+	if report.Aborted {
+		t.Fatalf("Aborted before the minimum objects.")
+	}
+
+	report.Add(Result{Status: ResultFailure})
+	if !report.Aborted {
+		t.Fatalf("2 of 20 failures should abort.")
+	}
+
+	cf := &CloudFiles{}
+	cf.SetFailureBudget(FailureBudget{})
+	if cf.newReport().budget != nil {
+		t.Fatalf("A zero budget should turn the check off.")
+	}
+}
@@ -0,0 +1,185 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"time"
+)
+
+// The suffix of the companion object holding an object's ChecksumRecord.
+const ChecksumSuffix = ".checksums"
+
+// A SegmentChecksum is the MD5 of one segment of an object, in byte order.
+type SegmentChecksum struct {
+	Path string `json:"path"`
+	ETag string `json:"etag"`
+	Size int64  `json:"size_bytes"`
+	// Hashes holds the segment's HashConfig sums by hash name, when the
+	// copy had any.
+	Hashes map[string]string `json:"hashes,omitempty"`
+}
+
+// A ChecksumRecord is stored as a small JSON companion object next to the
+// object it describes so copies can later be verified or resumed.
+type ChecksumRecord struct {
+	Object   string            `json:"object"`
+	Size     int64             `json:"size_bytes"`
+	SHA256   string            `json:"sha256"`
+	Segments []SegmentChecksum `json:"segments"`
+	Created  time.Time         `json:"created"`
+}
+
+func checksumObjectName(filename string) string {
+	return filename + ChecksumSuffix
+}
+
+func (cf CloudFiles) putChecksums(dc, bucket string, options *WriteOptions, record *ChecksumRecord) error {
+	/*
+		Write a checksum record as the companion object of record.Object.
+	*/
+	payLoad, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	// The record is JSON whatever the object it describes is.
+	if options != nil {
+		copied := *options
+		copied.ContentType = "application/json"
+		options = &copied
+	}
+
+	_, err = cf.putFile(dc, bucket, checksumObjectName(record.Object), bytes.NewReader(payLoad), options)
+	if err != nil {
+		return fmt.Errorf("Could not write checksums for %s: %w", record.Object, err)
+	}
+
+	return nil
+}
+
+func (cf CloudFiles) GetChecksums(dc, bucket, filename string) (*ChecksumRecord, error) {
+	/*
+		Fetch the checksum record written alongside an object.
+	*/
+	var buffer bytes.Buffer
+
+	_, _, err := cf.GetChunk(dc, bucket, checksumObjectName(filename), &buffer, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &ChecksumRecord{}
+	err = json.Unmarshal(buffer.Bytes(), record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (cf CloudFiles) PutFileWithChecksums(dc, bucket, filename string, data io.Reader) (string, error) {
+	/*
+		Like PutFile, but also writes a checksum record holding the MD5 and
+		SHA-256 of the uploaded data.
+		Returns a tuple of etag, error
+	*/
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	counter := &countingWriter{}
+
+	etag, err := cf.PutFile(dc, bucket, filename,
+		io.TeeReader(data, io.MultiWriter(md5Hash, sha256Hash, counter)))
+	if err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(md5Hash.Sum(nil))
+	if !sameETag(sum, etag) {
+		return "", fmt.Errorf("Upload etag does not match local md5: %s %s!", sum, etag)
+	}
+
+	record := &ChecksumRecord{
+		Object: filename,
+		Size:   counter.count,
+		SHA256: hex.EncodeToString(sha256Hash.Sum(nil)),
+		Segments: []SegmentChecksum{
+			{Path: fmt.Sprintf("%s/%s", bucket, filename), ETag: etag, Size: counter.count},
+		},
+		Created: time.Now().UTC(),
+	}
+
+	return etag, cf.putChecksums(dc, bucket, nil, record)
+}
+
+type countingWriter struct {
+	count int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.count += int64(len(p))
+	return len(p), nil
+}
+
+// orderedHasher hashes chunks that finish out of order in byte order.
+// Each chunk waits until every chunk before it has been written.
+type orderedHasher struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	next    int64
+	aborted bool
+	hash    hash.Hash
+}
+
+func newOrderedHasher(h hash.Hash) *orderedHasher {
+	oh := &orderedHasher{hash: h}
+	oh.cond = sync.NewCond(&oh.mutex)
+	return oh
+}
+
+func (oh *orderedHasher) write(chunkIndex int64, data io.Reader) error {
+	oh.mutex.Lock()
+	defer oh.mutex.Unlock()
+
+	for oh.next != chunkIndex && !oh.aborted {
+		oh.cond.Wait()
+	}
+
+	if oh.aborted {
+		return fmt.Errorf("Checksum of chunk %d aborted.", chunkIndex)
+	}
+
+	_, err := io.Copy(oh.hash, data)
+	if err != nil {
+		oh.aborted = true
+	} else {
+		oh.next++
+	}
+
+	oh.cond.Broadcast()
+	return err
+}
+
+func (oh *orderedHasher) abort() {
+	/*
+		Release every chunk waiting on its turn, used when the copy fails.
+	*/
+	oh.mutex.Lock()
+	defer oh.mutex.Unlock()
+
+	oh.aborted = true
+	oh.cond.Broadcast()
+}
+
+func (oh *orderedHasher) sum() string {
+	oh.mutex.Lock()
+	defer oh.mutex.Unlock()
+
+	return hex.EncodeToString(oh.hash.Sum(nil))
+}
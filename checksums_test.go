@@ -0,0 +1,58 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOrderedHasher(t *testing.T) {
+	// Test chunks written out of order are hashed in byte order.
+	chunks := []string{"alpha", "beta", "gamma", "delta"}
+	oh := newOrderedHasher(sha256.New())
+
+	var wg sync.WaitGroup
+	for i := len(chunks) - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			oh.write(int64(index), strings.NewReader(chunks[index]))
+		}(i)
+	}
+	wg.Wait()
+
+	sum := sha256.Sum256([]byte(strings.Join(chunks, "")))
+	if oh.sum() != hex.EncodeToString(sum[:]) {
+		t.Fatalf("Ordered hash does not match.")
+	}
+}
+
+func TestPutFileWithChecksums(t *testing.T) {
+	// Test a plain PUT records a checksum companion object.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	data := []byte("some data worth checking")
+	etag, err := cf.PutFileWithChecksums("TEST", "testing", "file.bin", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Could not put file: %s", err)
+	}
+
+	record, err := cf.GetChecksums("TEST", "testing", "file.bin")
+	if err != nil {
+		t.Fatalf("Could not get checksums: %s", err)
+	}
+
+	sum := sha256.Sum256(data)
+	if record.SHA256 != hex.EncodeToString(sum[:]) || record.Size != int64(len(data)) {
+		t.Fatalf("Unexpected checksum record: %+v", record)
+	}
+
+	if len(record.Segments) != 1 || record.Segments[0].ETag != etag {
+		t.Fatalf("Unexpected segments: %+v", record.Segments)
+	}
+}
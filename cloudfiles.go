@@ -9,9 +9,13 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	neturl "net/url"
 	"os"
+	"path"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type cloudFilesAuth struct {
@@ -32,6 +36,7 @@ type serviceEndpoints struct {
 
 type serviceCatalog struct {
 	Name      string             `json:"name"`
+	Type      string             `json:"type"`
 	Endpoints []serviceEndpoints `json:"endpoints"`
 }
 
@@ -41,8 +46,9 @@ type tenantData struct {
 }
 
 type tokenData struct {
-	Id     string     `json:"id"`
-	Tenant tenantData `json:"tenant"`
+	Id      string     `json:"id"`
+	Expires string     `json:"expires"`
+	Tenant  tenantData `json:"tenant"`
 }
 
 type serviceAccess struct {
@@ -58,32 +64,45 @@ type manifestItem struct {
 	Path string `json:"path"`
 	ETag string `json:"etag"`
 	Size int64  `json:"size_bytes"`
+	// Hashes are the HashConfig sums of a copied segment, they are not
+	// part of the manifest.
+	Hashes map[string]string `json:"-"`
 }
 
-// Create interface for sorting
+// The segments of a manifest, in the order their bytes appear in the
+// object.
 type manifestList []manifestItem
 
-func (slice manifestList) Len() int {
-	return len(slice)
-}
-
-func (slice manifestList) Less(i, j int) bool {
-	return slice[i].Path < slice[j].Path
-}
-
-func (slice manifestList) Swap(i, j int) {
-	slice[i], slice[j] = slice[j], slice[i]
-}
-
 type CloudFiles struct {
-	userName    string
-	apiEndpoint string
-	tenantId    string
-	authToken   string
-	apiKey      string
-	dcs         map[string]string
-	dcsInternal map[string]string
-	localDC     string
+	userName        string
+	apiEndpoint     string
+	tenantId        string
+	authToken       string
+	apiKey          string
+	dcs             map[string]string
+	dcsInternal     map[string]string
+	cdns            map[string]string
+	localDC         string
+	breaker         *circuitBreaker
+	retries         *RetryOptions
+	throttle        *throttle
+	containerLimits *containerLimiter
+	trash           *trashConfig
+	accounting      func(Usage)
+	audit           *auditLog
+	dryRun          *Plan
+	transport       http.RoundTripper
+	client          *http.Client
+	memory          *memoryBudget
+	policy          *Policy
+	failureBudget   *FailureBudget
+	clocks          *serverClocks
+	limits          *limitsCache
+	auth            *authState
+	keystone        *KeystoneV3Auth
+	tempAuth        *tempAuthConfig
+	// account replaces the account of the storage URLs, see ForAccount.
+	account string
 }
 
 func NewCloudFilesImpersonation(token string) *CloudFiles {
@@ -94,6 +113,37 @@ func NewCloudFilesImpersonation(token string) *CloudFiles {
 		authToken:   token,
 		dcs:         make(map[string]string),
 		dcsInternal: make(map[string]string),
+		cdns:        make(map[string]string),
+		clocks:      newServerClocks(),
+		limits:      newLimitsCache(),
+		auth:        newAuthState(token),
+	}
+
+	return cf
+}
+
+func NewCloudFilesFromToken(token string, storageURLs map[string]string) *CloudFiles {
+	/*
+	   Create a new cloud files object from a token obtained elsewhere,
+	   such as a delegated Keystone token, and the storage URL of each
+	   region it is used in.  Nothing is asked of the identity service, and
+	   the token is not renewed when it expires.
+	*/
+	cf := NewCloudFilesImpersonation(token)
+
+	regions := make([]string, 0, len(storageURLs))
+	for region := range storageURLs {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	for _, region := range regions {
+		url := strings.TrimSuffix(storageURLs[region], "/")
+		cf.dcs[region] = url
+		cf.dcsInternal[region] = url
+		if cf.tenantId == "" {
+			cf.tenantId = path.Base(url)
+		}
 	}
 
 	return cf
@@ -108,68 +158,243 @@ func NewCloudFiles(userName, apiKey string) *CloudFiles {
 		apiKey:      apiKey,
 		dcs:         make(map[string]string),
 		dcsInternal: make(map[string]string),
+		cdns:        make(map[string]string),
+		clocks:      newServerClocks(),
+		limits:      newLimitsCache(),
+		auth:        newAuthState(""),
 	}
 
 	return cf
 }
 
+func NewCloudFilesWithEndpoint(userName, apiKey, endpoint string) *CloudFiles {
+	/*
+	   Create a new cloud files object authenticating against the identity
+	   service at endpoint, such as UKIdentityEndpoint or the Keystone
+	   v2.0 URL of a private deployment.
+	*/
+	cf := NewCloudFiles(userName, apiKey)
+	cf.SetIdentityEndpoint(endpoint)
+	return cf
+}
+
 func (cf *CloudFiles) loadCatalog(resp *http.Response) error {
 	/*
 		Read the service catalog and store endpoints on object.
 	*/
-	if resp.StatusCode != 200 {
-
-		responseBody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("Could not authenticate: %d", resp.StatusCode)
-		} else {
-			return fmt.Errorf("Could not authenticate: %s (%d)", responseBody, resp.StatusCode)
-		}
-	}
-
-	var respData accessWrapper
-	err := json.NewDecoder(resp.Body).Decode(&respData)
-
+	respData, err := cf.readAccess(resp)
 	if err != nil {
 		return err
 	}
 
-	cf.authToken = respData.Access.Token.Id
+	cf.setToken(respData.Access.Token.Id, respData.Access.Token.expiresAt())
 	cf.tenantId = respData.Access.Token.Tenant.Id
 
 	// Load all endpoints into memory.
 	catalog := respData.Access.Catalog
 	for i := range catalog {
-		if catalog[i].Name == "cloudFiles" {
-			endpoints := catalog[i].Endpoints
+		endpoints := catalog[i].Endpoints
+		// Deployments other than Rackspace name Swift differently, but
+		// give it the object-store type.
+		switch {
+		case catalog[i].Name == "cloudFiles" || catalog[i].Type == "object-store":
 			for inner := range endpoints {
 				cf.dcs[endpoints[inner].Region] = endpoints[inner].PublicURL
 				cf.dcsInternal[endpoints[inner].Region] = endpoints[inner].InternalURL
 			}
-			break
+		case catalog[i].Name == "cloudFilesCDN":
+			for inner := range endpoints {
+				cf.cdns[endpoints[inner].Region] = endpoints[inner].PublicURL
+			}
 		}
 	}
 
 	return nil
 }
 
+func (cf CloudFiles) readAccess(resp *http.Response) (*accessWrapper, error) {
+	/*
+		Parse the token and service catalog of an identity response.
+	*/
+	if !succeeded(resp) {
+		return nil, newCloudFilesError(resp, "Could not authenticate")
+	}
+
+	// Keystone v3 gives the token in a header, and its catalog in another
+	// form.  Swift's v1 auth only has headers.
+	if resp.Header.Get("X-Subject-Token") != "" {
+		return readKeystoneAccess(resp)
+	}
+	if cf.tempAuth != nil {
+		return cf.tempAuth.readAccess(resp)
+	}
+
+	var respData accessWrapper
+	if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+		return nil, err
+	}
+	return &respData, nil
+}
+
 func (cf *CloudFiles) SetLocalDC(dc string) {
+	/*
+		Reach the region the client runs in by its internal URL, over
+		ServiceNet, which is faster and not billed for bandwidth.  Other
+		regions are still reached by their public URLs, ServiceNet does not
+		cross regions.
+	*/
 	cf.localDC = dc
 }
 
+func (cf CloudFiles) LocalDC() string {
+	return cf.localDC
+}
+
+func (cf CloudFiles) endpoint(dc string) (string, error) {
+	/*
+		Find the storage endpoint for a region, preferring the internal URL
+		for the local DC when the catalog has one.
+	*/
+	endpoint := cf.dcs[dc]
+	if dc == cf.localDC && cf.dcsInternal[dc] != "" {
+		endpoint = cf.dcsInternal[dc]
+	}
+
+	if endpoint == "" {
+		return "", fmt.Errorf("Could not find region %s in service catalog.", dc)
+	}
+
+	return cf.onAccount(endpoint), nil
+}
+
+func (cf CloudFiles) do(dc string, req *http.Request) (*http.Response, error) {
+	/*
+		Send a storage request to the given region.  All object and container
+		requests go through here so per-region policies apply uniformly.
+		A request rejected because the token expired is sent once more
+		with a new one, when the client can authorize again and the body
+		can be read again.  A token about to expire is renewed first.
+		Overloaded answers are retried as SetRetries allows.
+	*/
+	if cf.tokenExpiring() {
+		// A failed refresh leaves the old token, which may yet be accepted.
+		if err := cf.reauthorize(cf.token()); err == nil && req.Header.Get("X-Auth-Token") != "" {
+			req.Header.Set("X-Auth-Token", cf.token())
+		}
+	}
+
+	resp, err := cf.sendRetrying(dc, req)
+	if err != nil || resp.StatusCode != 401 || !cf.canReauthorize() {
+		return resp, err
+	}
+
+	retry, ok := replayable(req)
+	if reauthErr := cf.reauthorize(req.Header.Get("X-Auth-Token")); reauthErr != nil || !ok {
+		// Later requests use the new token even when this one cannot be
+		// sent again.
+		return resp, nil
+	}
+
+	resp.Body.Close()
+	retry.Header.Set("X-Auth-Token", cf.token())
+	return cf.sendRetrying(dc, retry)
+}
+
+func (cf CloudFiles) send(dc string, req *http.Request) (*http.Response, error) {
+	/*
+		Send a storage request once.
+	*/
+	if err := cf.checkPolicy(dc, req); err != nil {
+		return nil, err
+	}
+
+	if cf.dryRun != nil {
+		if !cf.dryRun.sends(req) {
+			return cf.dryRun.respond(dc, req)
+		}
+		cf.dryRun.record(dc, req)
+	}
+
+	probe := false
+	if cf.breaker != nil {
+		var err error
+		if probe, err = cf.breaker.allow(dc); err != nil {
+			return nil, err
+		}
+	}
+
+	var sent *countingBody
+	if cf.accounting != nil && req.Body != nil {
+		sent = &countingBody{body: req.Body}
+		req.Body = sent
+	}
+
+	if cf.throttle != nil {
+		req.Body = wrapBody(req.Body, cf.throttle.limiter(dc).upload)
+	}
+
+	client := cf.httpClient()
+	started := time.Now()
+	resp, err := client.Do(req)
+
+	if err == nil {
+		cf.clocks.observe(dc, started, time.Now(), resp.Header.Get("Date"))
+	}
+
+	if cf.breaker != nil {
+		cf.breaker.record(dc, probe, err == nil && resp.StatusCode < 500)
+	}
+
+	if err == nil && cf.throttle != nil {
+		resp.Body = wrapBody(resp.Body, cf.throttle.limiter(dc).download)
+	}
+
+	if err == nil {
+		cf.auditRequest(dc, req, resp)
+	}
+
+	if err == nil && cf.accounting != nil {
+		resp.Body = &reportingBody{
+			countingBody: countingBody{body: resp.Body},
+			usage:        cf.usageFor(dc, req),
+			sent:         sent,
+			report:       cf.accounting,
+		}
+	}
+
+	return resp, err
+}
+
 func (cf *CloudFiles) RefreshCatalog() error {
 	/*
 		Request an updated catalog using the token.
 	*/
 
-	if cf.authToken == "" {
+	token := cf.token()
+	if token == "" {
 		return fmt.Errorf("Cannot refresh catalog: auth token is missing.")
 	}
 
-	client := &http.Client{}
+	// Swift's v1 auth has no catalog but the one it gives with a token.
+	if cf.tempAuth != nil {
+		return cf.Authorize()
+	}
 
-	url := "https://identity.api.rackspacecloud.com/v2.0/tokens/%s/endpoints"
-	url = fmt.Sprintf(url, cf.authToken)
+	if cf.keystone != nil {
+		resp, err := cf.requestKeystoneCatalog(token)
+		if err != nil {
+			return err
+		}
+
+		defer resp.Body.Close()
+
+		return cf.loadCatalog(resp)
+	}
+
+	client := cf.httpClient()
+
+	url := cf.IdentityEndpoint() + "/tokens/%s/endpoints"
+	url = fmt.Sprintf(url, token)
 
 	req, err := http.NewRequest("GET", url, nil)
 
@@ -192,9 +417,31 @@ func (cf *CloudFiles) Authorize() error {
 	/*
 	   Authorize against the identity service.
 	*/
-	client := &http.Client{}
+	resp, err := cf.requestToken()
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	return cf.loadCatalog(resp)
+}
+
+func (cf CloudFiles) requestToken() (*http.Response, error) {
+	/*
+		Ask the identity service for a token with the username and api key,
+		or the Keystone v3 credentials.
+	*/
+	if cf.keystone != nil {
+		return cf.requestKeystoneToken()
+	}
+	if cf.tempAuth != nil {
+		return cf.requestTempAuthToken()
+	}
 
-	url := "https://identity.api.rackspacecloud.com/v2.0/tokens"
+	client := cf.httpClient()
+
+	url := cf.IdentityEndpoint() + "/tokens"
 
 	authData := make(map[string]interface{})
 	authData["auth"] = raxKeyCreds{
@@ -206,25 +453,17 @@ func (cf *CloudFiles) Authorize() error {
 
 	payLoad, err := json.Marshal(authData)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req, err := http.NewRequest("POST", url, bytes.NewReader(payLoad))
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	return cf.loadCatalog(resp)
+	return client.Do(req)
 }
 
 func (cf CloudFiles) GetFileSize(dc, bucket, filename string) (int64, string, error) {
@@ -232,36 +471,40 @@ func (cf CloudFiles) GetFileSize(dc, bucket, filename string) (int64, string, er
 		Get the size of a remote cloudfiles file.
 		Returns a 3-tuple of length, etag, error
 	*/
-	endpoint := cf.dcs[dc]
-
-	if dc == cf.localDC {
-		endpoint = cf.dcsInternal[dc]
+	url, err := cf.objectURL(dc, bucket, filename)
+	if err != nil {
+		return 0, "", err
 	}
 
-	if endpoint == "" {
-		return 0, "", fmt.Errorf("Could not find region %s in service catalog.", dc)
+	req, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return 0, "", err
 	}
 
-	client := &http.Client{}
+	//req.Header.Add("Range", "0")
+	req.Header.Add("X-Auth-Token", cf.token())
+	resp, err := cf.do(dc, req)
 
-	url := fmt.Sprintf("%s/%s/%s", endpoint, bucket, filename)
+	if err != nil {
+		return 0, "", err
+	}
 
-	req, err := http.NewRequest("HEAD", url, nil)
-	//req.Header.Add("Range", "0")
-	req.Header.Add("X-Auth-Token", cf.authToken)
-	resp, err := client.Do(req)
+	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return 0, "", fmt.Errorf("Could not fetch cloud file, status: %d", resp.StatusCode)
+	if resp.StatusCode == 404 {
+		return 0, "", &ObjectMissingError{Region: dc, Bucket: bucket, Name: filename}
 	}
 
-	contentLength, err := strconv.ParseInt(resp.Header["Content-Length"][0], 10, 64)
+	if !succeeded(resp) {
+		return 0, "", newCloudFilesError(resp, "Could not fetch cloud file")
+	}
 
+	size, err := contentLength(resp.Header)
 	if err != nil {
-		return 0, "", fmt.Errorf("Could not determine content length.")
+		return 0, "", err
 	}
 
-	return contentLength, resp.Header["Etag"][0], nil
+	return size, resp.Header.Get("Etag"), nil
 }
 
 func (cf CloudFiles) GetChunk(dc, bucket, remoteFilename string, out io.Writer,
@@ -271,37 +514,44 @@ func (cf CloudFiles) GetChunk(dc, bucket, remoteFilename string, out io.Writer,
 	   out - must be closed by caller.
 	*/
 
-	endpoint := cf.dcs[dc]
-	if dc == cf.localDC {
-		endpoint = cf.dcsInternal[dc]
-	}
-
-	if endpoint == "" {
-		return 0, "", fmt.Errorf("Could not find region %s in service catalog.", dc)
+	url, err := cf.objectURL(dc, bucket, remoteFilename)
+	if err != nil {
+		return 0, "", err
 	}
 
-	client := &http.Client{}
-
-	url := fmt.Sprintf("%s/%s/%s", endpoint, bucket, remoteFilename)
-
 	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, "", err
+	}
 
 	// The range includes the offset byte, so remove one from the end
 	if length > 0 {
 		req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
 	}
-	req.Header.Add("X-Auth-Token", cf.authToken)
+	req.Header.Add("X-Auth-Token", cf.token())
+
+	// Never let the transport negotiate compression of object data, the
+	// etag describes the bytes as stored.
+	req.Header.Add("Accept-Encoding", "identity")
 
 	// Get response...
-	resp, err := client.Do(req)
+	resp, err := cf.do(dc, req)
 
-	// Support response and partial response
-	if resp.StatusCode != 200 && resp.StatusCode != 206 {
-		return 0, "", fmt.Errorf("Could not fetch cloud file, status: %d", resp.StatusCode)
+	if err != nil {
+		return 0, "", err
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode == 404 {
+		return 0, "", &ObjectMissingError{Region: dc, Bucket: bucket, Name: remoteFilename}
+	}
+
+	// Support response and partial response
+	if !succeeded(resp) {
+		return 0, "", newCloudFilesError(resp, "Could not fetch cloud file")
+	}
+
 	// ETags...so the etag returned is always the etag of the entire file, so
 	// we must generate a new one if the chunk represented only a portion of
 	// the file...
@@ -318,7 +568,7 @@ func (cf CloudFiles) GetChunk(dc, bucket, remoteFilename string, out io.Writer,
 
 	} else {
 		size, err = io.Copy(out, resp.Body)
-		etag = resp.Header["Etag"][0]
+		etag = resp.Header.Get("Etag")
 	}
 
 	if err != nil {
@@ -333,227 +583,567 @@ func (cf CloudFiles) PutFile(dc, bucket, filename string, data io.Reader) (strin
 	   Write the data in io.Reader to Cloudfiles.
 	   Returns a tuple of etag, error
 	*/
-	endpoint := cf.dcs[dc]
-	if dc == cf.localDC {
-		endpoint = cf.dcsInternal[dc]
-	}
+	return cf.PutFileWithOptions(dc, bucket, filename, data, nil)
+}
 
-	if endpoint == "" {
-		return "", fmt.Errorf("Could not find region %s in service catalog.", dc)
+func (cf CloudFiles) PutFileWithOptions(dc, bucket, filename string, data io.Reader,
+	options *WriteOptions) (string, error) {
+	/*
+	   Like PutFile, with the given write options.  A nil options behaves
+	   like PutFile.
+	   Returns a tuple of etag, error
+	*/
+	etag, kept, err := cf.keepUpload(dc, bucket, filename, data, options)
+	if err != nil || kept {
+		return etag, err
 	}
 
-	client := &http.Client{}
+	return cf.putFile(dc, bucket, filename, data, options)
+}
 
-	url := fmt.Sprintf("%s/%s/%s", endpoint, bucket, filename)
+func (cf CloudFiles) putFile(dc, bucket, filename string, data io.Reader,
+	options *WriteOptions) (string, error) {
+	/*
+		Upload data, sending its MD5 for the server to check when asked to.
+		Data to compress or encrypt is staged transformed in a temporary
+		file, so it can still be hashed and retried.
+	*/
+	if options.transforms() != "" && options.encoded == "" {
+		tmpFile, err := ioutil.TempFile("", "")
+		if err != nil {
+			return "", err
+		}
 
-	req, err := http.NewRequest("PUT", url, data)
+		defer os.Remove(tmpFile.Name())
+		defer tmpFile.Close()
 
-	req.Header.Add("Content-Type", "application/octet-stream")
-	req.Header.Add("X-Auth-Token", cf.authToken)
-	resp, err := client.Do(req)
+		if err := options.encodeTo(tmpFile, data); err != nil {
+			return "", err
+		}
+		if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		data, options = tmpFile, options.asEncoded()
+	}
 
-	// Support response and partial response
-	if resp.StatusCode != 201 {
-		return "", fmt.Errorf("Could not put cloud file, status: %d", resp.StatusCode)
+	expected := ""
+	if options != nil && options.VerifyMD5 {
+		if seeker, ok := data.(io.ReadSeeker); ok {
+			var err error
+			if expected, err = md5Of(seeker); err != nil {
+				return "", err
+			}
+		}
 	}
 
+	return cf.putObject(dc, bucket, filename, data, expected, options)
+}
+
+func (cf CloudFiles) putObject(dc, bucket, filename string, data io.Reader, expected string,
+	options *WriteOptions) (string, error) {
+	/*
+		Upload an object, asking the server to check it against the
+		expected MD5 when one is given.
+	*/
+	if options.mirrored(dc) {
+		return cf.putMirrored(dc, bucket, filename, data, expected, options)
+	}
+
+	url, err := cf.objectURL(dc, bucket, filename)
 	if err != nil {
 		return "", err
 	}
 
-	return resp.Header["Etag"][0], nil
+	etag := ""
+	err = options.retry(data, func() error {
+		req, err := http.NewRequest("PUT", url, data)
+		if err != nil {
+			return err
+		}
+
+		// The client closes request bodies, keep seekable data open for
+		// a retry, and rewind it for one of the request alone.
+		if seeker, ok := data.(io.ReadSeeker); ok {
+			start, err := seeker.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+			req.Body = ioutil.NopCloser(data)
+			req.GetBody = func() (io.ReadCloser, error) {
+				if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+					return nil, err
+				}
+				return options.pace(ioutil.NopCloser(data)), nil
+			}
+		}
+		req.Body = options.pace(req.Body)
+
+		req.Header.Add("Content-Type", "application/octet-stream")
+		req.Header.Add("X-Auth-Token", cf.token())
+		if expected != "" {
+			req.Header.Add("ETag", expected)
+		}
+		options.apply(req)
+
+		release := cf.acquireContainer(dc, bucket)
+		defer release()
+
+		resp, err := cf.do(dc, req)
+
+		if err != nil {
+			return err
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode == 412 {
+			return &DestinationExistsError{Region: dc, Bucket: bucket, Name: filename}
+		}
+
+		// Support response and partial response
+		if !succeeded(resp) {
+			return newCloudFilesError(resp, "Could not put cloud file")
+		}
+
+		etag = resp.Header.Get("Etag")
+		return nil
+	})
+
+	return etag, err
 }
 
-func (cf CloudFiles) putManifest(dc, bucket, filename string, manifestItems manifestList) error {
-	endpoint := cf.dcs[dc]
-	if dc == cf.localDC {
-		endpoint = cf.dcsInternal[dc]
+func (cf CloudFiles) putManifest(dc, bucket, filename string, manifestItems manifestList,
+	options *WriteOptions) error {
+	if options.mirrored(dc) {
+		return cf.putMirroredManifest(dc, bucket, filename, manifestItems, options)
 	}
 
-	if endpoint == "" {
-		return fmt.Errorf("Could not find region %s in service catalog.", dc)
+	url, err := cf.objectURL(dc, bucket, filename)
+	if err != nil {
+		return err
 	}
-
-	// Sort manifest
-	sort.Sort(manifestItems)
+	url += "?multipart-manifest=put"
 
 	payLoad, err := json.Marshal(manifestItems)
 	if err != nil {
 		return err
 	}
 
-	client := &http.Client{}
-
-	url := fmt.Sprintf("%s/%s/%s?multipart-manifest=put", endpoint, bucket, filename)
-
 	req, err := http.NewRequest("PUT", url, bytes.NewReader(payLoad))
+	if err != nil {
+		return err
+	}
+
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("X-Auth-Token", cf.authToken)
-	resp, err := client.Do(req)
+	req.Header.Add("X-Auth-Token", cf.token())
+	options.apply(req)
 
-	// Support response and partial response
-	if resp.StatusCode != 201 {
-		defer resp.Body.Close()
-		errorMessage := new(bytes.Buffer)
-		errorMessage.ReadFrom(resp.Body)
+	release := cf.acquireContainer(dc, bucket)
+	defer release()
 
-		return fmt.Errorf("Could not put cloud file manifest, status: %d, error: %s",
-			resp.StatusCode, errorMessage.String())
-	}
+	resp, err := cf.do(dc, req)
 
 	if err != nil {
 		return err
 	}
 
+	defer resp.Body.Close()
+
+	// Support response and partial response
+	if !succeeded(resp) {
+		return newCloudFilesError(resp, "Could not put cloud file manifest")
+	}
+
 	return nil
 }
 
-func (cf CloudFiles) CopyFile(sourceDC, sourceBucket, sourceFile, destDC, destBucket, destFile string) error {
+type objectEntry struct {
+	Name         string `json:"name"`
+	Hash         string `json:"hash"`
+	Bytes        int64  `json:"bytes"`
+	ContentType  string `json:"content_type"`
+	LastModified string `json:"last_modified"`
+	// Set instead of the others for names a delimiter rolled up.
+	Subdir string `json:"subdir,omitempty"`
+}
+
+func (cf CloudFiles) walkObjects(dc, bucket, prefix string, fn func(objectEntry) error) error {
+	/*
+		Call fn for every object in a container beginning with prefix, in
+		name order, following markers until the listing is exhausted.
+		Entries are decoded one at a time as they arrive so arbitrarily
+		large containers can be enumerated in constant memory.  An error
+		from fn stops the walk and is returned.
+	*/
+	marker := ""
+
+	for {
+		count, last, err := cf.walkPage(dc, bucket, prefix, marker, 0, fn)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return nil
+		}
+
+		marker = last
+	}
+}
+
+func (cf CloudFiles) walkPage(dc, bucket, prefix, marker string, limit int,
+	fn func(objectEntry) error) (int, string, error) {
+	/*
+		Stream a single page of a listing, of at most limit entries when
+		limit is positive.
+		Returns the number of entries seen and the last name.
+	*/
+	query := neturl.Values{}
+	query.Set("format", "json")
+	query.Set("prefix", prefix)
+	if marker != "" {
+		query.Set("marker", marker)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	return cf.listPage(dc, bucket, query, fn)
+}
+
+func (cf CloudFiles) listPage(dc, bucket string, query neturl.Values,
+	fn func(objectEntry) error) (int, string, error) {
 	/*
-		Copy a file from source cloudfiles to dest cloudfiles.
+		Stream the page of a listing selected by query.  The last name is
+		that of a subdir when the page ends with one.
 	*/
-	// 256MB chunks, tune as needed
-	chunkSize := int64(256 * 1024 * 1024)
+	url, err := cf.containerURL(dc, bucket)
+	if err != nil {
+		return 0, "", err
+	}
+	url += "?" + query.Encode()
 
-	size, _, err := cf.GetFileSize(sourceDC, sourceBucket, sourceFile)
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return err
+		return 0, "", err
 	}
 
-	chunkCount := size / chunkSize
-	remainder := size % chunkSize
+	req.Header.Add("X-Auth-Token", cf.token())
+	acceptGzip(req)
+	resp, err := cf.do(dc, req)
 
-	if remainder > 0 {
-		chunkCount++
+	if err != nil {
+		return 0, "", err
 	}
 
-	// Create a place to store all of our manifest items
-	manifests := make(manifestList, 0, chunkCount)
+	defer resp.Body.Close()
 
-	// Create semaphore for concurrency
-	concurrency := 5
-	sem := make(chan bool, concurrency)
+	if resp.StatusCode == 204 {
+		return 0, "", nil
+	}
 
-	// Create other communication channels
-	errorChan := make(chan error, concurrency)
-	manifestChan := make(chan manifestItem, concurrency)
+	if !succeeded(resp) {
+		return 0, "", newCloudFilesError(resp, "Could not list container %s", bucket)
+	}
 
-	var processError error = nil
+	if err = decodeBody(resp); err != nil {
+		return 0, "", err
+	}
 
-	// Loop through all chunks and create goroutines for each...
-	// The number of active goroutines is limited by the length of sem
-loop:
-	for chunkId := int64(0); chunkId < chunkCount; chunkId++ {
-		sem <- true
+	decoder := json.NewDecoder(resp.Body)
 
-		go func(chunkIndex int64, ec chan error, mf chan manifestItem) {
-			defer func() { <-sem }()
+	// Step into the array and decode its elements one by one.
+	if _, err = decoder.Token(); err != nil {
+		return 0, "", err
+	}
 
-			tmpFile, err := ioutil.TempFile("", "")
-			defer os.Remove(tmpFile.Name())
+	count, last := 0, ""
+	for decoder.More() {
+		var entry objectEntry
+		if err = decoder.Decode(&entry); err != nil {
+			return count, last, err
+		}
 
-			if err != nil {
-				//  This would be bad...
-				ec <- err
-				return
-			}
+		if err = fn(entry); err != nil {
+			return count, last, err
+		}
 
-			size := chunkSize
+		count++
+		last = entry.Name
+		if entry.Subdir != "" {
+			last = entry.Subdir
+		}
+	}
 
-			if chunkIndex == (chunkCount - 1) {
-				size = remainder
-			}
+	return count, last, nil
+}
 
-			// Download the file.
-			bytesRead, etag, err := cf.GetChunk(sourceDC, sourceBucket, sourceFile,
-				tmpFile, chunkIndex*chunkSize, size)
+// How many entries a listingCursor fetches at a time.
+const listingPageSize = 1000
+
+// A listingCursor steps through a container listing in name order one page
+// at a time.  Unlike walkObjects no response is held open between entries,
+// so slow work such as copies can happen while iterating, and two cursors
+// can be merged without holding either listing in memory.
+type listingCursor struct {
+	cf     CloudFiles
+	dc     string
+	bucket string
+	prefix string
+	marker string
+	page   []objectEntry
+	done   bool
+}
 
-			if err != nil {
-				ec <- err
-				tmpFile.Close()
-				return
-			}
+func (cf CloudFiles) newListingCursor(dc, bucket, prefix string) *listingCursor {
+	return &listingCursor{cf: cf, dc: dc, bucket: bucket, prefix: prefix}
+}
 
-			tmpFile.Sync()
-			tmpFile.Seek(0, 0)
+func (lc *listingCursor) peek() (objectEntry, bool, error) {
+	/*
+		Return the current entry without moving past it, fetching the next
+		page when the current one is used up.
+	*/
+	if len(lc.page) == 0 && !lc.done {
+		page := make([]objectEntry, 0, listingPageSize)
+		_, last, err := lc.cf.walkPage(lc.dc, lc.bucket, lc.prefix, lc.marker, listingPageSize,
+			func(entry objectEntry) error {
+				page = append(page, entry)
+				return nil
+			})
+		if err != nil {
+			return objectEntry{}, false, err
+		}
 
-			// The destination file name of the "part".
-			destFileName := fmt.Sprintf("%s-%d", destFile, chunkIndex)
+		lc.page = page
+		lc.marker = last
+		lc.done = len(page) == 0
+	}
 
-			// Smart recovery, first check the etag of the chunk/file to put
-			// and determine if we should actually upload.
-			_, etagUp, err := cf.GetFileSize(destDC, destBucket, destFileName)
+	if len(lc.page) == 0 {
+		return objectEntry{}, false, nil
+	}
 
-			if err == nil && etagUp == etag {
-				// File already exists in remote DC, don't upload again.
-			} else {
-				etagUp, err = cf.PutFile(destDC, destBucket,
-					destFileName, tmpFile)
+	return lc.page[0], true, nil
+}
 
-				if err != nil {
-					ec <- err
-					return
-				}
-			}
+func (lc *listingCursor) next() (objectEntry, bool, error) {
+	entry, ok, err := lc.peek()
+	if ok {
+		lc.page = lc.page[1:]
+	}
+	return entry, ok, err
+}
 
-			if etagUp != etag {
-				ec <- fmt.Errorf("Upload etag does not match download etag: %s %s!", etag, etagUp)
-				return
-			}
+func (lc *listingCursor) find(name string) (objectEntry, bool, error) {
+	/*
+		Move forward to the entry with the given name.  Names must be asked
+		for in increasing order, as when merging with another listing.
+	*/
+	return lc.findPassing(name, nil)
+}
 
-			manifest := manifestItem{
-				Path: fmt.Sprintf("%s/%s", destBucket, destFileName),
-				ETag: etag,
-				Size: bytesRead,
-			}
+func (lc *listingCursor) findPassing(name string, passed func(objectEntry)) (objectEntry, bool, error) {
+	/*
+		Like find, calling passed for every entry moved past on the way.
+	*/
+	for {
+		entry, ok, err := lc.peek()
+		if err != nil || !ok {
+			return objectEntry{}, false, err
+		}
 
-			mf <- manifest
-
-			// get the url
-		}(chunkId, errorChan, manifestChan)
-
-		select {
-		case err := <-errorChan:
-			// Handle download/upload errors
-			fmt.Printf("Oh no, error: %s\n", err)
-			processError = err
-			break loop
-		case manifest := <-manifestChan:
-			manifests = append(manifests, manifest)
-		default:
-			// Do nothing allow semaphore to continue loading jobs
+		if entry.Name >= name {
+			return entry, entry.Name == name, nil
 		}
-	}
 
-	// Fill the semaphone channel back up to ensure
-	// all operations have completed.
-	for i := 0; i < cap(sem); i++ {
-		sem <- true
-
-		// Again read data coming from channels
-		select {
-		case err := <-errorChan:
-			// Handle download/upload errors
-			fmt.Printf("Oh no, error: %s", err)
-			processError = err
-			break
-		case manifest := <-manifestChan:
-			manifests = append(manifests, manifest)
-		default:
-			// Do nothing allow semaphore to continue clearing jobs
+		if passed != nil {
+			passed(entry)
 		}
+		lc.page = lc.page[1:]
 	}
+}
 
-	// Handle any errors passed from the goroutines
-	if processError != nil {
-		return processError
+func (cf CloudFiles) deleteObject(dc, bucket, filename string) error {
+	/*
+		Delete a single object, treating an already missing object as
+		deleted.
+	*/
+	url, err := cf.objectURL(dc, bucket, filename)
+	if err != nil {
+		return err
 	}
 
-	err = cf.putManifest(destDC, destBucket, destFile, manifests)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("X-Auth-Token", cf.token())
+
+	release := cf.acquireContainer(dc, bucket)
+	defer release()
+
+	resp, err := cf.do(dc, req)
 
 	if err != nil {
 		return err
 	}
 
+	defer resp.Body.Close()
+
+	if !succeeded(resp) && resp.StatusCode != 404 {
+		return newCloudFilesError(resp, "Could not delete cloud file")
+	}
+
 	return nil
 }
+
+func (cf CloudFiles) serverCopy(dc, sourceBucket, sourceFile, destBucket, destFile string,
+	headers map[string]string) (string, error) {
+	/*
+		Copy an object within a region without moving its data through the
+		client.  Extra headers are set on the new object.  A static large
+		object is copied as its manifest, so the copy references the same
+		segments instead of assembling them, which would fail over 5GB.
+		Other objects are unaffected by multipart-manifest=get.
+		Returns a tuple of etag, error
+	*/
+	url, err := cf.objectURL(dc, destBucket, destFile)
+	if err != nil {
+		return "", err
+	}
+	url += "?multipart-manifest=get"
+
+	req, err := http.NewRequest("PUT", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Add("X-Auth-Token", cf.token())
+	req.Header.Add("X-Copy-From", fmt.Sprintf("/%s/%s", sourceBucket, sourceFile))
+	req.Header.Add("Content-Length", "0")
+	for key, value := range headers {
+		req.Header.Add(key, value)
+	}
+
+	release := cf.acquireContainer(dc, destBucket)
+	defer release()
+
+	resp, err := cf.do(dc, req)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if !succeeded(resp) {
+		return "", newCloudFilesError(resp, "Could not copy cloud file")
+	}
+
+	return resp.Header.Get("Etag"), nil
+}
+
+func (cf CloudFiles) headContainer(dc, bucket string) (http.Header, error) {
+	/*
+		Fetch the headers of a container.
+	*/
+	url, err := cf.containerURL(dc, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("X-Auth-Token", cf.token())
+	resp, err := cf.do(dc, req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, &ContainerMissingError{Region: dc, Bucket: bucket}
+	}
+
+	if !succeeded(resp) {
+		return nil, newCloudFilesError(resp, "Could not fetch container %s", bucket)
+	}
+
+	return resp.Header, nil
+}
+
+func (cf CloudFiles) createContainer(dc, bucket string, headers map[string]string) error {
+	/*
+		Create a container with the given headers, succeeding if it
+		already exists.
+	*/
+	url, err := cf.containerURL(dc, bucket)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PUT", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("X-Auth-Token", cf.token())
+	req.Header.Add("Content-Length", "0")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := cf.do(dc, req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if !succeeded(resp) {
+		return newCloudFilesError(resp, "Could not create container %s", bucket)
+	}
+
+	return nil
+}
+
+func (cf CloudFiles) headObject(dc, bucket, filename string) (http.Header, error) {
+	/*
+		Fetch the headers of an object.
+	*/
+	url, err := cf.objectURL(dc, bucket, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("X-Auth-Token", cf.token())
+	resp, err := cf.do(dc, req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, &ObjectMissingError{Region: dc, Bucket: bucket, Name: filename}
+	}
+
+	if !succeeded(resp) {
+		return nil, newCloudFilesError(resp, "Could not fetch cloud file")
+	}
+
+	return resp.Header, nil
+}
@@ -14,18 +14,17 @@ var (
 	TestApiKey   = os.Getenv("TEST_KEY")
 )
 
-func TestMain(m *testing.M) {
+func requireCredentials(t *testing.T) {
+	// The live tests talk to Rackspace, everything else runs against fakeSwift.
 	if TestUserName == "" || TestApiKey == "" {
-		fmt.Println("Please set the environment variables TEST_USERNAME and TEST_KEY")
-		os.Exit(1)
-	} else {
-		os.Exit(m.Run())
+		t.Skip("Please set the environment variables TEST_USERNAME and TEST_KEY")
 	}
 }
 
 func TestGetFileLength(t *testing.T) {
 	// Test we can get the length of a cloudfiles file without pulling the entire file
 	fmt.Println("Test get file length...")
+	requireCredentials(t)
 	cf := NewCloudFiles(TestUserName, TestApiKey)
 	err := cf.Authorize()
 
@@ -48,6 +47,7 @@ func TestGetFileLength(t *testing.T) {
 func TestGetFileChunk(t *testing.T) {
 	// Test we can get a chunk of a file
 	fmt.Println("Test get file chunk...")
+	requireCredentials(t)
 	cf := NewCloudFiles(TestUserName, TestApiKey)
 	err := cf.Authorize()
 
@@ -94,6 +94,7 @@ func TestGetFileChunk(t *testing.T) {
 func TestPutFileChunk(t *testing.T) {
 	// Test we can put a file chunk
 	fmt.Println("Test put file chunk...")
+	requireCredentials(t)
 	cf := NewCloudFiles(TestUserName, TestApiKey)
 	err := cf.Authorize()
 
@@ -123,6 +124,7 @@ func TestPutFileChunk(t *testing.T) {
 func TestCopyFile(t *testing.T) {
 	// Test we can copy one file from DC to DC.
 	fmt.Println("Test copy file...")
+	requireCredentials(t)
 	cf := NewCloudFiles(TestUserName, TestApiKey)
 	err := cf.Authorize()
 
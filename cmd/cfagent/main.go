@@ -0,0 +1,225 @@
+// Command cfagent runs copies and migrations for a central controller.  It
+// is meant to run in each region next to the storage endpoints and exposes
+// its jobs over a small REST API:
+//
+//	POST   /jobs             submit a job, the body is a JobState without
+//	                         version, or an exported job to resume
+//	GET    /jobs             the state of every job
+//	GET    /jobs/{id}        the state of one job, with its segments
+//	POST   /jobs/{id}/pause  pause a job
+//	POST   /jobs/{id}/resume resume a job
+//	POST   /jobs/{id}/cancel cancel a job
+//	DELETE /jobs/{id}        cancel a job and forget it
+//
+// Requests must carry "Authorization: Bearer <token>" when -token or
+// $CFAGENT_TOKEN is set.
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mentat/gocloudfiles"
+)
+
+type agent struct {
+	cf    *gocloudfiles.CloudFiles
+	token string
+
+	mutex sync.Mutex
+	jobs  map[string]*gocloudfiles.Job
+}
+
+func newAgent(cf *gocloudfiles.CloudFiles, token string) *agent {
+	return &agent{cf: cf, token: token, jobs: make(map[string]*gocloudfiles.Job)}
+}
+
+func (a *agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.token != "" {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(a.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "Missing or wrong token.")
+			return
+		}
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "jobs" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, "No such endpoint.")
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == "GET":
+		a.list(w)
+	case len(parts) == 1 && r.Method == "POST":
+		a.submit(w, r)
+	case len(parts) == 2 && r.Method == "GET":
+		a.show(w, parts[1])
+	case len(parts) == 2 && r.Method == "DELETE":
+		a.control(w, parts[1], "delete")
+	case len(parts) == 3 && r.Method == "POST":
+		a.control(w, parts[1], parts[2])
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+	}
+}
+
+func (a *agent) submit(w http.ResponseWriter, r *http.Request) {
+	/*
+		Start a job from a JobState.  A new job needs no version and gets an
+		ID when it has none, an exported one resumes under its own ID.
+	*/
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	state := map[string]interface{}{}
+	if err := json.Unmarshal(body, &state); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Could not parse job: %s", err))
+		return
+	}
+	if _, ok := state["version"]; !ok {
+		state["version"] = gocloudfiles.JobStateVersion
+	}
+	if id, _ := state["id"].(string); id == "" {
+		state["id"] = gocloudfiles.NewTransferID()
+	}
+	body, _ = json.Marshal(state)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	id := state["id"].(string)
+	if _, ok := a.jobs[id]; ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Job %s already exists.", id))
+		return
+	}
+
+	job, err := a.cf.ImportJob(body, nil)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a.jobs[job.ID] = job
+
+	writeJSON(w, http.StatusCreated, map[string]string{"id": job.ID})
+}
+
+func (a *agent) list(w http.ResponseWriter) {
+	a.mutex.Lock()
+	jobs := make([]*gocloudfiles.Job, 0, len(a.jobs))
+	for _, job := range a.jobs {
+		jobs = append(jobs, job)
+	}
+	a.mutex.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+
+	// Segments that cannot be listed are left out rather than hiding
+	// the job.
+	states := make([]*gocloudfiles.JobState, 0, len(jobs))
+	for _, job := range jobs {
+		state, _ := job.State()
+		states = append(states, state)
+	}
+
+	writeJSON(w, http.StatusOK, states)
+}
+
+func (a *agent) find(w http.ResponseWriter, id string) *gocloudfiles.Job {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	job, ok := a.jobs[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No job %s.", id))
+	}
+	return job
+}
+
+func (a *agent) show(w http.ResponseWriter, id string) {
+	job := a.find(w, id)
+	if job == nil {
+		return
+	}
+
+	state, err := job.State()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (a *agent) control(w http.ResponseWriter, id, action string) {
+	job := a.find(w, id)
+	if job == nil {
+		return
+	}
+
+	switch action {
+	case "pause":
+		job.Pause()
+	case "resume":
+		job.Resume()
+	case "cancel":
+		job.Cancel()
+	case "delete":
+		job.Cancel()
+		a.mutex.Lock()
+		delete(a.jobs, id)
+		a.mutex.Unlock()
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Unknown action %s.", action))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:7070", "address to serve the API on")
+	profile := flag.String("profile", gocloudfiles.DefaultProfile, "credentials profile to use")
+	profilesPath := flag.String("profiles", gocloudfiles.DefaultProfilesPath(), "profiles file")
+	token := flag.String("token", os.Getenv("CFAGENT_TOKEN"), "bearer token API requests must carry")
+	flag.Parse()
+
+	profiles, err := gocloudfiles.LoadProfiles(*profilesPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cf, err := profiles.Client(*profile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *token == "" {
+		log.Printf("No -token set, the API on %s is open to anyone who can reach it.", *listen)
+	}
+
+	log.Printf("Serving jobs on %s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, newAgent(cf, *token)))
+}
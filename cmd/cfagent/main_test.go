@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mentat/gocloudfiles"
+)
+
+func TestAgentJobLifecycle(t *testing.T) {
+	// Test jobs are submitted, inspected, paused and deleted over the API.
+	server := httptest.NewServer(newAgent(gocloudfiles.NewCloudFilesImpersonation("token"), "secret"))
+	defer server.Close()
+
+	call := func(method, path, body, token string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %s", err)
+		}
+		return resp
+	}
+
+	if resp := call("GET", "/jobs", "", "wrong"); resp.StatusCode != 401 {
+		t.Fatalf("Wrong token should be refused: %d", resp.StatusCode)
+	}
+
+	if resp := call("POST", "/jobs", `{"kind": "move"}`, "secret"); resp.StatusCode != 400 {
+		t.Fatalf("Invalid job should be refused: %d", resp.StatusCode)
+	}
+
+	// The client knows no regions, so the copy fails at once.
+	resp := call("POST", "/jobs", `{"kind": "copy", "paused": true,
+		"source": {"region": "IAD", "container": "a", "object": "x"},
+		"dest": {"region": "DFW", "container": "b", "object": "x"}}`, "secret")
+	if resp.StatusCode != 201 {
+		t.Fatalf("Could not submit job: %d", resp.StatusCode)
+	}
+	var submitted map[string]string
+	json.NewDecoder(resp.Body).Decode(&submitted)
+	id := submitted["id"]
+
+	if resp := call("POST", "/jobs/"+id+"/resume", "", "secret"); resp.StatusCode != 204 {
+		t.Fatalf("Could not resume job: %d", resp.StatusCode)
+	}
+
+	resp = call("GET", "/jobs", "", "secret")
+	var states []gocloudfiles.JobState
+	json.NewDecoder(resp.Body).Decode(&states)
+	if len(states) != 1 || states[0].ID != id || states[0].Kind != "copy" {
+		t.Fatalf("Unexpected jobs %+v", states)
+	}
+
+	if resp := call("DELETE", "/jobs/"+id, "", "secret"); resp.StatusCode != 204 {
+		t.Fatalf("Could not delete job: %d", resp.StatusCode)
+	}
+	if resp := call("GET", "/jobs/"+id, "", "secret"); resp.StatusCode != 404 {
+		t.Fatalf("Deleted job should be gone: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,76 @@
+package gocloudfiles
+
+import (
+	"fmt"
+)
+
+func (cf CloudFiles) Concat(dc, bucket, target string, sources []string) (int64, error) {
+	/*
+		Stitch existing objects of a container, in order, into target
+		without moving any data, such as the parts written by parallel
+		jobs.  Target becomes a static large object whose manifest
+		references each source with the size and etag it has now, so the
+		server refuses the manifest if a source changes before it is
+		written.  Sources that are static large objects contribute their
+		segments, empty ones are left out.  The sources must stay in place,
+		the target reads its data from them.
+		Returns a tuple of the target's size, error
+	*/
+	if len(sources) == 0 {
+		return 0, fmt.Errorf("Could not concatenate into %s, no sources given.", target)
+	}
+
+	var items manifestList
+	contentType := ""
+	total := int64(0)
+
+	for _, source := range sources {
+		if source == target {
+			return 0, fmt.Errorf("Could not concatenate into %s, it is also a source.", target)
+		}
+
+		headers, err := cf.headObject(dc, bucket, source)
+		if err != nil {
+			return 0, err
+		}
+		info := newObjectInfo(source, headers)
+
+		if contentType == "" {
+			contentType = info.ContentType
+		}
+
+		switch {
+		case info.StaticLargeObject:
+			segments, err := cf.getManifest(dc, bucket, source)
+			if err != nil {
+				return 0, err
+			}
+			for _, segment := range segments {
+				items = append(items, segment.item())
+			}
+		case info.Bytes > 0:
+			items = append(items, manifestItem{
+				Path: fmt.Sprintf("%s/%s", bucket, source),
+				ETag: ParseETag(info.ETag).Value,
+				Size: info.Bytes,
+			})
+		}
+		total += info.Bytes
+
+		if len(items) > maxManifestSegments {
+			return 0, fmt.Errorf("Could not concatenate into %s, it would have more than %d segments.",
+				target, maxManifestSegments)
+		}
+	}
+
+	if len(items) == 0 {
+		return 0, fmt.Errorf("Could not concatenate into %s, every source is empty.", target)
+	}
+
+	err := cf.putManifest(dc, bucket, target, items, &WriteOptions{ContentType: contentType})
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
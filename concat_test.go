@@ -0,0 +1,42 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestConcat(t *testing.T) {
+	// Test parts, including a large object, are stitched without copying.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("testing/part-0", []byte("first "))
+	fs.put("testing/part-empty", []byte{})
+	data := []byte("second part, segmented")
+	if _, err := cf.UploadFile("TEST", "testing", "part-1", bytes.NewReader(data), int64(len(data)),
+		&UploadFileOptions{SegmentSize: 8}); err != nil {
+		t.Fatalf("Could not upload part: %s", err)
+	}
+
+	size, err := cf.Concat("TEST", "testing", "whole", []string{"part-0", "part-empty", "part-1"})
+	if err != nil || size != 28 {
+		t.Fatalf("Could not concatenate: %d %v", size, err)
+	}
+
+	if stored, _ := fs.get("testing/whole"); string(stored) != "first second part, segmented" {
+		t.Fatalf("Unexpected object %q", stored)
+	}
+	if segments := fs.manifests["testing/whole"]; len(segments) != 4 ||
+		segments[0].Name != "/testing/part-0" {
+		t.Fatalf("Unexpected segments %+v", segments)
+	}
+
+	if _, err := cf.Concat("TEST", "testing", "part-0", []string{"part-0", "part-1"}); err == nil {
+		t.Fatalf("A target among its sources should be refused.")
+	}
+	if _, err := cf.Concat("TEST", "testing", "whole", []string{"part-0", "gone"}); !errors.Is(err, ErrObjectMissing) {
+		t.Fatalf("Expected a missing source: %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package gocloudfiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClusterLimits are the limits a region's cluster reports at /info.
+type ClusterLimits struct {
+	// MaxFileSize is the largest single object or segment.
+	MaxFileSize int64
+	// MaxManifestSegments is how many segments a manifest may list.
+	MaxManifestSegments int
+	// MinSegmentSize is the smallest segment but the last.
+	MinSegmentSize int64
+}
+
+// DefaultClusterLimits are Swift's defaults, used by Config.Validate for
+// regions without Limits.
+var DefaultClusterLimits = ClusterLimits{
+	MaxFileSize:         maxObjectSize,
+	MaxManifestSegments: maxManifestSegments,
+	MinSegmentSize:      1,
+}
+
+// Config is the configuration of a long running agent, checked as a whole
+// by Validate before any work starts.
+type Config struct {
+	Profile Profile
+
+	// Regions the agent works in.
+	Regions []string
+
+	// ChunkSize is the size of copy chunks and upload segments, 256MB
+	// when zero.
+	ChunkSize int64
+
+	// Concurrency is how many objects are worked on at once,
+	// ChunkConcurrency how many chunks of them are in flight.  Both
+	// default to 5.
+	Concurrency      int
+	ChunkConcurrency int
+
+	// MemoryLimit is passed to SetMemoryLimit.
+	MemoryLimit int64
+
+	// Retries of a failed object or write, RetryDelay apart.
+	Retries    int
+	RetryDelay time.Duration
+
+	// Limits of each region, from ClusterLimits.  Regions without one are
+	// checked against DefaultClusterLimits.
+	Limits map[string]*ClusterLimits
+}
+
+// A ConfigProblem is one thing wrong with a Config and how to fix it.
+type ConfigProblem struct {
+	Field   string
+	Problem string
+	Hint    string
+}
+
+// ErrInvalidConfig is matched by errors.Is when Config.Validate found
+// problems.
+var ErrInvalidConfig = errors.New("Invalid configuration.")
+
+// ConfigError lists every problem Config.Validate found.
+type ConfigError struct {
+	Problems []ConfigProblem
+}
+
+func (e *ConfigError) Error() string {
+	lines := []string{fmt.Sprintf("Configuration has %d problems:", len(e.Problems))}
+	for _, problem := range e.Problems {
+		lines = append(lines, fmt.Sprintf("  %s: %s %s", problem.Field, problem.Problem, problem.Hint))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// A verify range of each side is held in memory per worker.
+const verifyBytesPerWorker = 2 * 1024 * 1024
+
+func (c Config) Validate() error {
+	/*
+		Check the whole configuration and report every problem at once,
+		each with a hint, so a misconfigured agent fails at startup rather
+		than hours into a job.  Nothing is sent to the server, fetch each
+		region's Limits with ClusterLimits first to check against them.
+		Returns nil or a *ConfigError
+	*/
+	var problems []ConfigProblem
+	add := func(field, problem, hint string) {
+		problems = append(problems, ConfigProblem{Field: field, Problem: problem, Hint: hint})
+	}
+
+	profile := c.Profile
+	switch {
+	case profile.Token != "" && (profile.UserName != "" || profile.APIKey != ""):
+		add("Profile", "Has both a token and a username or api key.",
+			"Remove one, the token is used and the key is ignored.")
+	case profile.Token == "" && (profile.UserName == "" || profile.APIKey == ""):
+		add("Profile", "Needs a token or both a username and an api key.",
+			"Set them in the profiles file or CLOUDFILES_USERNAME and CLOUDFILES_API_KEY.")
+	}
+
+	if len(c.Regions) == 0 {
+		add("Regions", "No regions given.", "List the regions the agent works in, e.g. DFW.")
+	}
+	seen := map[string]bool{}
+	for _, region := range c.Regions {
+		if region != strings.ToUpper(strings.TrimSpace(region)) || region == "" {
+			add("Regions", fmt.Sprintf("Region %q is not a region code.", region),
+				"Use the upper case code from the service catalog, e.g. ORD.")
+		}
+		if seen[region] {
+			add("Regions", fmt.Sprintf("Region %s is listed twice.", region), "Remove the duplicate.")
+		}
+		seen[region] = true
+	}
+	if profile.Region != "" && len(c.Regions) > 0 && !seen[profile.Region] {
+		add("Profile.Region", fmt.Sprintf("Default region %s is not in Regions.", profile.Region),
+			"Add it to Regions or change the profile's region.")
+	}
+	if profile.LocalDC != "" && len(c.Regions) > 0 && !seen[profile.LocalDC] {
+		add("Profile.LocalDC", fmt.Sprintf("Local DC %s is not in Regions.", profile.LocalDC),
+			"Add it to Regions or change the profile's local_dc.")
+	}
+
+	chunkSize := c.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = defaultChunkSize
+	}
+	if chunkSize < 0 {
+		add("ChunkSize", fmt.Sprintf("Chunk size %d is negative.", chunkSize), "Use zero for 256MB.")
+	}
+	checked := map[string]bool{}
+	for _, region := range c.Regions {
+		if checked[region] {
+			continue
+		}
+		checked[region] = true
+
+		limits := c.Limits[region]
+		if limits == nil {
+			limits = &DefaultClusterLimits
+		}
+		if chunkSize > limits.MaxFileSize {
+			add("ChunkSize", fmt.Sprintf("Chunk size %d is over the %d byte object limit of %s.",
+				chunkSize, limits.MaxFileSize, region), "Lower ChunkSize.")
+		}
+		if chunkSize > 0 && chunkSize < limits.MinSegmentSize {
+			add("ChunkSize", fmt.Sprintf("Chunk size %d is under the %d byte segment minimum of %s.",
+				chunkSize, limits.MinSegmentSize, region), "Raise ChunkSize.")
+		}
+	}
+
+	concurrency := c.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultConcurrency
+	}
+	if c.Concurrency < 0 || c.ChunkConcurrency < 0 {
+		add("Concurrency", "Concurrency is negative.", "Use zero for the default of 5.")
+	}
+	if c.MemoryLimit < 0 {
+		add("MemoryLimit", "Memory limit is negative.", "Use zero for no limit.")
+	}
+	if c.MemoryLimit > 0 && c.MemoryLimit < int64(concurrency)*verifyBytesPerWorker {
+		add("MemoryLimit", fmt.Sprintf("Memory limit %d leaves less than 2MB for each of %d workers, "+
+			"they would wait on each other.", c.MemoryLimit, concurrency),
+			"Raise MemoryLimit or lower Concurrency.")
+	}
+
+	if c.Retries < 0 || c.RetryDelay < 0 {
+		add("Retries", "Retries or RetryDelay is negative.", "Use zero to not retry.")
+	}
+	if c.Retries > 0 && c.RetryDelay == 0 {
+		add("RetryDelay", fmt.Sprintf("%d retries have no delay, they would all fail during a blip.",
+			c.Retries), "Set RetryDelay, e.g. a few seconds.")
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+func (cf CloudFiles) ClusterLimits(dc string) (*ClusterLimits, error) {
+	/*
+		Fetch a region's limits from the cluster's /info document, which
+		needs no token.  Limits it does not report keep Swift's defaults.
+		Returns a tuple of limits, error
+	*/
+	endpoint, err := cf.endpoint(dc)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := neturl.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path, u.RawQuery = "/info", ""
+
+	client := cf.httpClient()
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if !succeeded(resp) {
+		return nil, newCloudFilesError(resp, "Could not fetch limits of region %s", dc)
+	}
+
+	var info struct {
+		Swift struct {
+			MaxFileSize int64 `json:"max_file_size"`
+		} `json:"swift"`
+		SLO struct {
+			MaxManifestSegments int   `json:"max_manifest_segments"`
+			MinSegmentSize      int64 `json:"min_segment_size"`
+		} `json:"slo"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("Could not parse limits of region %s: %s", dc, err)
+	}
+
+	limits := DefaultClusterLimits
+	if info.Swift.MaxFileSize > 0 {
+		limits.MaxFileSize = info.Swift.MaxFileSize
+	}
+	if info.SLO.MaxManifestSegments > 0 {
+		limits.MaxManifestSegments = info.SLO.MaxManifestSegments
+	}
+	if info.SLO.MinSegmentSize > 0 {
+		limits.MinSegmentSize = info.SLO.MinSegmentSize
+	}
+	return &limits, nil
+}
+
+// The limits of each region copies plan their segments against, fetched
+// once.
+type limitsCache struct {
+	mutex  sync.Mutex
+	limits map[string]ClusterLimits
+}
+
+func newLimitsCache() *limitsCache {
+	return &limitsCache{limits: make(map[string]ClusterLimits)}
+}
+
+func (cf CloudFiles) segmentLimits(dc string) ClusterLimits {
+	/*
+		The limits of a region, from ClusterLimits the first time.
+		Swift's defaults stand in when /info cannot be read, many proxies
+		do not expose it.
+	*/
+	if cf.limits != nil {
+		cf.limits.mutex.Lock()
+		defer cf.limits.mutex.Unlock()
+		if limits, ok := cf.limits.limits[dc]; ok {
+			return limits
+		}
+	}
+
+	limits := DefaultClusterLimits
+	if fetched, err := cf.ClusterLimits(dc); err == nil {
+		limits = *fetched
+	}
+
+	if cf.limits != nil {
+		cf.limits.limits[dc] = limits
+	}
+	return limits
+}
@@ -0,0 +1,72 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	// Test every problem is reported at once.
+	good := Config{
+		Profile:    Profile{UserName: "me", APIKey: "key", Region: "DFW"},
+		Regions:    []string{"DFW", "ORD"},
+		Retries:    3,
+		RetryDelay: time.Second,
+	}
+	if err := good.Validate(); err != nil {
+		t.Fatalf("Good config refused: %s", err)
+	}
+
+	bad := Config{
+		Profile:     Profile{Token: "token", APIKey: "key", LocalDC: "IAD"},
+		Regions:     []string{"dfw", "ORD", "ORD"},
+		ChunkSize:   2 << 30,
+		Concurrency: 10,
+		MemoryLimit: 1024 * 1024,
+		Retries:     5,
+		Limits:      map[string]*ClusterLimits{"ORD": {MaxFileSize: 1 << 30, MinSegmentSize: 1}},
+	}
+	err := bad.Validate()
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("Bad config accepted: %v", err)
+	}
+
+	fields := map[string]int{}
+	for _, problem := range err.(*ConfigError).Problems {
+		fields[problem.Field]++
+		if problem.Hint == "" {
+			t.Fatalf("Problem without a hint: %+v", problem)
+		}
+	}
+	for _, field := range []string{"Profile", "Regions", "Profile.LocalDC", "ChunkSize", "MemoryLimit", "RetryDelay"} {
+		if fields[field] == 0 {
+			t.Fatalf("No problem reported for %s: %s", field, err)
+		}
+	}
+	// A region listed twice is checked against its limits once.
+	if fields["Regions"] != 2 || fields["ChunkSize"] != 1 {
+		t.Fatalf("Unexpected problems %v", fields)
+	}
+}
+
+func TestClusterLimits(t *testing.T) {
+	// Test limits are read from /info with defaults for the rest.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.info = `{"swift": {"max_file_size": 1073741824}, "slo": {"min_segment_size": 1048576}}`
+	limits, err := cf.ClusterLimits("TEST")
+	if err != nil || limits.MaxFileSize != 1<<30 || limits.MinSegmentSize != 1<<20 ||
+		limits.MaxManifestSegments != 1000 {
+		t.Fatalf("Unexpected limits %+v %v", limits, err)
+	}
+
+	config := Config{Profile: Profile{Token: "token"}, Regions: []string{"TEST"}, ChunkSize: 1024,
+		Limits: map[string]*ClusterLimits{"TEST": limits}}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "segment minimum") {
+		t.Fatalf("Expected a segment minimum problem: %v", err)
+	}
+}
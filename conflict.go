@@ -0,0 +1,118 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// What an upload or copy does when its destination object already exists.
+type ExistsPolicy int
+
+const (
+	// Replace the existing object, the default.
+	OverwriteExisting ExistsPolicy = iota
+	// Leave the existing object alone and report success.
+	SkipExisting
+	// Fail with ErrDestinationExists.
+	FailIfExists
+	// Replace the existing object only if the new data differs from it or,
+	// for copies, the source was modified after it.
+	OverwriteIfChanged
+)
+
+// ErrDestinationExists is matched by errors.Is when FailIfExists finds the
+// destination object already there.
+var ErrDestinationExists = errors.New("Destination object already exists.")
+
+// DestinationExistsError is returned when FailIfExists finds the destination
+// object already there.
+type DestinationExistsError struct {
+	Region string
+	Bucket string
+	Name   string
+}
+
+func (e *DestinationExistsError) Error() string {
+	return fmt.Sprintf("Object %s/%s already exists in region %s.", e.Bucket, e.Name, e.Region)
+}
+
+func (e *DestinationExistsError) Is(target error) bool {
+	return target == ErrDestinationExists || target == ErrPreconditionFailed
+}
+
+// Returned by copyFile when an existing destination is kept.
+var errDestinationKept = errors.New("Destination object kept.")
+
+func (cf CloudFiles) keepUpload(dc, bucket, filename string, data io.Reader,
+	options *WriteOptions) (string, bool, error) {
+	/*
+		Decide whether an upload leaves an existing object in place.
+		FailIfExists is left to the server, which refuses the PUT when the
+		object exists.
+		Returns a tuple of the kept object's etag, whether it is kept, error
+	*/
+	if options == nil || (options.IfExists != SkipExisting && options.IfExists != OverwriteIfChanged) {
+		return "", false, nil
+	}
+
+	existing, err := cf.statObject(dc, bucket, filename)
+	if errors.Is(err, ErrObjectMissing) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	if options.IfExists == SkipExisting {
+		return existing.ETag, true, nil
+	}
+
+	// Only data that can be rewound can be compared before it is sent.
+	seeker, ok := data.(io.ReadSeeker)
+	if !ok {
+		return "", false, nil
+	}
+
+	sum, err := md5Of(seeker)
+	if err != nil {
+		return "", false, err
+	}
+
+	return existing.ETag, sameETag(sum, existing.ETag), nil
+}
+
+func (cf CloudFiles) keepCopy(source *ObjectInfo, destDC, destBucket, destFile string,
+	policy ExistsPolicy) error {
+	/*
+		Check a copy's destination against its exists policy before anything
+		is copied.  Returns errDestinationKept when the copy should not
+		happen and a *DestinationExistsError for FailIfExists.
+	*/
+	if policy == OverwriteExisting {
+		return nil
+	}
+
+	existing, err := cf.statObject(destDC, destBucket, destFile)
+	if errors.Is(err, ErrObjectMissing) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch policy {
+	case SkipExisting:
+		return errDestinationKept
+	case FailIfExists:
+		return &DestinationExistsError{Region: destDC, Bucket: destBucket, Name: destFile}
+	}
+
+	// The same rule ReplicateContainer uses to leave objects alone.
+	if existing.Bytes == source.Bytes &&
+		(sameETag(existing.ETag, source.ETag) || !existing.LastModified.Before(source.LastModified)) {
+		return errDestinationKept
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPutFileExistsPolicies(t *testing.T) {
+	// Test each exists policy of an upload against an existing object.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("testing/file.txt", []byte("old"))
+
+	put := func(data string, policy ExistsPolicy) error {
+		_, err := cf.PutFileWithOptions("TEST", "testing", "file.txt", bytes.NewReader([]byte(data)),
+			&WriteOptions{IfExists: policy})
+		return err
+	}
+
+	if err := put("new", SkipExisting); err != nil {
+		t.Fatalf("Skipping should succeed: %s", err)
+	}
+	if data, _ := fs.get("testing/file.txt"); string(data) != "old" {
+		t.Fatalf("SkipExisting should keep the object: %q", data)
+	}
+
+	if err := put("new", FailIfExists); !errors.Is(err, ErrDestinationExists) {
+		t.Fatalf("Expected ErrDestinationExists but got %v", err)
+	}
+
+	if err := put("old", OverwriteIfChanged); err != nil {
+		t.Fatalf("Unchanged data should succeed: %s", err)
+	}
+	if err := put("new", OverwriteIfChanged); err != nil {
+		t.Fatalf("Changed data should be uploaded: %s", err)
+	}
+	if data, _ := fs.get("testing/file.txt"); string(data) != "new" {
+		t.Fatalf("OverwriteIfChanged should replace changed data: %q", data)
+	}
+
+	_, err := cf.PutFileWithOptions("TEST", "testing", "other.txt", bytes.NewReader([]byte("x")),
+		&WriteOptions{IfExists: FailIfExists})
+	if err != nil {
+		t.Fatalf("A missing object should be written: %s", err)
+	}
+}
+
+func TestCopyFilesExistsPolicy(t *testing.T) {
+	// Test a batch skips destinations that are up to date.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("src/same.txt", []byte("same"))
+	fs.put("dst/same.txt", []byte("same"))
+	fs.put("dst/stale.txt", []byte("old!"))
+	fs.put("src/stale.txt", []byte("new!"))
+	fs.put("src/new.txt", []byte("new"))
+
+	report, err := cf.CopyFiles("TEST", "src", "TEST", "dst", []string{"same.txt", "stale.txt", "new.txt"},
+		&CopyFilesOptions{Copy: &CopyOptions{IfExists: OverwriteIfChanged}})
+	if err != nil {
+		t.Fatalf("Could not copy: %s", err)
+	}
+
+	if report.Skipped != 1 || report.Succeeded != 2 {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+
+	if data, _ := fs.get("dst/stale.txt"); string(data) != "new!" {
+		t.Fatalf("A changed source should be copied: %q", data)
+	}
+
+	err = cf.CopyFileWithOptions("TEST", "src", "new.txt", "TEST", "dst", "new.txt",
+		&CopyOptions{IfExists: FailIfExists})
+	if !errors.Is(err, ErrDestinationExists) {
+		t.Fatalf("Expected ErrDestinationExists but got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The container headers CopyContainerSettings carries over besides the
+// X-Container-Meta-* ones, which hold quotas, CORS and temp URL keys.
+var containerSettingHeaders = []string{
+	"X-Container-Read",
+	"X-Container-Write",
+	"X-Versions-Location",
+	"X-History-Location",
+	"X-Versions-Enabled",
+}
+
+func containerSettings(headers http.Header) map[string]string {
+	/*
+		The settings among a container's headers: its metadata, ACLs and
+		versioning target.  Usage, the storage policy and container sync,
+		which name the region itself, are not settings.
+	*/
+	settings := make(map[string]string)
+	for key := range headers {
+		if strings.HasPrefix(key, containerMetaPrefix) {
+			settings[key] = headers.Get(key)
+		}
+	}
+	for _, key := range containerSettingHeaders {
+		if value := headers.Get(key); value != "" {
+			settings[key] = value
+		}
+	}
+	return settings
+}
+
+func (cf CloudFiles) CopyContainerSettings(sourceDC, sourceBucket, destDC, destBucket string) error {
+	/*
+		Give the destination container the source's metadata, quotas, CORS
+		rules, ACLs and versioning target, removing settings the source
+		does not have, so a region failed over to serves the container as
+		the source did.  The versioning container must exist in the
+		destination.  The error matches ErrContainerMissing when either
+		container does not exist.
+	*/
+	source, err := cf.headContainer(sourceDC, sourceBucket)
+	if err != nil {
+		return err
+	}
+	dest, err := cf.headContainer(destDC, destBucket)
+	if err != nil {
+		return err
+	}
+
+	wanted := containerSettings(source)
+	changes := make(map[string]string)
+	for key, value := range wanted {
+		if dest.Get(key) != value {
+			changes[key] = value
+		}
+	}
+	for key := range containerSettings(dest) {
+		if _, found := wanted[key]; found {
+			continue
+		}
+		// Versioning is turned off rather than removed.
+		if key == "X-Versions-Enabled" {
+			changes[key] = "false"
+		} else {
+			changes[key] = ""
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	err = cf.SetContainerMetadata(destDC, destBucket, changes)
+	if err != nil {
+		return fmt.Errorf("Could not copy the settings of container %s: %w", sourceBucket, err)
+	}
+	return nil
+}
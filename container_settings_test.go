@@ -0,0 +1,88 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCopyContainerSettings(t *testing.T) {
+	// Test the destination ends up with exactly the source's settings and
+	// keeps its own usage.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.headers["src"] = http.Header{
+		"X-Container-Meta-Quota-Bytes":                 {"1000"},
+		"X-Container-Meta-Access-Control-Allow-Origin": {"https://example.com"},
+		"X-Container-Read":                             {".r:*"},
+		"X-Versions-Location":                          {"src_versions"},
+		"X-Storage-Policy":                             {"gold"},
+	}
+	fs.headers["dst"] = http.Header{
+		"X-Container-Meta-Quota-Bytes": {"5"},
+		"X-Container-Meta-Owner":       {"old"},
+		"X-Container-Write":            {"someone"},
+		"X-Storage-Policy":             {"silver"},
+	}
+	fs.put("dst/file", []byte("data"))
+
+	if err := cf.CopyContainerSettings("TEST", "src", "TEST", "dst"); err != nil {
+		t.Fatalf("Could not copy settings: %s", err)
+	}
+
+	metadata, err := cf.GetContainerMetadata("TEST", "dst")
+	if err != nil {
+		t.Fatalf("Could not read settings: %s", err)
+	}
+	if len(metadata.Metadata) != 2 || metadata.Metadata["Quota-Bytes"] != "1000" ||
+		metadata.Metadata["Access-Control-Allow-Origin"] != "https://example.com" {
+		t.Fatalf("Unexpected metadata %v", metadata.Metadata)
+	}
+	if metadata.Headers.Get("X-Container-Read") != ".r:*" || metadata.Headers.Get("X-Container-Write") != "" {
+		t.Fatalf("Unexpected ACLs %v", metadata.Headers)
+	}
+	if metadata.VersionsLocation != "src_versions" || metadata.Headers.Get("X-Storage-Policy") != "silver" {
+		t.Fatalf("Unexpected versioning or policy %v", metadata.Headers)
+	}
+	if metadata.Objects != 1 {
+		t.Fatalf("Unexpected usage %d", metadata.Objects)
+	}
+
+	fs.missing["nowhere"] = true
+	if err := cf.CopyContainerSettings("TEST", "src", "TEST", "nowhere"); !errors.Is(err, ErrContainerMissing) {
+		t.Fatalf("Expected ErrContainerMissing, got %v", err)
+	}
+}
+
+func TestContainerSettingsOnCopy(t *testing.T) {
+	// Test replication and a copy creating its container bring the
+	// settings along.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.headers["src"] = http.Header{"X-Container-Meta-Quota-Bytes": {"1000"}, "X-Container-Read": {".r:*"}}
+	fs.put("src/file", []byte("data"))
+	fs.missing["created"] = true
+
+	err := cf.CopyFileWithOptions("TEST", "src", "file", "TEST", "created", "file",
+		&CopyOptions{CreateContainer: true, ContainerSettings: true})
+	if err != nil {
+		t.Fatalf("Could not copy: %s", err)
+	}
+	if settings := fs.headers["created"]; settings.Get("X-Container-Meta-Quota-Bytes") != "1000" ||
+		settings.Get("X-Container-Read") != ".r:*" {
+		t.Fatalf("Created container is missing the source's settings: %v", settings)
+	}
+
+	_, err = cf.ReplicateContainer("TEST", "src", "TEST", "dst", &ReplicateOptions{ContainerSettings: true})
+	if err != nil {
+		t.Fatalf("Could not replicate: %s", err)
+	}
+	if settings := fs.headers["dst"]; settings.Get("X-Container-Meta-Quota-Bytes") != "1000" ||
+		settings.Get("X-Container-Read") != ".r:*" {
+		t.Fatalf("Replica is missing the source's settings: %v", settings)
+	}
+}
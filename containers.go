@@ -0,0 +1,357 @@
+package gocloudfiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrContainerNotEmpty is matched by errors.Is when a container still holds
+// objects and cannot be deleted.
+var ErrContainerNotEmpty = errors.New("Container is not empty.")
+
+// ContainerNotEmptyError is returned by DeleteContainer for a container
+// that still holds objects.
+type ContainerNotEmptyError struct {
+	Region string
+	Bucket string
+}
+
+func (e *ContainerNotEmptyError) Error() string {
+	return fmt.Sprintf("Container %s in region %s is not empty.", e.Bucket, e.Region)
+}
+
+func (e *ContainerNotEmptyError) Is(target error) bool {
+	return target == ErrContainerNotEmpty || target == ErrConflict
+}
+
+func (cf CloudFiles) CreateContainer(dc, bucket string, headers map[string]string) error {
+	/*
+		Create a container, with headers such as X-Container-Meta-* or
+		X-Container-Read when given.  Creating a container that exists
+		succeeds and sets the headers on it.
+	*/
+	return cf.createContainer(dc, bucket, headers)
+}
+
+// DeleteContainerOptions tune DeleteContainerWithOptions.
+type DeleteContainerOptions struct {
+	// Recursive deletes every object first, as DeletePrefix does with an
+	// empty prefix, through the trash when one is configured.
+	Recursive bool
+
+	// Guard bounds the objects a recursive delete may remove.
+	Guard DeleteGuard
+
+	// Results receives one Result per object deleted when set.
+	Results chan<- Result
+}
+
+func (cf CloudFiles) DeleteContainer(dc, bucket string) error {
+	/*
+		Delete an empty container.  The error matches ErrContainerNotEmpty
+		when it holds objects and ErrContainerMissing when there is no such
+		container.
+	*/
+	url, err := cf.containerURL(dc, bucket)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("X-Auth-Token", cf.token())
+	resp, err := cf.do(dc, req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == 404:
+		return &ContainerMissingError{Region: dc, Bucket: bucket}
+	case resp.StatusCode == 409:
+		return &ContainerNotEmptyError{Region: dc, Bucket: bucket}
+	case !succeeded(resp):
+		return newCloudFilesError(resp, "Could not delete container %s", bucket)
+	}
+
+	return nil
+}
+
+func (cf CloudFiles) DeleteContainerWithOptions(dc, bucket string,
+	options *DeleteContainerOptions) (*Report, error) {
+	/*
+		DeleteContainer, optionally emptying the container first.  The
+		container is kept when any object could not be deleted, and the
+		report lists what was.
+		Returns a tuple of report, error
+	*/
+	if options == nil {
+		options = &DeleteContainerOptions{}
+	}
+
+	report := cf.newReport()
+	if options.Recursive {
+		var err error
+		report, err = cf.DeletePrefix(dc, bucket, "", &DeleteOptions{
+			Guard:   options.Guard,
+			Results: options.Results,
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, cf.DeleteContainer(dc, bucket)
+}
+
+// ContainerInfo describes a container as reported by the account listing.
+type ContainerInfo struct {
+	Name    string
+	Objects int64
+	Bytes   int64
+	// LastModified is zero on clusters whose listings do not report it.
+	LastModified time.Time
+}
+
+// ContainerListOptions select the part of an account
+// ListContainersWithOptions returns.
+type ContainerListOptions struct {
+	// Prefix limits the listing to names beginning with it.
+	Prefix string
+
+	// Only names after Marker and before EndMarker are listed when set.
+	Marker    string
+	EndMarker string
+
+	// Limit is the most containers returned, all when zero.
+	Limit int
+}
+
+// A ContainerListing is what ListContainersWithOptions found.
+type ContainerListing struct {
+	Containers []ContainerInfo
+
+	// NextMarker is set when Limit was reached, pass it as Marker for the
+	// next page, which may be empty.
+	NextMarker string
+}
+
+type containerEntry struct {
+	Name         string `json:"name"`
+	Count        int64  `json:"count"`
+	Bytes        int64  `json:"bytes"`
+	LastModified string `json:"last_modified"`
+}
+
+func (cf CloudFiles) ListContainers(dc string) ([]ContainerInfo, error) {
+	/*
+		Describe every container of the account in a region, in name
+		order, with its object count and bytes used.
+		Returns a tuple of container infos, error
+	*/
+	listing, err := cf.ListContainersWithOptions(dc, ContainerListOptions{})
+	return listing.Containers, err
+}
+
+func (cf CloudFiles) ListContainersWithOptions(dc string, options ContainerListOptions) (*ContainerListing, error) {
+	/*
+		List the account's containers in a region in name order, following
+		the server's pages until the listing or options.Limit is
+		exhausted.  Counts come from the listing and can lag recent writes
+		by a few seconds.
+		Returns a tuple of listing, error
+	*/
+	listing := &ContainerListing{}
+	marker := options.Marker
+
+	for {
+		query := neturl.Values{}
+		query.Set("format", "json")
+		if options.Prefix != "" {
+			query.Set("prefix", options.Prefix)
+		}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		if options.EndMarker != "" {
+			query.Set("end_marker", options.EndMarker)
+		}
+		if options.Limit > 0 {
+			query.Set("limit", strconv.Itoa(options.Limit-len(listing.Containers)))
+		}
+
+		entries, err := cf.accountPage(dc, query)
+		if err != nil {
+			return listing, err
+		}
+
+		if len(entries) == 0 {
+			return listing, nil
+		}
+
+		for _, entry := range entries {
+			info := ContainerInfo{Name: entry.Name, Objects: entry.Count, Bytes: entry.Bytes}
+			info.LastModified, _ = parseListingTime(entry.LastModified)
+			listing.Containers = append(listing.Containers, info)
+		}
+		marker = entries[len(entries)-1].Name
+
+		if options.Limit > 0 && len(listing.Containers) >= options.Limit {
+			listing.NextMarker = marker
+			return listing, nil
+		}
+	}
+}
+
+func (cf CloudFiles) accountPage(dc string, query neturl.Values) ([]containerEntry, error) {
+	/*
+		Fetch the page of the account listing selected by query.
+	*/
+	endpoint, err := cf.endpoint(dc)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("X-Auth-Token", cf.token())
+	acceptGzip(req)
+	resp, err := cf.do(dc, req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 204 {
+		return nil, nil
+	}
+
+	if !succeeded(resp) {
+		return nil, newCloudFilesError(resp, "Could not list containers in region %s", dc)
+	}
+
+	if err = decodeBody(resp); err != nil {
+		return nil, err
+	}
+
+	var entries []containerEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("Could not parse container listing of region %s: %s", dc, err)
+	}
+	return entries, nil
+}
+
+// Custom container metadata is sent and returned under this header prefix.
+const containerMetaPrefix = "X-Container-Meta-"
+
+// ContainerMetadata is everything a HEAD of a container reports.
+type ContainerMetadata struct {
+	Name    string
+	Objects int64
+	Bytes   int64
+
+	// Metadata holds the X-Container-Meta-* values, quotas among them,
+	// keyed by the name after the prefix in canonical form, such as
+	// "Quota-Bytes".
+	Metadata map[string]string
+
+	// VersionsLocation and HistoryLocation are the containers versioning
+	// archives to, empty when it is off.
+	VersionsLocation string
+	HistoryLocation  string
+
+	// Headers is the whole response, for headers without a field.
+	Headers http.Header
+}
+
+func (cf CloudFiles) GetContainerMetadata(dc, bucket string) (*ContainerMetadata, error) {
+	/*
+		Describe a container with its custom metadata, quotas, versioning
+		and every header of its HEAD.  The error matches
+		ErrContainerMissing when there is no such container.
+		Returns a tuple of metadata, error
+	*/
+	headers, err := cf.headContainer(dc, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &ContainerMetadata{
+		Name:             bucket,
+		Metadata:         map[string]string{},
+		VersionsLocation: headers.Get("X-Versions-Location"),
+		HistoryLocation:  headers.Get("X-History-Location"),
+		Headers:          headers,
+	}
+	metadata.Objects, _ = headerInt(headers, "X-Container-Object-Count")
+	metadata.Bytes, _ = headerInt(headers, "X-Container-Bytes-Used")
+	for key := range headers {
+		if strings.HasPrefix(key, containerMetaPrefix) {
+			metadata.Metadata[strings.TrimPrefix(key, containerMetaPrefix)] = headers.Get(key)
+		}
+	}
+	return metadata, nil
+}
+
+func (cf CloudFiles) SetContainerMetadata(dc, bucket string, headers map[string]string) error {
+	/*
+		Change a container's headers, given by their full names such as
+		X-Container-Meta-Owner, X-Container-Meta-Quota-Bytes or
+		X-Versions-Location.  Unlike objects, containers keep the headers
+		not given, and an empty value removes one.  The error matches
+		ErrContainerMissing when there is no such container.
+	*/
+	url, err := cf.containerURL(dc, bucket)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("X-Auth-Token", cf.token())
+	for key, value := range headers {
+		key = http.CanonicalHeaderKey(key)
+		if value == "" {
+			req.Header.Set("X-Remove-"+strings.TrimPrefix(key, "X-"), "x")
+		} else {
+			req.Header.Set(key, value)
+		}
+	}
+	resp, err := cf.do(dc, req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return &ContainerMissingError{Region: dc, Bucket: bucket}
+	}
+
+	if !succeeded(resp) {
+		return newCloudFilesError(resp, "Could not update container %s", bucket)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCreateAndDeleteContainer(t *testing.T) {
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	fs.missing["fresh"] = true
+
+	err := cf.CreateContainer("TEST", "fresh", map[string]string{"X-Container-Meta-Owner": "etl"})
+	if err != nil {
+		t.Fatalf("Could not create: %s", err)
+	}
+	headers, err := cf.headContainer("TEST", "fresh")
+	if err != nil || headers.Get("X-Container-Meta-Owner") != "etl" {
+		t.Fatalf("Container not created with its headers: %v %v", headers, err)
+	}
+
+	fs.put("fresh/a", []byte("a"))
+	fs.put("fresh/b", []byte("b"))
+
+	if err := cf.DeleteContainer("TEST", "fresh"); !errors.Is(err, ErrContainerNotEmpty) {
+		t.Fatalf("Expected a non empty container, got %v", err)
+	}
+
+	_, err = cf.DeleteContainerWithOptions("TEST", "fresh",
+		&DeleteContainerOptions{Recursive: true, Guard: DeleteGuard{MaxObjects: 1}})
+	if !errors.Is(err, ErrBlastRadius) {
+		t.Fatalf("Expected the guard to refuse, got %v", err)
+	}
+	if _, ok := fs.get("fresh/a"); !ok {
+		t.Fatalf("Refused delete removed objects.")
+	}
+
+	report, err := cf.DeleteContainerWithOptions("TEST", "fresh", &DeleteContainerOptions{Recursive: true})
+	if err != nil || report.Succeeded != 2 {
+		t.Fatalf("Could not delete recursively: %+v %v", report, err)
+	}
+	if _, err := cf.headContainer("TEST", "fresh"); !errors.Is(err, ErrContainerMissing) {
+		t.Fatalf("Container still exists: %v", err)
+	}
+	if err := cf.DeleteContainer("TEST", "fresh"); !errors.Is(err, ErrContainerMissing) {
+		t.Fatalf("Expected a missing container, got %v", err)
+	}
+}
+
+func TestListContainers(t *testing.T) {
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	fs.pageSize = 2
+
+	fs.put("logs/a", []byte("abc"))
+	fs.put("logs/b", []byte("de"))
+	fs.put("media/c", []byte("f"))
+	fs.put("zeta/d", nil)
+	if err := cf.CreateContainer("TEST", "empty", nil); err != nil {
+		t.Fatalf("Could not create: %s", err)
+	}
+
+	containers, err := cf.ListContainers("TEST")
+	if err != nil || len(containers) != 4 {
+		t.Fatalf("Unexpected containers %+v %v", containers, err)
+	}
+	if containers[0] != (ContainerInfo{Name: "empty"}) || containers[1].Name != "logs" ||
+		containers[1].Objects != 2 || containers[1].Bytes != 5 {
+		t.Fatalf("Unexpected stats %+v", containers)
+	}
+
+	listing, err := cf.ListContainersWithOptions("TEST", ContainerListOptions{Limit: 3})
+	if err != nil || len(listing.Containers) != 3 || listing.NextMarker != "media" {
+		t.Fatalf("Unexpected first page %+v %v", listing, err)
+	}
+	listing, _ = cf.ListContainersWithOptions("TEST", ContainerListOptions{Limit: 3, Marker: listing.NextMarker})
+	if len(listing.Containers) != 1 || listing.Containers[0].Name != "zeta" || listing.NextMarker != "" {
+		t.Fatalf("Unexpected second page %+v", listing)
+	}
+
+	listing, _ = cf.ListContainersWithOptions("TEST", ContainerListOptions{Prefix: "m"})
+	if len(listing.Containers) != 1 || listing.Containers[0].Name != "media" {
+		t.Fatalf("Unexpected prefix listing %+v", listing)
+	}
+}
+
+func TestContainerMetadata(t *testing.T) {
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.headers["media"] = http.Header{
+		"X-Container-Object-Count": {"3"},
+		"X-Container-Bytes-Used":   {"1024"},
+		"X-Container-Meta-Owner":   {"web"},
+	}
+
+	err := cf.SetContainerMetadata("TEST", "media", map[string]string{
+		"X-Container-Meta-Quota-Bytes": "4096",
+		"x-versions-location":          "media-versions",
+		"X-Container-Meta-Owner":       "",
+	})
+	if err != nil {
+		t.Fatalf("Could not set metadata: %s", err)
+	}
+
+	metadata, err := cf.GetContainerMetadata("TEST", "media")
+	if err != nil || metadata.Objects != 3 || metadata.Bytes != 1024 || metadata.VersionsLocation != "media-versions" {
+		t.Fatalf("Unexpected metadata %+v %v", metadata, err)
+	}
+	if len(metadata.Metadata) != 1 || metadata.Metadata["Quota-Bytes"] != "4096" {
+		t.Fatalf("Unexpected custom metadata %v", metadata.Metadata)
+	}
+
+	fs.missing["gone"] = true
+	if _, err := cf.GetContainerMetadata("TEST", "gone"); !errors.Is(err, ErrContainerMissing) {
+		t.Fatalf("Expected a missing container: %v", err)
+	}
+	if err := cf.SetContainerMetadata("TEST", "gone", nil); !errors.Is(err, ErrContainerMissing) {
+		t.Fatalf("Expected a missing container: %v", err)
+	}
+}
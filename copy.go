@@ -0,0 +1,894 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 256MB chunks, tune as needed
+const defaultChunkSize = int64(256 * 1024 * 1024)
+
+// Number of chunks copied at once.
+const defaultConcurrency = 5
+
+// ErrContainerMissing is matched by errors.Is when a copy's destination
+// container does not exist.
+var ErrContainerMissing = errors.New("Container does not exist.")
+
+// ContainerMissingError is returned before any data is copied when the
+// destination container does not exist.
+type ContainerMissingError struct {
+	Region string
+	Bucket string
+}
+
+func (e *ContainerMissingError) Error() string {
+	return fmt.Sprintf("Container %s does not exist in region %s.", e.Bucket, e.Region)
+}
+
+func (e *ContainerMissingError) Is(target error) bool {
+	return target == ErrContainerMissing || target == ErrNotFound
+}
+
+// CopyOptions tune CopyFileWithOptions.  The zero value copies exactly like
+// CopyFile.
+type CopyOptions struct {
+	// TransferID is included in segment names so copies of the same file
+	// racing each other never interleave segments.  Reuse an ID to resume
+	// its transfer, or leave it empty for plain "<dest>/.segments/<n>"
+	// segments.
+	TransferID string
+
+	// RemoveStaleTransfers deletes segments the destination's previous
+	// manifest referenced under any other transfer ID once the new manifest
+	// is committed.
+	RemoveStaleTransfers bool
+
+	// StaleSegments controls what happens to segments the destination's
+	// previous manifest referenced that are not part of this copy's plan,
+	// such as the tail of an older copy made with a different chunk size.
+	StaleSegments StaleSegmentPolicy
+
+	// WriteChecksums stores the segment MD5s and a SHA-256 of the whole
+	// object in a companion "<dest>.checksums" object, see GetChecksums.
+	WriteChecksums bool
+
+	// Hashes verifies the chunks passing through the client with more
+	// hashes than MD5.
+	Hashes *HashConfig
+
+	// Control pauses and resumes the copy while it runs.
+	Control *TransferControl
+
+	// Progress writes the copy's progress as JSON while it runs, for
+	// monitors outside the program.
+	Progress *ProgressOptions
+
+	// CreateContainer creates a missing destination container instead of
+	// failing with ErrContainerMissing.
+	CreateContainer bool
+
+	// ContainerSettings gives a container CreateContainer creates the
+	// source container's metadata, quotas, CORS rules, ACLs and versioning
+	// target.
+	ContainerSettings bool
+
+	// Write applies to the segments, manifest and checksum record the
+	// copy writes.
+	Write *WriteOptions
+
+	// Signer, when set, signs a TransferRecord of the copy and stores it in
+	// a companion "<dest>.transfer" object, see GetTransferRecord.
+	Signer Signer
+
+	// CheckQuota fails with ErrQuotaExceeded before anything is copied
+	// when the object would not fit the destination container's or
+	// account's quota.
+	CheckQuota bool
+
+	// ChunkSize is the size of the segments a copy is split into, zero
+	// means 256MB.  A last segment under the destination cluster's
+	// minimum is merged into the one before it.
+	ChunkSize int64
+
+	// Concurrency is how many chunks of the copy are in flight at once,
+	// defaultConcurrency when zero.  More hide the latency of a distant
+	// region, fewer spare a host short of memory or temporary disk.
+	// CopyFiles shares its ChunkConcurrency between its copies instead.
+	Concurrency int
+
+	// SegmentDigits zero pads segment numbers to this width, zero means
+	// DefaultSegmentDigits.  Numbers too large for the width are written
+	// in full.
+	SegmentDigits int
+
+	// IfExists decides what happens when the destination object already
+	// exists.  OverwriteIfChanged copies only when the sizes differ, or the
+	// source changed after the destination was written and their etags
+	// differ.
+	IfExists ExistsPolicy
+
+	// Chunk slots shared by every copy of a CopyFiles batch.
+	slots chan bool
+}
+
+type StaleSegmentPolicy int
+
+const (
+	// Leave stale segments in place.
+	KeepStaleSegments StaleSegmentPolicy = iota
+	// Delete stale segments before any chunk is copied.
+	DeleteStaleSegmentsBefore
+	// Delete stale segments once the new manifest is committed.
+	DeleteStaleSegmentsAfter
+	// Once the new manifest is committed, read it back and delete every
+	// segment of the previous manifest it does not reference, whatever
+	// its name or container, such as those of a manifest another tool
+	// wrote.
+	DeleteUnreferencedSegments
+)
+
+func (options *CopyOptions) chunkSize() int64 {
+	if options == nil || options.ChunkSize == 0 {
+		return defaultChunkSize
+	}
+	return options.ChunkSize
+}
+
+func (options *CopyOptions) concurrency() int {
+	if options == nil || options.Concurrency == 0 {
+		return defaultConcurrency
+	}
+	return options.Concurrency
+}
+
+func NewTransferID() string {
+	/*
+		Generate a random transfer ID suitable for CopyOptions.TransferID.
+	*/
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func validTransferID(transferID string) bool {
+	for _, r := range transferID {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Segments live in a reserved pseudo directory below their object, so they
+// can never be mistaken for an unrelated object sharing the name's prefix.
+const segmentDir = "/.segments/"
+
+// Segment numbers are zero padded to this many digits when
+// CopyOptions.SegmentDigits is zero, so they sort in byte order.
+const DefaultSegmentDigits = 8
+
+func segmentName(destFile, transferID string, index int64, digits int) string {
+	if transferID == "" {
+		return fmt.Sprintf("%s%s%0*d", destFile, segmentDir, digits, index)
+	}
+	return fmt.Sprintf("%s%s%s/%0*d", destFile, segmentDir, transferID, digits, index)
+}
+
+func isSegment(name string) bool {
+	return strings.Contains(name, segmentDir)
+}
+
+func parseSegmentName(destFile, name string) (transferID string, index int64, ok bool) {
+	/*
+		Split a segment name produced by segmentName back into its transfer
+		ID and chunk index.  Names that do not look like segments of
+		destFile are rejected.
+	*/
+	if !strings.HasPrefix(name, destFile+segmentDir) {
+		return "", 0, false
+	}
+
+	rest := name[len(destFile)+len(segmentDir):]
+	if i := strings.Index(rest, "/"); i >= 0 {
+		transferID, rest = rest[:i], rest[i+1:]
+		if transferID == "" || !validTransferID(transferID) {
+			return "", 0, false
+		}
+	}
+
+	if rest == "" || strings.TrimLeft(rest, "0123456789") != "" {
+		return "", 0, false
+	}
+
+	index, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return transferID, index, true
+}
+
+type copyPlan struct {
+	sourceDC     string
+	sourceBucket string
+	sourceFile   string
+	destDC       string
+	destBucket   string
+	destFile     string
+	transferID   string
+	size         int64
+	chunkSize    int64
+	chunkCount   int64
+	remainder    int64
+	hasher       *orderedHasher
+	hashes       *HashConfig
+	// Segments referenced by the destination's manifest before the copy.
+	previous      []sloSegment
+	write         *WriteOptions
+	segmentDigits int
+	// Objects smaller than a chunk are copied as a plain object.
+	inline bool
+	// Nil unless CopyOptions.Progress is set.
+	progress *progressTracker
+	// Etags of the segments already at the destination, listed once so
+	// smart recovery does not HEAD every segment.  Nil when the listing
+	// failed and segments are HEADed instead.
+	existing map[string]string
+}
+
+func (plan *copyPlan) segment(chunkIndex int64) string {
+	return segmentName(plan.destFile, plan.transferID, chunkIndex, plan.segmentDigits)
+}
+
+func segmentPrefix(destFile, transferID string) string {
+	if transferID == "" {
+		return destFile + segmentDir
+	}
+	return destFile + segmentDir + transferID + "/"
+}
+
+func (plan *copyPlan) segmentPrefix() string {
+	return segmentPrefix(plan.destFile, plan.transferID)
+}
+
+func (cf CloudFiles) listSegments(plan *copyPlan) map[string]string {
+	/*
+		List the segments already written under the plan's prefix with
+		their etags.
+	*/
+	existing := make(map[string]string)
+	err := cf.walkObjects(plan.destDC, plan.destBucket, plan.segmentPrefix(), func(entry objectEntry) error {
+		existing[entry.Name] = entry.Hash
+		return nil
+	})
+	if err != nil {
+		return nil
+	}
+	return existing
+}
+
+func (plan *copyPlan) otherTransfer(transferID string, chunkIndex int64) bool {
+	return transferID != plan.transferID
+}
+
+func (plan *copyPlan) stale(transferID string, chunkIndex int64) bool {
+	return plan.inline || transferID != plan.transferID || chunkIndex >= plan.chunkCount
+}
+
+func (cf CloudFiles) CopyFile(sourceDC, sourceBucket, sourceFile, destDC, destBucket, destFile string) error {
+	/*
+		Copy a file from source cloudfiles to dest cloudfiles.
+	*/
+	return cf.CopyFileWithOptions(sourceDC, sourceBucket, sourceFile,
+		destDC, destBucket, destFile, nil)
+}
+
+func (cf CloudFiles) CopyFileWithOptions(sourceDC, sourceBucket, sourceFile,
+	destDC, destBucket, destFile string, options *CopyOptions) error {
+	/*
+		Copy a file from source cloudfiles to dest cloudfiles using the
+		given options.  A nil options behaves like CopyFile.
+	*/
+	_, err := cf.copyFile(sourceDC, sourceBucket, sourceFile, destDC, destBucket, destFile, options)
+	if err == errDestinationKept {
+		return nil
+	}
+	return err
+}
+
+func chunkLayout(size, chunkSize int64) (int64, int64) {
+	/*
+		Split a size into chunks.  Only a partial last chunk adds one, so
+		an exact multiple of the chunk size never ends in an empty chunk.
+		Returns a tuple of chunk count, size of a partial last chunk
+	*/
+	count, remainder := size/chunkSize, size%chunkSize
+	if remainder > 0 {
+		count++
+	}
+	return count, remainder
+}
+
+func segmentLayout(size, chunkSize int64, limits ClusterLimits) (int64, int64, error) {
+	/*
+		chunkLayout checked against a cluster's limits.  A partial last
+		chunk under the minimum segment size joins the chunk before it,
+		which some clusters require of every segment.
+		Returns a tuple of chunk count, size of a partial last chunk, error
+	*/
+	count, remainder := chunkLayout(size, chunkSize)
+	if count > 1 && remainder > 0 && remainder < limits.MinSegmentSize {
+		count--
+		remainder += chunkSize
+	}
+
+	largest := chunkSize
+	if remainder > largest {
+		largest = remainder
+	}
+
+	switch {
+	case largest > limits.MaxFileSize:
+		return 0, 0, fmt.Errorf("Segments of %d bytes would exceed the %d byte object limit, lower the chunk size.",
+			largest, limits.MaxFileSize)
+	case count > 1 && chunkSize < limits.MinSegmentSize:
+		return 0, 0, fmt.Errorf("Chunk size %d is under the %d byte minimum segment size, raise it.",
+			chunkSize, limits.MinSegmentSize)
+	case count > int64(limits.MaxManifestSegments):
+		return 0, 0, fmt.Errorf("%d segments would exceed the %d a manifest may list, raise the chunk size.",
+			count, limits.MaxManifestSegments)
+	}
+
+	return count, remainder, nil
+}
+
+func (cf CloudFiles) copyFile(sourceDC, sourceBucket, sourceFile,
+	destDC, destBucket, destFile string, options *CopyOptions) (copied int64, err error) {
+	/*
+		CopyFileWithOptions, returning the number of bytes copied.
+	*/
+	if options == nil {
+		options = &CopyOptions{}
+	}
+
+	if !validTransferID(options.TransferID) {
+		return 0, fmt.Errorf("Invalid transfer ID %q, use only letters, digits and underscores.",
+			options.TransferID)
+	}
+
+	chunkSize := options.chunkSize()
+	if chunkSize < 0 {
+		return 0, fmt.Errorf("Chunk size %d is negative.", chunkSize)
+	}
+	if options.Concurrency < 0 {
+		return 0, fmt.Errorf("Concurrency %d is negative.", options.Concurrency)
+	}
+	if err := options.Hashes.validate(); err != nil {
+		return 0, err
+	}
+	// Segments are checked against the source's, so a copy keeps its bytes.
+	if options.Write.transforms() != "" {
+		return 0, fmt.Errorf("Copies cannot compress or encrypt, they keep the source's bytes.")
+	}
+	started := time.Now().UTC()
+
+	source, err := cf.statObject(sourceDC, sourceBucket, sourceFile)
+	if err != nil {
+		return 0, err
+	}
+	size := source.Bytes
+
+	err = cf.keepCopy(source, destDC, destBucket, destFile, options.IfExists)
+	if err != nil {
+		return 0, err
+	}
+
+	// Large objects are always rewritten as segments of their own, a plain
+	// copy of a manifest would share the source's segments.  Empty objects
+	// have no chunks and are always written directly.
+	inline := size == 0 || size < chunkSize && !source.StaticLargeObject
+
+	chunkCount, remainder := chunkLayout(size, chunkSize)
+	if !inline {
+		chunkCount, remainder, err = segmentLayout(size, chunkSize, cf.segmentLimits(destDC))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// The exists policy is decided above, the copy's own writes replace
+	// whatever is there.
+	write := options.Write
+	if write != nil && write.IfExists != OverwriteExisting {
+		copied := *write
+		copied.IfExists = OverwriteExisting
+		write = &copied
+	}
+
+	// Find a missing destination now rather than after the first chunks.
+	containerHeaders, err := cf.headContainer(destDC, destBucket)
+	if errors.Is(err, ErrContainerMissing) && options.CreateContainer {
+		var settings map[string]string
+		if options.ContainerSettings {
+			var sourceHeaders http.Header
+			if sourceHeaders, err = cf.headContainer(sourceDC, sourceBucket); err != nil {
+				return 0, err
+			}
+			settings = containerSettings(sourceHeaders)
+		}
+		err = cf.createContainer(destDC, destBucket, settings)
+		containerHeaders = http.Header{}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if options.CheckQuota {
+		// An inline copy is one object, a segmented one its segments and
+		// the manifest.  The checksum and transfer records are one more
+		// each.
+		objects := int64(1)
+		if !inline {
+			objects = chunkCount + 1
+		}
+		if options.WriteChecksums {
+			objects++
+		}
+		if options.Signer != nil {
+			objects++
+		}
+
+		err = cf.checkQuotas(destDC, destBucket, containerHeaders, size, objects)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	plan := &copyPlan{
+		sourceDC:     sourceDC,
+		sourceBucket: sourceBucket,
+		sourceFile:   sourceFile,
+		destDC:       destDC,
+		destBucket:   destBucket,
+		destFile:     destFile,
+		transferID:   options.TransferID,
+		size:         size,
+		chunkSize:    chunkSize,
+		chunkCount:   chunkCount,
+		remainder:    remainder,
+		inline:       inline,
+		write:        write,
+		hashes:       options.Hashes,
+	}
+
+	plan.segmentDigits = options.SegmentDigits
+	if plan.segmentDigits <= 0 {
+		plan.segmentDigits = DefaultSegmentDigits
+	}
+
+	if options.WriteChecksums {
+		plan.hasher = newOrderedHasher(sha256.New())
+	}
+
+	plan.progress = cf.newProgressTracker(options.Progress, plan)
+	defer func() { plan.progress.finish(err) }()
+
+	if options.RemoveStaleTransfers || options.StaleSegments != KeepStaleSegments {
+		plan.previous = cf.previousSegments(destDC, destBucket, destFile)
+	}
+
+	if options.StaleSegments == DeleteStaleSegmentsBefore {
+		err = cf.removeSegments(plan, plan.stale)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var segments []SegmentChecksum
+	if plan.inline {
+		if err = options.Control.wait(); err != nil {
+			return 0, err
+		}
+		if options.slots != nil {
+			options.slots <- true
+		}
+		plan.progress.chunk(0, ChunkCopying)
+		segments, err = cf.copyInline(plan)
+		if options.slots != nil {
+			<-options.slots
+		}
+	} else {
+		segments, err = cf.copySegments(plan, options)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	sum := ""
+	if plan.hasher != nil {
+		sum = plan.hasher.sum()
+		err = cf.putChecksums(destDC, destBucket, write, &ChecksumRecord{
+			Object:   destFile,
+			Size:     size,
+			SHA256:   sum,
+			Segments: segments,
+			Created:  time.Now().UTC(),
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if options.Signer != nil {
+		err = cf.putTransferRecord(destDC, destBucket, destFile, options.Signer, write, &TransferRecord{
+			Source:      sourceDC + "/" + sourceBucket + "/" + sourceFile,
+			Destination: destDC + "/" + destBucket + "/" + destFile,
+			TransferID:  options.TransferID,
+			Size:        size,
+			SHA256:      sum,
+			Segments:    segments,
+			Subject:     cf.subject(),
+			Started:     started,
+			Finished:    time.Now().UTC(),
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if options.RemoveStaleTransfers {
+		err = cf.removeSegments(plan, plan.otherTransfer)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if options.StaleSegments == DeleteStaleSegmentsAfter {
+		err = cf.removeSegments(plan, plan.stale)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if options.StaleSegments == DeleteUnreferencedSegments {
+		err = cf.removeUnreferencedSegments(plan)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return size, nil
+}
+
+func (cf CloudFiles) copySegments(plan *copyPlan, options *CopyOptions) ([]SegmentChecksum, error) {
+	/*
+		Copy every chunk of the plan into its own segment, in parallel, and
+		commit the manifest joining them.
+	*/
+	plan.existing = cf.listSegments(plan)
+
+	// Create a place to store all of our manifest items, indexed by chunk
+	manifests := make(manifestList, plan.chunkCount)
+
+	// Create semaphore for concurrency, unless a batch shares one
+	sem := options.slots
+	if sem == nil {
+		sem = make(chan bool, options.concurrency())
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var processError error = nil
+
+	failed := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return processError != nil
+	}
+
+	// Loop through all chunks and create goroutines for each...
+	// The number of active goroutines is limited by the length of sem, and
+	// no new chunks are started once one of them has failed.
+	for chunkId := int64(0); chunkId < plan.chunkCount && !failed(); chunkId++ {
+		if err := options.Control.wait(); err != nil {
+			mutex.Lock()
+			processError = err
+			mutex.Unlock()
+			break
+		}
+		sem <- true
+		wg.Add(1)
+
+		go func(chunkIndex int64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			plan.progress.chunk(chunkIndex, ChunkCopying)
+			var manifest manifestItem
+			err := catchPanic(plan.destFile, chunkIndex, func() (err error) {
+				manifest, err = cf.copyChunk(plan, chunkIndex, plan.segment(chunkIndex))
+				return err
+			})
+
+			if err != nil {
+				plan.progress.chunk(chunkIndex, ChunkFailed)
+			} else {
+				plan.progress.chunk(chunkIndex, ChunkDone)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				if processError == nil {
+					processError = err
+				}
+				if plan.hasher != nil {
+					plan.hasher.abort()
+				}
+				return
+			}
+
+			manifests[chunkIndex] = manifest
+		}(chunkId)
+	}
+
+	// Wait for all in flight chunks to finish.
+	wg.Wait()
+
+	// Handle any errors passed from the goroutines
+	if processError != nil {
+		return nil, processError
+	}
+
+	// Manifest items are indexed by chunk, so the manifest lists the
+	// segments in byte order whatever their names.
+	segments := make([]SegmentChecksum, 0, len(manifests))
+	for _, manifest := range manifests {
+		segments = append(segments, SegmentChecksum(manifest))
+	}
+
+	err := cf.putManifest(plan.destDC, plan.destBucket, plan.destFile, manifests, plan.write)
+	if err != nil {
+		return nil, err
+	}
+
+	return segments, nil
+}
+
+func (cf CloudFiles) copyInline(plan *copyPlan) ([]SegmentChecksum, error) {
+	/*
+		Copy an object smaller than one chunk straight to the destination,
+		without segments or a manifest.  Within a region the server copies
+		it, unless checksums need the data to pass through the client.
+		An empty object is created without reading the source, a range
+		request for its first chunk would be unsatisfiable.
+	*/
+	if plan.size == 0 {
+		etag, err := cf.putObject(plan.destDC, plan.destBucket, plan.destFile,
+			bytes.NewReader(nil), "", plan.write)
+		if err != nil {
+			return nil, err
+		}
+
+		return []SegmentChecksum{{
+			Path: fmt.Sprintf("%s/%s", plan.destBucket, plan.destFile),
+			ETag: etag,
+		}}, nil
+	}
+
+	if plan.sourceDC == plan.destDC && plan.hasher == nil {
+		etag, err := cf.serverCopy(plan.destDC, plan.sourceBucket, plan.sourceFile,
+			plan.destBucket, plan.destFile, plan.write.headers())
+		if err != nil {
+			return nil, err
+		}
+
+		return []SegmentChecksum{{
+			Path: fmt.Sprintf("%s/%s", plan.destBucket, plan.destFile),
+			ETag: etag,
+			Size: plan.size,
+		}}, nil
+	}
+
+	item, err := cf.copyChunk(plan, 0, plan.destFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return []SegmentChecksum{SegmentChecksum(item)}, nil
+}
+
+func (cf CloudFiles) copyChunk(plan *copyPlan, chunkIndex int64, destFileName string) (manifestItem, error) {
+	/*
+		Copy a single chunk of the plan into the named destination object
+		and return the manifest entry describing it.
+	*/
+	tmpFile, err := ioutil.TempFile("", "")
+
+	if err != nil {
+		//  This would be bad...
+		return manifestItem{}, err
+	}
+
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	size := plan.chunkSize
+
+	// A size that is an exact multiple of the chunk size has no partial
+	// last chunk.
+	if chunkIndex == (plan.chunkCount-1) && plan.remainder > 0 {
+		size = plan.remainder
+	}
+
+	// Download the file.
+	downloaded := plan.hashes.start()
+	bytesRead, etag, err := cf.GetChunk(plan.sourceDC, plan.sourceBucket, plan.sourceFile,
+		downloaded.writer(tmpFile), chunkIndex*plan.chunkSize, size)
+
+	if err != nil {
+		return manifestItem{}, err
+	}
+
+	tmpFile.Sync()
+	tmpFile.Seek(0, 0)
+
+	if plan.hasher != nil {
+		err = plan.hasher.write(chunkIndex, tmpFile)
+		if err != nil {
+			return manifestItem{}, err
+		}
+		tmpFile.Seek(0, 0)
+	}
+
+	// Smart recovery, first check the etag of the chunk/file to put
+	// and determine if we should actually upload.  Segments are looked
+	// up in the listing taken before the copy started.
+	etagUp, found := "", false
+	if plan.existing != nil {
+		etagUp, found = plan.existing[destFileName]
+	} else {
+		_, etagUp, err = cf.GetFileSize(plan.destDC, plan.destBucket, destFileName)
+		found = err == nil
+	}
+
+	if found && sameETag(etagUp, etag) {
+		// File already exists in remote DC, don't upload again.
+	} else {
+		expected := ""
+		if plan.write != nil && plan.write.VerifyMD5 {
+			expected = etag
+		}
+
+		uploaded := plan.hashes.start()
+		etagUp, err = cf.putObject(plan.destDC, plan.destBucket,
+			destFileName, uploaded.readSeeker(tmpFile), expected, plan.write)
+
+		if err != nil {
+			return manifestItem{}, err
+		}
+
+		if err = compareSums(downloaded.sums(), uploaded.sums()); err != nil {
+			return manifestItem{}, err
+		}
+	}
+
+	if !sameETag(etagUp, etag) {
+		return manifestItem{}, fmt.Errorf("Upload etag does not match download etag: %s %s!", etag, etagUp)
+	}
+
+	return manifestItem{
+		Path:   fmt.Sprintf("%s/%s", plan.destBucket, destFileName),
+		ETag:   etag,
+		Size:   bytesRead,
+		Hashes: downloaded.sums(),
+	}, nil
+}
+
+func (cf CloudFiles) previousSegments(dc, bucket, filename string) []sloSegment {
+	/*
+		The segments a destination's current manifest references.  Anything
+		that is not a static large object, including a missing one, has
+		none, so cleanup never deletes more than the old manifest held.
+	*/
+	headers, err := cf.headObject(dc, bucket, filename)
+	if err != nil || !isStaticLargeObject(headers) {
+		return nil
+	}
+
+	segments, err := cf.getManifest(dc, bucket, filename)
+	if err != nil {
+		return nil
+	}
+
+	return segments
+}
+
+func (cf CloudFiles) removeSegments(plan *copyPlan, match func(string, int64) bool) error {
+	/*
+		Delete segments of the destination's previous manifest for which
+		match returns true.  Objects the manifest does not reference, or
+		that are not named like our segments, are never touched.
+	*/
+	for _, segment := range plan.previous {
+		container, name := segment.location()
+		if container != plan.destBucket {
+			continue
+		}
+
+		transferID, chunkIndex, ok := parseSegmentName(plan.destFile, name)
+		if !ok || !match(transferID, chunkIndex) {
+			continue
+		}
+
+		err := cf.deleteObject(plan.destDC, plan.destBucket, name)
+		if err != nil {
+			return fmt.Errorf("Could not remove stale segment %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (cf CloudFiles) removeUnreferencedSegments(plan *copyPlan) error {
+	/*
+		Delete segments of the destination's previous manifest that its
+		current one does not reference.  The current manifest is read back
+		rather than taken from the plan, so one committed by a copy racing
+		this one keeps its segments.  When it cannot be read nothing is
+		deleted, as new segments may have taken old names.
+	*/
+	if len(plan.previous) == 0 {
+		return nil
+	}
+
+	headers, err := cf.headObject(plan.destDC, plan.destBucket, plan.destFile)
+	if err != nil {
+		return fmt.Errorf("Could not read back %s to remove its old segments: %w", plan.destFile, err)
+	}
+
+	referenced := make(map[string]bool)
+	if isStaticLargeObject(headers) {
+		current, err := cf.getManifest(plan.destDC, plan.destBucket, plan.destFile)
+		if err != nil {
+			return fmt.Errorf("Could not read back %s to remove its old segments: %w", plan.destFile, err)
+		}
+		for _, segment := range current {
+			referenced[strings.TrimPrefix(segment.Name, "/")] = true
+		}
+	}
+
+	for _, segment := range plan.previous {
+		name := strings.TrimPrefix(segment.Name, "/")
+		if referenced[name] {
+			continue
+		}
+		// A manifest may list a segment more than once.
+		referenced[name] = true
+
+		container, object := segment.location()
+		if container == "" || object == "" {
+			continue
+		}
+
+		err := cf.deleteObject(plan.destDC, container, object)
+		if err != nil {
+			return fmt.Errorf("Could not remove unreferenced segment %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// CopyFilesOptions tune CopyFiles.
+type CopyFilesOptions struct {
+	// Number of objects copied at once, defaults to 5.
+	Concurrency int
+
+	// Number of chunks in flight across all objects at once, defaults to
+	// 5.  Objects being copied share this budget instead of each running
+	// their own.
+	ChunkConcurrency int
+
+	// Copy is used for every object copied.
+	Copy *CopyOptions
+
+	// How many times a failed object is copied again, RetryDelay apart.
+	// Segments already in place are not copied again.
+	Retries    int
+	RetryDelay time.Duration
+
+	// Results receives one Result per object when set.
+	Results chan<- Result
+}
+
+func (cf CloudFiles) CopyFiles(sourceDC, sourceBucket, destDC, destBucket string, names []string,
+	options *CopyFilesOptions) (*Report, error) {
+	/*
+		Copy a list of objects between containers, keeping their names, with
+		a pool of workers sharing one budget of chunks in flight.  Objects
+		fail independently and are retried on their own; the report counts
+		each and the error is a *MultiError when any of them failed.
+	*/
+	if options == nil {
+		options = &CopyFilesOptions{}
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	chunkConcurrency := options.ChunkConcurrency
+	if chunkConcurrency <= 0 {
+		chunkConcurrency = defaultConcurrency
+	}
+
+	copied := CopyOptions{}
+	if options.Copy != nil {
+		copied = *options.Copy
+	}
+	copied.slots = make(chan bool, chunkConcurrency)
+
+	report := cf.newReport()
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	work := make(chan string)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				result := cf.copyFilesObject(sourceDC, sourceBucket, destDC, destBucket, name,
+					&copied, options)
+
+				mutex.Lock()
+				report.record(options.Results, result)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, name := range names {
+		mutex.Lock()
+		aborted := report.Aborted
+		mutex.Unlock()
+		if aborted {
+			break
+		}
+		work <- name
+	}
+	close(work)
+	wg.Wait()
+
+	return report, report.Err()
+}
+
+func (cf CloudFiles) copyFilesObject(sourceDC, sourceBucket, destDC, destBucket, name string,
+	copied *CopyOptions, options *CopyFilesOptions) Result {
+	result := Result{
+		Op:     "copy",
+		DC:     destDC,
+		Bucket: destBucket,
+		Name:   name,
+	}
+
+	var size int64
+	var err error
+	for attempt := 0; ; attempt++ {
+		// Each object gets its own copy of the options, they are shared
+		// by workers running at the same time.
+		objectOptions := *copied
+		err = catchPanic(name, -1, func() (err error) {
+			size, err = cf.copyFile(sourceDC, sourceBucket, name, destDC, destBucket, name, &objectOptions)
+			return err
+		})
+		if err == nil || err == errDestinationKept || errors.Is(err, ErrDestinationExists) ||
+			errors.Is(err, ErrWorkerPanic) || err == ErrCanceled || attempt >= options.Retries {
+			break
+		}
+		time.Sleep(options.RetryDelay)
+	}
+
+	if err == errDestinationKept {
+		result.Status = ResultSkipped
+		result.Reason = "destination exists"
+		return result
+	}
+
+	if err != nil {
+		result.Status = ResultFailure
+		result.Err = err
+		return result
+	}
+
+	result.Status = ResultSuccess
+	result.Bytes = size
+	return result
+}
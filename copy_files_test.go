@@ -0,0 +1,37 @@
+package gocloudfiles
+
+import (
+	"testing"
+)
+
+func TestCopyFiles(t *testing.T) {
+	// Test a batch retries failed objects and reports each of them.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("src/a.txt", []byte("aaa"))
+	fs.put("src/b.txt", []byte("bb"))
+	putLargeObject(fs, "src", "c.iso", "c.iso/part-0", "c.iso/part-1")
+	fs.fail("dst/b.txt", 1)
+
+	report, err := cf.CopyFiles("TEST", "src", "TEST", "dst", []string{"a.txt", "b.txt", "c.iso", "missing"},
+		&CopyFilesOptions{Concurrency: 2, ChunkConcurrency: 1, Retries: 1})
+	if err == nil {
+		t.Fatalf("The missing object should fail the batch.")
+	}
+
+	if report.Succeeded != 3 || report.Failed != 1 || report.Bytes != 7 {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+
+	if report.Failures[0].Name != "missing" {
+		t.Fatalf("Unexpected failure: %+v", report.Failures[0])
+	}
+
+	for name, want := range map[string]string{"a.txt": "aaa", "b.txt": "bb", "c.iso": "xx"} {
+		if data, _ := fs.get("dst/" + name); string(data) != want {
+			t.Fatalf("Unexpected copy of %s: %q", name, data)
+		}
+	}
+}
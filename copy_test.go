@@ -0,0 +1,501 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseSegmentName(t *testing.T) {
+	// Test segment names round trip and unrelated objects are rejected.
+	transferID, index, ok := parseSegmentName("file.iso", segmentName("file.iso", "abc123", 7, DefaultSegmentDigits))
+	if !ok || transferID != "abc123" || index != 7 {
+		t.Fatalf("Could not parse transfer segment: %s %d %v", transferID, index, ok)
+	}
+
+	transferID, index, ok = parseSegmentName("file.iso", segmentName("file.iso", "", 12, DefaultSegmentDigits))
+	if !ok || transferID != "" || index != 12 {
+		t.Fatalf("Could not parse plain segment: %s %d %v", transferID, index, ok)
+	}
+
+	if name := segmentName("file.iso", "", 12, 4); name != "file.iso/.segments/0012" {
+		t.Fatalf("Segment numbers should be zero padded: %s", name)
+	}
+
+	if _, index, ok := parseSegmentName("file.iso", "file.iso/.segments/12"); !ok || index != 12 {
+		t.Fatalf("Unpadded segments of older copies should still parse.")
+	}
+
+	for _, name := range []string{"file.iso", "file.iso-2", "file.iso-x-2", "file.iso/.segments/",
+		"file.iso/.segments/x", "file.iso/.segments//1", "other/.segments/1"} {
+		if _, _, ok := parseSegmentName("file.iso", name); ok {
+			t.Fatalf("%s should not parse as a segment.", name)
+		}
+	}
+}
+
+// putLargeObject stores segments and a manifest referencing them.
+func putLargeObject(fs *fakeSwift, container, name string, segments ...string) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	joined := make([]byte, 0)
+	manifest := make([]sloSegment, 0, len(segments))
+	for _, segment := range segments {
+		fs.store(container+"/"+segment, []byte("x"))
+		joined = append(joined, 'x')
+		manifest = append(manifest, sloSegment{Name: "/" + container + "/" + segment, Bytes: 1})
+	}
+
+	fs.store(container+"/"+name, joined)
+	fs.headers[container+"/"+name] = http.Header{"X-Static-Large-Object": {"True"}}
+	fs.manifests[container+"/"+name] = manifest
+}
+
+func TestRemoveStaleTransfers(t *testing.T) {
+	// Test only segments of other transfers are removed.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	putLargeObject(fs, "testing", "file.iso",
+		segmentName("file.iso", "mine", 0, DefaultSegmentDigits),
+		segmentName("file.iso", "theirs", 0, DefaultSegmentDigits),
+		segmentName("file.iso", "", 0, DefaultSegmentDigits))
+	fs.put("testing/"+segmentName("file.iso", "orphan", 0, DefaultSegmentDigits), []byte("d"))
+	fs.put("testing/file.iso-2", []byte("e"))
+
+	plan := &copyPlan{destDC: "TEST", destBucket: "testing", destFile: "file.iso", transferID: "mine"}
+	plan.previous = cf.previousSegments("TEST", "testing", "file.iso")
+
+	err := cf.removeSegments(plan, plan.otherTransfer)
+	if err != nil {
+		t.Fatalf("Could not remove stale transfers: %s", err)
+	}
+
+	for name, want := range map[string]bool{
+		segmentName("file.iso", "mine", 0, DefaultSegmentDigits):   true,
+		segmentName("file.iso", "theirs", 0, DefaultSegmentDigits): false,
+		segmentName("file.iso", "", 0, DefaultSegmentDigits):       false,
+		// Not referenced by the manifest, so never ours to delete.
+		segmentName("file.iso", "orphan", 0, DefaultSegmentDigits): true,
+		"file.iso-2": true,
+	} {
+		if _, ok := fs.get("testing/" + name); ok != want {
+			t.Fatalf("Expected %s to exist: %v", name, want)
+		}
+	}
+}
+
+func TestRemoveStaleSegments(t *testing.T) {
+	// Test both policies remove the old tail but never sibling objects.
+	for _, policy := range []StaleSegmentPolicy{DeleteStaleSegmentsBefore, DeleteStaleSegmentsAfter} {
+		fs := newFakeSwift()
+		cf := fs.client()
+
+		segments := make([]string, 3)
+		for i := range segments {
+			segments[i] = segmentName("file.iso", "", int64(i), DefaultSegmentDigits)
+		}
+		putLargeObject(fs, "testing", "file.iso", segments...)
+		// A large source is copied as segments, even when it is small.
+		putLargeObject(fs, "source", "file.iso", "part")
+
+		siblings := []string{"file.iso-2", "file.iso-1", "file.iso-x-2", "file.iso.bak"}
+		for _, sibling := range siblings {
+			fs.put("testing/"+sibling, []byte("keep"))
+		}
+
+		err := cf.CopyFileWithOptions("TEST", "source", "file.iso", "TEST", "testing", "file.iso",
+			&CopyOptions{StaleSegments: policy})
+		if err != nil {
+			t.Fatalf("Could not copy with policy %d: %s", policy, err)
+		}
+
+		for i, segment := range segments {
+			if _, ok := fs.get("testing/" + segment); ok != (i == 0) {
+				t.Fatalf("Unexpected state for segment %d with policy %d: %v", i, policy, ok)
+			}
+		}
+
+		for _, sibling := range siblings {
+			if _, ok := fs.get("testing/" + sibling); !ok {
+				t.Fatalf("Unrelated object %s should be kept with policy %d.", sibling, policy)
+			}
+		}
+
+		if data, _ := fs.get("testing/file.iso"); string(data) != "x" {
+			t.Fatalf("Unexpected destination %q with policy %d", data, policy)
+		}
+
+		fs.Close()
+	}
+}
+
+func TestRemoveUnreferencedSegments(t *testing.T) {
+	// Test a copy removes whatever segments the replaced manifest had,
+	// wherever they are, and keeps the ones it reuses.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("source/file.bin", []byte("abcdefghij"))
+	options := &CopyOptions{ChunkSize: 2, StaleSegments: DeleteUnreferencedSegments}
+	err := cf.CopyFileWithOptions("TEST", "source", "file.bin", "TEST", "testing", "file.bin", options)
+	if err != nil {
+		t.Fatalf("Could not copy: %s", err)
+	}
+
+	// Another tool's segments, referenced alongside ours.
+	fs.mutex.Lock()
+	fs.store("testing_segments/file.bin/1", []byte("x"))
+	fs.manifests["testing/file.bin"] = append(fs.manifests["testing/file.bin"],
+		sloSegment{Name: "/testing_segments/file.bin/1", Bytes: 1})
+	fs.mutex.Unlock()
+	fs.put("testing_segments/file.bin/2", []byte("keep"))
+
+	options.ChunkSize = 4
+	err = cf.CopyFileWithOptions("TEST", "source", "file.bin", "TEST", "testing", "file.bin", options)
+	if err != nil {
+		t.Fatalf("Could not copy again: %s", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		segment := "testing/" + segmentName("file.bin", "", int64(i), DefaultSegmentDigits)
+		if _, found := fs.get(segment); found != (i < 3) {
+			t.Fatalf("Unexpected state for segment %d: %v", i, found)
+		}
+	}
+	if _, found := fs.get("testing_segments/file.bin/1"); found {
+		t.Fatalf("Segment of the replaced manifest in another container should be removed.")
+	}
+	if _, found := fs.get("testing_segments/file.bin/2"); !found {
+		t.Fatalf("Object no manifest referenced should be kept.")
+	}
+
+	if data, _ := fs.get("testing/file.bin"); string(data) != "abcdefghij" {
+		t.Fatalf("Unexpected copy %q", data)
+	}
+}
+
+func TestCopyFileMissingContainer(t *testing.T) {
+	// Test a missing destination container fails before any chunk is copied.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("src/file.bin", []byte("data"))
+	fs.missing["dst"] = true
+
+	err := cf.CopyFile("TEST", "src", "file.bin", "TEST", "dst", "file.bin")
+	if !errors.Is(err, ErrContainerMissing) {
+		t.Fatalf("Expected ErrContainerMissing but got: %v", err)
+	}
+
+	err = cf.CopyFileWithOptions("TEST", "src", "file.bin", "TEST", "dst", "file.bin",
+		&CopyOptions{CreateContainer: true})
+	if err != nil {
+		t.Fatalf("Could not copy into a created container: %s", err)
+	}
+
+	if data, _ := fs.get("dst/file.bin"); string(data) != "data" {
+		t.Fatalf("Unexpected copy %q", data)
+	}
+}
+
+func TestManifestKeepsChunkOrder(t *testing.T) {
+	// Test a manifest lists segments by chunk index, not by name.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	var items manifestList
+	var want []byte
+	for i := int64(0); i < 12; i++ {
+		// Unpadded names sort 0, 1, 10, 11, 2, ...
+		name := segmentName("big.bin", "", i, 1)
+		fs.put("testing/"+name, []byte{byte('a' + i)})
+		items = append(items, manifestItem{Path: "testing/" + name, Size: 1})
+		want = append(want, byte('a'+i))
+	}
+
+	if err := cf.putManifest("TEST", "testing", "big.bin", items, nil); err != nil {
+		t.Fatalf("Could not put manifest: %s", err)
+	}
+
+	if got, _ := fs.get("testing/big.bin"); string(got) != string(want) {
+		t.Fatalf("Manifest segments out of order: %q", got)
+	}
+}
+
+func TestCopyFileSmallObjectInline(t *testing.T) {
+	// Test objects smaller than a chunk are copied without a manifest.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.dcs["OTHER"] = fs.server.URL
+
+	fs.put("src/small.txt", []byte("small"))
+	write := &WriteOptions{Headers: map[string]string{"X-Object-Meta-Tier": "cold"}}
+
+	for _, dc := range []string{"TEST", "OTHER"} {
+		name := "small-" + dc + ".txt"
+		err := cf.CopyFileWithOptions("TEST", "src", "small.txt", dc, "dst", name, &CopyOptions{Write: write})
+		if err != nil {
+			t.Fatalf("Could not copy to %s: %s", dc, err)
+		}
+
+		if data, _ := fs.get("dst/" + name); string(data) != "small" {
+			t.Fatalf("Unexpected copy in %s: %q", dc, data)
+		}
+
+		if _, ok := fs.manifests["dst/"+name]; ok || isStaticLargeObject(fs.headers["dst/"+name]) {
+			t.Fatalf("A small object should not get a manifest in %s.", dc)
+		}
+
+		if fs.headers["dst/"+name].Get("X-Object-Meta-Tier") != "cold" {
+			t.Fatalf("Write options were not applied in %s: %v", dc, fs.headers["dst/"+name])
+		}
+
+		if _, ok := fs.get("dst/" + segmentName(name, "", 0, DefaultSegmentDigits)); ok {
+			t.Fatalf("A small object should not get segments in %s.", dc)
+		}
+	}
+}
+
+func TestCopyFileInlineRemovesOldSegments(t *testing.T) {
+	// Test replacing a large object with a small one can remove its segments.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	segments := []string{segmentName("file.iso", "", 0, DefaultSegmentDigits),
+		segmentName("file.iso", "", 1, DefaultSegmentDigits)}
+	putLargeObject(fs, "testing", "file.iso", segments...)
+	fs.put("source/file.iso", []byte("small"))
+
+	err := cf.CopyFileWithOptions("TEST", "source", "file.iso", "TEST", "testing", "file.iso",
+		&CopyOptions{StaleSegments: DeleteStaleSegmentsAfter})
+	if err != nil {
+		t.Fatalf("Could not copy: %s", err)
+	}
+
+	for _, segment := range segments {
+		if _, ok := fs.get("testing/" + segment); ok {
+			t.Fatalf("Segment %s of the replaced large object should be removed.", segment)
+		}
+	}
+}
+
+func TestCopyFileListsExistingSegments(t *testing.T) {
+	// Test a resumed copy finds its segments in one listing instead of HEADs.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	putLargeObject(fs, "src", "big.iso", "big.iso/part-0")
+	if err := cf.CopyFile("TEST", "src", "big.iso", "TEST", "dst", "big.iso"); err != nil {
+		t.Fatalf("Could not copy: %s", err)
+	}
+
+	meter := NewUsageMeter()
+	cf.SetAccounting(meter.Record)
+	if err := cf.CopyFile("TEST", "src", "big.iso", "TEST", "dst", "big.iso"); err != nil {
+		t.Fatalf("Could not resume copy: %s", err)
+	}
+
+	for _, usage := range meter.Totals() {
+		// The only HEAD left is the destination container check.
+		if usage.Container == "dst" && (usage.Op == "head" && usage.Requests > 1 || usage.Op == "upload") {
+			t.Fatalf("Existing segments should be found in the listing: %+v", usage)
+		}
+	}
+}
+
+func TestCopyFileEmptyObject(t *testing.T) {
+	// Test an empty object is copied with a single PUT and no range request.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.dcs["OTHER"] = fs.server.URL
+
+	fs.put("src/empty.txt", []byte{})
+
+	for _, dc := range []string{"TEST", "OTHER"} {
+		err := cf.CopyFileWithOptions("TEST", "src", "empty.txt", dc, "dst", "empty.txt",
+			&CopyOptions{WriteChecksums: true})
+		if err != nil {
+			t.Fatalf("Could not copy empty object to %s: %s", dc, err)
+		}
+
+		data, ok := fs.get("dst/empty.txt")
+		if !ok || len(data) != 0 || isStaticLargeObject(fs.headers["dst/empty.txt"]) {
+			t.Fatalf("Empty object should be copied as a plain object to %s: %q", dc, data)
+		}
+	}
+}
+
+func TestChunkLayoutExactMultiple(t *testing.T) {
+	// Test sizes that are exact multiples of the chunk size have no empty last chunk.
+	cases := []struct{ size, count, remainder int64 }{
+		{0, 0, 0}, {4, 1, 0}, {8, 2, 0}, {9, 3, 1},
+	}
+	for _, c := range cases {
+		count, remainder := chunkLayout(c.size, 4)
+		if count != c.count || remainder != c.remainder {
+			t.Fatalf("Unexpected layout %d %d for size %d", count, remainder, c.size)
+		}
+	}
+
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("src/file.bin", []byte("abcdefgh"))
+
+	plan := &copyPlan{
+		sourceDC:      "TEST",
+		sourceBucket:  "src",
+		sourceFile:    "file.bin",
+		destDC:        "TEST",
+		destBucket:    "dst",
+		destFile:      "file.bin",
+		size:          8,
+		chunkSize:     4,
+		segmentDigits: DefaultSegmentDigits,
+	}
+	plan.chunkCount, plan.remainder = chunkLayout(plan.size, plan.chunkSize)
+
+	if _, err := cf.copySegments(plan, &CopyOptions{}); err != nil {
+		t.Fatalf("Could not copy segments: %s", err)
+	}
+
+	for index, want := range []string{"abcd", "efgh"} {
+		segment, _ := fs.get("dst/" + segmentName("file.bin", "", int64(index), DefaultSegmentDigits))
+		if string(segment) != want {
+			t.Fatalf("Unexpected segment %d: %q", index, segment)
+		}
+	}
+	if _, ok := fs.get("dst/" + segmentName("file.bin", "", 2, DefaultSegmentDigits)); ok {
+		t.Fatalf("No empty last segment should be written.")
+	}
+	if copied, _ := fs.get("dst/file.bin"); string(copied) != "abcdefgh" {
+		t.Fatalf("Unexpected copy %q", copied)
+	}
+}
+
+func TestCopyFileMergesSmallTail(t *testing.T) {
+	// Test a last segment under the cluster's minimum joins the one before it.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	fs.info = `{"swift": {"max_file_size": 8}, "slo": {"max_manifest_segments": 4, "min_segment_size": 3}}`
+
+	fs.put("src/file.bin", []byte("abcdefghi"))
+
+	err := cf.CopyFileWithOptions("TEST", "src", "file.bin", "TEST", "dst", "file.bin", &CopyOptions{ChunkSize: 4})
+	if err != nil {
+		t.Fatalf("Could not copy: %s", err)
+	}
+
+	manifest := fs.manifests["dst/file.bin"]
+	if len(manifest) != 2 || manifest[0].Bytes != 4 || manifest[1].Bytes != 5 {
+		t.Fatalf("Tail not merged: %+v", manifest)
+	}
+	if copied, _ := fs.get("dst/file.bin"); string(copied) != "abcdefghi" {
+		t.Fatalf("Unexpected copy %q", copied)
+	}
+
+	for _, chunkSize := range []int64{2, 9} {
+		fs.put("src/big.bin", make([]byte, 10))
+		err = cf.CopyFileWithOptions("TEST", "src", "big.bin", "TEST", "dst", "big.bin",
+			&CopyOptions{ChunkSize: chunkSize})
+		if err == nil {
+			t.Fatalf("Chunk size %d should be refused by the cluster's limits.", chunkSize)
+		}
+	}
+	if _, ok := fs.get("dst/big.bin"); ok {
+		t.Fatalf("Refused copies should not write.")
+	}
+}
+
+func TestSegmentLayout(t *testing.T) {
+	limits := ClusterLimits{MaxFileSize: 100, MaxManifestSegments: 3, MinSegmentSize: 10}
+	cases := []struct {
+		size, chunkSize, count, remainder int64
+		ok                                bool
+	}{
+		{30, 10, 3, 0, true},
+		{35, 10, 3, 15, true},
+		{31, 10, 3, 11, true},
+		{5, 10, 1, 5, true},
+		{45, 10, 0, 0, false},
+		{20, 5, 0, 0, false},
+		{150, 110, 0, 0, false},
+	}
+	for _, c := range cases {
+		count, remainder, err := segmentLayout(c.size, c.chunkSize, limits)
+		if (err == nil) != c.ok || c.ok && (count != c.count || remainder != c.remainder) {
+			t.Fatalf("Unexpected layout of %d in %d: %d %d %v", c.size, c.chunkSize, count, remainder, err)
+		}
+	}
+}
+
+type inFlightTransport struct {
+	mutex    sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (ft *inFlightTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "PUT" || !strings.Contains(req.URL.Path, "/.segments/") {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+
+	ft.mutex.Lock()
+	ft.inFlight++
+	if ft.inFlight > ft.peak {
+		ft.peak = ft.inFlight
+	}
+	ft.mutex.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+	resp, err := http.DefaultTransport.RoundTrip(req)
+
+	ft.mutex.Lock()
+	ft.inFlight--
+	ft.mutex.Unlock()
+	return resp, err
+}
+
+func TestCopyFileConcurrency(t *testing.T) {
+	// Test no more than Concurrency chunks are copied at once.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	fs.put("src/file.bin", []byte("abcdefghijklmnop"))
+
+	for _, concurrency := range []int{1, 2} {
+		transport := &inFlightTransport{}
+		cf.SetTransport(transport)
+		err := cf.CopyFileWithOptions("TEST", "src", "file.bin", "TEST", "dst", "file.bin",
+			&CopyOptions{ChunkSize: 2, Concurrency: concurrency, TransferID: NewTransferID()})
+		if err != nil {
+			t.Fatalf("Could not copy: %s", err)
+		}
+		if transport.peak < 1 || transport.peak > concurrency {
+			t.Fatalf("%d chunks in flight with a concurrency of %d", transport.peak, concurrency)
+		}
+		if copied, _ := fs.get("dst/file.bin"); string(copied) != "abcdefghijklmnop" {
+			t.Fatalf("Unexpected copy %q", copied)
+		}
+	}
+
+	err := cf.CopyFileWithOptions("TEST", "src", "file.bin", "TEST", "dst", "file.bin", &CopyOptions{Concurrency: -1})
+	if err == nil {
+		t.Fatalf("A negative concurrency should be refused.")
+	}
+}
@@ -0,0 +1,150 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// A Cutover moves an application's storage from an old container to a new
+// one, possibly in another region, without downtime.  The application
+// reads and writes through the Cutover while a bulk copy, such as
+// ReplicateContainer or a Migration, brings the rest of the old objects
+// over.  Writes go to both containers, so the bulk copy never brings back
+// an old version and the old container stays usable for a rollback.
+// Reads prefer the new container and fall back to the old one for objects
+// not copied yet.
+type Cutover struct {
+	cf     CloudFiles
+	old    ObjectLocation
+	target ObjectLocation
+
+	mutex     sync.Mutex
+	fallbacks int64
+}
+
+func (cf CloudFiles) NewCutover(oldDC, oldBucket, newDC, newBucket string) *Cutover {
+	/*
+		Serve objects from newBucket, falling back to oldBucket, and write
+		them to both.
+	*/
+	return &Cutover{
+		cf:     cf,
+		old:    ObjectLocation{Region: oldDC, Container: oldBucket},
+		target: ObjectLocation{Region: newDC, Container: newBucket},
+	}
+}
+
+func (c *Cutover) PutFile(filename string, data io.Reader) (string, error) {
+	/*
+		Write an object to the new container, then to the old one.  Within
+		a region the server copies it to the old container, otherwise the
+		data is kept in a temporary file and uploaded again.  When only the
+		new write succeeds the error says so, reads already see the object.
+		Returns a tuple of etag, error
+	*/
+	sameRegion := c.old.Region == c.target.Region
+
+	var spool *os.File
+	if !sameRegion {
+		var err error
+		spool, err = ioutil.TempFile("", "")
+		if err != nil {
+			return "", err
+		}
+
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+
+		data = io.TeeReader(data, spool)
+	}
+
+	etag, err := c.cf.PutFile(c.target.Region, c.target.Container, filename, data)
+	if err != nil {
+		return "", err
+	}
+
+	if sameRegion {
+		_, err = c.cf.serverCopy(c.old.Region, c.target.Container, filename, c.old.Container, filename, nil)
+	} else if _, err = spool.Seek(0, 0); err == nil {
+		_, err = c.cf.PutFile(c.old.Region, c.old.Container, filename, spool)
+	}
+	if err != nil {
+		return etag, fmt.Errorf("Wrote %s to %s but not to %s: %w", filename, c.target, c.old, err)
+	}
+
+	return etag, nil
+}
+
+func (c *Cutover) GetChunk(filename string, out io.Writer, offset, length int64) (int64, string, error) {
+	/*
+		GetChunk from the new container, or from the old one when the
+		object has not been copied yet.
+		Returns a 3-tuple of size, etag, error
+	*/
+	size, etag, err := c.cf.GetChunk(c.target.Region, c.target.Container, filename, out, offset, length)
+	if !errors.Is(err, ErrObjectMissing) {
+		return size, etag, err
+	}
+
+	c.fellBack()
+	return c.cf.GetChunk(c.old.Region, c.old.Container, filename, out, offset, length)
+}
+
+func (c *Cutover) StatObject(filename string) (*ObjectInfo, error) {
+	/*
+		StatObject in the new container, or in the old one when the object
+		has not been copied yet.
+		Returns a tuple of object info, error
+	*/
+	info, err := c.cf.StatObject(c.target.Region, c.target.Container, filename)
+	if !errors.Is(err, ErrObjectMissing) {
+		return info, err
+	}
+
+	c.fellBack()
+	return c.cf.StatObject(c.old.Region, c.old.Container, filename)
+}
+
+func (c *Cutover) DeleteFile(filename string) error {
+	/*
+		Delete an object from both containers, so the bulk copy cannot
+		bring it back.  The error matches ErrObjectMissing only when neither
+		container had it.
+	*/
+	newErr := c.cf.DeleteFile(c.target.Region, c.target.Container, filename)
+	if newErr != nil && !errors.Is(newErr, ErrObjectMissing) {
+		return newErr
+	}
+
+	oldErr := c.cf.DeleteFile(c.old.Region, c.old.Container, filename)
+	if oldErr != nil && !errors.Is(oldErr, ErrObjectMissing) {
+		return fmt.Errorf("Deleted %s from %s but not from %s: %w", filename, c.target, c.old, oldErr)
+	}
+
+	if newErr != nil && oldErr != nil {
+		return newErr
+	}
+	return nil
+}
+
+func (c *Cutover) Fallbacks() int64 {
+	/*
+		How many reads the old container served.  Once the bulk copy is
+		done it stops growing, and reads can be moved to the new container
+		alone.
+	*/
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.fallbacks
+}
+
+func (c *Cutover) fellBack() {
+	c.mutex.Lock()
+	c.fallbacks++
+	c.mutex.Unlock()
+}
@@ -0,0 +1,72 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCutoverWritesBoth(t *testing.T) {
+	// Test writes reach both containers, within a region and across.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.dcs["OTHER"] = fs.server.URL
+
+	for _, dc := range []string{"TEST", "OTHER"} {
+		cutover := cf.NewCutover("TEST", "old", dc, "new")
+		if _, err := cutover.PutFile("file-"+dc, bytes.NewReader([]byte("data"))); err != nil {
+			t.Fatalf("Could not put through a cutover to %s: %s", dc, err)
+		}
+
+		for _, bucket := range []string{"old", "new"} {
+			if data, ok := fs.get(bucket + "/file-" + dc); !ok || string(data) != "data" {
+				t.Fatalf("Cutover to %s did not write %s: %q", dc, bucket, data)
+			}
+		}
+	}
+}
+
+func TestCutoverReadsFallBack(t *testing.T) {
+	// Test reads prefer the new container and fall back to the old one.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("old/copied", []byte("stale"))
+	fs.put("new/copied", []byte("fresh"))
+	fs.put("old/pending", []byte("only old"))
+	cutover := cf.NewCutover("TEST", "old", "TEST", "new")
+
+	var out bytes.Buffer
+	if _, _, err := cutover.GetChunk("copied", &out, 0, 0); err != nil || out.String() != "fresh" {
+		t.Fatalf("Expected the new copy, got %q %v", out.String(), err)
+	}
+	if cutover.Fallbacks() != 0 {
+		t.Fatalf("Fell back for a copied object.")
+	}
+
+	out.Reset()
+	if _, _, err := cutover.GetChunk("pending", &out, 0, 0); err != nil || out.String() != "only old" {
+		t.Fatalf("Expected the old copy, got %q %v", out.String(), err)
+	}
+	if info, err := cutover.StatObject("pending"); err != nil || info.Bytes != 8 {
+		t.Fatalf("Could not stat the old copy: %+v %v", info, err)
+	}
+	if cutover.Fallbacks() != 2 {
+		t.Fatalf("Expected 2 fallbacks, got %d", cutover.Fallbacks())
+	}
+
+	if err := cutover.DeleteFile("pending"); err != nil {
+		t.Fatalf("Could not delete: %s", err)
+	}
+	if _, ok := fs.get("old/pending"); ok {
+		t.Fatalf("Delete left the old copy.")
+	}
+	if err := cutover.DeleteFile("pending"); !errors.Is(err, ErrObjectMissing) {
+		t.Fatalf("Expected ErrObjectMissing, got %v", err)
+	}
+	if _, err := cutover.StatObject("pending"); !errors.Is(err, ErrObjectMissing) {
+		t.Fatalf("Expected ErrObjectMissing, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBlastRadius is matched by errors.Is when a destructive operation was
+// refused because it would touch more than its DeleteGuard allows.
+var ErrBlastRadius = errors.New("Operation exceeds its configured blast radius.")
+
+// BlastRadiusError describes what a refused destructive operation would
+// have deleted.  Nothing is deleted when the first pass refuses it; when
+// objects added since push the delete past its guard, it stops before the
+// first object over the limit.
+type BlastRadiusError struct {
+	Objects    int
+	Bytes      int64
+	MaxObjects int
+	MaxBytes   int64
+	Reason     string
+}
+
+func (e *BlastRadiusError) Error() string {
+	return fmt.Sprintf("Refusing to delete %d objects (%d bytes): %s", e.Objects, e.Bytes, e.Reason)
+}
+
+func (e *BlastRadiusError) Is(target error) bool {
+	return target == ErrBlastRadius
+}
+
+// A DeleteGuard bounds how much a destructive operation may delete.  Zero
+// values impose no limit.
+type DeleteGuard struct {
+	MaxObjects int
+	MaxBytes   int64
+	// RequirePrefix refuses to operate on an entire container.
+	RequirePrefix bool
+}
+
+func (guard DeleteGuard) limited() bool {
+	return guard.MaxObjects > 0 || guard.MaxBytes > 0
+}
+
+func (guard DeleteGuard) check(prefix string, objects int, total int64) error {
+	/*
+		Verify the planned deletions fit the guard before anything is
+		deleted.
+	*/
+	err := &BlastRadiusError{
+		Objects:    objects,
+		Bytes:      total,
+		MaxObjects: guard.MaxObjects,
+		MaxBytes:   guard.MaxBytes,
+	}
+
+	switch {
+	case guard.RequirePrefix && prefix == "":
+		err.Reason = "a prefix is required"
+	case guard.MaxObjects > 0 && objects > guard.MaxObjects:
+		err.Reason = fmt.Sprintf("more than %d objects", guard.MaxObjects)
+	case guard.MaxBytes > 0 && total > guard.MaxBytes:
+		err.Reason = fmt.Sprintf("more than %d bytes", guard.MaxBytes)
+	default:
+		return nil
+	}
+
+	return err
+}
+
+// DeleteFileOptions tune DeleteFileWithOptions.
+type DeleteFileOptions struct {
+	// Segments also deletes the segments a static large object's manifest
+	// references.  They are kept while a trash is configured, the trashed
+	// manifest still needs them.
+	Segments bool
+}
+
+func (cf CloudFiles) DeleteFile(dc, bucket, filename string) error {
+	/*
+		Delete one object, through the trash when one is configured.  The
+		error matches ErrObjectMissing when there is no such object.
+	*/
+	return cf.DeleteFileWithOptions(dc, bucket, filename, nil)
+}
+
+func (cf CloudFiles) DeleteFileWithOptions(dc, bucket, filename string, options *DeleteFileOptions) error {
+	/*
+		DeleteFile, optionally deleting a large object's segments once its
+		manifest is gone so a failure never leaves a manifest pointing at
+		missing segments.
+	*/
+	if options == nil {
+		options = &DeleteFileOptions{}
+	}
+
+	headers, err := cf.headObject(dc, bucket, filename)
+	if err != nil {
+		return err
+	}
+
+	var segments []sloSegment
+	if options.Segments && cf.trash == nil && isStaticLargeObject(headers) {
+		if segments, err = cf.getManifest(dc, bucket, filename); err != nil {
+			return err
+		}
+	}
+
+	if err := cf.removeObject(dc, bucket, filename); err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		container, name := segment.location()
+		if err := cf.deleteObject(dc, container, name); err != nil {
+			return fmt.Errorf("Deleted %s but not its segment %s: %w", filename, segment.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteOptions tune DeletePrefix.
+type DeleteOptions struct {
+	Guard DeleteGuard
+
+	// Results receives one Result per object when set.
+	Results chan<- Result
+}
+
+func (cf CloudFiles) DeletePrefix(dc, bucket, prefix string, options *DeleteOptions) (*Report, error) {
+	/*
+		Delete every object in the container whose name begins with prefix.
+		The guard is checked against a full pass over the listing first, so
+		a prefix typo is refused before anything is deleted, and again as
+		objects are deleted, so objects uploaded in between cannot take
+		the delete past it.  Neither pass holds the listing in memory.
+	*/
+	if options == nil {
+		options = &DeleteOptions{}
+	}
+
+	objects, total := 0, int64(0)
+	if options.Guard.limited() {
+		err := cf.walkObjects(dc, bucket, prefix, func(entry objectEntry) error {
+			objects++
+			total += entry.Bytes
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := options.Guard.check(prefix, objects, total)
+	if err != nil {
+		return nil, err
+	}
+
+	report := cf.newReport()
+	entries := cf.newListingCursor(dc, bucket, prefix)
+	deleted, deletedBytes := 0, int64(0)
+
+	for !report.Aborted {
+		entry, ok, err := entries.next()
+		if err != nil {
+			return report, err
+		}
+		if !ok {
+			break
+		}
+
+		deleted++
+		deletedBytes += entry.Bytes
+		if options.Guard.limited() {
+			if err := options.Guard.check(prefix, deleted, deletedBytes); err != nil {
+				return report, err
+			}
+		}
+
+		result := Result{
+			Op:     "delete",
+			DC:     dc,
+			Bucket: bucket,
+			Name:   entry.Name,
+			Bytes:  entry.Bytes,
+			Status: ResultSuccess,
+		}
+
+		err = cf.removeObject(dc, bucket, entry.Name)
+		if err != nil {
+			result.Status = ResultFailure
+			result.Err = err
+		}
+
+		report.record(options.Results, result)
+	}
+
+	return report, report.Err()
+}
@@ -0,0 +1,121 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDeletePrefixGuard(t *testing.T) {
+	// Test a delete exceeding its guard is refused before anything is removed.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("testing/logs/1", []byte("one"))
+	fs.put("testing/logs/2", []byte("two"))
+	fs.put("testing/keep", []byte("keep"))
+
+	_, err := cf.DeletePrefix("TEST", "testing", "logs/",
+		&DeleteOptions{Guard: DeleteGuard{MaxObjects: 1}})
+	if !errors.Is(err, ErrBlastRadius) {
+		t.Fatalf("Expected ErrBlastRadius but got: %v", err)
+	}
+
+	if _, ok := fs.get("testing/logs/1"); !ok {
+		t.Fatalf("Nothing should be deleted when the guard trips.")
+	}
+
+	_, err = cf.DeletePrefix("TEST", "testing", "",
+		&DeleteOptions{Guard: DeleteGuard{RequirePrefix: true}})
+	if !errors.Is(err, ErrBlastRadius) {
+		t.Fatalf("An empty prefix should be refused: %v", err)
+	}
+
+	report, err := cf.DeletePrefix("TEST", "testing", "logs/",
+		&DeleteOptions{Guard: DeleteGuard{MaxObjects: 2, MaxBytes: 6}})
+	if err != nil || report.Succeeded != 2 {
+		t.Fatalf("Could not delete prefix: %+v %v", report, err)
+	}
+
+	if _, ok := fs.get("testing/keep"); !ok {
+		t.Fatalf("Objects outside the prefix should be kept.")
+	}
+}
+
+// A transport uploading more objects when DeletePrefix deletes its first,
+// after the guard's first pass.
+type growingTransport struct {
+	fs      *fakeSwift
+	deletes int
+}
+
+func (gt *growingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == "DELETE" {
+		gt.deletes++
+		if gt.deletes == 1 {
+			gt.fs.put("testing/logs/3", []byte("three"))
+			gt.fs.put("testing/logs/4", []byte("four"))
+		}
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDeletePrefixGuardHoldsWhileDeleting(t *testing.T) {
+	// Test objects uploaded after the guard's check do not take the delete
+	// past its limit.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.SetTransport(&growingTransport{fs: fs})
+
+	fs.put("testing/logs/1", []byte("one"))
+	fs.put("testing/logs/2", []byte("two"))
+
+	report, err := cf.DeletePrefix("TEST", "testing", "logs/",
+		&DeleteOptions{Guard: DeleteGuard{MaxObjects: 3}})
+	if !errors.Is(err, ErrBlastRadius) || report == nil || report.Succeeded != 3 {
+		t.Fatalf("Expected the delete to stop at the guard: %+v %v", report, err)
+	}
+	if _, ok := fs.get("testing/logs/4"); !ok {
+		t.Fatalf("The object over the guard should be kept.")
+	}
+}
+
+func TestDeleteFile(t *testing.T) {
+	// Test a missing object is told apart and segments go on request.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	if err := cf.DeleteFile("TEST", "testing", "gone"); !errors.Is(err, ErrObjectMissing) {
+		t.Fatalf("Expected a missing object: %v", err)
+	}
+
+	data := []byte("0123456789abcdef")
+	for _, name := range []string{"kept", "cleaned"} {
+		if _, err := cf.UploadFile("TEST", "testing", name, bytes.NewReader(data), int64(len(data)),
+			&UploadFileOptions{SegmentSize: 8}); err != nil {
+			t.Fatalf("Could not upload %s: %s", name, err)
+		}
+	}
+
+	if err := cf.DeleteFile("TEST", "testing", "kept"); err != nil {
+		t.Fatalf("Could not delete: %s", err)
+	}
+	if _, ok := fs.get("testing/kept/.segments/00000000"); !ok {
+		t.Fatalf("Segments should be kept by default.")
+	}
+
+	err := cf.DeleteFileWithOptions("TEST", "testing", "cleaned", &DeleteFileOptions{Segments: true})
+	if err != nil {
+		t.Fatalf("Could not delete with segments: %s", err)
+	}
+	for path := range fs.objects {
+		if strings.HasPrefix(path, "testing/cleaned") {
+			t.Fatalf("%s left behind", path)
+		}
+	}
+}
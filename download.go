@@ -0,0 +1,229 @@
+package gocloudfiles
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DownloadOptions tune downloads of large objects.
+type DownloadOptions struct {
+	// How many times a failed segment is retried before giving up.
+	Retries int
+	// How long to wait before retrying a segment.
+	RetryDelay time.Duration
+	// Decompress objects stored with Content-Encoding: gzip as they are
+	// written out.  Segments are still checked against their manifest
+	// hashes as stored, before decompression.
+	Decompress bool
+	// DecryptionKey is the WriteOptions.EncryptionKey of encrypted
+	// objects.
+	DecryptionKey []byte
+}
+
+var DefaultDownloadOptions = DownloadOptions{
+	Retries:    3,
+	RetryDelay: time.Second,
+}
+
+// A segment as listed by ?multipart-manifest=get.
+type sloSegment struct {
+	Name   string `json:"name"`
+	Hash   string `json:"hash"`
+	Bytes  int64  `json:"bytes"`
+	SubSLO bool   `json:"sub_slo"`
+}
+
+func (segment sloSegment) location() (string, string) {
+	/*
+		Split "/container/object" into its container and object.
+	*/
+	parts := strings.SplitN(strings.TrimPrefix(segment.Name, "/"), "/", 2)
+	if len(parts) != 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func (segment sloSegment) item() manifestItem {
+	/*
+		The manifest entry that references this segment again.
+	*/
+	return manifestItem{
+		Path: strings.TrimPrefix(segment.Name, "/"),
+		ETag: segment.Hash,
+		Size: segment.Bytes,
+	}
+}
+
+func (cf CloudFiles) getManifest(dc, bucket, filename string) ([]sloSegment, error) {
+	/*
+		Fetch the segment list of a static large object.
+	*/
+	url, err := cf.objectURL(dc, bucket, filename)
+	if err != nil {
+		return nil, err
+	}
+	url += "?multipart-manifest=get"
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("X-Auth-Token", cf.token())
+	acceptGzip(req)
+	resp, err := cf.do(dc, req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if !succeeded(resp) {
+		return nil, newCloudFilesError(resp, "Could not fetch manifest of %s", filename)
+	}
+
+	if err = decodeBody(resp); err != nil {
+		return nil, err
+	}
+
+	var segments []sloSegment
+	err = json.NewDecoder(resp.Body).Decode(&segments)
+	if err != nil {
+		return nil, err
+	}
+
+	return segments, nil
+}
+
+func isStaticLargeObject(headers http.Header) bool {
+	return strings.EqualFold(headers.Get("X-Static-Large-Object"), "true")
+}
+
+func (cf CloudFiles) DownloadLargeObject(dc, bucket, filename string, out io.Writer,
+	options *DownloadOptions) (int64, error) {
+	/*
+		Download an object to out.  Static large objects are fetched
+		segment by segment, so a failed segment is retried on its own
+		rather than restarting the whole download.  Other objects are
+		downloaded with a single GET.  Objects the client compressed or
+		encrypted are decoded, and with Decompress, gzip encoded objects
+		are decompressed; the size is then of the decoded data.
+		Returns a tuple of bytes written, error
+	*/
+	if options == nil {
+		options = &DefaultDownloadOptions
+	}
+
+	headers, err := cf.headObject(dc, bucket, filename)
+	if err != nil {
+		return 0, err
+	}
+
+	var decoder *decodeWriter
+	if transforms := headers.Get(transformHeader); transforms != "" {
+		if err := checkTransforms(transforms, options.DecryptionKey); err != nil {
+			return 0, err
+		}
+		decoder = newDecodeWriter(out, func(data io.Reader) (io.Reader, error) {
+			return decodeTransforms(data, transforms, options.DecryptionKey)
+		})
+	} else if options.Decompress && strings.EqualFold(headers.Get("Content-Encoding"), "gzip") {
+		decoder = newGunzipWriter(out)
+	} else {
+		return cf.fetchObject(dc, bucket, filename, out, headers, options)
+	}
+
+	_, err = cf.fetchObject(dc, bucket, filename, decoder, headers, options)
+	size, finishErr := decoder.finish()
+	if err == nil {
+		err = finishErr
+	}
+	return size, err
+}
+
+func (cf CloudFiles) fetchObject(dc, bucket, filename string, out io.Writer, headers http.Header,
+	options *DownloadOptions) (int64, error) {
+	if !isStaticLargeObject(headers) {
+		size, _, err := cf.GetChunk(dc, bucket, filename, out, 0, 0)
+		return size, err
+	}
+
+	return cf.downloadSegments(dc, bucket, filename, out, options)
+}
+
+func (cf CloudFiles) downloadSegments(dc, bucket, filename string, out io.Writer,
+	options *DownloadOptions) (int64, error) {
+	segments, err := cf.getManifest(dc, bucket, filename)
+	if err != nil {
+		return 0, err
+	}
+
+	written := int64(0)
+	for _, segment := range segments {
+		container, name := segment.location()
+
+		var size int64
+		if segment.SubSLO {
+			size, err = cf.downloadSegments(dc, container, name, out, options)
+		} else {
+			size, err = cf.downloadSegment(dc, container, name, segment.Hash, out, options)
+		}
+
+		written += size
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+func (cf CloudFiles) downloadSegment(dc, bucket, filename, hash string, out io.Writer,
+	options *DownloadOptions) (int64, error) {
+	/*
+		Stage one segment in a temporary file, retrying until the MD5 of
+		the bytes received matches the manifest, then copy it to out.
+	*/
+	tmpFile, err := ioutil.TempFile("", "")
+	if err != nil {
+		return 0, err
+	}
+
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	for attempt := 0; ; attempt++ {
+		tmpFile.Truncate(0)
+		tmpFile.Seek(0, 0)
+
+		received := md5.New()
+		_, _, err := cf.GetChunk(dc, bucket, filename, io.MultiWriter(tmpFile, received), 0, 0)
+		sum := hex.EncodeToString(received.Sum(nil))
+		if err == nil && hash != "" && !sameETag(sum, hash) {
+			err = fmt.Errorf("Segment %s/%s md5 %s does not match manifest %s.", bucket, filename, sum, hash)
+		}
+
+		if err == nil {
+			break
+		}
+
+		if attempt >= options.Retries {
+			return 0, err
+		}
+
+		time.Sleep(options.RetryDelay)
+	}
+
+	tmpFile.Seek(0, 0)
+	return io.Copy(out, tmpFile)
+}
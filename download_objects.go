@@ -0,0 +1,263 @@
+package gocloudfiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Progress event reports how many bytes of an object have been written
+// so far.
+type Progress struct {
+	Name  string
+	Bytes int64
+}
+
+// DownloadObjectsOptions tune DownloadObjects.
+type DownloadObjectsOptions struct {
+	// Number of objects downloaded at once, defaults to 5.
+	Concurrency int
+
+	// Download tunes each object's DownloadLargeObject.
+	Download *DownloadOptions
+
+	// Results receives one Result per object when set.
+	Results chan<- Result
+
+	// Progress receives events as object data is written when set.  It
+	// must be drained or downloads will stall.
+	Progress chan<- Progress
+
+	// PortableNames writes each object under EncodeLocalName of its
+	// name, so names with characters Windows refuses restore everywhere.
+	// It is always on when running on Windows, where paths past MAX_PATH
+	// are opened with the \\?\ prefix.
+	PortableNames bool
+
+	// SkipUnchanged skips objects whose etag matches the one recorded in
+	// a sidecar file when the local copy was written, as long as the local
+	// file has not been modified since.
+	SkipUnchanged bool
+}
+
+// Recorded next to each downloaded file as ".<name>.etag".
+type downloadSidecar struct {
+	ETag    string    `json:"etag"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+func sidecarPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".etag")
+}
+
+func readSidecar(path string) (*downloadSidecar, bool) {
+	/*
+		Load the sidecar of a local file, only returning it if the file
+		still looks exactly like it did when the sidecar was written.
+	*/
+	data, err := ioutil.ReadFile(sidecarPath(path))
+	if err != nil {
+		return nil, false
+	}
+
+	sidecar := &downloadSidecar{}
+	if json.Unmarshal(data, sidecar) != nil {
+		return nil, false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != sidecar.Size || !info.ModTime().Equal(sidecar.ModTime) {
+		return nil, false
+	}
+
+	return sidecar, true
+}
+
+func writeSidecar(path, etag string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(downloadSidecar{
+		ETag:    etag,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(sidecarPath(path), data, 0644)
+}
+
+type progressWriter struct {
+	file     *os.File
+	name     string
+	written  int64
+	progress chan<- Progress
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.file.Write(p)
+	pw.written += int64(n)
+	if pw.progress != nil && n > 0 {
+		pw.progress <- Progress{Name: pw.name, Bytes: pw.written}
+	}
+	return n, err
+}
+
+func localPath(destDir, name string) (string, error) {
+	/*
+		Map an object name to a path under destDir, refusing names that
+		would escape it.
+	*/
+	path := filepath.Join(destDir, filepath.FromSlash(name))
+
+	rel, err := filepath.Rel(destDir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("Object name %q does not map to a path inside %s.", name, destDir)
+	}
+
+	return path, nil
+}
+
+func (cf CloudFiles) DownloadObjects(dc, bucket string, names []string, destDir string,
+	options *DownloadObjectsOptions) (*Report, error) {
+	/*
+		Download a set of objects into destDir with a pool of workers.
+		Object names containing "/" become subdirectories.  Each file is
+		written under a temporary name and renamed into place once
+		complete.
+	*/
+	if options == nil {
+		options = &DownloadObjectsOptions{}
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	report := cf.newReport()
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	work := make(chan string)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				result := cf.downloadObject(dc, bucket, name, destDir, options)
+
+				mutex.Lock()
+				report.record(options.Results, result)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, name := range names {
+		mutex.Lock()
+		aborted := report.Aborted
+		mutex.Unlock()
+		if aborted {
+			break
+		}
+		work <- name
+	}
+	close(work)
+	wg.Wait()
+
+	return report, report.Err()
+}
+
+func (cf CloudFiles) downloadObject(dc, bucket, name, destDir string,
+	options *DownloadObjectsOptions) Result {
+	result := Result{
+		Op:     "download",
+		DC:     dc,
+		Bucket: bucket,
+		Name:   name,
+		Status: ResultFailure,
+	}
+
+	localName := name
+	if options.PortableNames || runtime.GOOS == "windows" {
+		localName = EncodeLocalName(name)
+	}
+
+	path, err := localPath(destDir, localName)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	path = longPath(path)
+
+	etag := ""
+	if options.SkipUnchanged {
+		info, err := cf.statObject(dc, bucket, name)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+
+		etag = info.ETag
+		if sidecar, ok := readSidecar(path); ok && sameETag(sidecar.ETag, etag) {
+			result.Status = ResultSkipped
+			result.Reason = "local copy is unchanged"
+			return result
+		}
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	tmpPath := path + ".partial"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	writer := &progressWriter{file: file, name: name, progress: options.Progress}
+	size, err := cf.DownloadLargeObject(dc, bucket, name, writer, options.Download)
+
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+
+	if err != nil {
+		os.Remove(tmpPath)
+		result.Err = err
+		return result
+	}
+
+	if options.SkipUnchanged {
+		err = writeSidecar(path, etag)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+	}
+
+	result.Status = ResultSuccess
+	result.Bytes = size
+	return result
+}
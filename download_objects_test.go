@@ -0,0 +1,82 @@
+package gocloudfiles
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadObjects(t *testing.T) {
+	// Test a set of objects is downloaded with a result for each.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("testing/a.txt", []byte("alpha"))
+	fs.put("testing/dir/b.txt", []byte("beta"))
+
+	destDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(destDir)
+
+	progress := make(chan Progress, 100)
+	report, err := cf.DownloadObjects("TEST", "testing",
+		[]string{"a.txt", "dir/b.txt", "missing.txt", "../escape"}, destDir,
+		&DownloadObjectsOptions{Concurrency: 2, Progress: progress})
+
+	if report.Succeeded != 2 || report.Failed != 2 || err == nil {
+		t.Fatalf("Unexpected report: %+v %v", report, err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(destDir, "dir", "b.txt"))
+	if err != nil || string(data) != "beta" {
+		t.Fatalf("Unexpected download %q %v", data, err)
+	}
+
+	if len(progress) == 0 {
+		t.Fatalf("Expected progress events.")
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "missing.txt.partial")); !os.IsNotExist(err) {
+		t.Fatalf("Failed downloads should not leave partial files.")
+	}
+}
+
+func TestDownloadObjectsSkipUnchanged(t *testing.T) {
+	// Test repeated downloads only fetch objects that changed.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("testing/a.txt", []byte("alpha"))
+	fs.put("testing/b.txt", []byte("beta"))
+
+	destDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(destDir)
+
+	names := []string{"a.txt", "b.txt"}
+	options := &DownloadObjectsOptions{SkipUnchanged: true}
+
+	report, err := cf.DownloadObjects("TEST", "testing", names, destDir, options)
+	if err != nil || report.Succeeded != 2 {
+		t.Fatalf("First download should fetch everything: %+v %v", report, err)
+	}
+
+	fs.put("testing/b.txt", []byte("beta two"))
+
+	report, err = cf.DownloadObjects("TEST", "testing", names, destDir, options)
+	if err != nil || report.Succeeded != 1 || report.Skipped != 1 {
+		t.Fatalf("Second download should only fetch the change: %+v %v", report, err)
+	}
+
+	data, _ := ioutil.ReadFile(filepath.Join(destDir, "b.txt"))
+	if string(data) != "beta two" {
+		t.Fatalf("Changed object was not downloaded: %q", data)
+	}
+}
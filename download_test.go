@@ -0,0 +1,86 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDownloadLargeObjectRetriesSegment(t *testing.T) {
+	// Test a failing segment is retried without refetching the others.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("testing/big.bin-0", []byte("first "))
+	fs.put("testing/big.bin-1", []byte("second"))
+
+	err := cf.putManifest("TEST", "testing", "big.bin", manifestList{
+		{Path: "testing/big.bin-0"},
+		{Path: "testing/big.bin-1"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Could not put manifest: %s", err)
+	}
+
+	fs.fail("testing/big.bin-1", 2)
+
+	var out bytes.Buffer
+	size, err := cf.DownloadLargeObject("TEST", "testing", "big.bin", &out,
+		&DownloadOptions{Retries: 2})
+	if err != nil {
+		t.Fatalf("Could not download: %s", err)
+	}
+
+	if size != 12 || out.String() != "first second" {
+		t.Fatalf("Unexpected download %d %q", size, out.String())
+	}
+
+	fs.fail("testing/big.bin-1", 3)
+	out.Reset()
+
+	_, err = cf.DownloadLargeObject("TEST", "testing", "big.bin", &out,
+		&DownloadOptions{Retries: 2})
+	if err == nil {
+		t.Fatalf("Download should fail once retries are exhausted.")
+	}
+}
+
+func TestDownloadLargeObjectChecksReceivedBytes(t *testing.T) {
+	// Test a segment damaged in transit is retried even though its etag header matches.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("testing/big.bin-0", []byte("first "))
+	fs.put("testing/big.bin-1", []byte("second"))
+
+	err := cf.putManifest("TEST", "testing", "big.bin", manifestList{
+		{Path: "testing/big.bin-0"},
+		{Path: "testing/big.bin-1"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Could not put manifest: %s", err)
+	}
+
+	fs.mutex.Lock()
+	fs.corruptions["testing/big.bin-1"] = 1
+	fs.mutex.Unlock()
+
+	var out bytes.Buffer
+	_, err = cf.DownloadLargeObject("TEST", "testing", "big.bin", &out,
+		&DownloadOptions{Retries: 1})
+	if err != nil || out.String() != "first second" {
+		t.Fatalf("Damaged segment should be retried: %q %v", out.String(), err)
+	}
+
+	fs.mutex.Lock()
+	fs.corruptions["testing/big.bin-1"] = 2
+	fs.mutex.Unlock()
+	out.Reset()
+
+	_, err = cf.DownloadLargeObject("TEST", "testing", "big.bin", &out,
+		&DownloadOptions{Retries: 1})
+	if err == nil {
+		t.Fatalf("Download should fail when every attempt is damaged.")
+	}
+}
@@ -0,0 +1,133 @@
+package gocloudfiles
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// A PlannedRequest is a request a dry run would have sent.
+type PlannedRequest struct {
+	Region  string
+	Method  string
+	URL     string
+	Headers http.Header
+	Bytes   int64
+}
+
+// A Plan records the requests of a dry run, see SetDryRun.
+type Plan struct {
+	// SendReads sends GET and HEAD requests for real so operations can
+	// look at what exists; they are recorded too.  Without it reads get
+	// an empty 404.
+	SendReads bool
+
+	mutex    sync.Mutex
+	requests []PlannedRequest
+}
+
+func (cf *CloudFiles) SetDryRun(plan *Plan) {
+	/*
+		Record storage requests in plan instead of sending them.  Requests
+		that change something are answered as if they succeeded, so any
+		operation runs to the end and plan lists what it would have done.
+		A nil plan sends requests again.
+	*/
+	cf.dryRun = plan
+}
+
+func (plan *Plan) Requests() []PlannedRequest {
+	/*
+		The requests recorded so far, in the order they were made.
+	*/
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+
+	return append([]PlannedRequest(nil), plan.requests...)
+}
+
+func (plan *Plan) String() string {
+	/*
+		One line per request, e.g. "PUT https://.../bucket/file (12 bytes)".
+	*/
+	var lines []string
+	for _, request := range plan.Requests() {
+		line := request.Method + " " + request.URL
+		if request.Bytes > 0 {
+			line += fmt.Sprintf(" (%d bytes)", request.Bytes)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (plan *Plan) sends(req *http.Request) bool {
+	return plan.SendReads && (req.Method == "GET" || req.Method == "HEAD")
+}
+
+func (plan *Plan) record(dc string, req *http.Request) {
+	headers := req.Header.Clone()
+	if headers.Get("X-Auth-Token") != "" {
+		headers.Set("X-Auth-Token", "redacted")
+	}
+
+	plan.mutex.Lock()
+	plan.requests = append(plan.requests, PlannedRequest{
+		Region:  dc,
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: headers,
+	})
+	plan.mutex.Unlock()
+}
+
+func (plan *Plan) respond(dc string, req *http.Request) (*http.Response, error) {
+	/*
+		Record a request that is not sent and make up the response a
+		successful one would get.
+	*/
+	plan.record(dc, req)
+
+	hash := md5.New()
+	size := int64(0)
+	if req.Body != nil {
+		var err error
+		size, err = io.Copy(hash, req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	plan.mutex.Lock()
+	plan.requests[len(plan.requests)-1].Bytes = size
+	plan.mutex.Unlock()
+
+	resp := &http.Response{
+		StatusCode: 404,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}
+
+	switch req.Method {
+	case "PUT", "COPY":
+		resp.StatusCode = 201
+		resp.Header.Set("Etag", hex.EncodeToString(hash.Sum(nil)))
+		if etag := req.Header.Get("ETag"); etag != "" {
+			resp.Header.Set("Etag", etag)
+		}
+	case "POST":
+		resp.StatusCode = 202
+	case "DELETE":
+		resp.StatusCode = 204
+	}
+	resp.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+
+	return resp, nil
+}
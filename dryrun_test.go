@@ -0,0 +1,49 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDryRunRecordsWrites(t *testing.T) {
+	// Test a dry run copy reads the source but writes nothing.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("src/file.bin", []byte("planned data"))
+
+	plan := &Plan{SendReads: true}
+	cf.SetDryRun(plan)
+
+	if err := cf.CopyFile("TEST", "src", "file.bin", "TEST", "dst", "file.bin"); err != nil {
+		t.Fatalf("Dry run copy should succeed: %s", err)
+	}
+
+	if _, ok := fs.get("dst/file.bin"); ok {
+		t.Fatalf("A dry run should not write the destination.")
+	}
+
+	methods := map[string]int{}
+	for _, request := range plan.Requests() {
+		methods[request.Method]++
+		if request.Headers.Get("X-Auth-Token") != "redacted" {
+			t.Fatalf("Tokens should not be recorded: %+v", request.Headers)
+		}
+	}
+
+	if methods["HEAD"] == 0 || methods["PUT"] == 0 {
+		t.Fatalf("Expected reads and the copy:\n%s", plan)
+	}
+
+	cf.SetDryRun(nil)
+	cf.PutFile("TEST", "dst", "real.txt", bytes.NewReader([]byte("real")))
+	if _, ok := fs.get("dst/real.txt"); !ok {
+		t.Fatalf("Requests should be sent once the dry run is off.")
+	}
+
+	if !strings.Contains(plan.String(), "PUT ") {
+		t.Fatalf("Plan should list its writes:\n%s", plan)
+	}
+}
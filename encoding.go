@@ -0,0 +1,100 @@
+package gocloudfiles
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (gb *gzipBody) Close() error {
+	gb.Reader.Close()
+	return gb.body.Close()
+}
+
+func acceptGzip(req *http.Request) {
+	/*
+		Ask for a compressed response.  Only used for metadata such as
+		listings and manifests, which compress well; object bodies are never
+		compressed in transit so their etags stay meaningful.
+	*/
+	req.Header.Set("Accept-Encoding", "gzip")
+}
+
+func decodeBody(resp *http.Response) error {
+	/*
+		Transparently decompress a response requested with acceptGzip.
+	*/
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return nil
+	}
+
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	resp.Body = &gzipBody{Reader: reader, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	return nil
+}
+
+// Decodes the data written to it into out, for objects stored compressed
+// or encrypted.
+type decodeWriter struct {
+	pipe    *io.PipeWriter
+	done    chan bool
+	written int64
+	err     error
+}
+
+func newGunzipWriter(out io.Writer) *decodeWriter {
+	/*
+		Decompress the gzip data written, for objects stored with
+		Content-Encoding: gzip.
+	*/
+	return newDecodeWriter(out, func(data io.Reader) (io.Reader, error) {
+		return gzip.NewReader(data)
+	})
+}
+
+func newDecodeWriter(out io.Writer, decode func(io.Reader) (io.Reader, error)) *decodeWriter {
+	reader, writer := io.Pipe()
+	dw := &decodeWriter{pipe: writer, done: make(chan bool)}
+
+	go func() {
+		defer close(dw.done)
+
+		decoded, err := decode(reader)
+		if err == nil {
+			dw.written, err = io.Copy(out, decoded)
+		}
+
+		// Unblock the writer if decoding stopped early.
+		dw.err = err
+		reader.CloseWithError(err)
+	}()
+
+	return dw
+}
+
+func (dw *decodeWriter) Write(p []byte) (int, error) {
+	return dw.pipe.Write(p)
+}
+
+func (dw *decodeWriter) finish() (int64, error) {
+	/*
+		Wait for everything written to be decoded.
+		Returns a tuple of decoded bytes, error
+	*/
+	dw.pipe.Close()
+	<-dw.done
+	return dw.written, dw.err
+}
@@ -0,0 +1,125 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGzipListingOnly(t *testing.T) {
+	// Test listings are fetched compressed and object data never is.
+	var listingEncoding, objectEncoding string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/testing" {
+			listingEncoding = r.Header.Get("Accept-Encoding")
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			if r.URL.Query().Get("marker") == "" {
+				gz.Write([]byte(`[{"name": "a.txt", "bytes": 3}]`))
+			} else {
+				gz.Write([]byte(`[]`))
+			}
+			gz.Close()
+			return
+		}
+
+		objectEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Etag", "etag")
+		w.Write([]byte("abc"))
+	}))
+	defer server.Close()
+
+	cf := NewCloudFilesImpersonation("token")
+	cf.dcs["TEST"] = server.URL
+
+	entries := make([]objectEntry, 0)
+	err := cf.walkObjects("TEST", "testing", "", func(entry objectEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Could not list: %s", err)
+	}
+
+	if len(entries) != 1 || entries[0].Name != "a.txt" || listingEncoding != "gzip" {
+		t.Fatalf("Unexpected listing %+v with encoding %q", entries, listingEncoding)
+	}
+
+	_, _, err = cf.GetChunk("TEST", "testing", "a.txt", ioutil.Discard, 0, 0)
+	if err != nil {
+		t.Fatalf("Could not get object: %s", err)
+	}
+
+	if objectEncoding != "identity" {
+		t.Fatalf("Object data should not be compressed, got %q", objectEncoding)
+	}
+}
+
+func gzipped(data string) []byte {
+	var out bytes.Buffer
+	gz := gzip.NewWriter(&out)
+	gz.Write([]byte(data))
+	gz.Close()
+	return out.Bytes()
+}
+
+func TestDownloadDecompressesEncodedObjects(t *testing.T) {
+	// Test objects stored with Content-Encoding: gzip download decompressed on request.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	stored := gzipped("hello gzip")
+	_, err := cf.PutFileWithOptions("TEST", "testing", "a.txt", bytes.NewReader(stored),
+		&WriteOptions{Headers: map[string]string{"Content-Encoding": "gzip"}})
+	if err != nil {
+		t.Fatalf("Could not put file: %s", err)
+	}
+
+	info, err := cf.statObject("TEST", "testing", "a.txt")
+	if err != nil || info.ContentEncoding != "gzip" {
+		t.Fatalf("Encoding should be reported: %+v %v", info, err)
+	}
+
+	var out bytes.Buffer
+	size, err := cf.DownloadLargeObject("TEST", "testing", "a.txt", &out, nil)
+	if err != nil || !bytes.Equal(out.Bytes(), stored) || size != int64(len(stored)) {
+		t.Fatalf("Object should download as stored by default: %d %v", size, err)
+	}
+
+	out.Reset()
+	size, err = cf.DownloadLargeObject("TEST", "testing", "a.txt", &out, &DownloadOptions{Decompress: true})
+	if err != nil || out.String() != "hello gzip" || size != 10 {
+		t.Fatalf("Object should be decompressed: %q %d %v", out.String(), size, err)
+	}
+
+	// Segments are verified as stored and decompressed as one stream.
+	fs.put("testing/big.gz-0", gzipped("first "))
+	fs.put("testing/big.gz-1", gzipped("second"))
+	err = cf.putManifest("TEST", "testing", "big.gz", manifestList{
+		{Path: "testing/big.gz-0"},
+		{Path: "testing/big.gz-1"},
+	}, &WriteOptions{Headers: map[string]string{"Content-Encoding": "gzip"}})
+	if err != nil {
+		t.Fatalf("Could not put manifest: %s", err)
+	}
+
+	out.Reset()
+	_, err = cf.DownloadLargeObject("TEST", "testing", "big.gz", &out, &DownloadOptions{Decompress: true})
+	if err != nil || out.String() != "first second" {
+		t.Fatalf("Large object should be decompressed: %q %v", out.String(), err)
+	}
+
+	fs.put("testing/bad.txt", []byte("not gzip"))
+	fs.mutex.Lock()
+	fs.headers["testing/bad.txt"] = http.Header{"Content-Encoding": {"gzip"}}
+	fs.mutex.Unlock()
+	if _, err := cf.DownloadLargeObject("TEST", "testing", "bad.txt", ioutil.Discard,
+		&DownloadOptions{Decompress: true}); err == nil {
+		t.Fatalf("Invalid gzip data should fail.")
+	}
+}
@@ -0,0 +1,143 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"time"
+)
+
+// How much of the source Estimate downloads and uploads to measure
+// throughput.
+const estimateSampleSize = int64(8 * 1024 * 1024)
+
+// A TransferEstimate projects the cost of copying an object with CopyFile.
+type TransferEstimate struct {
+	Size        int64
+	Chunks      int64
+	SampleBytes int64
+	// Measured single stream download rate from the source in bytes per
+	// second.
+	Throughput float64
+	// Measured single stream upload rate to the destination in bytes per
+	// second.
+	UploadThroughput float64
+	Duration         time.Duration
+	Requests         int64
+}
+
+func (cf CloudFiles) Estimate(sourceDC, sourceBucket, sourceFile,
+	destDC, destBucket, destFile string, options *CopyOptions) (*TransferEstimate, error) {
+	/*
+		HEAD the source, time a small ranged download of it and time an
+		upload of that sample to the destination to project how long
+		CopyFileWithOptions would take and how many requests it would make.
+		The sample is written as a scratch object below the destination's
+		segment prefix and deleted again.
+	*/
+	if options == nil {
+		options = &CopyOptions{}
+	}
+
+	source, err := cf.statObject(sourceDC, sourceBucket, sourceFile)
+	if err != nil {
+		return nil, err
+	}
+	size := source.Bytes
+
+	chunkSize := options.chunkSize()
+	concurrency := int64(options.concurrency())
+
+	estimate := &TransferEstimate{
+		Size:   size,
+		Chunks: (size + chunkSize - 1) / chunkSize,
+	}
+
+	estimate.Requests = copyRequests(size, chunkSize, source.StaticLargeObject,
+		sourceDC == destDC && !options.WriteChecksums)
+	if options.WriteChecksums {
+		estimate.Requests++
+	}
+	// Cleanup reads the destination's previous manifest once.
+	if options.RemoveStaleTransfers || options.StaleSegments != KeepStaleSegments {
+		estimate.Requests += 2
+	}
+	// And reads the new one back before deleting what it dropped.
+	if options.StaleSegments == DeleteUnreferencedSegments {
+		estimate.Requests += 2
+	}
+
+	sample := estimateSampleSize
+	if sample > size {
+		sample = size
+	}
+
+	if sample == 0 {
+		return estimate, nil
+	}
+
+	release := cf.reserveMemory(sample)
+	defer release()
+
+	var buffer bytes.Buffer
+
+	start := time.Now()
+	sampled, _, err := cf.GetChunk(sourceDC, sourceBucket, sourceFile, &buffer, 0, sample)
+	if err != nil {
+		return nil, err
+	}
+	downloaded := time.Since(start)
+
+	scratch := destFile + segmentDir + "estimate_" + NewTransferID()
+
+	start = time.Now()
+	_, err = cf.PutFile(destDC, destBucket, scratch, bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	uploaded := time.Since(start)
+
+	err = cf.deleteObject(destDC, destBucket, scratch)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate.SampleBytes = sampled
+	if downloaded <= 0 || uploaded <= 0 || sampled == 0 {
+		return estimate, nil
+	}
+
+	estimate.Throughput = float64(sampled) / downloaded.Seconds()
+	estimate.UploadThroughput = float64(sampled) / uploaded.Seconds()
+
+	streams := concurrency
+	if estimate.Chunks < streams {
+		streams = estimate.Chunks
+	}
+
+	// Every byte is downloaded and then uploaded by one of the streams.
+	seconds := float64(size)/(estimate.Throughput*float64(streams)) +
+		float64(size)/(estimate.UploadThroughput*float64(streams))
+	estimate.Duration = time.Duration(seconds * float64(time.Second))
+
+	return estimate, nil
+}
+
+func copyRequests(size, chunkSize int64, largeObject, serverSide bool) int64 {
+	/*
+		The requests CopyFileWithOptions makes for an object: a HEAD of the
+		source and of the destination container, a listing of the segments
+		already there, then a GET and PUT for every chunk and finally the
+		manifest.  Objects smaller than a chunk are copied by the server
+		when serverSide, or with one GET, HEAD and PUT without a manifest,
+		and empty objects with a single PUT.
+	*/
+	switch {
+	case size == 0:
+		return 2 + 1
+	case size >= chunkSize || largeObject:
+		return 2 + 1 + 2*((size+chunkSize-1)/chunkSize) + 1
+	case serverSide:
+		return 2 + 1
+	default:
+		return 2 + 3
+	}
+}
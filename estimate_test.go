@@ -0,0 +1,47 @@
+package gocloudfiles
+
+import (
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	// Test an estimate counts requests and measures a throughput.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("src/file.bin", make([]byte, 1000))
+
+	estimate, err := cf.Estimate("TEST", "src", "file.bin", "TEST", "dst", "file.bin",
+		&CopyOptions{WriteChecksums: true})
+	if err != nil {
+		t.Fatalf("Could not estimate: %s", err)
+	}
+
+	if estimate.Size != 1000 || estimate.Chunks != 1 || estimate.SampleBytes != 1000 {
+		t.Fatalf("Unexpected estimate: %+v", estimate)
+	}
+
+	// A small object is copied without a manifest.
+	if estimate.Requests != 6 {
+		t.Fatalf("Expected 6 requests but got %d", estimate.Requests)
+	}
+
+	if estimate.Throughput <= 0 || estimate.UploadThroughput <= 0 || estimate.Duration <= 0 {
+		t.Fatalf("Expected throughputs and a duration: %+v", estimate)
+	}
+
+	// Without checksums the server copies it.
+	estimate, err = cf.Estimate("TEST", "src", "file.bin", "TEST", "dst", "file.bin", nil)
+	if err != nil || estimate.Requests != 3 {
+		t.Fatalf("Expected 3 requests for a server side copy: %+v %v", estimate, err)
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	for path := range fs.objects {
+		if path != "src/file.bin" {
+			t.Fatalf("The upload sample %s should be deleted.", path)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package gocloudfiles
+
+import (
+	"strings"
+)
+
+// An ETag as reported by the server.  Plain objects report the MD5 of their
+// data, but static large objects report a quoted composite etag, the MD5 of
+// their segments' etags, so the raw strings of equal objects can differ in
+// quoting and case.
+type ETag struct {
+	// Value is the etag without quotes, in lower case.
+	Value string
+	// Composite is set for etags that are not the MD5 of the object's
+	// data, such as those of large objects.
+	Composite bool
+}
+
+func ParseETag(raw string) ETag {
+	/*
+		Normalize an etag from a header, listing or manifest.  Quoted and
+		multipart ("<md5>-<parts>") etags are marked composite.
+	*/
+	value := strings.TrimSpace(raw)
+	value = strings.TrimPrefix(value, "W/")
+
+	composite := false
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = value[1 : len(value)-1]
+		composite = true
+	}
+	if strings.Contains(value, "-") {
+		composite = true
+	}
+
+	return ETag{Value: strings.ToLower(value), Composite: composite}
+}
+
+func (e ETag) Equal(other ETag) bool {
+	/*
+		Whether two etags describe the same object.  Only the values are
+		compared, since a listing reports a large object's composite etag
+		without the quotes that mark it in headers.  Empty etags equal
+		nothing.
+	*/
+	return e.Value != "" && e.Value == other.Value
+}
+
+func (e ETag) String() string {
+	return e.Value
+}
+
+func sameETag(a, b string) bool {
+	return ParseETag(a).Equal(ParseETag(b))
+}
@@ -0,0 +1,68 @@
+package gocloudfiles
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestParseETag(t *testing.T) {
+	// Test etags normalize quoting and case and mark composite ones.
+	cases := []struct {
+		raw       string
+		value     string
+		composite bool
+	}{
+		{"d41d8cd98f00b204e9800998ecf8427e", "d41d8cd98f00b204e9800998ecf8427e", false},
+		{`"D41D8CD98F00B204E9800998ECF8427E"`, "d41d8cd98f00b204e9800998ecf8427e", true},
+		{` W/"abc" `, "abc", true},
+		{"abc-3", "abc-3", true},
+		{"", "", false},
+	}
+
+	for _, c := range cases {
+		etag := ParseETag(c.raw)
+		if etag.Value != c.value || etag.Composite != c.composite {
+			t.Fatalf("Unexpected etag %+v for %q", etag, c.raw)
+		}
+	}
+
+	if !ParseETag(`"ABC"`).Equal(ParseETag("abc")) {
+		t.Fatalf("Quoted etag should equal its listing form.")
+	}
+	if ParseETag("").Equal(ParseETag("")) {
+		t.Fatalf("Empty etags should not be equal.")
+	}
+}
+
+func TestCopyFileMatchesQuotedETag(t *testing.T) {
+	// Test a large object's quoted etag still matches an unchanged destination.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	data := []byte("same data")
+	fs.put("dst/file.bin", data)
+	fs.put("src/file.bin", data)
+
+	sum := md5.Sum(data)
+	fs.mutex.Lock()
+	fs.headers["src/file.bin"] = map[string][]string{
+		"Etag": {`"` + strings.ToUpper(hex.EncodeToString(sum[:])) + `"`},
+	}
+	modified := fs.modified["dst/file.bin"]
+	fs.mutex.Unlock()
+
+	err := cf.CopyFileWithOptions("TEST", "src", "file.bin", "TEST", "dst", "file.bin",
+		&CopyOptions{IfExists: OverwriteIfChanged})
+	if err != nil {
+		t.Fatalf("Could not copy file: %s", err)
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if !fs.modified["dst/file.bin"].Equal(modified) {
+		t.Fatalf("Unchanged destination should not be rewritten.")
+	}
+}
@@ -0,0 +1,231 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An Expiration is an object and when the server will delete it.
+type Expiration struct {
+	Name     string
+	Bytes    int64
+	DeleteAt time.Time
+}
+
+// ExpirationOptions tune ListExpiring, ExtendExpirations and
+// CancelExpirations.
+type ExpirationOptions struct {
+	// Prefix limits ListExpiring to the objects beginning with it.
+	Prefix string
+
+	// Within limits ListExpiring to the objects deleted before this long
+	// from now when positive, including those already overdue.
+	Within time.Duration
+
+	// Number of objects looked at or updated at once, defaults to 5.
+	Concurrency int
+
+	// Results receives one Result per object extended or canceled when
+	// set.
+	Results chan<- Result
+}
+
+func (cf CloudFiles) ListExpiring(dc, bucket string, options *ExpirationOptions) ([]Expiration, error) {
+	/*
+		Find the objects of a container that are set to expire, soonest
+		first, so a mistaken TTL is seen before the server acts on it.
+		Listings do not show X-Delete-At, so every object under the prefix
+		is looked at with a HEAD, and the account's hidden expirer queue is
+		not readable with a normal token.  Objects deleted meanwhile are
+		left out.
+		Returns a tuple of expirations, error
+	*/
+	if options == nil {
+		options = &ExpirationOptions{}
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	deadline := time.Time{}
+	if options.Within > 0 {
+		deadline = time.Now().Add(options.Within)
+	}
+
+	var expirations []Expiration
+	var firstErr error
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	work := make(chan string)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				info, err := cf.statObject(dc, bucket, name)
+
+				mutex.Lock()
+				switch {
+				case errors.Is(err, ErrObjectMissing):
+				case err != nil:
+					if firstErr == nil {
+						firstErr = err
+					}
+				case info.DeleteAt.IsZero():
+				case !deadline.IsZero() && info.DeleteAt.After(deadline):
+				default:
+					expirations = append(expirations, Expiration{
+						Name:     name,
+						Bytes:    info.Bytes,
+						DeleteAt: info.DeleteAt,
+					})
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	// Segments expire with their manifest and are not listed on their own.
+	err := cf.walkObjects(dc, bucket, options.Prefix, func(entry objectEntry) error {
+		if !isSegment(entry.Name) {
+			work <- entry.Name
+		}
+		return nil
+	})
+	close(work)
+	wg.Wait()
+
+	if err == nil {
+		err = firstErr
+	}
+
+	sort.Slice(expirations, func(i, j int) bool {
+		if !expirations[i].DeleteAt.Equal(expirations[j].DeleteAt) {
+			return expirations[i].DeleteAt.Before(expirations[j].DeleteAt)
+		}
+		return expirations[i].Name < expirations[j].Name
+	})
+
+	return expirations, err
+}
+
+func (cf CloudFiles) ExtendExpirations(dc, bucket string, names []string, by time.Duration,
+	options *ExpirationOptions) (*Report, error) {
+	/*
+		Push back the expiry of each object by the given duration from its
+		current X-Delete-At.  Objects that are not set to expire are
+		skipped, use PutFileWithOptions to set one.
+		Returns a tuple of report, error
+	*/
+	return cf.updateExpirations(dc, bucket, names, options, func(current time.Time) (time.Time, bool) {
+		if current.IsZero() {
+			return current, false
+		}
+		return current.Add(by), true
+	})
+}
+
+func (cf CloudFiles) CancelExpirations(dc, bucket string, names []string,
+	options *ExpirationOptions) (*Report, error) {
+	/*
+		Remove the expiry of each object so the server keeps it.  Objects
+		that are not set to expire are skipped.
+		Returns a tuple of report, error
+	*/
+	return cf.updateExpirations(dc, bucket, names, options, func(current time.Time) (time.Time, bool) {
+		return time.Time{}, !current.IsZero()
+	})
+}
+
+func (cf CloudFiles) updateExpirations(dc, bucket string, names []string, options *ExpirationOptions,
+	change func(time.Time) (time.Time, bool)) (*Report, error) {
+	if options == nil {
+		options = &ExpirationOptions{}
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	report := cf.newReport()
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	work := make(chan string)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				result := cf.updateExpiration(dc, bucket, name, change)
+
+				mutex.Lock()
+				report.record(options.Results, result)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, name := range names {
+		mutex.Lock()
+		aborted := report.Aborted
+		mutex.Unlock()
+		if aborted {
+			break
+		}
+		work <- name
+	}
+	close(work)
+	wg.Wait()
+
+	return report, report.Err()
+}
+
+func (cf CloudFiles) updateExpiration(dc, bucket, name string, change func(time.Time) (time.Time, bool)) Result {
+	/*
+		Rewrite one object's X-Delete-At.  A POST replaces all of an
+		object's metadata, so the metadata it has is sent again with it.
+	*/
+	result := Result{
+		Op:     "expire",
+		DC:     dc,
+		Bucket: bucket,
+		Name:   name,
+		Status: ResultFailure,
+	}
+
+	headers, err := cf.headObject(dc, bucket, name)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	deleteAt, ok := change(newObjectInfo(name, headers).DeleteAt)
+	if !ok {
+		result.Status = ResultSkipped
+		result.Reason = "not expiring"
+		return result
+	}
+
+	update := postedHeaders(headers)
+	if !deleteAt.IsZero() {
+		update["X-Delete-At"] = strconv.FormatInt(deleteAt.Unix(), 10)
+	}
+
+	if err := cf.updateObject(dc, bucket, name, update); err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Status = ResultSuccess
+	return result
+}
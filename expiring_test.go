@@ -0,0 +1,55 @@
+package gocloudfiles
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpirations(t *testing.T) {
+	// Test expiring objects are listed, extended and canceled.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	soon := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	later := time.Now().Add(48 * time.Hour).Truncate(time.Second).UTC()
+	for name, deleteAt := range map[string]time.Time{"later": later, "soon": soon, "kept": {}} {
+		_, err := cf.PutFileWithOptions("TEST", "testing", name, strings.NewReader(name), &WriteOptions{
+			DeleteAt: deleteAt,
+			Headers:  map[string]string{"X-Object-Meta-Owner": "ops"},
+		})
+		if err != nil {
+			t.Fatalf("Could not upload %s: %s", name, err)
+		}
+	}
+
+	expiring, err := cf.ListExpiring("TEST", "testing", nil)
+	if err != nil || len(expiring) != 2 || expiring[0].Name != "soon" || !expiring[0].DeleteAt.Equal(soon) ||
+		expiring[1].Name != "later" {
+		t.Fatalf("Unexpected expirations %+v %v", expiring, err)
+	}
+
+	expiring, _ = cf.ListExpiring("TEST", "testing", &ExpirationOptions{Within: 2 * time.Hour})
+	if len(expiring) != 1 || expiring[0].Name != "soon" {
+		t.Fatalf("Unexpected expirations within 2h %+v", expiring)
+	}
+
+	report, err := cf.ExtendExpirations("TEST", "testing", []string{"soon", "kept"}, 24*time.Hour, nil)
+	if err != nil || report.Succeeded != 1 || report.Skipped != 1 {
+		t.Fatalf("Unexpected extend report %+v %v", report, err)
+	}
+	if info, _ := cf.statObject("TEST", "testing", "soon"); !info.DeleteAt.Equal(soon.Add(24 * time.Hour)) {
+		t.Fatalf("Expiry not extended: %s", info.DeleteAt)
+	}
+
+	if _, err := cf.CancelExpirations("TEST", "testing", []string{"soon", "later"}, nil); err != nil {
+		t.Fatalf("Could not cancel: %s", err)
+	}
+	if expiring, _ := cf.ListExpiring("TEST", "testing", nil); len(expiring) != 0 {
+		t.Fatalf("Expirations left %+v", expiring)
+	}
+	if header := fs.headers["testing/soon"]; header.Get("X-Object-Meta-Owner") != "ops" {
+		t.Fatalf("Metadata lost: %v", header)
+	}
+}
@@ -0,0 +1,478 @@
+package gocloudfiles
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeSwift is a tiny in memory object store speaking enough of the Swift
+// API to exercise the client without real credentials.
+type fakeSwift struct {
+	mutex    sync.Mutex
+	objects  map[string][]byte
+	headers  map[string]http.Header
+	modified map[string]time.Time
+	// SLO manifests as returned by ?multipart-manifest=get.
+	manifests map[string][]sloSegment
+	// GETs and PUTs of a path fail with a 503 while its count is positive.
+	failures map[string]int
+	// GETs of a path return damaged data under the correct etag while its
+	// count is positive.
+	corruptions map[string]int
+	// Listings return at most this many entries when positive.
+	pageSize int
+	// Containers that do not exist until they are created with a PUT.
+	missing map[string]bool
+	clock   time.Time
+	// Objects whose X-Delete-At is not after expireAt are gone, when it is
+	// set.
+	expireAt time.Time
+	// The /info document, missing when empty.
+	info string
+	// Requests with any other X-Auth-Token are rejected with a 401, when
+	// it is set.
+	token  string
+	server *httptest.Server
+}
+
+func newFakeSwift() *fakeSwift {
+	fs := &fakeSwift{
+		objects:     make(map[string][]byte),
+		headers:     make(map[string]http.Header),
+		modified:    make(map[string]time.Time),
+		manifests:   make(map[string][]sloSegment),
+		failures:    make(map[string]int),
+		corruptions: make(map[string]int),
+		missing:     make(map[string]bool),
+		clock:       time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	fs.server = httptest.NewServer(fs)
+	return fs
+}
+
+func (fs *fakeSwift) Close() {
+	fs.server.Close()
+}
+
+func (fs *fakeSwift) client() *CloudFiles {
+	cf := NewCloudFilesImpersonation("token")
+	cf.dcs["TEST"] = fs.server.URL
+	return cf
+}
+
+func (fs *fakeSwift) put(path string, data []byte) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.store(path, data)
+}
+
+func (fs *fakeSwift) store(path string, data []byte) {
+	// Every write happens a second after the previous one.
+	fs.clock = fs.clock.Add(time.Second)
+	fs.objects[path] = data
+	fs.modified[path] = fs.clock
+}
+
+func (fs *fakeSwift) remove(path string) {
+	delete(fs.objects, path)
+	delete(fs.headers, path)
+	delete(fs.modified, path)
+	delete(fs.manifests, path)
+}
+
+func (fs *fakeSwift) fail(path string, count int) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.failures[path] = count
+}
+
+func (fs *fakeSwift) get(path string) ([]byte, bool) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	data, ok := fs.objects[path]
+	return data, ok
+}
+
+func (fs *fakeSwift) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	parts := strings.SplitN(path, "/", 2)
+
+	if path == "info" && fs.info != "" {
+		w.Write([]byte(fs.info))
+		return
+	}
+
+	if fs.token != "" && r.Header.Get("X-Auth-Token") != fs.token {
+		w.WriteHeader(401)
+		return
+	}
+
+	if path == "" && r.Method == "GET" {
+		fs.serveAccount(w, r)
+		return
+	}
+
+	if len(parts) == 1 {
+		fs.serveContainer(w, r, parts[0])
+		return
+	}
+
+	if fs.missing[parts[0]] {
+		w.WriteHeader(404)
+		return
+	}
+
+	if at, err := strconv.ParseInt(fs.headers[path].Get("X-Delete-At"), 10, 64); err == nil &&
+		!fs.expireAt.IsZero() && at <= fs.expireAt.Unix() {
+		fs.remove(path)
+	}
+
+	switch r.Method {
+	case "HEAD", "GET":
+		data, ok := fs.objects[path]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+
+		if r.URL.Query().Get("multipart-manifest") == "get" && fs.manifests[path] != nil {
+			json.NewEncoder(w).Encode(fs.manifests[path])
+			return
+		}
+
+		if fs.failures[path] > 0 && r.Method == "GET" {
+			fs.failures[path]--
+			w.WriteHeader(503)
+			return
+		}
+
+		sum := md5.Sum(data)
+		w.Header().Set("Etag", hex.EncodeToString(sum[:]))
+		w.Header().Set("Last-Modified", fs.modified[path].Format(http.TimeFormat))
+		for key, values := range fs.headers[path] {
+			w.Header()[key] = values
+		}
+
+		status := 200
+		if rng := r.Header.Get("Range"); rng != "" && len(data) == 0 {
+			w.WriteHeader(416)
+			return
+		} else if rng != "" {
+			var start, end int
+			fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			if end >= len(data) {
+				end = len(data) - 1
+			}
+			data = data[start : end+1]
+			status = 206
+		}
+
+		if fs.corruptions[path] > 0 && r.Method == "GET" && len(data) > 0 {
+			fs.corruptions[path]--
+			damaged := append([]byte(nil), data...)
+			damaged[0] ^= 0xff
+			data = damaged
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == "GET" {
+			w.Write(data)
+		}
+	case "PUT":
+		data, _ := ioutil.ReadAll(r.Body)
+		if fs.failures[path] > 0 {
+			fs.failures[path]--
+			w.WriteHeader(503)
+			return
+		}
+		if _, ok := fs.objects[path]; ok && r.Header.Get("If-None-Match") == "*" {
+			w.WriteHeader(412)
+			return
+		}
+		if etag := r.Header.Get("ETag"); etag != "" {
+			sum := md5.Sum(data)
+			if etag != hex.EncodeToString(sum[:]) {
+				w.WriteHeader(422)
+				return
+			}
+		}
+		manifest := []sloSegment(nil)
+		if source := r.Header.Get("X-Copy-From"); source != "" {
+			sourcePath := strings.TrimPrefix(source, "/")
+			sourceData, ok := fs.objects[sourcePath]
+			if !ok {
+				w.WriteHeader(404)
+				return
+			}
+			data = sourceData
+			if r.URL.Query().Get("multipart-manifest") == "get" {
+				manifest = fs.manifests[sourcePath]
+			}
+			for key, values := range fs.headers[sourcePath] {
+				if _, ok := r.Header[key]; !ok && key != "X-Delete-At" {
+					r.Header[key] = values
+				}
+			}
+		}
+		if r.URL.Query().Get("multipart-manifest") == "put" {
+			var items []manifestItem
+			json.Unmarshal(data, &items)
+			joined := make([]byte, 0)
+			for _, item := range items {
+				segment := fs.objects[item.Path]
+				joined = append(joined, segment...)
+				sum := md5.Sum(segment)
+				manifest = append(manifest, sloSegment{
+					Name:  "/" + item.Path,
+					Hash:  hex.EncodeToString(sum[:]),
+					Bytes: int64(len(segment)),
+				})
+			}
+			data = joined
+		}
+
+		fs.store(path, data)
+		header := http.Header{}
+		for key, values := range r.Header {
+			if strings.HasPrefix(key, "X-Object-Meta-") && values[0] != "" ||
+				key == "Content-Type" || key == "Content-Encoding" || key == "X-Delete-At" {
+				header[key] = values
+			}
+		}
+		if after, err := strconv.ParseInt(r.Header.Get("X-Delete-After"), 10, 64); err == nil {
+			header.Set("X-Delete-At", strconv.FormatInt(fs.clock.Unix()+after, 10))
+		}
+		if manifest != nil {
+			header.Set("X-Static-Large-Object", "True")
+			fs.manifests[path] = manifest
+		} else {
+			delete(fs.manifests, path)
+		}
+		fs.headers[path] = header
+
+		sum := md5.Sum(data)
+		w.Header().Set("Etag", hex.EncodeToString(sum[:]))
+		w.WriteHeader(201)
+	case "DELETE":
+		if _, ok := fs.objects[path]; !ok {
+			w.WriteHeader(404)
+			return
+		}
+		fs.remove(path)
+		w.WriteHeader(204)
+	case "POST":
+		if _, ok := fs.objects[path]; !ok {
+			w.WriteHeader(404)
+			return
+		}
+		header := http.Header{}
+		for key, values := range fs.headers[path] {
+			if !strings.HasPrefix(key, "X-Object-Meta-") && key != "X-Delete-At" {
+				header[key] = values
+			}
+		}
+		for key, values := range r.Header {
+			if strings.HasPrefix(key, "X-Object-Meta-") || key == "X-Delete-At" {
+				header[key] = values
+			}
+		}
+		fs.headers[path] = header
+		w.WriteHeader(202)
+	default:
+		w.WriteHeader(405)
+	}
+}
+
+func (fs *fakeSwift) serveAccount(w http.ResponseWriter, r *http.Request) {
+	// Containers exist once created or written to.
+	totals := map[string]*containerEntry{}
+	for path := range fs.headers {
+		if path != "" && !strings.Contains(path, "/") {
+			totals[path] = &containerEntry{Name: path}
+		}
+	}
+	for path, data := range fs.objects {
+		name := strings.SplitN(path, "/", 2)[0]
+		if totals[name] == nil {
+			totals[name] = &containerEntry{Name: name}
+		}
+		totals[name].Count++
+		totals[name].Bytes += int64(len(data))
+	}
+
+	query := r.URL.Query()
+	names := make([]string, 0)
+	for name := range totals {
+		if !fs.missing[name] && strings.HasPrefix(name, query.Get("prefix")) && name > query.Get("marker") &&
+			(query.Get("end_marker") == "" || name < query.Get("end_marker")) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	limit := fs.pageSize
+	if requested, err := strconv.Atoi(query.Get("limit")); err == nil && (limit == 0 || requested < limit) {
+		limit = requested
+	}
+	if limit > 0 && len(names) > limit {
+		names = names[:limit]
+	}
+
+	entries := make([]containerEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, *totals[name])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (fs *fakeSwift) serveContainer(w http.ResponseWriter, r *http.Request, container string) {
+	if r.Method == "PUT" {
+		delete(fs.missing, container)
+		if fs.headers[container] == nil {
+			fs.headers[container] = http.Header{}
+		}
+		for key, values := range r.Header {
+			if strings.HasPrefix(key, "X-Container-") || strings.HasSuffix(key, "-Location") {
+				fs.headers[container][key] = values
+			}
+		}
+		w.WriteHeader(201)
+		return
+	}
+
+	if fs.missing[container] {
+		w.WriteHeader(404)
+		return
+	}
+
+	if r.Method == "DELETE" {
+		for path := range fs.objects {
+			if strings.HasPrefix(path, container+"/") {
+				w.WriteHeader(409)
+				return
+			}
+		}
+		fs.missing[container] = true
+		delete(fs.headers, container)
+		w.WriteHeader(204)
+		return
+	}
+
+	if r.Method == "HEAD" {
+		var count, bytes int
+		for path, data := range fs.objects {
+			if strings.HasPrefix(path, container+"/") {
+				count++
+				bytes += len(data)
+			}
+		}
+		w.Header().Set("X-Container-Object-Count", strconv.Itoa(count))
+		w.Header().Set("X-Container-Bytes-Used", strconv.Itoa(bytes))
+		for key, values := range fs.headers[container] {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(204)
+		return
+	}
+
+	if r.Method == "POST" {
+		if fs.headers[container] == nil {
+			fs.headers[container] = http.Header{}
+		}
+		for key, values := range r.Header {
+			switch {
+			case strings.HasPrefix(key, "X-Remove-"):
+				fs.headers[container].Del("X-" + strings.TrimPrefix(key, "X-Remove-"))
+			case strings.HasPrefix(key, "X-Container-") || strings.HasSuffix(key, "-Location"):
+				fs.headers[container][key] = values
+			}
+		}
+		w.WriteHeader(204)
+		return
+	}
+
+	if r.Method != "GET" {
+		w.WriteHeader(405)
+		return
+	}
+
+	query := r.URL.Query()
+	prefix := container + "/" + query.Get("prefix")
+	marker := query.Get("marker")
+	endMarker := query.Get("end_marker")
+	delimiter := query.Get("delimiter")
+
+	names := make([]string, 0)
+	for path := range fs.objects {
+		if strings.HasPrefix(path, prefix) {
+			name := strings.TrimPrefix(path, container+"/")
+			if name > marker && (endMarker == "" || name < endMarker) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	// Like Swift, names are rolled up into their subdir, which is left
+	// out when it is the marker.
+	subdirs := map[string]bool{}
+	if delimiter != "" {
+		rolled := names[:0]
+		for _, name := range names {
+			rest := strings.TrimPrefix(name, query.Get("prefix"))
+			if end := strings.Index(rest, delimiter); end >= 0 {
+				subdir := name[:len(name)-len(rest)+end+len(delimiter)]
+				if subdirs[subdir] || subdir == marker {
+					continue
+				}
+				subdirs[subdir] = true
+				name = subdir
+			}
+			rolled = append(rolled, name)
+		}
+		names = rolled
+	}
+
+	limit := fs.pageSize
+	if requested, err := strconv.Atoi(query.Get("limit")); err == nil && (limit == 0 || requested < limit) {
+		limit = requested
+	}
+	if limit > 0 && len(names) > limit {
+		names = names[:limit]
+	}
+
+	entries := make([]objectEntry, 0, len(names))
+	for _, name := range names {
+		if subdirs[name] {
+			entries = append(entries, objectEntry{Subdir: name})
+			continue
+		}
+		data := fs.objects[container+"/"+name]
+		sum := md5.Sum(data)
+		entries = append(entries, objectEntry{
+			Name:         name,
+			Hash:         hex.EncodeToString(sum[:]),
+			Bytes:        int64(len(data)),
+			LastModified: fs.modified[container+"/"+name].Format("2006-01-02T15:04:05.000000"),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,215 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The kinds of failure a FaultTransport injects.
+type FaultKind int
+
+const (
+	// Answer with Status instead of sending the request.
+	FaultStatus FaultKind = iota
+	// Wait Delay, then fail the request with a timeout error.
+	FaultTimeout
+	// Send the request but cut the response body off after After bytes.
+	FaultTruncate
+	// Send the request but wait Delay before every read of the response
+	// body.
+	FaultSlow
+)
+
+// A FaultRule describes which requests fail and how.
+type FaultRule struct {
+	// Method only matches requests with this method when set.
+	Method string
+	// Path only matches requests whose URL path it matches when set.
+	Path *regexp.Regexp
+	// Probability is the chance a matching request fails, zero means
+	// every one does.
+	Probability float64
+	// Times stops the rule after this many failures, zero means never.
+	Times int
+
+	Kind   FaultKind
+	Status int
+	Delay  time.Duration
+	After  int64
+	// Header is sent with a FaultStatus answer, such as a Retry-After.
+	Header http.Header
+
+	injected int
+}
+
+// FaultTransport is an http.RoundTripper that injects failures into the
+// requests it passes to Base, for checking retry and resume settings before
+// relying on them.  Install it with SetTransport.
+type FaultTransport struct {
+	// Base sends the requests, http.DefaultTransport when nil.
+	Base  http.RoundTripper
+	Rules []*FaultRule
+	// Seed makes the probabilities repeatable.
+	Seed int64
+
+	mutex    sync.Mutex
+	random   *rand.Rand
+	injected int
+}
+
+// The error of a FaultTimeout, it reports itself as a timeout like a
+// net.Error would.
+type faultTimeoutError struct {
+	url string
+}
+
+func (e *faultTimeoutError) Error() string {
+	return fmt.Sprintf("Injected timeout for %s.", e.url)
+}
+
+func (e *faultTimeoutError) Timeout() bool   { return true }
+func (e *faultTimeoutError) Temporary() bool { return true }
+
+func (cf *CloudFiles) SetTransport(transport http.RoundTripper) {
+	/*
+		Send requests through the given transport, nil means the one of
+		SetHTTPClient's client or the shared default.
+	*/
+	cf.transport = transport
+}
+
+func (ft *FaultTransport) Injected() int {
+	/*
+		How many failures have been injected so far.
+	*/
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+	return ft.injected
+}
+
+func (ft *FaultTransport) match(req *http.Request) *FaultRule {
+	/*
+		Find the first rule that fails this request, if any.
+	*/
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if ft.random == nil {
+		ft.random = rand.New(rand.NewSource(ft.Seed))
+	}
+
+	for _, rule := range ft.Rules {
+		if rule.Method != "" && !strings.EqualFold(rule.Method, req.Method) {
+			continue
+		}
+		if rule.Path != nil && !rule.Path.MatchString(req.URL.Path) {
+			continue
+		}
+		if rule.Times > 0 && rule.injected >= rule.Times {
+			continue
+		}
+		if rule.Probability > 0 && ft.random.Float64() >= rule.Probability {
+			continue
+		}
+
+		rule.injected++
+		ft.injected++
+		return rule
+	}
+
+	return nil
+}
+
+func (ft *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := ft.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	rule := ft.match(req)
+	if rule == nil {
+		return base.RoundTrip(req)
+	}
+
+	switch rule.Kind {
+	case FaultStatus:
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		header := make(http.Header)
+		for key, values := range rule.Header {
+			header[key] = append([]string(nil), values...)
+		}
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", rule.Status, http.StatusText(rule.Status)),
+			StatusCode: rule.Status,
+			Header:     header,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	case FaultTimeout:
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		select {
+		case <-time.After(rule.Delay):
+		case <-req.Context().Done():
+		}
+		return nil, &faultTimeoutError{url: req.URL.String()}
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if rule.Kind == FaultTruncate {
+		resp.Body = &truncatedBody{body: resp.Body, remaining: rule.After}
+	} else {
+		resp.Body = &slowBody{body: resp.Body, delay: rule.Delay}
+	}
+	return resp, nil
+}
+
+type truncatedBody struct {
+	body      io.ReadCloser
+	remaining int64
+}
+
+func (tb *truncatedBody) Read(p []byte) (int, error) {
+	if tb.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > tb.remaining {
+		p = p[:tb.remaining]
+	}
+
+	n, err := tb.body.Read(p)
+	tb.remaining -= int64(n)
+	return n, err
+}
+
+func (tb *truncatedBody) Close() error {
+	return tb.body.Close()
+}
+
+type slowBody struct {
+	body  io.ReadCloser
+	delay time.Duration
+}
+
+func (sb *slowBody) Read(p []byte) (int, error) {
+	time.Sleep(sb.delay)
+	return sb.body.Read(p)
+}
+
+func (sb *slowBody) Close() error {
+	return sb.body.Close()
+}
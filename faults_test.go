@@ -0,0 +1,64 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFaultTransport(t *testing.T) {
+	// Test injected failures reach the client and retries get past them.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	faults := &FaultTransport{Rules: []*FaultRule{
+		{Method: "PUT", Path: regexp.MustCompile(`/file\.txt$`), Kind: FaultStatus, Status: 500, Times: 1},
+		{Method: "GET", Path: regexp.MustCompile(`/cut\.txt$`), Kind: FaultTruncate, After: 3},
+		{Method: "HEAD", Kind: FaultTimeout, Delay: time.Millisecond, Times: 1},
+	}}
+	cf.SetTransport(faults)
+
+	_, err := cf.PutFileWithOptions("TEST", "testing", "file.txt", bytes.NewReader([]byte("hello")),
+		&WriteOptions{Retries: 1})
+	if err != nil || faults.Injected() != 1 {
+		t.Fatalf("A retried upload should get past one failure: %v %d", err, faults.Injected())
+	}
+
+	fs.put("testing/cut.txt", []byte("truncated"))
+	var out bytes.Buffer
+	if _, _, err = cf.GetChunk("TEST", "testing", "cut.txt", &out, 0, 0); err == nil {
+		t.Fatalf("A truncated body should fail the download: %q", out.String())
+	}
+
+	_, _, err = cf.GetFileSize("TEST", "testing", "file.txt")
+	if timeout, ok := err.(interface{ Timeout() bool }); !ok || !timeout.Timeout() {
+		t.Fatalf("Expected a timeout but got %v", err)
+	}
+
+	if _, _, err = cf.GetFileSize("TEST", "testing", "file.txt"); err != nil {
+		t.Fatalf("The timeout rule should stop after one failure: %s", err)
+	}
+}
+
+func TestFaultTransportProbability(t *testing.T) {
+	// Test a seeded probability fails a repeatable share of requests.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("testing/file.txt", []byte("x"))
+	faults := &FaultTransport{Seed: 1, Rules: []*FaultRule{
+		{Kind: FaultStatus, Status: 503, Probability: 0.5},
+	}}
+	cf.SetTransport(faults)
+
+	for i := 0; i < 100; i++ {
+		cf.GetFileSize("TEST", "testing", "file.txt")
+	}
+
+	if injected := faults.Injected(); injected < 30 || injected > 70 {
+		t.Fatalf("Expected about half the requests to fail: %d", injected)
+	}
+}
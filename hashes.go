@@ -0,0 +1,155 @@
+package gocloudfiles
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// A ChunkHash is a hash chunks can be verified with besides MD5.
+type ChunkHash struct {
+	// Name keys the hash's sums in SegmentChecksum.Hashes.
+	Name string
+	New  func() hash.Hash
+}
+
+// Hashes from the standard library.  Others, such as xxhash, are used by
+// giving their constructor in a ChunkHash.
+var (
+	SHA256Hash = ChunkHash{Name: "sha256", New: sha256.New}
+	SHA512Hash = ChunkHash{Name: "sha512", New: sha512.New}
+	CRC32CHash = ChunkHash{Name: "crc32c", New: func() hash.Hash {
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	}}
+)
+
+// HashConfig chooses how copied chunks are verified.  MD5 is always
+// computed, the server's etags are MD5s, and the Extra hashes are computed
+// alongside it in the same pass over the data.
+type HashConfig struct {
+	// Extra hashes each chunk as it is downloaded and again as it is
+	// uploaded, and fails the chunk when they differ, catching data
+	// damaged on the client's disk or memory in between.  Their sums are
+	// kept in the checksum record with WriteChecksums.
+	Extra []ChunkHash
+}
+
+func (hc *HashConfig) validate() error {
+	/*
+		Check every hash has a constructor and a name of its own.
+	*/
+	if hc == nil {
+		return nil
+	}
+
+	seen := map[string]bool{"md5": true}
+	for _, h := range hc.Extra {
+		if h.Name == "" || h.New == nil {
+			return fmt.Errorf("Chunk hash %q needs a name and a constructor.", h.Name)
+		}
+		if seen[h.Name] {
+			return fmt.Errorf("Chunk hash %s is given twice.", h.Name)
+		}
+		seen[h.Name] = true
+	}
+	return nil
+}
+
+// chunkHashes are the running Extra hashes of one pass over a chunk.
+type chunkHashes struct {
+	names  []string
+	hashes []hash.Hash
+}
+
+func (hc *HashConfig) start() *chunkHashes {
+	/*
+		Begin one pass over a chunk, nil without Extra hashes.
+	*/
+	if hc == nil || len(hc.Extra) == 0 {
+		return nil
+	}
+
+	ch := &chunkHashes{}
+	for _, h := range hc.Extra {
+		ch.names = append(ch.names, h.Name)
+		ch.hashes = append(ch.hashes, h.New())
+	}
+	return ch
+}
+
+func (ch *chunkHashes) writer(w io.Writer) io.Writer {
+	/*
+		A writer to w that feeds the hashes too.
+	*/
+	if ch == nil {
+		return w
+	}
+
+	writers := []io.Writer{w}
+	for _, h := range ch.hashes {
+		writers = append(writers, h)
+	}
+	return io.MultiWriter(writers...)
+}
+
+func (ch *chunkHashes) readSeeker(rs io.ReadSeeker) io.ReadSeeker {
+	/*
+		A reader of rs that feeds the hashes what is read and starts them
+		over when a retry rewinds it.
+	*/
+	if ch == nil {
+		return rs
+	}
+	return &hashingReader{ReadSeeker: rs, hashes: ch}
+}
+
+type hashingReader struct {
+	io.ReadSeeker
+	hashes *chunkHashes
+}
+
+func (hr *hashingReader) Read(p []byte) (int, error) {
+	n, err := hr.ReadSeeker.Read(p)
+	for _, h := range hr.hashes.hashes {
+		h.Write(p[:n])
+	}
+	return n, err
+}
+
+func (hr *hashingReader) Seek(offset int64, whence int) (int64, error) {
+	position, err := hr.ReadSeeker.Seek(offset, whence)
+	if err == nil && (whence != io.SeekCurrent || offset != 0) {
+		for _, h := range hr.hashes.hashes {
+			h.Reset()
+		}
+	}
+	return position, err
+}
+
+func (ch *chunkHashes) sums() map[string]string {
+	if ch == nil {
+		return nil
+	}
+
+	sums := make(map[string]string, len(ch.names))
+	for i, name := range ch.names {
+		sums[name] = hex.EncodeToString(ch.hashes[i].Sum(nil))
+	}
+	return sums
+}
+
+func compareSums(downloaded, uploaded map[string]string) error {
+	/*
+		Fail when a hash of the uploaded data differs from the downloaded.
+	*/
+	for name, sum := range downloaded {
+		if uploaded[name] != sum {
+			return fmt.Errorf("Upload %s does not match download %s: %s %s!", name, name, sum, uploaded[name])
+		}
+	}
+	return nil
+}
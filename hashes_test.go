@@ -0,0 +1,67 @@
+package gocloudfiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCopyFileExtraHashes(t *testing.T) {
+	// Test chunks are verified with extra hashes whose sums reach the
+	// checksum record.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.dcs["OTHER"] = fs.server.URL
+
+	data := []byte("0123456789abcdefghij")
+	fs.put("src/file.bin", data)
+
+	hashes := &HashConfig{Extra: []ChunkHash{SHA256Hash, CRC32CHash}}
+	err := cf.CopyFileWithOptions("TEST", "src", "file.bin", "OTHER", "dst", "file.bin",
+		&CopyOptions{ChunkSize: 8, WriteChecksums: true, Hashes: hashes})
+	if err != nil {
+		t.Fatalf("Could not copy: %s", err)
+	}
+
+	record, err := cf.GetChecksums("OTHER", "dst", "file.bin")
+	if err != nil {
+		t.Fatalf("Could not get checksums: %s", err)
+	}
+	if len(record.Segments) != 3 {
+		t.Fatalf("Expected 3 segments, got %d", len(record.Segments))
+	}
+	for i, segment := range record.Segments {
+		end := (i + 1) * 8
+		if end > len(data) {
+			end = len(data)
+		}
+		sum := sha256.Sum256(data[i*8 : end])
+		if segment.Hashes["sha256"] != hex.EncodeToString(sum[:]) || len(segment.Hashes["crc32c"]) != 8 {
+			t.Fatalf("Unexpected hashes of segment %d: %v", i, segment.Hashes)
+		}
+	}
+
+	for _, invalid := range []*HashConfig{
+		{Extra: []ChunkHash{SHA256Hash, SHA256Hash}},
+		{Extra: []ChunkHash{{Name: "md5", New: SHA256Hash.New}}},
+		{Extra: []ChunkHash{{Name: "xxhash"}}},
+	} {
+		err := cf.CopyFileWithOptions("TEST", "src", "file.bin", "OTHER", "dst", "other.bin",
+			&CopyOptions{ChunkSize: 8, Hashes: invalid})
+		if err == nil {
+			t.Fatalf("Copied with invalid hashes %+v", invalid.Extra)
+		}
+	}
+}
+
+func TestCompareSums(t *testing.T) {
+	// Test a chunk damaged between download and upload is caught.
+	downloaded := map[string]string{"sha256": "aa", "crc32c": "bb"}
+	if err := compareSums(downloaded, map[string]string{"sha256": "aa", "crc32c": "bb"}); err != nil {
+		t.Fatalf("Matching sums failed: %s", err)
+	}
+	if err := compareSums(downloaded, map[string]string{"sha256": "aa", "crc32c": "cc"}); err == nil {
+		t.Fatalf("Damaged chunk not caught.")
+	}
+}
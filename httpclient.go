@@ -0,0 +1,131 @@
+package gocloudfiles
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTPOptions tune the connection pool of NewTransport.  Zero values take
+// the defaults.
+type HTTPOptions struct {
+	// MaxIdleConnsPerHost is how many connections to a region are kept
+	// open for reuse, 32 when zero.  net/http keeps 2, fewer than a
+	// chunked copy has requests in flight.
+	MaxIdleConnsPerHost int
+
+	// MaxIdleConns caps the idle connections to all hosts, 256 when zero.
+	MaxIdleConns int
+
+	// IdleConnTimeout closes connections idle this long, 90 seconds when
+	// zero.
+	IdleConnTimeout time.Duration
+
+	// DialTimeout and TLSHandshakeTimeout bound setting up a connection,
+	// 30 and 10 seconds when zero.
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout bounds the wait for a response once a request
+	// is sent, none when zero.  Bodies are never timed out, a large
+	// download may take hours.
+	ResponseHeaderTimeout time.Duration
+}
+
+// Response bodies closed with at most this much unread are read to the end
+// first, so their connection goes back to the pool.  Longer ones are cheaper
+// to drop.
+const drainLimit = 256 * 1024
+
+// The transport of clients without SetHTTPClient, shared so they all pool
+// their connections.
+var defaultTransport = NewTransport(HTTPOptions{})
+
+func NewTransport(options HTTPOptions) *http.Transport {
+	/*
+		A transport tuned for many concurrent requests to a few storage
+		hosts, for SetHTTPClient.
+	*/
+	if options.MaxIdleConnsPerHost <= 0 {
+		options.MaxIdleConnsPerHost = 32
+	}
+	if options.MaxIdleConns <= 0 {
+		options.MaxIdleConns = 256
+	}
+	if options.IdleConnTimeout <= 0 {
+		options.IdleConnTimeout = 90 * time.Second
+	}
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = 30 * time.Second
+	}
+	if options.TLSHandshakeTimeout <= 0 {
+		options.TLSHandshakeTimeout = 10 * time.Second
+	}
+
+	dialer := &net.Dialer{Timeout: options.DialTimeout, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          options.MaxIdleConns,
+		MaxIdleConnsPerHost:   options.MaxIdleConnsPerHost,
+		IdleConnTimeout:       options.IdleConnTimeout,
+		TLSHandshakeTimeout:   options.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: options.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+func (cf *CloudFiles) SetHTTPClient(client *http.Client) {
+	/*
+		Send every request, to the identity service and to storage, with
+		the given client, such as one with a transport from NewTransport or
+		instrumented by the application.  nil restores the shared default.
+		A SetTransport transport replaces the client's own.
+	*/
+	cf.client = client
+}
+
+func (cf CloudFiles) httpClient() *http.Client {
+	/*
+		The client requests are sent with.  Its transport reads short
+		response bodies to the end when they are closed, so connections are
+		reused even where a caller only looked at the status.
+	*/
+	client := http.Client{Transport: defaultTransport}
+	if cf.client != nil {
+		client = *cf.client
+	}
+	if cf.transport != nil {
+		client.Transport = cf.transport
+	}
+	if client.Transport == nil {
+		client.Transport = http.DefaultTransport
+	}
+
+	client.Transport = drainingTransport{client.Transport}
+	return &client
+}
+
+type drainingTransport struct {
+	http.RoundTripper
+}
+
+func (dt drainingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := dt.RoundTripper.RoundTrip(req)
+	if err == nil && resp.Body != nil {
+		resp.Body = &drainingBody{ReadCloser: resp.Body}
+	}
+	return resp, err
+}
+
+type drainingBody struct {
+	io.ReadCloser
+}
+
+func (db *drainingBody) Close() error {
+	io.CopyN(ioutil.Discard, db.ReadCloser, drainLimit)
+	return db.ReadCloser.Close()
+}
@@ -0,0 +1,78 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type countingTransport struct {
+	mutex sync.Mutex
+	count int
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.mutex.Lock()
+	ct.count++
+	ct.mutex.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestConnectionsReused(t *testing.T) {
+	// Test requests whose answers are not read share one connection.
+	var mutex sync.Mutex
+	connections := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		w.Write(bytes.Repeat([]byte("Not Found "), 20000))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mutex.Lock()
+			connections++
+			mutex.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	cf := NewCloudFilesFromToken("token", map[string]string{"TEST": server.URL})
+	for i := 0; i < 10; i++ {
+		var out bytes.Buffer
+		if _, _, err := cf.GetChunk("TEST", "testing", "missing", &out, 0, 0); !errors.Is(err, ErrObjectMissing) {
+			t.Fatalf("Expected ErrObjectMissing, got %v", err)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if connections != 1 {
+		t.Fatalf("Expected one connection, %d were opened.", connections)
+	}
+}
+
+func TestSetHTTPClient(t *testing.T) {
+	// Test an injected client sends every request, and SetTransport still
+	// takes precedence over its transport.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	counting := &countingTransport{}
+	cf.SetHTTPClient(&http.Client{Transport: counting})
+	cf.PutFile("TEST", "testing", "file", bytes.NewReader([]byte("data")))
+	if counting.count != 1 {
+		t.Fatalf("Expected the injected client, it sent %d requests.", counting.count)
+	}
+
+	faults := &countingTransport{}
+	cf.SetTransport(faults)
+	cf.PutFile("TEST", "testing", "file", bytes.NewReader([]byte("data")))
+	if counting.count != 1 || faults.count != 1 {
+		t.Fatalf("Expected the transport, counts %d %d", counting.count, faults.count)
+	}
+}
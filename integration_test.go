@@ -0,0 +1,199 @@
+//go:build integration
+// +build integration
+
+package gocloudfiles
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// The integration tests run against a Swift all-in-one, such as
+//
+//	docker run -d -p 8080:8080 openstackswift/saio
+//
+// authenticating with Swift's v1 auth, whose defaults are SAIO's.
+var (
+	SwiftAuthURL = envDefault("SWIFT_AUTH_URL", SAIOAuthEndpoint)
+	SwiftUser    = envDefault("SWIFT_USER", "test:tester")
+	SwiftKey     = envDefault("SWIFT_KEY", "testing")
+)
+
+// The region name the Swift endpoint is registered under.
+const swiftRegion = "SAIO"
+
+func envDefault(name, value string) string {
+	if set := os.Getenv(name); set != "" {
+		return set
+	}
+	return value
+}
+
+func swiftClient(t *testing.T) *CloudFiles {
+	// Authenticate against the Swift endpoint, skipping when it is not up.
+	cf := NewCloudFilesTempAuth(SwiftAuthURL, SwiftUser, SwiftKey, swiftRegion)
+	if err := cf.Authorize(); err != nil {
+		var unreachable *neturl.Error
+		if errors.As(err, &unreachable) {
+			t.Skipf("Swift is not reachable at %s: %s", SwiftAuthURL, err)
+		}
+		t.Fatalf("Could not authenticate as %s: %s", SwiftUser, err)
+	}
+	return cf
+}
+
+func swiftContainer(t *testing.T, cf *CloudFiles) string {
+	// Create a container of its own for a test, deleted with everything in
+	// it when the test ends.
+	bucket := fmt.Sprintf("gocloudfiles-%s-%d", t.Name(), time.Now().UnixNano())
+	if err := cf.CreateContainer(swiftRegion, bucket, nil); err != nil {
+		t.Fatalf("Could not create container: %s", err)
+	}
+	t.Cleanup(func() {
+		if _, err := cf.DeleteContainerWithOptions(swiftRegion, bucket,
+			&DeleteContainerOptions{Recursive: true}); err != nil && !errors.Is(err, ErrContainerMissing) {
+			t.Errorf("Could not clean up container %s: %s", bucket, err)
+		}
+	})
+	return bucket
+}
+
+func TestIntegrationAccount(t *testing.T) {
+	// Test a new container shows up in the account listing and counts.
+	cf := swiftClient(t)
+	bucket := swiftContainer(t, cf)
+
+	if _, err := cf.PutFile(swiftRegion, bucket, "hello.txt", bytes.NewReader([]byte("hello"))); err != nil {
+		t.Fatalf("Could not put: %s", err)
+	}
+
+	listing, err := cf.ListContainersWithOptions(swiftRegion, ContainerListOptions{Prefix: bucket})
+	if err != nil {
+		t.Fatalf("Could not list containers: %s", err)
+	}
+	if len(listing.Containers) != 1 || listing.Containers[0].Name != bucket {
+		t.Fatalf("Container %s not listed: %+v", bucket, listing.Containers)
+	}
+
+	info, err := cf.GetAccountInfo(swiftRegion)
+	if err != nil {
+		t.Fatalf("Could not get account info: %s", err)
+	}
+	if info.Containers < 1 {
+		t.Fatalf("Account reports %d containers.", info.Containers)
+	}
+}
+
+func TestIntegrationCopyLargeObject(t *testing.T) {
+	// Test a copy split into segments reads back whole as a static large
+	// object.
+	cf := swiftClient(t)
+	source := swiftContainer(t, cf)
+	dest := swiftContainer(t, cf)
+
+	limits := cf.segmentLimits(swiftRegion)
+	chunkSize := int64(1024 * 1024)
+	if chunkSize < limits.MinSegmentSize {
+		chunkSize = limits.MinSegmentSize
+	}
+
+	data := make([]byte, 2*chunkSize+chunkSize/2)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("Could not generate data: %s", err)
+	}
+	if _, err := cf.PutFile(swiftRegion, source, "data.bin", bytes.NewReader(data)); err != nil {
+		t.Fatalf("Could not put: %s", err)
+	}
+
+	err := cf.CopyFileWithOptions(swiftRegion, source, "data.bin", swiftRegion, dest, "data.bin",
+		&CopyOptions{ChunkSize: chunkSize})
+	if err != nil {
+		t.Fatalf("Could not copy: %s", err)
+	}
+
+	info, err := cf.StatObject(swiftRegion, dest, "data.bin")
+	if err != nil {
+		t.Fatalf("Could not stat copy: %s", err)
+	}
+	if !info.StaticLargeObject || info.Bytes != int64(len(data)) {
+		t.Fatalf("Copy is not a %d byte large object: %+v", len(data), info)
+	}
+
+	var out bytes.Buffer
+	if _, err := cf.DownloadLargeObject(swiftRegion, dest, "data.bin", &out, nil); err != nil {
+		t.Fatalf("Could not download copy: %s", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Copy differs from the source.")
+	}
+
+	segments, err := cf.ListObjects(swiftRegion, dest, ListOptions{Prefix: "data.bin/"})
+	if err != nil {
+		t.Fatalf("Could not list segments: %s", err)
+	}
+	if len(segments.Objects) != 3 {
+		t.Fatalf("Expected 3 segments, got %d", len(segments.Objects))
+	}
+
+	if err := cf.DeleteFileWithOptions(swiftRegion, dest, "data.bin",
+		&DeleteFileOptions{Segments: true}); err != nil {
+		t.Fatalf("Could not delete copy: %s", err)
+	}
+	remaining, err := cf.ListObjects(swiftRegion, dest, ListOptions{})
+	if err != nil {
+		t.Fatalf("Could not list destination: %s", err)
+	}
+	if len(remaining.Objects) != 0 {
+		t.Fatalf("Delete left %d objects.", len(remaining.Objects))
+	}
+}
+
+func TestIntegrationListAndDelete(t *testing.T) {
+	// Test listings with a delimiter and marker, then a recursive delete of
+	// the container.
+	cf := swiftClient(t)
+	bucket := swiftContainer(t, cf)
+
+	for _, name := range []string{"a.txt", "logs/1", "logs/2", "z.txt"} {
+		if _, err := cf.PutFile(swiftRegion, bucket, name, bytes.NewReader([]byte(name))); err != nil {
+			t.Fatalf("Could not put %s: %s", name, err)
+		}
+	}
+
+	listing, err := cf.ListObjects(swiftRegion, bucket, ListOptions{Delimiter: "/"})
+	if err != nil {
+		t.Fatalf("Could not list: %s", err)
+	}
+	if len(listing.Objects) != 2 || len(listing.Subdirs) != 1 || listing.Subdirs[0] != "logs/" {
+		t.Fatalf("Unexpected listing %+v", listing)
+	}
+
+	page, err := cf.ListObjects(swiftRegion, bucket, ListOptions{Marker: "a.txt", Limit: 2})
+	if err != nil {
+		t.Fatalf("Could not list page: %s", err)
+	}
+	if len(page.Objects) != 2 || page.Objects[0].Name != "logs/1" || page.NextMarker != "logs/2" {
+		t.Fatalf("Unexpected page %+v", page)
+	}
+
+	if err := cf.DeleteContainer(swiftRegion, bucket); !errors.Is(err, ErrContainerNotEmpty) {
+		t.Fatalf("Expected ErrContainerNotEmpty, got %v", err)
+	}
+
+	report, err := cf.DeleteContainerWithOptions(swiftRegion, bucket, &DeleteContainerOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("Could not delete container: %s", err)
+	}
+	if report.Succeeded != 4 {
+		t.Fatalf("Expected 4 objects deleted, got %d", report.Succeeded)
+	}
+	if _, err := cf.GetContainerMetadata(swiftRegion, bucket); !errors.Is(err, ErrContainerMissing) {
+		t.Fatalf("Expected ErrContainerMissing, got %v", err)
+	}
+}
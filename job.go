@@ -0,0 +1,228 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrCanceled is returned by a transfer stopped with Cancel.
+var ErrCanceled = errors.New("Transfer canceled.")
+
+// A TransferControl pauses and resumes a running copy.  While paused no
+// new chunks are started; chunks already in flight finish and their
+// segments are kept, so a resumed copy carries on where it left off.
+type TransferControl struct {
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	paused   bool
+	canceled bool
+}
+
+func NewTransferControl() *TransferControl {
+	tc := &TransferControl{}
+	tc.cond = sync.NewCond(&tc.mutex)
+	return tc
+}
+
+func (tc *TransferControl) Pause() {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	tc.paused = true
+}
+
+func (tc *TransferControl) Resume() {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	tc.paused = false
+	tc.cond.Broadcast()
+}
+
+func (tc *TransferControl) Paused() bool {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	return tc.paused
+}
+
+func (tc *TransferControl) Cancel() {
+	/*
+		Stop the transfer for good, paused or not.  Chunks in flight finish
+		and their segments are kept, so the copy can still be resumed under
+		its transfer ID by a new job.
+	*/
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	tc.canceled = true
+	tc.cond.Broadcast()
+}
+
+func (tc *TransferControl) wait() error {
+	/*
+		Block the scheduler for as long as the transfer is paused.
+		Returns ErrCanceled once the transfer is canceled
+	*/
+	if tc == nil {
+		return nil
+	}
+
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	for tc.paused && !tc.canceled {
+		tc.cond.Wait()
+	}
+
+	if tc.canceled {
+		return ErrCanceled
+	}
+	return nil
+}
+
+// A Job is a handle on a copy running in the background.  ID is the
+// transfer ID its segments are written under.
+type Job struct {
+	*TransferControl
+	ID    string
+	cf    CloudFiles
+	state JobState
+	done  chan bool
+	err   error
+}
+
+func (cf CloudFiles) StartCopy(sourceDC, sourceBucket, sourceFile,
+	destDC, destBucket, destFile string, options *CopyOptions) *Job {
+	/*
+		Start CopyFileWithOptions in the background and return a handle that
+		can pause, resume and wait for it.  The job's ID is its transfer ID,
+		so passing it back as CopyOptions.TransferID resumes the job.
+	*/
+	copied := CopyOptions{}
+	if options != nil {
+		copied = *options
+	}
+
+	if copied.TransferID == "" {
+		copied.TransferID = NewTransferID()
+	}
+
+	if copied.Control == nil {
+		copied.Control = NewTransferControl()
+	}
+
+	job := &Job{
+		TransferControl: copied.Control,
+		ID:              copied.TransferID,
+		cf:              cf,
+		state: JobState{
+			ID:            copied.TransferID,
+			Kind:          "copy",
+			Source:        &JobLocation{Region: sourceDC, Container: sourceBucket, Object: sourceFile},
+			Dest:          &JobLocation{Region: destDC, Container: destBucket, Object: destFile},
+			SegmentDigits: copied.SegmentDigits,
+			ChunkSize:     copied.ChunkSize,
+		},
+		done: make(chan bool),
+	}
+
+	go func() {
+		defer close(job.done)
+		job.err = catchPanic(destFile, -1, func() error {
+			return cf.CopyFileWithOptions(sourceDC, sourceBucket, sourceFile,
+				destDC, destBucket, destFile, &copied)
+		})
+	}()
+
+	return job
+}
+
+func (job *Job) Done() <-chan bool {
+	return job.done
+}
+
+func (job *Job) Wait() error {
+	<-job.done
+	return job.err
+}
+
+// Why a job is in the state it is.
+type JobReason int
+
+const (
+	// The job is still working.
+	JobRunning JobReason = iota
+	// The job is paused and waits for Resume.
+	JobPaused
+	// The job finished without an error.
+	JobCompleted
+	// The job was stopped with Cancel.
+	JobCanceled
+	// The job was aborted by the client's FailureBudget.
+	JobBudgetExceeded
+	// A worker of the job panicked.
+	JobPanicked
+	// The job stopped on any other error, such as a failed request.
+	JobFailed
+)
+
+func (r JobReason) String() string {
+	switch r {
+	case JobRunning:
+		return "running"
+	case JobPaused:
+		return "paused"
+	case JobCompleted:
+		return "completed"
+	case JobCanceled:
+		return "canceled"
+	case JobBudgetExceeded:
+		return "budget exceeded"
+	case JobPanicked:
+		return "panicked"
+	case JobFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// A JobStatus says what a job is doing or why it stopped.
+type JobStatus struct {
+	Reason JobReason
+	// Terminal is set once the job stopped for good.
+	Terminal bool
+	// Err is the error the job stopped with, unwrap it or use errors.As
+	// for details such as a *BudgetExceededError or *PanicError.
+	Err error
+}
+
+func (job *Job) Status() JobStatus {
+	/*
+		Describe the job as it is now.  A job that was canceled while a
+		worker failed reports whichever error stopped it first.
+	*/
+	select {
+	case <-job.done:
+	default:
+		if job.Paused() {
+			return JobStatus{Reason: JobPaused}
+		}
+		return JobStatus{Reason: JobRunning}
+	}
+
+	status := JobStatus{Terminal: true, Err: job.err}
+	switch {
+	case job.err == nil:
+		status.Reason = JobCompleted
+	case errors.Is(job.err, ErrCanceled):
+		status.Reason = JobCanceled
+	case errors.Is(job.err, ErrBudgetExceeded):
+		status.Reason = JobBudgetExceeded
+	case errors.Is(job.err, ErrWorkerPanic):
+		status.Reason = JobPanicked
+	default:
+		status.Reason = JobFailed
+	}
+	return status
+}
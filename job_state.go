@@ -0,0 +1,154 @@
+package gocloudfiles
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JobStateVersion is the version of the JobState schema written by Export.
+const JobStateVersion = 1
+
+// A JobLocation names one object of a job.
+type JobLocation struct {
+	Region    string `json:"region"`
+	Container string `json:"container"`
+	Object    string `json:"object"`
+}
+
+// A SegmentState is a segment of a copy already at its destination.
+type SegmentState struct {
+	Index int64  `json:"index"`
+	Name  string `json:"name"`
+	ETag  string `json:"etag"`
+	Bytes int64  `json:"bytes"`
+}
+
+// JobState is the JSON form of a job written by Job.Export and read by
+// ImportJob, so a partly done job can move to another host or be inspected.
+// Kind is "copy", with Source, Dest and Segments, or "migration", with
+// Migration.
+type JobState struct {
+	Version       int            `json:"version"`
+	ID            string         `json:"id"`
+	Kind          string         `json:"kind"`
+	Source        *JobLocation   `json:"source,omitempty"`
+	Dest          *JobLocation   `json:"dest,omitempty"`
+	SegmentDigits int            `json:"segment_digits,omitempty"`
+	ChunkSize     int64          `json:"chunk_size,omitempty"`
+	Migration     *Migration     `json:"migration,omitempty"`
+	Paused        bool           `json:"paused"`
+	Done          bool           `json:"done"`
+	Error         string         `json:"error,omitempty"`
+	Reason        string         `json:"reason,omitempty"`
+	Segments      []SegmentState `json:"segments,omitempty"`
+}
+
+func (job *Job) State() (*JobState, error) {
+	/*
+		Describe the job as it is now.  The segments of a copy are listed
+		from its destination, so they show what a resumed job would not
+		upload again.  When they cannot be listed the state is returned
+		without them, along with the error.
+	*/
+	state := job.state
+	state.Version = JobStateVersion
+	state.Paused = job.Paused()
+
+	status := job.Status()
+	state.Reason = status.Reason.String()
+	if status.Terminal {
+		state.Done = true
+		if status.Err != nil {
+			state.Error = status.Err.Error()
+		}
+	}
+
+	if state.Kind != "copy" {
+		return &state, nil
+	}
+
+	prefix := state.Dest.Object + segmentDir + state.ID + "/"
+	err := job.cf.walkObjects(state.Dest.Region, state.Dest.Container, prefix, func(entry objectEntry) error {
+		transferID, index, ok := parseSegmentName(state.Dest.Object, entry.Name)
+		if ok && transferID == state.ID {
+			state.Segments = append(state.Segments, SegmentState{
+				Index: index,
+				Name:  entry.Name,
+				ETag:  entry.Hash,
+				Bytes: entry.Bytes,
+			})
+		}
+		return nil
+	})
+	return &state, err
+}
+
+func (job *Job) Export() ([]byte, error) {
+	/*
+		The job's JobState as indented JSON.
+	*/
+	state, err := job.State()
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(state, "", "  ")
+}
+
+func (cf CloudFiles) ImportJob(data []byte, options *CopyOptions) (*Job, error) {
+	/*
+		Resume a job exported with Export, on this host.  Segments a copy
+		already wrote are not uploaded again, and a job exported paused
+		starts paused.  Options tune a resumed copy, its transfer ID and
+		segment digits come from the state.
+	*/
+	state := &JobState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("Could not parse job state: %s", err)
+	}
+
+	if state.Version != JobStateVersion {
+		return nil, fmt.Errorf("Unsupported job state version %d.", state.Version)
+	}
+
+	if !validTransferID(state.ID) || state.ID == "" {
+		return nil, fmt.Errorf("Invalid job ID %q.", state.ID)
+	}
+
+	control := NewTransferControl()
+	if state.Paused {
+		control.Pause()
+	}
+
+	switch state.Kind {
+	case "copy":
+		if state.Source == nil || state.Dest == nil {
+			return nil, fmt.Errorf("Copy job %s needs a source and destination.", state.ID)
+		}
+
+		copied := CopyOptions{}
+		if options != nil {
+			copied = *options
+		}
+		copied.TransferID = state.ID
+		copied.SegmentDigits = state.SegmentDigits
+		copied.ChunkSize = state.ChunkSize
+		copied.Control = control
+
+		return cf.StartCopy(state.Source.Region, state.Source.Container, state.Source.Object,
+			state.Dest.Region, state.Dest.Container, state.Dest.Object, &copied), nil
+	case "migration":
+		if state.Migration == nil {
+			return nil, fmt.Errorf("Migration job %s has no migration.", state.ID)
+		}
+		if err := state.Migration.Validate(); err != nil {
+			return nil, err
+		}
+
+		return cf.StartMigration(state.Migration, &MigrationOptions{
+			TransferID: state.ID,
+			Control:    control,
+		}), nil
+	default:
+		return nil, fmt.Errorf("Unknown job kind %q.", state.Kind)
+	}
+}
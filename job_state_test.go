@@ -0,0 +1,77 @@
+package gocloudfiles
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExportImportCopyJob(t *testing.T) {
+	// Test a paused copy moves to another client through its exported state.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	putLargeObject(fs, "src", "file.bin", "part")
+
+	control := NewTransferControl()
+	control.Pause()
+	job := cf.StartCopy("TEST", "src", "file.bin", "TEST", "dst", "file.bin",
+		&CopyOptions{Control: control, SegmentDigits: 4})
+
+	data, err := job.Export()
+	if err != nil {
+		t.Fatalf("Could not export job: %s", err)
+	}
+
+	state := &JobState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		t.Fatalf("Could not decode state: %s", err)
+	}
+	if state.Version != JobStateVersion || state.ID != job.ID || state.Kind != "copy" ||
+		!state.Paused || state.Done || state.Dest.Object != "file.bin" || len(state.Segments) != 0 {
+		t.Fatalf("Unexpected state %s", data)
+	}
+
+	other := fs.client()
+	imported, err := other.ImportJob(data, nil)
+	if err != nil {
+		t.Fatalf("Could not import job: %s", err)
+	}
+	if imported.ID != job.ID || !imported.Paused() {
+		t.Fatalf("Imported job should keep its ID and stay paused.")
+	}
+
+	imported.Resume()
+	if err := imported.Wait(); err != nil {
+		t.Fatalf("Could not finish imported job: %s", err)
+	}
+
+	if _, ok := fs.get("dst/" + segmentName("file.bin", job.ID, 0, 4)); !ok {
+		t.Fatalf("Imported job should write the exported job's segments.")
+	}
+
+	state, err = imported.State()
+	if err != nil || !state.Done || state.Error != "" || len(state.Segments) != 1 || state.Segments[0].Bytes != 1 {
+		t.Fatalf("Unexpected finished state %+v %v", state, err)
+	}
+
+	job.Resume()
+	job.Wait()
+}
+
+func TestImportJobRejectsBadState(t *testing.T) {
+	// Test unknown versions, kinds and IDs are refused.
+	cf := NewCloudFilesImpersonation("token")
+
+	for _, doc := range []string{
+		`{"version": 2, "id": "abc", "kind": "copy"}`,
+		`{"version": 1, "id": "a/b", "kind": "copy"}`,
+		`{"version": 1, "id": "abc", "kind": "copy"}`,
+		`{"version": 1, "id": "abc", "kind": "move"}`,
+		`{"version": 1, "id": "abc", "kind": "migration"}`,
+	} {
+		if _, err := cf.ImportJob([]byte(doc), nil); err == nil {
+			t.Fatalf("State should be refused: %s", doc)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPausedCopyJob(t *testing.T) {
+	// Test a paused job starts no chunks until it is resumed.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	data := []byte("hello paused world")
+	fs.put("src/file.bin", data)
+
+	control := NewTransferControl()
+	control.Pause()
+
+	job := cf.StartCopy("TEST", "src", "file.bin", "TEST", "dst", "file.bin",
+		&CopyOptions{Control: control})
+
+	select {
+	case <-job.Done():
+		t.Fatalf("Paused job should not finish: %v", job.Wait())
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if _, ok := fs.get("dst/" + segmentName("file.bin", job.ID, 0, DefaultSegmentDigits)); ok {
+		t.Fatalf("No segment should be written while paused.")
+	}
+
+	job.Resume()
+
+	if err := job.Wait(); err != nil {
+		t.Fatalf("Could not copy file: %s", err)
+	}
+
+	copied, _ := fs.get("dst/file.bin")
+	if !bytes.Equal(copied, data) {
+		t.Fatalf("Copied data does not match: %q", copied)
+	}
+}
+
+func TestResumeJobByID(t *testing.T) {
+	// Test a job's ID is its transfer ID so it can be resumed by it.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	// Large objects are copied as segments named after the transfer.
+	putLargeObject(fs, "src", "file.bin", "part")
+
+	job := cf.StartCopy("TEST", "src", "file.bin", "TEST", "dst", "file.bin", nil)
+	if err := job.Wait(); err != nil {
+		t.Fatalf("Could not copy file: %s", err)
+	}
+
+	if _, ok := fs.get("dst/" + segmentName("file.bin", job.ID, 0, DefaultSegmentDigits)); !ok {
+		t.Fatalf("Segments should be written under the job ID %s.", job.ID)
+	}
+
+	resumed := cf.StartCopy("TEST", "src", "file.bin", "TEST", "dst", "file.bin",
+		&CopyOptions{TransferID: job.ID})
+	if err := resumed.Wait(); err != nil || resumed.ID != job.ID {
+		t.Fatalf("Could not resume job %s as %s: %v", job.ID, resumed.ID, err)
+	}
+}
+
+func TestCancelPausedCopyJob(t *testing.T) {
+	// Test canceling a paused job stops it without writing anything.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	putLargeObject(fs, "src", "file.bin", "part")
+
+	control := NewTransferControl()
+	control.Pause()
+	job := cf.StartCopy("TEST", "src", "file.bin", "TEST", "dst", "file.bin",
+		&CopyOptions{Control: control})
+
+	job.Cancel()
+	if err := job.Wait(); err != ErrCanceled {
+		t.Fatalf("Canceled job should fail with ErrCanceled: %v", err)
+	}
+
+	if _, ok := fs.get("dst/file.bin"); ok {
+		t.Fatalf("Canceled job should not write a manifest.")
+	}
+}
+
+func TestJobStatus(t *testing.T) {
+	// Test a stopped job says why it stopped.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	putLargeObject(fs, "src", "file.bin", "part")
+
+	control := NewTransferControl()
+	control.Pause()
+	job := cf.StartCopy("TEST", "src", "file.bin", "TEST", "dst", "file.bin",
+		&CopyOptions{Control: control})
+	if status := job.Status(); status.Reason != JobPaused || status.Terminal {
+		t.Fatalf("Unexpected status of a paused job %+v", status)
+	}
+
+	job.Cancel()
+	job.Wait()
+	if status := job.Status(); status.Reason != JobCanceled || !status.Terminal || status.Err != ErrCanceled {
+		t.Fatalf("Unexpected status of a canceled job %+v", status)
+	}
+	if state, _ := job.State(); state.Reason != "canceled" || !state.Done {
+		t.Fatalf("Unexpected state %+v", state)
+	}
+
+	job = cf.StartCopy("TEST", "src", "file.bin", "TEST", "dst", "file.bin", nil)
+	job.Wait()
+	if status := job.Status(); status.Reason != JobCompleted || status.Err != nil {
+		t.Fatalf("Unexpected status of a finished job %+v", status)
+	}
+
+	cases := map[JobReason]error{
+		JobBudgetExceeded: fmt.Errorf("Step 1 of migration m: %w", &BudgetExceededError{Reason: "3 failures", Failures: &MultiError{}}),
+		JobPanicked:       &PanicError{Object: "file.bin", Chunk: 2},
+		JobFailed:         errors.New("Could not fetch cloud file, status: 500"),
+	}
+	for reason, err := range cases {
+		stopped := &Job{TransferControl: NewTransferControl(), done: make(chan bool), err: err}
+		close(stopped.done)
+		if status := stopped.Status(); status.Reason != reason || status.Err != err {
+			t.Fatalf("Expected %s, got %+v", reason, status)
+		}
+	}
+}
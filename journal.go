@@ -0,0 +1,111 @@
+package gocloudfiles
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// A DeletionJournal remembers, per mirrored container pair, when each
+// destination object was first seen missing from the source, so mirror
+// deletes can wait out a confirmation window.
+type DeletionJournal interface {
+	GetMissing(key string) (map[string]time.Time, error)
+	SetMissing(key string, missing map[string]time.Time) error
+}
+
+// MemoryDeletionJournal keeps the journal for the life of the process.
+type MemoryDeletionJournal struct {
+	mutex   sync.Mutex
+	entries map[string]map[string]time.Time
+}
+
+func NewMemoryDeletionJournal() *MemoryDeletionJournal {
+	return &MemoryDeletionJournal{entries: make(map[string]map[string]time.Time)}
+}
+
+func (mj *MemoryDeletionJournal) GetMissing(key string) (map[string]time.Time, error) {
+	mj.mutex.Lock()
+	defer mj.mutex.Unlock()
+
+	missing := make(map[string]time.Time, len(mj.entries[key]))
+	for name, seen := range mj.entries[key] {
+		missing[name] = seen
+	}
+	return missing, nil
+}
+
+func (mj *MemoryDeletionJournal) SetMissing(key string, missing map[string]time.Time) error {
+	mj.mutex.Lock()
+	defer mj.mutex.Unlock()
+
+	mj.entries[key] = missing
+	return nil
+}
+
+// FileDeletionJournal persists the journal as a JSON object in a local file
+// so the confirmation window spans restarts between scheduled runs.
+type FileDeletionJournal struct {
+	mutex sync.Mutex
+	path  string
+}
+
+func NewFileDeletionJournal(path string) *FileDeletionJournal {
+	return &FileDeletionJournal{path: path}
+}
+
+func (fj *FileDeletionJournal) load() (map[string]map[string]time.Time, error) {
+	entries := make(map[string]map[string]time.Time)
+
+	data, err := ioutil.ReadFile(fj.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &entries)
+	return entries, err
+}
+
+func (fj *FileDeletionJournal) GetMissing(key string) (map[string]time.Time, error) {
+	fj.mutex.Lock()
+	defer fj.mutex.Unlock()
+
+	entries, err := fj.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if entries[key] == nil {
+		return make(map[string]time.Time), nil
+	}
+	return entries[key], nil
+}
+
+func (fj *FileDeletionJournal) SetMissing(key string, missing map[string]time.Time) error {
+	fj.mutex.Lock()
+	defer fj.mutex.Unlock()
+
+	entries, err := fj.load()
+	if err != nil {
+		return err
+	}
+	entries[key] = missing
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so a crash never leaves a truncated file.
+	tmpPath := fj.path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, fj.path)
+}
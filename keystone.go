@@ -0,0 +1,297 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// KeystoneV3Auth are the credentials and scope for a Keystone v3 identity
+// service, as generic OpenStack clouds run.  Either Password with a user,
+// or an application credential, is given.
+type KeystoneV3Auth struct {
+	// The user is named by UserID, or by UserName within the domain of
+	// UserDomainID or UserDomainName, "Default" when neither is set.
+	UserID         string
+	UserName       string
+	UserDomainID   string
+	UserDomainName string
+	Password       string
+
+	// An application credential is named by its ID, or by its name
+	// together with the user that owns it.  It carries its own scope.
+	ApplicationCredentialID     string
+	ApplicationCredentialName   string
+	ApplicationCredentialSecret string
+
+	// Password tokens are scoped to the project of ProjectID, or of
+	// ProjectName within ProjectDomainID or ProjectDomainName, or else to
+	// the domain of DomainID or DomainName.  Unscoped tokens carry no
+	// catalog.
+	ProjectID         string
+	ProjectName       string
+	ProjectDomainID   string
+	ProjectDomainName string
+	DomainID          string
+	DomainName        string
+}
+
+type keystoneDomain struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type keystoneUser struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name,omitempty"`
+	Domain   *keystoneDomain `json:"domain,omitempty"`
+	Password string          `json:"password,omitempty"`
+}
+
+type keystonePassword struct {
+	User keystoneUser `json:"user"`
+}
+
+type keystoneApplicationCredential struct {
+	ID     string        `json:"id,omitempty"`
+	Name   string        `json:"name,omitempty"`
+	User   *keystoneUser `json:"user,omitempty"`
+	Secret string        `json:"secret"`
+}
+
+type keystoneIdentity struct {
+	Methods               []string                       `json:"methods"`
+	Password              *keystonePassword              `json:"password,omitempty"`
+	ApplicationCredential *keystoneApplicationCredential `json:"application_credential,omitempty"`
+}
+
+type keystoneProject struct {
+	ID     string          `json:"id,omitempty"`
+	Name   string          `json:"name,omitempty"`
+	Domain *keystoneDomain `json:"domain,omitempty"`
+}
+
+type keystoneScope struct {
+	Project *keystoneProject `json:"project,omitempty"`
+	Domain  *keystoneDomain  `json:"domain,omitempty"`
+}
+
+type keystoneAuthRequest struct {
+	Auth struct {
+		Identity keystoneIdentity `json:"identity"`
+		Scope    *keystoneScope   `json:"scope,omitempty"`
+	} `json:"auth"`
+}
+
+type keystoneEndpoint struct {
+	Interface string `json:"interface"`
+	Region    string `json:"region"`
+	RegionID  string `json:"region_id"`
+	URL       string `json:"url"`
+}
+
+type keystoneService struct {
+	Name      string             `json:"name"`
+	Type      string             `json:"type"`
+	Endpoints []keystoneEndpoint `json:"endpoints"`
+}
+
+type keystoneTokenResponse struct {
+	Token struct {
+		ExpiresAt string            `json:"expires_at"`
+		Project   tenantData        `json:"project"`
+		Catalog   []keystoneService `json:"catalog"`
+	} `json:"token"`
+}
+
+func NewCloudFilesKeystoneV3(endpoint string, auth KeystoneV3Auth) *CloudFiles {
+	/*
+	   Create a new cloud files object authenticating against the Keystone
+	   v3 identity service at endpoint, such as
+	   https://keystone.example.com:5000/v3.
+	*/
+	cf := NewCloudFiles(auth.UserName, "")
+	if cf.userName == "" {
+		cf.userName = auth.UserID
+	}
+	cf.keystone = &auth
+	cf.SetIdentityEndpoint(endpoint)
+	return cf
+}
+
+func (k KeystoneV3Auth) domain(id, name string) *keystoneDomain {
+	if id == "" && name == "" {
+		return nil
+	}
+	return &keystoneDomain{ID: id, Name: name}
+}
+
+func (k KeystoneV3Auth) user() *keystoneUser {
+	/*
+		The user, with its domain when it is named rather than given by ID.
+	*/
+	if k.UserID != "" {
+		return &keystoneUser{ID: k.UserID}
+	}
+	if k.UserName == "" {
+		return nil
+	}
+
+	domain := k.domain(k.UserDomainID, k.UserDomainName)
+	if domain == nil {
+		domain = &keystoneDomain{Name: "Default"}
+	}
+	return &keystoneUser{Name: k.UserName, Domain: domain}
+}
+
+func (k KeystoneV3Auth) request() (*keystoneAuthRequest, error) {
+	/*
+		The body of a token request for the credentials.
+		Returns a tuple of request, error
+	*/
+	var req keystoneAuthRequest
+	identity := &req.Auth.Identity
+
+	if k.ApplicationCredentialSecret != "" {
+		credential := &keystoneApplicationCredential{
+			ID:     k.ApplicationCredentialID,
+			Secret: k.ApplicationCredentialSecret,
+		}
+		if credential.ID == "" {
+			credential.Name = k.ApplicationCredentialName
+			credential.User = k.user()
+			if credential.Name == "" || credential.User == nil {
+				return nil, fmt.Errorf("Application credential needs an ID, or a name and its user.")
+			}
+		}
+
+		identity.Methods = []string{"application_credential"}
+		identity.ApplicationCredential = credential
+		return &req, nil
+	}
+
+	user := k.user()
+	if user == nil || k.Password == "" {
+		return nil, fmt.Errorf("Keystone v3 auth needs a user and password, or an application credential.")
+	}
+	user.Password = k.Password
+
+	identity.Methods = []string{"password"}
+	identity.Password = &keystonePassword{User: *user}
+
+	switch {
+	case k.ProjectID != "":
+		req.Auth.Scope = &keystoneScope{Project: &keystoneProject{ID: k.ProjectID}}
+	case k.ProjectName != "":
+		domain := k.domain(k.ProjectDomainID, k.ProjectDomainName)
+		if domain == nil {
+			return nil, fmt.Errorf("Project %s needs a project domain.", k.ProjectName)
+		}
+		req.Auth.Scope = &keystoneScope{Project: &keystoneProject{Name: k.ProjectName, Domain: domain}}
+	case k.DomainID != "" || k.DomainName != "":
+		req.Auth.Scope = &keystoneScope{Domain: k.domain(k.DomainID, k.DomainName)}
+	}
+
+	return &req, nil
+}
+
+func (cf CloudFiles) requestKeystoneToken() (*http.Response, error) {
+	/*
+		Ask a Keystone v3 identity service for a token.
+	*/
+	body, err := cf.keystone.request()
+	if err != nil {
+		return nil, err
+	}
+
+	payLoad, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", cf.IdentityEndpoint()+"/auth/tokens", bytes.NewReader(payLoad))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	client := cf.httpClient()
+	return client.Do(req)
+}
+
+func (cf CloudFiles) requestKeystoneCatalog(token string) (*http.Response, error) {
+	/*
+		Validate a Keystone v3 token, which answers with its catalog.
+	*/
+	req, err := http.NewRequest("GET", cf.IdentityEndpoint()+"/auth/tokens", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("X-Auth-Token", token)
+	req.Header.Add("X-Subject-Token", token)
+	client := cf.httpClient()
+	return client.Do(req)
+}
+
+func readKeystoneAccess(resp *http.Response) (*accessWrapper, error) {
+	/*
+		Parse a Keystone v3 token response, whose token is in the
+		X-Subject-Token header, into the v2.0 form the rest of the client
+		reads.  Endpoints are grouped by region, a region's public and
+		internal interfaces becoming its PublicURL and InternalURL, the
+		public one standing in for a missing internal one.
+	*/
+	var respData keystoneTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+		return nil, err
+	}
+
+	var access accessWrapper
+	access.Access.Token = tokenData{
+		Id:      resp.Header.Get("X-Subject-Token"),
+		Expires: respData.Token.ExpiresAt,
+		Tenant:  respData.Token.Project,
+	}
+
+	for _, service := range respData.Token.Catalog {
+		catalog := serviceCatalog{Name: service.Name, Type: service.Type}
+		regions := make(map[string]int)
+
+		for _, endpoint := range service.Endpoints {
+			region := endpoint.RegionID
+			if region == "" {
+				region = endpoint.Region
+			}
+
+			i, found := regions[region]
+			if !found {
+				i = len(catalog.Endpoints)
+				regions[region] = i
+				catalog.Endpoints = append(catalog.Endpoints, serviceEndpoints{
+					Region:   region,
+					TenantId: respData.Token.Project.Id,
+				})
+			}
+
+			url := strings.TrimSuffix(endpoint.URL, "/")
+			switch endpoint.Interface {
+			case "public":
+				catalog.Endpoints[i].PublicURL = url
+			case "internal":
+				catalog.Endpoints[i].InternalURL = url
+			}
+		}
+
+		for i := range catalog.Endpoints {
+			if catalog.Endpoints[i].InternalURL == "" {
+				catalog.Endpoints[i].InternalURL = catalog.Endpoints[i].PublicURL
+			}
+		}
+		access.Access.Catalog = append(access.Access.Catalog, catalog)
+	}
+
+	return &access, nil
+}
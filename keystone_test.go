@@ -0,0 +1,147 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKeystone is a Keystone v3 identity service issuing tokens numbered
+// from 1, with a catalog naming the fake Swift as region TEST.
+type fakeKeystone struct {
+	mutex    sync.Mutex
+	issued   int
+	requests []keystoneAuthRequest
+	server   *httptest.Server
+}
+
+func newFakeKeystone(fs *fakeSwift) *fakeKeystone {
+	fk := &fakeKeystone{}
+	fk.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/auth/tokens" {
+			w.WriteHeader(404)
+			return
+		}
+
+		fk.mutex.Lock()
+		token := r.Header.Get("X-Subject-Token")
+		if r.Method == "POST" {
+			var body keystoneAuthRequest
+			json.NewDecoder(r.Body).Decode(&body)
+			fk.requests = append(fk.requests, body)
+			fk.issued++
+			token = fmt.Sprintf("token%d", fk.issued)
+		}
+		fk.mutex.Unlock()
+
+		var resp keystoneTokenResponse
+		resp.Token.ExpiresAt = time.Now().Add(time.Hour).UTC().Format("2006-01-02T15:04:05.000000Z")
+		resp.Token.Project.Id = "project-id"
+		resp.Token.Catalog = []keystoneService{
+			{Name: "keystone", Type: "identity", Endpoints: []keystoneEndpoint{
+				{Interface: "public", RegionID: "TEST", URL: fk.server.URL + "/v3"},
+			}},
+			{Name: "swift", Type: "object-store", Endpoints: []keystoneEndpoint{
+				{Interface: "public", RegionID: "TEST", URL: fs.server.URL + "/"},
+				{Interface: "internal", RegionID: "TEST", URL: fs.server.URL},
+				{Interface: "admin", RegionID: "TEST", URL: "http://admin.invalid"},
+				{Interface: "public", Region: "OTHER", URL: fs.server.URL},
+			}},
+		}
+
+		w.Header().Set("X-Subject-Token", token)
+		w.WriteHeader(201)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	return fk
+}
+
+func TestKeystoneV3Password(t *testing.T) {
+	// Test a password token scoped to a project authorizes, loads the v3
+	// catalog and is renewed when Swift rejects it.
+	fs := newFakeSwift()
+	defer fs.Close()
+	fk := newFakeKeystone(fs)
+	defer fk.server.Close()
+
+	cf := NewCloudFilesKeystoneV3(fk.server.URL+"/v3/", KeystoneV3Auth{
+		UserName: "demo", Password: "secret",
+		ProjectName: "demo", ProjectDomainName: "Default",
+	})
+	if err := cf.Authorize(); err != nil {
+		t.Fatalf("Could not authorize: %s", err)
+	}
+
+	if cf.tenantId != "project-id" || cf.dcs["TEST"] != fs.server.URL || cf.dcsInternal["OTHER"] != fs.server.URL {
+		t.Fatalf("Unexpected catalog: %s %v %v", cf.tenantId, cf.dcs, cf.dcsInternal)
+	}
+	if cf.TokenExpiresAt().IsZero() {
+		t.Fatalf("Token expiry not read.")
+	}
+
+	body, _ := json.Marshal(fk.requests[0])
+	expected := `{"auth":{"identity":{"methods":["password"],"password":{"user":{"name":"demo",` +
+		`"domain":{"name":"Default"},"password":"secret"}}},"scope":{"project":{"name":"demo","domain":{"name":"Default"}}}}}`
+	if string(body) != expected {
+		t.Fatalf("Unexpected auth request %s", body)
+	}
+
+	fs.token = "token2"
+	if _, err := cf.PutFile("TEST", "testing", "file", bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatalf("Could not put after the token expired: %s", err)
+	}
+	if fk.issued != 2 {
+		t.Fatalf("Expected a new token, %d were issued.", fk.issued)
+	}
+
+	if err := cf.RefreshCatalog(); err != nil {
+		t.Fatalf("Could not refresh the catalog: %s", err)
+	}
+	if fk.issued != 2 || cf.token() != "token2" {
+		t.Fatalf("Refreshing the catalog replaced the token.")
+	}
+}
+
+func TestKeystoneV3Requests(t *testing.T) {
+	// Test the identity, scope and validation of each kind of credential.
+	for _, test := range []struct {
+		auth     KeystoneV3Auth
+		expected string
+	}{
+		{KeystoneV3Auth{ApplicationCredentialID: "cred", ApplicationCredentialSecret: "s"},
+			`{"methods":["application_credential"],"application_credential":{"id":"cred","secret":"s"}}`},
+		{KeystoneV3Auth{ApplicationCredentialName: "cred", ApplicationCredentialSecret: "s", UserID: "u"},
+			`{"methods":["application_credential"],"application_credential":{"name":"cred","user":{"id":"u"},"secret":"s"}}`},
+		{KeystoneV3Auth{UserID: "u", Password: "p", DomainID: "d"},
+			`{"methods":["password"],"password":{"user":{"id":"u","password":"p"}}} {"domain":{"id":"d"}}`},
+		{KeystoneV3Auth{UserName: "u", UserDomainID: "d", Password: "p", ProjectID: "p1"},
+			`{"methods":["password"],"password":{"user":{"name":"u","domain":{"id":"d"},"password":"p"}}} {"project":{"id":"p1"}}`},
+		{KeystoneV3Auth{UserName: "u"}, "error"},
+		{KeystoneV3Auth{ApplicationCredentialName: "cred", ApplicationCredentialSecret: "s"}, "error"},
+		{KeystoneV3Auth{UserName: "u", Password: "p", ProjectName: "p"}, "error"},
+	} {
+		req, err := test.auth.request()
+		got := "error"
+		if err == nil {
+			identity, _ := json.Marshal(req.Auth.Identity)
+			got = string(identity)
+			if req.Auth.Scope != nil {
+				scope, _ := json.Marshal(req.Auth.Scope)
+				got += " " + string(scope)
+			}
+		}
+		if got != test.expected {
+			t.Fatalf("Expected %s, got %s", test.expected, got)
+		}
+	}
+
+	if !strings.Contains(fmt.Sprint(NewCloudFilesKeystoneV3("http://keystone.invalid/v3", KeystoneV3Auth{}).Authorize()), "needs a user") {
+		t.Fatalf("Authorized without credentials.")
+	}
+}
@@ -0,0 +1,304 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The heartbeat object a LagMonitor writes when LagOptions.Object is empty.
+const DefaultHeartbeatObject = ".heartbeat"
+
+// LagOptions tune a LagMonitor.  Zero values take the defaults.
+type LagOptions struct {
+	// Object is the heartbeat's name in both containers,
+	// DefaultHeartbeatObject when empty.
+	Object string
+
+	// Interval between heartbeats, a minute when zero.
+	Interval time.Duration
+
+	// PollInterval is how often the destination is checked for a
+	// heartbeat, a second when zero.
+	PollInterval time.Duration
+
+	// Timeout is how long a heartbeat may take to reach the destination
+	// before it is counted as missed, 15 minutes when zero.
+	Timeout time.Duration
+}
+
+func (options LagOptions) withDefaults() LagOptions {
+	if options.Object == "" {
+		options.Object = DefaultHeartbeatObject
+	}
+	if options.Interval <= 0 {
+		options.Interval = time.Minute
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 15 * time.Minute
+	}
+	return options
+}
+
+// A LagSample is the outcome of one heartbeat.
+type LagSample struct {
+	// Sent is when the heartbeat was written to the source.
+	Sent time.Time
+	// Lag is how long it took to appear in the destination.
+	Lag time.Duration
+	// Missed is set when it did not appear within the timeout.
+	Missed bool
+	// Err is why the heartbeat could not be written or checked.
+	Err error
+}
+
+// ReplicationLag is what a LagMonitor measured so far.
+type ReplicationLag struct {
+	Source      ObjectLocation
+	Destination ObjectLocation
+
+	// Last is the lag of the newest heartbeat that arrived, Max the
+	// largest lag seen.
+	Last time.Duration
+	Max  time.Duration
+
+	// Current is how far behind the destination is now: the age of the
+	// oldest heartbeat sent since the last one arrived, or Last when none
+	// is outstanding.  It keeps growing while replication is stalled.
+	Current time.Duration
+
+	// Heartbeats sent, of which Missed did not arrive in time and Failed
+	// could not be written or checked.
+	Heartbeats int
+	Missed     int
+	Failed     int
+
+	// LastSample is the latest heartbeat's outcome.
+	LastSample LagSample
+}
+
+// A LagMonitor measures the replication lag between two containers, such
+// as the two ends of a container sync or ReplicateContainer schedule,
+// with a heartbeat object written to the source and waited for in the
+// destination.  Its String method makes it an expvar.Var.
+type LagMonitor struct {
+	cf          CloudFiles
+	source      ObjectLocation
+	destination ObjectLocation
+	options     LagOptions
+	now         func() time.Time
+
+	mutex    sync.Mutex
+	sequence int
+	lag      ReplicationLag
+	pending  time.Time
+	stop     chan bool
+	wg       sync.WaitGroup
+}
+
+func (cf CloudFiles) NewLagMonitor(sourceDC, sourceBucket, destDC, destBucket string,
+	options *LagOptions) *LagMonitor {
+	/*
+		Monitor the lag of replication from the source container to the
+		destination container.  The replication itself is left to whatever
+		already copies the containers, the heartbeat must be among the
+		objects it copies.  A nil options takes the defaults.
+	*/
+	if options == nil {
+		options = &LagOptions{}
+	}
+	resolved := options.withDefaults()
+
+	source := ObjectLocation{Region: sourceDC, Container: sourceBucket, Object: resolved.Object}
+	destination := ObjectLocation{Region: destDC, Container: destBucket, Object: resolved.Object}
+	return &LagMonitor{
+		cf:          cf,
+		source:      source,
+		destination: destination,
+		options:     resolved,
+		now:         time.Now,
+		lag:         ReplicationLag{Source: source, Destination: destination},
+	}
+}
+
+func (lm *LagMonitor) Start() {
+	/*
+		Send a heartbeat every interval in the background until Stop.  A
+		heartbeat is only sent once the previous one arrived or was missed.
+	*/
+	lm.mutex.Lock()
+	defer lm.mutex.Unlock()
+
+	if lm.stop != nil {
+		return
+	}
+
+	lm.stop = make(chan bool)
+	lm.wg.Add(1)
+	go lm.loop(lm.stop)
+}
+
+func (lm *LagMonitor) Stop() {
+	/*
+		Stop sending heartbeats and wait for the one in flight to be
+		abandoned.
+	*/
+	lm.mutex.Lock()
+	if lm.stop != nil {
+		close(lm.stop)
+		lm.stop = nil
+	}
+	lm.mutex.Unlock()
+
+	lm.wg.Wait()
+}
+
+func (lm *LagMonitor) Measure() LagSample {
+	/*
+		Send one heartbeat now and wait until it arrives in the destination
+		or the timeout passes.  The sample is also added to Lag.
+	*/
+	return lm.measure(nil)
+}
+
+func (lm *LagMonitor) Lag() ReplicationLag {
+	/*
+		The lag measured so far, with Current as of now.
+	*/
+	lm.mutex.Lock()
+	defer lm.mutex.Unlock()
+
+	lag := lm.lag
+	if !lm.pending.IsZero() {
+		if behind := lm.now().Sub(lm.pending); behind > lag.Current {
+			lag.Current = behind
+		}
+	}
+	return lag
+}
+
+func (lm *LagMonitor) String() string {
+	/*
+		The lag as JSON, in seconds, for expvar.Publish.
+	*/
+	lag := lm.Lag()
+	metric := struct {
+		Source         string  `json:"source"`
+		Destination    string  `json:"destination"`
+		LastSeconds    float64 `json:"last_seconds"`
+		MaxSeconds     float64 `json:"max_seconds"`
+		CurrentSeconds float64 `json:"current_seconds"`
+		Heartbeats     int     `json:"heartbeats"`
+		Missed         int     `json:"missed"`
+		Failed         int     `json:"failed"`
+	}{
+		Source:         lag.Source.String(),
+		Destination:    lag.Destination.String(),
+		LastSeconds:    lag.Last.Seconds(),
+		MaxSeconds:     lag.Max.Seconds(),
+		CurrentSeconds: lag.Current.Seconds(),
+		Heartbeats:     lag.Heartbeats,
+		Missed:         lag.Missed,
+		Failed:         lag.Failed,
+	}
+
+	encoded, _ := json.Marshal(metric)
+	return string(encoded)
+}
+
+func (lm *LagMonitor) loop(stop chan bool) {
+	defer lm.wg.Done()
+
+	ticker := time.NewTicker(lm.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		lm.measure(stop)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (lm *LagMonitor) measure(stop chan bool) LagSample {
+	/*
+		Write a heartbeat and poll the destination until its etag matches.
+		Every heartbeat holds its send time and a sequence number, so no
+		two share an etag and an older heartbeat is never mistaken for it.
+	*/
+	lm.mutex.Lock()
+	lm.sequence++
+	sample := LagSample{Sent: lm.now()}
+	payload := fmt.Sprintf("%s %d\n", sample.Sent.UTC().Format(time.RFC3339Nano), lm.sequence)
+	lm.lag.Heartbeats++
+	if lm.pending.IsZero() {
+		lm.pending = sample.Sent
+	}
+	lm.mutex.Unlock()
+
+	etag, err := lm.cf.PutFile(lm.source.Region, lm.source.Container, lm.source.Object,
+		bytes.NewReader([]byte(payload)))
+	if err != nil {
+		sample.Err = fmt.Errorf("Could not write heartbeat to %s: %w", lm.source, err)
+		return lm.record(sample)
+	}
+
+	poll := time.NewTicker(lm.options.PollInterval)
+	defer poll.Stop()
+	deadline := sample.Sent.Add(lm.options.Timeout)
+
+	var lastErr error
+	for {
+		headers, err := lm.cf.headObject(lm.destination.Region, lm.destination.Container, lm.destination.Object)
+		switch {
+		case err == nil && sameETag(headers.Get("Etag"), etag):
+			sample.Lag = lm.now().Sub(sample.Sent)
+			return lm.record(sample)
+		case err != nil && !errors.Is(err, ErrObjectMissing):
+			lastErr = err
+		}
+
+		if !lm.now().Before(deadline) {
+			sample.Missed = true
+			sample.Err = lastErr
+			return lm.record(sample)
+		}
+
+		select {
+		case <-stop:
+			sample.Err = fmt.Errorf("Heartbeat to %s abandoned.", lm.destination)
+			return lm.record(sample)
+		case <-poll.C:
+		}
+	}
+}
+
+func (lm *LagMonitor) record(sample LagSample) LagSample {
+	lm.mutex.Lock()
+	defer lm.mutex.Unlock()
+
+	lm.lag.LastSample = sample
+	switch {
+	case sample.Missed:
+		lm.lag.Missed++
+	case sample.Err != nil:
+		lm.lag.Failed++
+	default:
+		lm.lag.Last = sample.Lag
+		lm.lag.Current = sample.Lag
+		if sample.Lag > lm.lag.Max {
+			lm.lag.Max = sample.Lag
+		}
+		lm.pending = time.Time{}
+	}
+	return sample
+}
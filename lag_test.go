@@ -0,0 +1,78 @@
+package gocloudfiles
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLagMonitorMeasures(t *testing.T) {
+	// Test a heartbeat copied to the destination is timed.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.dcs["DEST"] = fs.server.URL
+
+	stop := make(chan bool)
+	defer close(stop)
+	go func() {
+		// Replicate the heartbeat a little after it is written.
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+			if data, ok := fs.get("source/" + DefaultHeartbeatObject); ok {
+				fs.put("dest/"+DefaultHeartbeatObject, data)
+			}
+		}
+	}()
+
+	monitor := cf.NewLagMonitor("TEST", "source", "DEST", "dest",
+		&LagOptions{PollInterval: 5 * time.Millisecond, Timeout: 5 * time.Second})
+	sample := monitor.Measure()
+	if sample.Err != nil || sample.Missed {
+		t.Fatalf("Heartbeat did not arrive: %+v", sample)
+	}
+	if sample.Lag <= 0 {
+		t.Fatalf("Unexpected lag %s", sample.Lag)
+	}
+
+	lag := monitor.Lag()
+	if lag.Heartbeats != 1 || lag.Last != sample.Lag || lag.Current != sample.Lag || lag.Max != sample.Lag {
+		t.Fatalf("Unexpected lag %+v", lag)
+	}
+	if lag.Destination.String() != "DEST:dest/.heartbeat" {
+		t.Fatalf("Unexpected destination %s", lag.Destination)
+	}
+}
+
+func TestLagMonitorMissed(t *testing.T) {
+	// Test a heartbeat that never arrives is missed and the lag keeps
+	// growing.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	monitor := cf.NewLagMonitor("TEST", "source", "TEST", "dest",
+		&LagOptions{PollInterval: 5 * time.Millisecond, Timeout: 30 * time.Millisecond})
+	sample := monitor.Measure()
+	if !sample.Missed || sample.Err != nil {
+		t.Fatalf("Expected a missed heartbeat, got %+v", sample)
+	}
+
+	first := monitor.Lag()
+	if first.Missed != 1 || first.Current < 30*time.Millisecond {
+		t.Fatalf("Unexpected lag %+v", first)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if later := monitor.Lag(); later.Current <= first.Current {
+		t.Fatalf("Lag did not grow from %s to %s", first.Current, later.Current)
+	}
+
+	if metric := monitor.String(); !strings.Contains(metric, `"missed":1`) ||
+		!strings.Contains(metric, `"source":"TEST:source/.heartbeat"`) {
+		t.Fatalf("Unexpected metric %s", metric)
+	}
+}
@@ -0,0 +1,139 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// A LeaderElector elects one agent of a fleet to run a job.  Every agent
+// campaigns for the same Lock; the winner renews it as long as it runs and
+// the others take over once its lease lapses.
+type LeaderElector struct {
+	lock     *Lock
+	interval time.Duration
+
+	mutex   sync.Mutex
+	lastErr error
+	stop    chan bool
+	wg      sync.WaitGroup
+}
+
+func (cf CloudFiles) NewLeaderElector(dc, bucket, job, agent string, lease time.Duration) *LeaderElector {
+	/*
+		Campaign as agent for the leadership of job, held as the lock object
+		job in bucket.  The leader renews every third of the lease, so it
+		survives missing two renewals, and a failed leader is replaced
+		within a lease.
+	*/
+	lock := cf.NewLock(dc, bucket, job, agent, lease)
+	return &LeaderElector{
+		lock:     lock,
+		interval: lock.ttl / 3,
+	}
+}
+
+func (le *LeaderElector) Start() {
+	/*
+		Campaign in the background until Stop.
+	*/
+	le.mutex.Lock()
+	defer le.mutex.Unlock()
+
+	if le.stop != nil {
+		return
+	}
+
+	le.stop = make(chan bool)
+	le.wg.Add(1)
+	go le.loop(le.stop)
+}
+
+func (le *LeaderElector) Stop() {
+	/*
+		Stop campaigning and step down, so another agent takes over at once
+		rather than after the lease.
+	*/
+	le.mutex.Lock()
+	if le.stop != nil {
+		close(le.stop)
+		le.stop = nil
+	}
+	le.mutex.Unlock()
+
+	le.wg.Wait()
+
+	if le.IsLeader() {
+		le.lock.Release()
+	}
+}
+
+func (le *LeaderElector) IsLeader() bool {
+	/*
+		Whether this agent holds an unexpired lease.  A leader that cannot
+		renew stops being one when its lease runs out, even before it learns
+		another agent took over.
+	*/
+	return le.lock.now().Before(le.lock.Expires())
+}
+
+func (le *LeaderElector) LastError() error {
+	/*
+		The error of the last campaign, other than losing to another agent.
+	*/
+	le.mutex.Lock()
+	defer le.mutex.Unlock()
+
+	return le.lastErr
+}
+
+func (le *LeaderElector) Guard(task func() error) func() error {
+	/*
+		Wrap a task, e.g. for Scheduler.Add, so it only runs on the leader.
+		On the other agents it does nothing and returns nil.
+	*/
+	return func() error {
+		if !le.IsLeader() {
+			return nil
+		}
+		return task()
+	}
+}
+
+func (le *LeaderElector) loop(stop chan bool) {
+	defer le.wg.Done()
+
+	ticker := time.NewTicker(le.interval)
+	defer ticker.Stop()
+
+	for {
+		le.campaign()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (le *LeaderElector) campaign() {
+	/*
+		Renew the lease when leading, otherwise try to take it.
+	*/
+	err := ErrLockLost
+	if !le.lock.Expires().IsZero() {
+		err = le.lock.Renew()
+	}
+
+	if err == ErrLockLost {
+		err = le.lock.Acquire()
+		if errors.Is(err, ErrLockHeld) {
+			err = nil
+		}
+	}
+
+	le.mutex.Lock()
+	le.lastErr = err
+	le.mutex.Unlock()
+}
@@ -0,0 +1,68 @@
+package gocloudfiles
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaderElectorFailover(t *testing.T) {
+	// Test one agent leads and another takes over once its lease lapses.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	a := cf.NewLeaderElector("TEST", "locks", "replicate", "agent-a", time.Minute)
+	b := cf.NewLeaderElector("TEST", "locks", "replicate", "agent-b", time.Minute)
+	a.lock.now, b.lock.now = clock, clock
+
+	a.campaign()
+	b.campaign()
+	if !a.IsLeader() || b.IsLeader() || b.LastError() != nil {
+		t.Fatalf("Exactly the first agent should lead: %v %v %v", a.IsLeader(), b.IsLeader(), b.LastError())
+	}
+
+	runs := 0
+	task := func() error { runs++; return nil }
+	a.Guard(task)()
+	b.Guard(task)()
+	if runs != 1 {
+		t.Fatalf("Only the leader should run the task, ran %d times.", runs)
+	}
+
+	// The leader keeps its lease by renewing.
+	now = now.Add(40 * time.Second)
+	a.campaign()
+	b.campaign()
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("Renewing leader should keep leading.")
+	}
+
+	// The leader stops renewing and its lease lapses.
+	now = now.Add(2 * time.Minute)
+	fs.mutex.Lock()
+	fs.expireAt = now
+	fs.mutex.Unlock()
+
+	if a.IsLeader() {
+		t.Fatalf("Lapsed leader should step down.")
+	}
+
+	b.campaign()
+	a.campaign()
+	if a.IsLeader() || !b.IsLeader() {
+		t.Fatalf("Second agent should take over.")
+	}
+
+	b.Stop()
+	if _, ok := fs.get("locks/replicate"); ok {
+		t.Fatalf("Stopped leader should release its lease.")
+	}
+
+	a.campaign()
+	if !a.IsLeader() {
+		t.Fatalf("First agent should lead again after the release.")
+	}
+}
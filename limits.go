@@ -0,0 +1,76 @@
+package gocloudfiles
+
+import (
+	"sync"
+)
+
+// ContainerConcurrency caps how many write requests may be in flight
+// against a single container at once, across every goroutine using the
+// client.  Default applies to each container without an entry in
+// Containers, which is keyed by "<dc>/<bucket>".  Zero means unlimited.
+type ContainerConcurrency struct {
+	Default    int
+	Containers map[string]int
+}
+
+type containerLimiter struct {
+	limits ContainerConcurrency
+	mutex  sync.Mutex
+	slots  map[string]chan bool
+}
+
+func newContainerLimiter(limits ContainerConcurrency) *containerLimiter {
+	return &containerLimiter{
+		limits: limits,
+		slots:  make(map[string]chan bool),
+	}
+}
+
+func (cl *containerLimiter) semaphore(dc, bucket string) chan bool {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+
+	key := dc + "/" + bucket
+	sem, ok := cl.slots[key]
+	if ok {
+		return sem
+	}
+
+	limit, ok := cl.limits.Containers[key]
+	if !ok {
+		limit = cl.limits.Default
+	}
+
+	if limit > 0 {
+		sem = make(chan bool, limit)
+	}
+
+	cl.slots[key] = sem
+	return sem
+}
+
+func (cf CloudFiles) acquireContainer(dc, bucket string) func() {
+	/*
+		Wait for a write slot on the container.  The returned function
+		releases it.
+	*/
+	if cf.containerLimits == nil {
+		return func() {}
+	}
+
+	sem := cf.containerLimits.semaphore(dc, bucket)
+	if sem == nil {
+		return func() {}
+	}
+
+	sem <- true
+	return func() { <-sem }
+}
+
+func (cf *CloudFiles) SetContainerConcurrency(limits ContainerConcurrency) {
+	/*
+		Limit concurrent writes per container so many workers aimed at one
+		container don't trip its write rate limit.
+	*/
+	cf.containerLimits = newContainerLimiter(limits)
+}
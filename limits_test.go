@@ -0,0 +1,52 @@
+package gocloudfiles
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestContainerConcurrency(t *testing.T) {
+	// Test a container cap holds writers back without affecting others.
+	cf := NewCloudFilesImpersonation("token")
+	cf.SetContainerConcurrency(ContainerConcurrency{
+		Default:    2,
+		Containers: map[string]int{"IAD/busy": 1},
+	})
+
+	var mutex sync.Mutex
+	active, peak := 0, 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := cf.acquireContainer("IAD", "busy")
+			defer release()
+
+			mutex.Lock()
+			active++
+			if active > peak {
+				peak = active
+			}
+			mutex.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mutex.Lock()
+			active--
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if peak != 1 {
+		t.Fatalf("Expected at most one writer but saw %d", peak)
+	}
+
+	first := cf.acquireContainer("IAD", "other")
+	second := cf.acquireContainer("IAD", "other")
+	first()
+	second()
+}
@@ -0,0 +1,179 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWalkObjectsPages(t *testing.T) {
+	// Test a listing is streamed across pages in name order.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	fs.pageSize = 2
+
+	for i := 0; i < 5; i++ {
+		fs.put(fmt.Sprintf("testing/obj-%d", i), []byte("x"))
+	}
+	fs.put("testing/other", []byte("x"))
+
+	names := make([]string, 0)
+	err := cf.walkObjects("TEST", "testing", "obj-", func(entry objectEntry) error {
+		names = append(names, entry.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Could not walk objects: %s", err)
+	}
+
+	if fmt.Sprint(names) != "[obj-0 obj-1 obj-2 obj-3 obj-4]" {
+		t.Fatalf("Unexpected listing: %v", names)
+	}
+
+	stop := errors.New("stop")
+	err = cf.walkObjects("TEST", "testing", "", func(entry objectEntry) error {
+		return stop
+	})
+	if err != stop {
+		t.Fatalf("Expected the walk to stop with the callback error: %v", err)
+	}
+}
+
+func TestListingCursorFind(t *testing.T) {
+	// Test a cursor pages forward to the names it is asked for.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	fs.pageSize = 2
+
+	for i := 0; i < 7; i++ {
+		fs.put(fmt.Sprintf("testing/obj-%d", i), []byte("x"))
+	}
+
+	cursor := cf.newListingCursor("TEST", "testing", "")
+	for _, want := range []struct {
+		name  string
+		found bool
+	}{{"obj-1", true}, {"obj-15", false}, {"obj-2", true}, {"obj-6", true}, {"obj-7", false}} {
+		entry, ok, err := cursor.find(want.name)
+		if err != nil || ok != want.found || ok && entry.Name != want.name {
+			t.Fatalf("Unexpected lookup of %s: %+v %v %v", want.name, entry, ok, err)
+		}
+	}
+}
+
+func TestListModifiedSince(t *testing.T) {
+	// Test listings and HEADs report modification times.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	for _, name := range []string{"a", "b", "c"} {
+		fs.put("testing/"+name, []byte(name))
+	}
+	since := fs.modified["testing/b"]
+
+	infos, err := cf.ListModifiedSince("TEST", "testing", "", since)
+	if err != nil || len(infos) != 1 || infos[0].Name != "c" || !infos[0].LastModified.Equal(since.Add(time.Second)) {
+		t.Fatalf("Unexpected objects %+v %v", infos, err)
+	}
+
+	if all, _ := cf.ListObjects("TEST", "testing", ListOptions{}); len(all.Objects) != 3 || all.Objects[0].Bytes != 1 {
+		t.Fatalf("Unexpected listing %+v", all)
+	}
+
+	if modified, err := cf.ModifiedSince("TEST", "testing", "b", since.Add(-time.Second)); err != nil || !modified {
+		t.Fatalf("b was modified in the last second: %v %v", modified, err)
+	}
+	if modified, _ := cf.ModifiedSince("TEST", "testing", "b", since.Add(time.Millisecond)); modified {
+		t.Fatalf("b was not modified since")
+	}
+	if _, err := cf.ModifiedSince("TEST", "testing", "gone", since); !errors.Is(err, ErrObjectMissing) {
+		t.Fatalf("Expected a missing object: %v", err)
+	}
+}
+
+func TestListObjectsOptions(t *testing.T) {
+	// Test delimiters, markers and limits page through a container.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	fs.pageSize = 2
+
+	for _, name := range []string{"a.txt", "logs/1", "logs/2", "photos/x/1", "photos/y", "z.txt"} {
+		fs.put("testing/"+name, []byte(name))
+	}
+
+	names := func(listing *ObjectListing) string {
+		var listed []string
+		for _, info := range listing.Objects {
+			listed = append(listed, info.Name)
+		}
+		return strings.Join(append(listed, listing.Subdirs...), ",")
+	}
+
+	listing, err := cf.ListObjects("TEST", "testing", ListOptions{Delimiter: "/"})
+	if err != nil || names(listing) != "a.txt,z.txt,logs/,photos/" || listing.NextMarker != "" {
+		t.Fatalf("Unexpected top level %s %v", names(listing), err)
+	}
+	if listing.Objects[0].Bytes != 5 || listing.Objects[0].ETag == "" || listing.Objects[0].LastModified.IsZero() {
+		t.Fatalf("Objects not described: %+v", listing.Objects[0])
+	}
+
+	listing, _ = cf.ListObjects("TEST", "testing", ListOptions{Prefix: "photos/", Delimiter: "/"})
+	if names(listing) != "photos/y,photos/x/" {
+		t.Fatalf("Unexpected subdirectory %s", names(listing))
+	}
+
+	listing, _ = cf.ListObjects("TEST", "testing", ListOptions{Marker: "a.txt", EndMarker: "photos/y"})
+	if names(listing) != "logs/1,logs/2,photos/x/1" {
+		t.Fatalf("Unexpected range %s", names(listing))
+	}
+
+	var pages []string
+	options := ListOptions{Limit: 3, Delimiter: "/"}
+	for {
+		listing, err := cf.ListObjects("TEST", "testing", options)
+		if err != nil {
+			t.Fatalf("Could not list: %s", err)
+		}
+		pages = append(pages, names(listing))
+		if listing.NextMarker == "" {
+			break
+		}
+		options.Marker = listing.NextMarker
+	}
+	if strings.Join(pages, "|") != "a.txt,logs/,photos/|z.txt" {
+		t.Fatalf("Unexpected pages %q", pages)
+	}
+}
+
+func TestObjectExists(t *testing.T) {
+	// Test only a 404 means an object does not exist.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	fs.put("testing/here", []byte("x"))
+
+	if exists, err := cf.ObjectExists("TEST", "testing", "here"); err != nil || !exists {
+		t.Fatalf("Object should exist: %v %v", exists, err)
+	}
+	if exists, err := cf.ObjectExists("TEST", "testing", "gone"); err != nil || exists {
+		t.Fatalf("Object should not exist: %v %v", exists, err)
+	}
+	if _, err := cf.ObjectExists("NOWHERE", "testing", "here"); err == nil {
+		t.Fatalf("An unknown region should be an error.")
+	}
+
+	if _, _, err := cf.GetFileSize("TEST", "testing", "gone"); !errors.Is(err, ErrObjectMissing) {
+		t.Fatalf("GetFileSize should report a missing object: %v", err)
+	}
+	var out bytes.Buffer
+	if _, _, err := cf.GetChunk("TEST", "testing", "gone", &out, 0, 0); !errors.Is(err, ErrObjectMissing) {
+		t.Fatalf("GetChunk should report a missing object: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Windows rejects these in file names, along with control characters and
+// trailing dots and spaces.
+const windowsReservedChars = `<>:"\|?*`
+
+// Windows device names that cannot be used as a file name, whatever the
+// extension.
+var windowsDeviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Longer paths need the \\?\ prefix on Windows.
+const windowsMaxPath = 260
+
+func escapeByte(b byte) string {
+	return fmt.Sprintf("%%%02X", b)
+}
+
+func EncodeLocalName(name string) string {
+	/*
+		Map an object name to one every platform accepts as a relative
+		path, keeping "/" as the separator.  Characters Windows refuses,
+		trailing dots and spaces, device names such as "CON" and "%"
+		itself are percent encoded, so DecodeLocalName reverses it.  "."
+		and ".." components are kept.
+	*/
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		// Relative components stay as they are so they are still refused.
+		if part == "." || part == ".." {
+			continue
+		}
+
+		var encoded strings.Builder
+		for j := 0; j < len(part); j++ {
+			b := part[j]
+			if b == '%' || b < 0x20 || strings.IndexByte(windowsReservedChars, b) >= 0 {
+				encoded.WriteString(escapeByte(b))
+			} else {
+				encoded.WriteByte(b)
+			}
+		}
+		part = encoded.String()
+
+		// Trailing dots and spaces are silently dropped by Windows.
+		trimmed := strings.TrimRight(part, ". ")
+		if len(trimmed) < len(part) {
+			var tail strings.Builder
+			for j := len(trimmed); j < len(part); j++ {
+				tail.WriteString(escapeByte(part[j]))
+			}
+			part = trimmed + tail.String()
+		}
+
+		base := part
+		if dot := strings.IndexByte(base, '.'); dot >= 0 {
+			base = base[:dot]
+		}
+		if windowsDeviceNames[strings.ToUpper(base)] {
+			part = escapeByte(part[0]) + part[1:]
+		}
+
+		parts[i] = part
+	}
+	return strings.Join(parts, "/")
+}
+
+func DecodeLocalName(local string) (string, error) {
+	/*
+		Reverse EncodeLocalName.
+		Returns a tuple of object name, error
+	*/
+	parts := strings.Split(filepath.ToSlash(local), "/")
+	for i, part := range parts {
+		decoded, err := url.PathUnescape(part)
+		if err != nil {
+			return "", fmt.Errorf("Could not decode local name %q: %s", local, err)
+		}
+		parts[i] = decoded
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+func longPath(path string) string {
+	/*
+		Let Windows open paths past MAX_PATH.  Other platforms are left
+		alone.
+	*/
+	if runtime.GOOS != "windows" || len(path) < windowsMaxPath || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
@@ -0,0 +1,55 @@
+package gocloudfiles
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeLocalName(t *testing.T) {
+	// Test names Windows refuses are encoded reversibly.
+	for name, want := range map[string]string{
+		"plain/file.txt":    "plain/file.txt",
+		"what?.txt":         "what%3F.txt",
+		"a:b/c|d":           "a%3Ab/c%7Cd",
+		"trailing./dot. ":   "trailing%2E/dot%2E%20",
+		"100%":              "100%25",
+		"con.txt":           "%63on.txt",
+		"dir/NUL":           "dir/%4EUL",
+		"console.txt":       "console.txt",
+		"tab\tname":         "tab%09name",
+		"../escape":         "../escape",
+		"unicode/ünïcødé.x": "unicode/ünïcødé.x",
+	} {
+		encoded := EncodeLocalName(name)
+		if encoded != want {
+			t.Fatalf("Expected %q to encode as %q but got %q", name, want, encoded)
+		}
+
+		decoded, err := DecodeLocalName(encoded)
+		if err != nil || decoded != name {
+			t.Fatalf("Could not decode %q back to %q: %q %v", encoded, name, decoded, err)
+		}
+	}
+}
+
+func TestDownloadObjectsPortableNames(t *testing.T) {
+	// Test portable names are used for the local files.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("testing/reports/q1:2016.csv", []byte("q1"))
+	destDir := t.TempDir()
+
+	_, err := cf.DownloadObjects("TEST", "testing", []string{"reports/q1:2016.csv"}, destDir,
+		&DownloadObjectsOptions{PortableNames: true})
+	if err != nil {
+		t.Fatalf("Could not download: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(destDir, "reports", "q1%3A2016.csv"))
+	if err != nil || string(data) != "q1" {
+		t.Fatalf("Unexpected download %q %v", data, err)
+	}
+}
@@ -0,0 +1,251 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The metadata a lock object carries.
+const (
+	lockOwnerHeader   = "X-Object-Meta-Lock-Owner"
+	lockTokenHeader   = "X-Object-Meta-Lock-Token"
+	lockExpiresHeader = "X-Object-Meta-Lock-Expires"
+)
+
+// ErrLockHeld is matched by errors.Is when Acquire finds the lock taken.
+var ErrLockHeld = errors.New("Lock is held by another owner.")
+
+// ErrLockLost is returned by Renew and Release once the lock expired or was
+// taken over.
+var ErrLockLost = errors.New("Lock is no longer held.")
+
+// LockHeldError is returned when Acquire finds the lock taken.
+type LockHeldError struct {
+	Name    string
+	Owner   string
+	Expires time.Time
+}
+
+func (e *LockHeldError) Error() string {
+	return fmt.Sprintf("Lock %s is held by %s until %s.", e.Name, e.Owner, e.Expires.Format(time.RFC3339))
+}
+
+func (e *LockHeldError) Is(target error) bool {
+	return target == ErrLockHeld
+}
+
+// A Lock is an advisory lock kept as an object.  Acquiring creates the
+// object only if it does not exist, and the object expires with the lock
+// through X-Delete-At, so a holder that dies releases it after its TTL.
+type Lock struct {
+	cf     CloudFiles
+	dc     string
+	bucket string
+	name   string
+	owner  string
+	ttl    time.Duration
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+	now     func() time.Time
+}
+
+func (cf CloudFiles) NewLock(dc, bucket, name, owner string, ttl time.Duration) *Lock {
+	/*
+		Describe a lock held as the object name in bucket.  Owner names the
+		holder for others to see, ttl is how long the lock lasts unless it
+		is renewed and is rounded up to whole seconds.
+	*/
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+
+	return &Lock{
+		cf:     cf,
+		dc:     dc,
+		bucket: bucket,
+		name:   name,
+		owner:  owner,
+		ttl:    (ttl + time.Second - 1) / time.Second * time.Second,
+		now:    time.Now,
+	}
+}
+
+func (l *Lock) Acquire() error {
+	/*
+		Take the lock.  The server creates the lock object only if it does
+		not exist, so of several agents acquiring at once exactly one
+		succeeds.  An expired lock no longer exists and can be taken.
+		Returns a *LockHeldError when another owner holds it
+	*/
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	token := NewTransferID()
+	expires := l.now().Add(l.ttl).Truncate(time.Second)
+
+	_, err := l.cf.putFile(l.dc, l.bucket, l.name, bytes.NewReader(nil), &WriteOptions{
+		Headers:  l.headers(token, expires),
+		DeleteAt: expires,
+		IfExists: FailIfExists,
+	})
+
+	if errors.Is(err, ErrDestinationExists) {
+		held, headErr := l.cf.headObject(l.dc, l.bucket, l.name)
+		if errors.Is(headErr, ErrObjectMissing) {
+			// Released or expired since, the caller may try again.
+			return &LockHeldError{Name: l.name}
+		}
+		if headErr != nil {
+			return headErr
+		}
+		return &LockHeldError{
+			Name:    l.name,
+			Owner:   held.Get(lockOwnerHeader),
+			Expires: lockExpiry(held),
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	l.token, l.expires = token, expires
+	return nil
+}
+
+func (l *Lock) Renew() error {
+	/*
+		Extend a held lock by its TTL from now.  Renew well within the TTL:
+		once the lock has expired another owner may hold it and Renew
+		returns ErrLockLost.
+	*/
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if err := l.check(); err != nil {
+		return err
+	}
+
+	expires := l.now().Add(l.ttl).Truncate(time.Second)
+	headers := l.headers(l.token, expires)
+	headers["X-Delete-At"] = strconv.FormatInt(expires.Unix(), 10)
+
+	err := l.cf.updateObject(l.dc, l.bucket, l.name, headers)
+	if errors.Is(err, ErrObjectMissing) {
+		l.token = ""
+		return ErrLockLost
+	}
+	if err != nil {
+		return err
+	}
+
+	l.expires = expires
+	return nil
+}
+
+func (l *Lock) Release() error {
+	/*
+		Give up a held lock so another owner can take it at once.
+		Returns ErrLockLost when the lock was no longer held
+	*/
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if err := l.check(); err != nil {
+		return err
+	}
+
+	l.token = ""
+	return l.cf.deleteObject(l.dc, l.bucket, l.name)
+}
+
+func (l *Lock) Expires() time.Time {
+	/*
+		When the lock lapses unless it is renewed, zero when it is not held.
+	*/
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.token == "" {
+		return time.Time{}
+	}
+	return l.expires
+}
+
+func (l *Lock) check() error {
+	/*
+		Make sure the lock object is still the one this lock created.
+	*/
+	if l.token == "" || !l.now().Before(l.expires) {
+		l.token = ""
+		return ErrLockLost
+	}
+
+	held, err := l.cf.headObject(l.dc, l.bucket, l.name)
+	if errors.Is(err, ErrObjectMissing) || err == nil && held.Get(lockTokenHeader) != l.token {
+		l.token = ""
+		return ErrLockLost
+	}
+	return err
+}
+
+func (l *Lock) headers(token string, expires time.Time) map[string]string {
+	return map[string]string{
+		lockOwnerHeader:   l.owner,
+		lockTokenHeader:   token,
+		lockExpiresHeader: strconv.FormatInt(expires.Unix(), 10),
+	}
+}
+
+func lockExpiry(headers http.Header) time.Time {
+	seconds, err := strconv.ParseInt(headers.Get(lockExpiresHeader), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+func (cf CloudFiles) updateObject(dc, bucket, filename string, headers map[string]string) error {
+	/*
+		Replace the metadata of an object with a POST.  Metadata not in
+		headers is removed.
+	*/
+	url, err := cf.objectURL(dc, bucket, filename)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("X-Auth-Token", cf.token())
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := cf.do(dc, req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return &ObjectMissingError{Region: dc, Bucket: bucket, Name: filename}
+	}
+
+	if !succeeded(resp) {
+		return newCloudFilesError(resp, "Could not update cloud file")
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLockAcquireRenewRelease(t *testing.T) {
+	// Test only one owner holds a lock and the other takes it once it lapses.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	a := cf.NewLock("TEST", "locks", "job", "agent-a", time.Minute)
+	b := cf.NewLock("TEST", "locks", "job", "agent-b", time.Minute)
+	a.now, b.now = clock, clock
+
+	if err := a.Acquire(); err != nil {
+		t.Fatalf("Could not acquire lock: %s", err)
+	}
+
+	err := b.Acquire()
+	var held *LockHeldError
+	if !errors.Is(err, ErrLockHeld) || !errors.As(err, &held) || held.Owner != "agent-a" {
+		t.Fatalf("Held lock should not be acquired: %v", err)
+	}
+
+	now = now.Add(50 * time.Second)
+	if err := a.Renew(); err != nil {
+		t.Fatalf("Could not renew lock: %s", err)
+	}
+	if !a.Expires().Equal(now.Add(time.Minute)) {
+		t.Fatalf("Renewed lock should expire a TTL from now: %s", a.Expires())
+	}
+
+	// The holder stops renewing and the lock object expires.
+	now = now.Add(2 * time.Minute)
+	fs.mutex.Lock()
+	fs.expireAt = now
+	fs.mutex.Unlock()
+
+	if err := b.Acquire(); err != nil {
+		t.Fatalf("Expired lock should be acquired: %s", err)
+	}
+
+	if err := a.Renew(); err != ErrLockLost {
+		t.Fatalf("Lapsed lock should not renew: %v", err)
+	}
+	if err := a.Release(); err != ErrLockLost {
+		t.Fatalf("Lapsed lock should not release: %v", err)
+	}
+
+	if err := b.Release(); err != nil {
+		t.Fatalf("Could not release lock: %s", err)
+	}
+	if _, ok := fs.get("locks/job"); ok {
+		t.Fatalf("Released lock object should be deleted.")
+	}
+	if err := a.Acquire(); err != nil {
+		t.Fatalf("Released lock should be acquired: %s", err)
+	}
+}
+
+func TestLockRenewDetectsTakeover(t *testing.T) {
+	// Test a holder whose object was replaced learns it lost the lock.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	lock := cf.NewLock("TEST", "locks", "job", "agent-a", time.Minute)
+	if err := lock.Acquire(); err != nil {
+		t.Fatalf("Could not acquire lock: %s", err)
+	}
+
+	fs.mutex.Lock()
+	fs.headers["locks/job"].Set(lockTokenHeader, "someone-else")
+	fs.mutex.Unlock()
+
+	if err := lock.Renew(); err != ErrLockLost {
+		t.Fatalf("Replaced lock should not renew: %v", err)
+	}
+	if _, ok := fs.get("locks/job"); !ok {
+		t.Fatalf("Another owner's lock should not be deleted.")
+	}
+}
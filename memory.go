@@ -0,0 +1,63 @@
+package gocloudfiles
+
+import (
+	"sync"
+)
+
+// memoryBudget is a weighted semaphore over bytes held in memory.
+type memoryBudget struct {
+	mutex sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+func newMemoryBudget(limit int64) *memoryBudget {
+	mb := &memoryBudget{limit: limit}
+	mb.cond = sync.NewCond(&mb.mutex)
+	return mb
+}
+
+func (cf *CloudFiles) SetMemoryLimit(maxBufferedBytes int64) {
+	/*
+		Cap the bytes the client buffers in memory at once across every
+		goroutine using it.  Operations wait for room before buffering, and
+		one needing more than the whole budget runs on its own.  Chunks of
+		a copy and segments of a download are staged in temporary files and
+		do not count.  Zero or less removes the cap.
+	*/
+	if maxBufferedBytes <= 0 {
+		cf.memory = nil
+		return
+	}
+	cf.memory = newMemoryBudget(maxBufferedBytes)
+}
+
+func (cf CloudFiles) reserveMemory(n int64) func() {
+	/*
+		Wait until n bytes fit the memory budget and take them.  The
+		returned function gives them back.
+	*/
+	mb := cf.memory
+	if mb == nil || n <= 0 {
+		return func() {}
+	}
+
+	if n > mb.limit {
+		n = mb.limit
+	}
+
+	mb.mutex.Lock()
+	for mb.used+n > mb.limit {
+		mb.cond.Wait()
+	}
+	mb.used += n
+	mb.mutex.Unlock()
+
+	return func() {
+		mb.mutex.Lock()
+		mb.used -= n
+		mb.mutex.Unlock()
+		mb.cond.Broadcast()
+	}
+}
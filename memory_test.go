@@ -0,0 +1,42 @@
+package gocloudfiles
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	// Test reservations wait for room and oversized ones run alone.
+	cf := NewCloudFilesImpersonation("token")
+	cf.SetMemoryLimit(100)
+
+	first := cf.reserveMemory(60)
+
+	var wg sync.WaitGroup
+	reserved := make(chan bool)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		release := cf.reserveMemory(50)
+		reserved <- true
+		release()
+	}()
+
+	select {
+	case <-reserved:
+		t.Fatalf("A reservation over the budget should wait.")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	first()
+	<-reserved
+	wg.Wait()
+
+	// More than the whole budget is clamped instead of waiting forever.
+	cf.reserveMemory(1000)()
+
+	if cf.memory.used != 0 {
+		t.Fatalf("Every reservation should be given back: %d", cf.memory.used)
+	}
+}
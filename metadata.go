@@ -0,0 +1,92 @@
+package gocloudfiles
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Custom metadata is sent and returned under this header prefix.
+const objectMetaPrefix = "X-Object-Meta-"
+
+// ObjectMetadata is everything a HEAD of an object reports.
+type ObjectMetadata struct {
+	ObjectInfo
+
+	// Metadata holds the X-Object-Meta-* values, keyed by the name after
+	// the prefix in canonical form, such as "Owner".
+	Metadata map[string]string
+
+	// Headers is the whole response, for headers without a field.
+	Headers http.Header
+}
+
+func (cf CloudFiles) GetObjectMetadata(dc, bucket, filename string) (*ObjectMetadata, error) {
+	/*
+		Describe an object with its custom metadata and every header of
+		its HEAD.  The error matches ErrObjectMissing when there is no such
+		object.
+		Returns a tuple of metadata, error
+	*/
+	headers, err := cf.headObject(dc, bucket, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &ObjectMetadata{
+		ObjectInfo: *newObjectInfo(filename, headers),
+		Metadata:   map[string]string{},
+		Headers:    headers,
+	}
+	for key := range headers {
+		if strings.HasPrefix(key, objectMetaPrefix) {
+			metadata.Metadata[strings.TrimPrefix(key, objectMetaPrefix)] = headers.Get(key)
+		}
+	}
+	return metadata, nil
+}
+
+func (cf CloudFiles) SetObjectMetadata(dc, bucket, filename string, metadata map[string]string) error {
+	/*
+		Change an object's custom metadata, keyed by the name after
+		X-Object-Meta-.  Keys not given keep their values and an empty
+		value removes a key.  A POST replaces all of an object's metadata,
+		so what it already has, its content type and expiry are sent again
+		with the changes.  The error matches ErrObjectMissing when there is
+		no such object.
+	*/
+	headers, err := cf.headObject(dc, bucket, filename)
+	if err != nil {
+		return err
+	}
+
+	update := postedHeaders(headers)
+	if deleteAt := headers.Get("X-Delete-At"); deleteAt != "" {
+		update["X-Delete-At"] = deleteAt
+	}
+	for key, value := range metadata {
+		key = http.CanonicalHeaderKey(objectMetaPrefix + key)
+		if value == "" {
+			delete(update, key)
+		} else {
+			update[key] = value
+		}
+	}
+
+	return cf.updateObject(dc, bucket, filename, update)
+}
+
+func postedHeaders(headers http.Header) map[string]string {
+	/*
+		The headers of an object a POST would drop unless sent again, but
+		for X-Delete-At, which callers decide on.
+	*/
+	update := map[string]string{}
+	for key := range headers {
+		switch {
+		case strings.HasPrefix(key, objectMetaPrefix), key == "Content-Type",
+			key == "Content-Encoding", key == "Content-Disposition":
+			update[key] = headers.Get(key)
+		}
+	}
+	return update
+}
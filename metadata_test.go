@@ -0,0 +1,49 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestObjectMetadata(t *testing.T) {
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("testing/report.pdf", []byte("data"))
+	fs.headers["testing/report.pdf"] = http.Header{
+		"Content-Type":        {"application/pdf"},
+		"X-Object-Meta-Owner": {"etl"},
+		"X-Object-Meta-Stage": {"raw"},
+		"X-Delete-At":         {"1900000000"},
+	}
+
+	metadata, err := cf.GetObjectMetadata("TEST", "testing", "report.pdf")
+	if err != nil || metadata.Bytes != 4 || metadata.ContentType != "application/pdf" || metadata.LastModified.IsZero() {
+		t.Fatalf("Unexpected metadata %+v %v", metadata, err)
+	}
+	if len(metadata.Metadata) != 2 || metadata.Metadata["Owner"] != "etl" {
+		t.Fatalf("Unexpected custom metadata %v", metadata.Metadata)
+	}
+
+	err = cf.SetObjectMetadata("TEST", "testing", "report.pdf", map[string]string{"stage": "clean", "Owner": ""})
+	if err != nil {
+		t.Fatalf("Could not set metadata: %s", err)
+	}
+
+	metadata, _ = cf.GetObjectMetadata("TEST", "testing", "report.pdf")
+	if len(metadata.Metadata) != 1 || metadata.Metadata["Stage"] != "clean" {
+		t.Fatalf("Unexpected metadata after update %v", metadata.Metadata)
+	}
+	if metadata.DeleteAt.Unix() != 1900000000 {
+		t.Fatalf("Update dropped the expiry: %v", metadata.DeleteAt)
+	}
+
+	if _, err := cf.GetObjectMetadata("TEST", "testing", "gone"); !errors.Is(err, ErrObjectMissing) {
+		t.Fatalf("Expected a missing object: %v", err)
+	}
+	if err := cf.SetObjectMetadata("TEST", "testing", "gone", nil); !errors.Is(err, ErrObjectMissing) {
+		t.Fatalf("Expected a missing object: %v", err)
+	}
+}
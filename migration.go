@@ -0,0 +1,263 @@
+package gocloudfiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Where a MigrationStep reads from or writes to.
+type MigrationLocation struct {
+	Region    string   `json:"region"`
+	Container string   `json:"container"`
+	Prefix    string   `json:"prefix,omitempty"`
+	Objects   []string `json:"objects,omitempty"`
+}
+
+// A MigrationStep is one operation of a Migration:
+//
+//	"copy"   copies From.Objects to the To container, keeping their names
+//	"sync"   replicates the From container, under From.Prefix, to To
+//	"delete" deletes the objects under From.Prefix
+type MigrationStep struct {
+	Op   string            `json:"op"`
+	From MigrationLocation `json:"from"`
+	To   MigrationLocation `json:"to,omitempty"`
+
+	// IfExists is "overwrite", "skip", "fail" or "changed", the default,
+	// for copies, so running a migration again only copies what changed.
+	IfExists string `json:"if_exists,omitempty"`
+
+	// MaxObjects and MaxBytes bound a delete, see DeleteGuard.
+	MaxObjects int   `json:"max_objects,omitempty"`
+	MaxBytes   int64 `json:"max_bytes,omitempty"`
+}
+
+// A Migration is a reviewable list of steps, run in order, usually kept as
+// a JSON document next to the code that needs it.
+type Migration struct {
+	Name  string          `json:"name"`
+	Steps []MigrationStep `json:"steps"`
+}
+
+// MigrationOptions tune StartMigration.
+type MigrationOptions struct {
+	// TransferID names the segments of every copy, passing a previous
+	// job's ID resumes its copies.  A new one is made when empty.
+	TransferID string
+
+	// Control pauses and resumes the migration between chunks and steps.
+	Control *TransferControl
+
+	// Results receives one Result per object of every step when set.
+	Results chan<- Result
+}
+
+var migrationExistsPolicies = map[string]ExistsPolicy{
+	"":          OverwriteIfChanged,
+	"changed":   OverwriteIfChanged,
+	"overwrite": OverwriteExisting,
+	"skip":      SkipExisting,
+	"fail":      FailIfExists,
+}
+
+func ParseMigration(data []byte) (*Migration, error) {
+	/*
+		Decode and validate a JSON migration document.
+	*/
+	migration := &Migration{}
+	if err := json.Unmarshal(data, migration); err != nil {
+		return nil, fmt.Errorf("Could not parse migration: %s", err)
+	}
+
+	if err := migration.Validate(); err != nil {
+		return nil, err
+	}
+	return migration, nil
+}
+
+func LoadMigration(path string) (*Migration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMigration(data)
+}
+
+func (m *Migration) Validate() error {
+	/*
+		Check every step is complete before anything runs, so a migration
+		never stops half way on a typo.  Deletes need a prefix, a whole
+		container cannot be deleted by a migration.
+	*/
+	if len(m.Steps) == 0 {
+		return fmt.Errorf("Migration %s has no steps.", m.Name)
+	}
+
+	for i, step := range m.Steps {
+		if err := step.validate(); err != nil {
+			return fmt.Errorf("Step %d of migration %s: %w", i+1, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func (step MigrationStep) validate() error {
+	if step.From.Region == "" || step.From.Container == "" {
+		return fmt.Errorf("A %s step needs a from region and container.", step.Op)
+	}
+
+	if _, ok := migrationExistsPolicies[step.IfExists]; !ok {
+		return fmt.Errorf("Unknown if_exists %q.", step.IfExists)
+	}
+
+	switch step.Op {
+	case "copy", "sync":
+		if step.To.Region == "" || step.To.Container == "" {
+			return fmt.Errorf("A %s step needs a to region and container.", step.Op)
+		}
+	case "delete":
+		if step.To.Region != "" || step.To.Container != "" {
+			return fmt.Errorf("A delete step has no destination.")
+		}
+	default:
+		return fmt.Errorf("Unknown op %q.", step.Op)
+	}
+
+	if step.Op == "copy" && len(step.From.Objects) == 0 {
+		return fmt.Errorf("A copy step needs from objects.")
+	}
+	for _, name := range step.From.Objects {
+		if name == "" {
+			return fmt.Errorf("A %s step has an empty object name.", step.Op)
+		}
+	}
+
+	if step.Op == "delete" && step.From.Prefix == "" {
+		return fmt.Errorf("A delete step needs a prefix.")
+	}
+
+	return nil
+}
+
+func (cf CloudFiles) PreviewMigration(m *Migration) ([]Result, *Plan, error) {
+	/*
+		Run a migration as a dry run with reads sent for real, see
+		SetDryRun.  The results are its diff: a success for every object
+		that would be copied or deleted and a skip for every object already
+		up to date.  The plan lists the writes it would have sent.
+		Returns a tuple of results, plan, error
+	*/
+	plan := &Plan{SendReads: true}
+	preview := cf
+	preview.SetDryRun(plan)
+
+	results := make(chan Result)
+	collected := make(chan []Result)
+	go func() {
+		var all []Result
+		for result := range results {
+			all = append(all, result)
+		}
+		collected <- all
+	}()
+
+	_, err := preview.runMigration(m, &MigrationOptions{TransferID: NewTransferID(), Results: results})
+	close(results)
+
+	return <-collected, plan, err
+}
+
+func (cf CloudFiles) StartMigration(m *Migration, options *MigrationOptions) *Job {
+	/*
+		Run the steps of a migration in order in the background.  A step
+		with failures stops the migration, so a delete after a copy never
+		runs when the copy did not complete.  The job's error is that of
+		the first step that failed.
+	*/
+	copied := MigrationOptions{}
+	if options != nil {
+		copied = *options
+	}
+
+	if copied.TransferID == "" {
+		copied.TransferID = NewTransferID()
+	}
+
+	if copied.Control == nil {
+		copied.Control = NewTransferControl()
+	}
+
+	job := &Job{
+		TransferControl: copied.Control,
+		ID:              copied.TransferID,
+		cf:              cf,
+		state:           JobState{ID: copied.TransferID, Kind: "migration", Migration: m},
+		done:            make(chan bool),
+	}
+
+	go func() {
+		defer close(job.done)
+		job.err = catchPanic(m.Name, -1, func() (err error) {
+			_, err = cf.runMigration(m, &copied)
+			return err
+		})
+	}()
+
+	return job
+}
+
+func (cf CloudFiles) runMigration(m *Migration, options *MigrationOptions) (*Report, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	for i, step := range m.Steps {
+		if err := options.Control.wait(); err != nil {
+			return report, err
+		}
+
+		stepReport, err := cf.runMigrationStep(step, options)
+		if stepReport != nil {
+			report.Succeeded += stepReport.Succeeded
+			report.Skipped += stepReport.Skipped
+			report.Failed += stepReport.Failed
+			report.Bytes += stepReport.Bytes
+			report.Failures = append(report.Failures, stepReport.Failures...)
+			report.Aborted = report.Aborted || stepReport.Aborted
+		}
+		if err != nil {
+			return report, fmt.Errorf("Step %d of migration %s: %w", i+1, m.Name, err)
+		}
+	}
+
+	return report, nil
+}
+
+func (cf CloudFiles) runMigrationStep(step MigrationStep, options *MigrationOptions) (*Report, error) {
+	copyOptions := &CopyOptions{
+		TransferID:      options.TransferID,
+		Control:         options.Control,
+		CreateContainer: true,
+	}
+
+	switch step.Op {
+	case "copy":
+		copyOptions.IfExists = migrationExistsPolicies[step.IfExists]
+		return cf.CopyFiles(step.From.Region, step.From.Container, step.To.Region, step.To.Container,
+			step.From.Objects, &CopyFilesOptions{Copy: copyOptions, Results: options.Results})
+	case "sync":
+		return cf.ReplicateContainer(step.From.Region, step.From.Container, step.To.Region, step.To.Container,
+			&ReplicateOptions{Prefix: step.From.Prefix, Copy: copyOptions, Results: options.Results})
+	default:
+		return cf.DeletePrefix(step.From.Region, step.From.Container, step.From.Prefix, &DeleteOptions{
+			Guard: DeleteGuard{
+				MaxObjects:    step.MaxObjects,
+				MaxBytes:      step.MaxBytes,
+				RequirePrefix: true,
+			},
+			Results: options.Results,
+		})
+	}
+}
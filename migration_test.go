@@ -0,0 +1,110 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testMigration = `{
+	"name": "move-logs",
+	"steps": [
+		{"op": "copy", "from": {"region": "TEST", "container": "old", "objects": ["a.txt", "b.txt"]},
+		 "to": {"region": "TEST", "container": "new"}},
+		{"op": "sync", "from": {"region": "TEST", "container": "old", "prefix": "logs/"},
+		 "to": {"region": "TEST", "container": "new"}},
+		{"op": "delete", "from": {"region": "TEST", "container": "old", "prefix": "logs/"}, "max_objects": 10}
+	]
+}`
+
+func TestMigrationPreviewAndRun(t *testing.T) {
+	// Test a migration previews without writing, runs its steps in order and replays cheaply.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("old/a.txt", []byte("a"))
+	fs.put("old/b.txt", []byte("b"))
+	fs.put("old/logs/1.log", []byte("one"))
+
+	migration, err := ParseMigration([]byte(testMigration))
+	if err != nil {
+		t.Fatalf("Could not parse migration: %s", err)
+	}
+
+	results, plan, err := cf.PreviewMigration(migration)
+	if err != nil {
+		t.Fatalf("Could not preview migration: %s", err)
+	}
+	if len(results) != 4 || len(plan.Requests()) == 0 {
+		t.Fatalf("Unexpected preview %+v", results)
+	}
+	if _, ok := fs.get("new/a.txt"); ok {
+		t.Fatalf("Preview should not copy anything.")
+	}
+	if _, ok := fs.get("old/logs/1.log"); !ok {
+		t.Fatalf("Preview should not delete anything.")
+	}
+
+	job := cf.StartMigration(migration, nil)
+	if err := job.Wait(); err != nil {
+		t.Fatalf("Could not run migration: %s", err)
+	}
+
+	for _, path := range []string{"new/a.txt", "new/b.txt", "new/logs/1.log"} {
+		if _, ok := fs.get(path); !ok {
+			t.Fatalf("Migration did not copy %s.", path)
+		}
+	}
+	if _, ok := fs.get("old/logs/1.log"); ok {
+		t.Fatalf("Migration did not delete the old logs.")
+	}
+
+	results, _, err = cf.PreviewMigration(migration)
+	if err != nil {
+		t.Fatalf("Could not preview again: %s", err)
+	}
+	for _, result := range results {
+		if result.Op == "copy" && result.Status != ResultSkipped {
+			t.Fatalf("Replayed copy should be skipped: %+v", result)
+		}
+	}
+}
+
+func TestMigrationStopsAtFailedStep(t *testing.T) {
+	// Test a failed copy keeps the following delete from running.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("old/logs/1.log", []byte("one"))
+
+	migration, err := ParseMigration([]byte(strings.Replace(testMigration, `"b.txt"`, `"missing.txt"`, 1)))
+	if err != nil {
+		t.Fatalf("Could not parse migration: %s", err)
+	}
+
+	err = cf.StartMigration(migration, nil).Wait()
+	if !errors.Is(err, ErrObjectMissing) {
+		t.Fatalf("Migration should fail on the missing object: %v", err)
+	}
+	if _, ok := fs.get("old/logs/1.log"); !ok {
+		t.Fatalf("Steps after a failed one should not run.")
+	}
+}
+
+func TestMigrationValidate(t *testing.T) {
+	// Test incomplete steps are refused before anything runs.
+	for _, doc := range []string{
+		`{"name": "x", "steps": []}`,
+		`{"name": "x", "steps": [{"op": "move", "from": {"region": "A", "container": "c"}}]}`,
+		`{"name": "x", "steps": [{"op": "copy", "from": {"region": "A", "container": "c"}, "to": {"region": "A", "container": "d"}}]}`,
+		`{"name": "x", "steps": [{"op": "sync", "from": {"region": "A", "container": "c"}}]}`,
+		`{"name": "x", "steps": [{"op": "delete", "from": {"region": "A", "container": "c"}}]}`,
+		`{"name": "x", "steps": [{"op": "copy", "from": {"region": "A", "container": "c", "objects": ["a"]}, "to": {"region": "A", "container": "d"}, "if_exists": "maybe"}]}`,
+	} {
+		if _, err := ParseMigration([]byte(doc)); err == nil {
+			t.Fatalf("Migration should be invalid: %s", doc)
+		}
+	}
+}
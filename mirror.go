@@ -0,0 +1,241 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// A Mirror duplicates writes to a secondary region under the same container
+// and object names, so new objects are born replicated instead of waiting
+// for the next ReplicateContainer run.  It applies to everything written
+// with the WriteOptions: objects, large object segments and their
+// manifests.  Server-side copies are not mirrored.  The containers must
+// exist in the secondary region.
+type Mirror struct {
+	Region string
+
+	// Queue makes the mirrored writes asynchronous: writes return once the
+	// primary succeeded and the data is kept in a temporary file until the
+	// queue uploads it.  Without one every write waits for its mirror.
+	Queue *MirrorQueue
+}
+
+// MirrorStats count the writes a MirrorQueue was given.
+type MirrorStats struct {
+	Queued   int64
+	Mirrored int64
+	Failed   int64
+	LastErr  error
+}
+
+// A MirrorQueue uploads mirrored writes in the background, in the order
+// they were made, so a manifest always follows its segments.  Writes wait
+// when the queue is full.
+type MirrorQueue struct {
+	cf   CloudFiles
+	jobs chan mirrorJob
+	wg   sync.WaitGroup
+
+	mutex sync.Mutex
+	stats MirrorStats
+}
+
+// A mirrorJob is one queued write, of a spooled object or of a manifest.
+type mirrorJob struct {
+	region   string
+	bucket   string
+	filename string
+	spool    *os.File
+	expected string
+	manifest manifestList
+	options  *WriteOptions
+}
+
+func (cf CloudFiles) NewMirrorQueue(size int) *MirrorQueue {
+	/*
+		Start a queue holding up to size writes, 100 when zero.  Close it
+		once the writes using it are done.
+	*/
+	if size <= 0 {
+		size = 100
+	}
+
+	q := &MirrorQueue{cf: cf, jobs: make(chan mirrorJob, size)}
+	q.wg.Add(1)
+	go q.run()
+	return q
+}
+
+func (q *MirrorQueue) Close() error {
+	/*
+		Wait for the queued writes to be mirrored.  The error counts the
+		ones that failed, the next replication run can bring them over.
+	*/
+	close(q.jobs)
+	q.wg.Wait()
+
+	stats := q.Stats()
+	if stats.Failed > 0 {
+		return fmt.Errorf("%d mirrored writes failed, the last: %w", stats.Failed, stats.LastErr)
+	}
+	return nil
+}
+
+func (q *MirrorQueue) Stats() MirrorStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.stats
+}
+
+func (q *MirrorQueue) add(job mirrorJob) {
+	q.mutex.Lock()
+	q.stats.Queued++
+	q.mutex.Unlock()
+
+	q.jobs <- job
+}
+
+func (q *MirrorQueue) run() {
+	defer q.wg.Done()
+
+	for job := range q.jobs {
+		var err error
+		if job.spool != nil {
+			_, err = q.cf.putObject(job.region, job.bucket, job.filename, job.spool, job.expected, job.options)
+			job.spool.Close()
+			os.Remove(job.spool.Name())
+		} else {
+			err = q.cf.putManifest(job.region, job.bucket, job.filename, job.manifest, job.options)
+		}
+
+		q.mutex.Lock()
+		if err != nil {
+			q.stats.Failed++
+			q.stats.LastErr = fmt.Errorf("Could not mirror %s to %s: %w", job.filename, job.region, err)
+		} else {
+			q.stats.Mirrored++
+		}
+		q.mutex.Unlock()
+	}
+}
+
+func (options *WriteOptions) mirrored(dc string) bool {
+	/*
+		Whether writes to dc are duplicated elsewhere.
+	*/
+	return options != nil && options.Mirror != nil && options.Mirror.Region != dc
+}
+
+func (options *WriteOptions) unmirrored() *WriteOptions {
+	/*
+		The options for each of the two writes a mirrored write makes.
+	*/
+	unmirrored := *options
+	unmirrored.Mirror = nil
+	return &unmirrored
+}
+
+func (cf CloudFiles) putMirrored(dc, bucket, filename string, data io.Reader, expected string,
+	options *WriteOptions) (string, error) {
+	/*
+		Write an object to dc, then to the mirror with the primary's etag
+		as the expected MD5, so both hold the same bytes.  Seekable data is
+		read again for a synchronous mirror, other data and anything queued
+		is kept in a temporary file while the primary is written.
+		Returns a tuple of etag, error
+	*/
+	mirror := options.Mirror
+	write := options.unmirrored()
+
+	var spool *os.File
+	queued := false
+	defer func() {
+		if spool != nil && !queued {
+			spool.Close()
+			os.Remove(spool.Name())
+		}
+	}()
+
+	seeker, seekable := data.(io.ReadSeeker)
+	var start int64
+	if seekable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			return "", err
+		}
+	}
+
+	if !seekable || mirror.Queue != nil {
+		var err error
+		if spool, err = ioutil.TempFile("", ""); err != nil {
+			return "", err
+		}
+
+		// Seekable data is spooled up front so the primary can still be
+		// retried.
+		if !seekable {
+			data = io.TeeReader(data, spool)
+		} else if _, err = io.Copy(spool, seeker); err != nil {
+			return "", err
+		} else if _, err = seeker.Seek(start, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+
+	etag, err := cf.putObject(dc, bucket, filename, data, expected, write)
+	if err != nil {
+		return "", err
+	}
+
+	var source io.Reader = spool
+	if spool != nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	} else {
+		source = seeker
+		_, err = seeker.Seek(start, io.SeekStart)
+	}
+	if err != nil {
+		return etag, err
+	}
+
+	if mirror.Queue != nil {
+		queued = true
+		mirror.Queue.add(mirrorJob{region: mirror.Region, bucket: bucket, filename: filename,
+			spool: spool, expected: etag, options: write})
+		return etag, nil
+	}
+
+	if _, err = cf.putObject(mirror.Region, bucket, filename, source, etag, write); err != nil {
+		return etag, fmt.Errorf("Wrote %s to %s but not to mirror %s: %w", filename, dc, mirror.Region, err)
+	}
+	return etag, nil
+}
+
+func (cf CloudFiles) putMirroredManifest(dc, bucket, filename string, manifestItems manifestList,
+	options *WriteOptions) error {
+	/*
+		Write a manifest to dc and the mirror, whose copies of the segments
+		were mirrored before it.
+	*/
+	mirror := options.Mirror
+	write := options.unmirrored()
+
+	if err := cf.putManifest(dc, bucket, filename, manifestItems, write); err != nil {
+		return err
+	}
+
+	if mirror.Queue != nil {
+		mirror.Queue.add(mirrorJob{region: mirror.Region, bucket: bucket, filename: filename,
+			manifest: manifestItems, options: write})
+		return nil
+	}
+
+	if err := cf.putManifest(mirror.Region, bucket, filename, manifestItems, write); err != nil {
+		return fmt.Errorf("Wrote %s to %s but not to mirror %s: %w", filename, dc, mirror.Region, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMirrorSync(t *testing.T) {
+	// Test objects, segments and manifests reach the mirror before the
+	// write returns.
+	primary := newFakeSwift()
+	defer primary.Close()
+	secondary := newFakeSwift()
+	defer secondary.Close()
+	cf := primary.client()
+	cf.dcs["OTHER"] = secondary.server.URL
+
+	write := &WriteOptions{Mirror: &Mirror{Region: "OTHER"}}
+	stream := io.MultiReader(strings.NewReader("not "), strings.NewReader("seekable"))
+	if _, err := cf.PutFileWithOptions("TEST", "media", "stream", stream, write); err != nil {
+		t.Fatalf("Could not put: %s", err)
+	}
+
+	data := bytes.Repeat([]byte("0123456789"), 3)
+	_, err := cf.UploadFile("TEST", "media", "large", bytes.NewReader(data), int64(len(data)),
+		&UploadFileOptions{SegmentSize: 8, Write: write})
+	if err != nil {
+		t.Fatalf("Could not upload: %s", err)
+	}
+
+	for _, fs := range []*fakeSwift{primary, secondary} {
+		if got, _ := fs.get("media/stream"); string(got) != "not seekable" {
+			t.Fatalf("Unexpected stream %q", got)
+		}
+		if got, _ := fs.get("media/large"); !bytes.Equal(got, data) {
+			t.Fatalf("Unexpected large object %q", got)
+		}
+	}
+
+	delete(cf.dcs, "OTHER")
+	if _, err := cf.PutFileWithOptions("TEST", "media", "half", strings.NewReader("data"), write); err == nil {
+		t.Fatalf("A failed mirror write was not reported.")
+	}
+	if _, ok := primary.get("media/half"); !ok {
+		t.Fatalf("The primary write was not kept.")
+	}
+}
+
+func TestMirrorQueue(t *testing.T) {
+	// Test queued mirror writes land once the queue is closed, and
+	// failures are counted.
+	primary := newFakeSwift()
+	defer primary.Close()
+	secondary := newFakeSwift()
+	defer secondary.Close()
+	cf := primary.client()
+	cf.dcs["OTHER"] = secondary.server.URL
+
+	queue := cf.NewMirrorQueue(2)
+	write := &WriteOptions{Mirror: &Mirror{Region: "OTHER", Queue: queue}}
+	data := bytes.Repeat([]byte("abcdefghij"), 3)
+	if _, err := cf.UploadFile("TEST", "media", "large", bytes.NewReader(data), int64(len(data)),
+		&UploadFileOptions{SegmentSize: 8, Write: write}); err != nil {
+		t.Fatalf("Could not upload: %s", err)
+	}
+	secondary.fail("media/broken", 1)
+	if _, err := cf.PutFileWithOptions("TEST", "media", "broken", strings.NewReader("data"), write); err != nil {
+		t.Fatalf("Could not put: %s", err)
+	}
+
+	if err := queue.Close(); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("Expected the failed mirror write, got %v", err)
+	}
+	if stats := queue.Stats(); stats.Queued != 6 || stats.Mirrored != 5 || stats.Failed != 1 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	if got, _ := secondary.get("media/large"); !bytes.Equal(got, data) {
+		t.Fatalf("Unexpected mirrored large object %q", got)
+	}
+}
@@ -0,0 +1,230 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrOutsideNamespace is matched by errors.Is when a name would reach outside
+// its Namespace.
+var ErrOutsideNamespace = errors.New("Name is outside the namespace.")
+
+// NamespaceError is returned for a name a Namespace refuses.
+type NamespaceError struct {
+	Prefix string
+	Name   string
+}
+
+func (e *NamespaceError) Error() string {
+	return fmt.Sprintf("Name %q is outside the namespace %s.", e.Name, e.Prefix)
+}
+
+func (e *NamespaceError) Is(target error) bool {
+	return target == ErrOutsideNamespace
+}
+
+// A Namespace is a handle on the objects under one prefix of one container,
+// e.g. a tenant's storage.  Names given to it are relative to the prefix
+// and may not climb out of it, so a subsystem handed a Namespace cannot
+// touch other tenants' objects.
+type Namespace struct {
+	cf        CloudFiles
+	dc        string
+	container string
+	prefix    string
+}
+
+func (cf CloudFiles) Namespace(dc, container, prefix string) (*Namespace, error) {
+	/*
+		Scope operations to the objects of container whose names begin with
+		prefix.  A prefix that does not end in "/" gets one.
+	*/
+	ns := &Namespace{cf: cf, dc: dc, container: container}
+	return ns.Sub(prefix)
+}
+
+func (ns *Namespace) Sub(prefix string) (*Namespace, error) {
+	/*
+		Narrow the namespace further to a prefix relative to it.
+	*/
+	if prefix == "" {
+		return ns, nil
+	}
+
+	full, err := ns.path(strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Namespace{cf: ns.cf, dc: ns.dc, container: ns.container, prefix: full + "/"}, nil
+}
+
+func (ns *Namespace) Prefix() string {
+	/*
+		The full name prefix of the namespace, e.g. to read Result names.
+	*/
+	return ns.prefix
+}
+
+func (ns *Namespace) path(name string) (string, error) {
+	/*
+		Resolve a relative name to its full object name.  Empty names,
+		absolute names and "." or ".." segments are refused, so no name
+		resolves outside the prefix however it is later interpreted.
+	*/
+	refuse := name == "" || strings.HasPrefix(name, "/") || strings.ContainsRune(name, '\\')
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "." || segment == ".." {
+			refuse = true
+		}
+	}
+
+	if refuse {
+		return "", &NamespaceError{Prefix: ns.prefix, Name: name}
+	}
+	return ns.prefix + name, nil
+}
+
+// Write headers that have Swift read another object, which may be outside
+// the namespace or in another account.
+var namespaceRefusedHeaders = []string{"X-Copy-From", "X-Copy-From-Account", "X-Object-Manifest"}
+
+func (ns *Namespace) checkWrite(options *WriteOptions) error {
+	/*
+		Refuse write options that reach outside the namespace: headers
+		reading other objects and mirrors to other regions.
+	*/
+	if options == nil {
+		return nil
+	}
+
+	for key := range options.Headers {
+		for _, refused := range namespaceRefusedHeaders {
+			if strings.EqualFold(key, refused) {
+				return fmt.Errorf("Header %s is not allowed in the namespace %s: %w", key, ns.prefix, ErrOutsideNamespace)
+			}
+		}
+	}
+	if options.Mirror != nil {
+		return fmt.Errorf("Mirror is not allowed in the namespace %s: %w", ns.prefix, ErrOutsideNamespace)
+	}
+	return nil
+}
+
+func (ns *Namespace) checkCopy(options *CopyOptions) error {
+	/*
+		Refuse copy options that write outside the namespace: status
+		objects and changes to the container itself.
+	*/
+	if options == nil {
+		return nil
+	}
+
+	if options.Progress != nil && options.Progress.Object != nil {
+		return fmt.Errorf("Progress.Object is not allowed in the namespace %s: %w", ns.prefix, ErrOutsideNamespace)
+	}
+	if options.CreateContainer || options.ContainerSettings {
+		return fmt.Errorf("Container changes are not allowed in the namespace %s: %w", ns.prefix, ErrOutsideNamespace)
+	}
+	return ns.checkWrite(options.Write)
+}
+
+func (ns *Namespace) PutFile(filename string, data io.Reader) (string, error) {
+	return ns.PutFileWithOptions(filename, data, nil)
+}
+
+func (ns *Namespace) PutFileWithOptions(filename string, data io.Reader, options *WriteOptions) (string, error) {
+	name, err := ns.path(filename)
+	if err != nil {
+		return "", err
+	}
+	if err := ns.checkWrite(options); err != nil {
+		return "", err
+	}
+	return ns.cf.PutFileWithOptions(ns.dc, ns.container, name, data, options)
+}
+
+func (ns *Namespace) UploadStream(filename string, data io.Reader, options *UploadStreamOptions) (int64, error) {
+	name, err := ns.path(filename)
+	if err != nil {
+		return 0, err
+	}
+	if options != nil {
+		if err := ns.checkWrite(options.Write); err != nil {
+			return 0, err
+		}
+	}
+	return ns.cf.UploadStream(ns.dc, ns.container, name, data, options)
+}
+
+func (ns *Namespace) GetFileSize(filename string) (int64, string, error) {
+	name, err := ns.path(filename)
+	if err != nil {
+		return 0, "", err
+	}
+	return ns.cf.GetFileSize(ns.dc, ns.container, name)
+}
+
+func (ns *Namespace) GetChunk(filename string, out io.Writer, offset, length int64) (int64, string, error) {
+	name, err := ns.path(filename)
+	if err != nil {
+		return 0, "", err
+	}
+	return ns.cf.GetChunk(ns.dc, ns.container, name, out, offset, length)
+}
+
+func (ns *Namespace) DownloadLargeObject(filename string, out io.Writer, options *DownloadOptions) (int64, error) {
+	name, err := ns.path(filename)
+	if err != nil {
+		return 0, err
+	}
+	return ns.cf.DownloadLargeObject(ns.dc, ns.container, name, out, options)
+}
+
+func (ns *Namespace) CopyFile(sourceFile, destFile string, options *CopyOptions) error {
+	/*
+		Copy an object to another name within the namespace.  Options
+		writing outside it, such as a Progress.Object, CreateContainer or
+		a Mirror, are refused.
+	*/
+	source, err := ns.path(sourceFile)
+	if err != nil {
+		return err
+	}
+	dest, err := ns.path(destFile)
+	if err != nil {
+		return err
+	}
+	if err := ns.checkCopy(options); err != nil {
+		return err
+	}
+	return ns.cf.CopyFileWithOptions(ns.dc, ns.container, source, ns.dc, ns.container, dest, options)
+}
+
+func (ns *Namespace) DeleteFile(filename string) error {
+	/*
+		Delete one object, through the trash when one is configured.
+	*/
+	name, err := ns.path(filename)
+	if err != nil {
+		return err
+	}
+	return ns.cf.removeObject(ns.dc, ns.container, name)
+}
+
+func (ns *Namespace) DeletePrefix(prefix string, options *DeleteOptions) (*Report, error) {
+	/*
+		Delete the objects under a prefix relative to the namespace, or all
+		of its objects for an empty prefix.  Results carry full names.
+	*/
+	full := ns.prefix
+	if prefix != "" {
+		var err error
+		if full, err = ns.path(prefix); err != nil {
+			return nil, err
+		}
+	}
+	return ns.cf.DeletePrefix(ns.dc, ns.container, full, options)
+}
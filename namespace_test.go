@@ -0,0 +1,114 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNamespaceScopesNames(t *testing.T) {
+	// Test a namespace reads and writes under its prefix only.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("shared/other/secret.txt", []byte("not yours"))
+
+	tenant, err := cf.Namespace("TEST", "shared", "tenants/acme")
+	if err != nil {
+		t.Fatalf("Could not create namespace: %s", err)
+	}
+
+	if _, err := tenant.PutFile("docs/a.txt", strings.NewReader("hello")); err != nil {
+		t.Fatalf("Could not put file: %s", err)
+	}
+	if _, ok := fs.get("shared/tenants/acme/docs/a.txt"); !ok {
+		t.Fatalf("File should be written under the prefix.")
+	}
+
+	var out bytes.Buffer
+	if _, _, err := tenant.GetChunk("docs/a.txt", &out, 0, 0); err != nil || out.String() != "hello" {
+		t.Fatalf("Could not read file back: %q %v", out.String(), err)
+	}
+
+	for _, name := range []string{"../other/secret.txt", "docs/../../x", "/abs", "", ".", `..\x`} {
+		_, err := tenant.PutFile(name, strings.NewReader("x"))
+		if !errors.Is(err, ErrOutsideNamespace) {
+			t.Fatalf("Name %q should be refused: %v", name, err)
+		}
+	}
+
+	docs, err := tenant.Sub("docs")
+	if err != nil || docs.Prefix() != "tenants/acme/docs/" {
+		t.Fatalf("Unexpected sub namespace %v", err)
+	}
+	if _, err := tenant.Sub("../other"); !errors.Is(err, ErrOutsideNamespace) {
+		t.Fatalf("Sub namespace should not climb out: %v", err)
+	}
+
+	report, err := tenant.DeletePrefix("", nil)
+	if err != nil || report.Succeeded != 1 {
+		t.Fatalf("Could not delete namespace: %v %v", report, err)
+	}
+	if _, ok := fs.get("shared/other/secret.txt"); !ok {
+		t.Fatalf("Objects outside the namespace should not be deleted.")
+	}
+}
+
+func TestNamespaceRefusesEscapes(t *testing.T) {
+	// Test options and headers reaching outside the namespace are refused
+	// before anything is written.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("shared/other/secret.txt", []byte("not yours"))
+	fs.put("shared/tenants/acme/a.txt", []byte("hello"))
+	tenant, err := cf.Namespace("TEST", "shared", "tenants/acme")
+	if err != nil {
+		t.Fatalf("Could not create namespace: %s", err)
+	}
+
+	for _, header := range []string{"X-Copy-From", "x-copy-from-account", "X-Object-Manifest"} {
+		options := &WriteOptions{Headers: map[string]string{header: "shared/other/secret.txt"}}
+		if _, err := tenant.PutFileWithOptions("b.txt", strings.NewReader(""), options); !errors.Is(err, ErrOutsideNamespace) {
+			t.Fatalf("Header %s should be refused: %v", header, err)
+		}
+		_, err := tenant.UploadStream("b.txt", strings.NewReader(""), &UploadStreamOptions{Write: options})
+		if !errors.Is(err, ErrOutsideNamespace) {
+			t.Fatalf("Header %s should be refused for streams: %v", header, err)
+		}
+		if err := tenant.CopyFile("a.txt", "b.txt", &CopyOptions{Write: options}); !errors.Is(err, ErrOutsideNamespace) {
+			t.Fatalf("Header %s should be refused for copies: %v", header, err)
+		}
+	}
+
+	mirror := &WriteOptions{Mirror: &Mirror{Region: "OTHER"}}
+	if _, err := tenant.PutFileWithOptions("b.txt", strings.NewReader("x"), mirror); !errors.Is(err, ErrOutsideNamespace) {
+		t.Fatalf("A mirror should be refused: %v", err)
+	}
+
+	for _, options := range []*CopyOptions{
+		{Progress: &ProgressOptions{Object: &ObjectLocation{Region: "TEST", Container: "shared", Object: "other/status"}}},
+		{Write: mirror},
+		{CreateContainer: true},
+		{ContainerSettings: true},
+	} {
+		if err := tenant.CopyFile("a.txt", "b.txt", options); !errors.Is(err, ErrOutsideNamespace) {
+			t.Fatalf("Copy options %+v should be refused: %v", options, err)
+		}
+	}
+
+	if _, ok := fs.get("shared/tenants/acme/b.txt"); ok {
+		t.Fatalf("Refused writes should not write.")
+	}
+	if _, ok := fs.get("shared/other/status"); ok {
+		t.Fatalf("Refused copies should not write status objects.")
+	}
+
+	options := &WriteOptions{Headers: map[string]string{"X-Object-Meta-Owner": "acme"}}
+	if _, err := tenant.PutFileWithOptions("b.txt", strings.NewReader("x"), options); err != nil {
+		t.Fatalf("Other headers should be allowed: %v", err)
+	}
+}
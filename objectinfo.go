@@ -0,0 +1,230 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"time"
+)
+
+// ErrObjectMissing is matched by errors.Is when an object does not exist.
+var ErrObjectMissing = errors.New("Object does not exist.")
+
+// ObjectMissingError is returned when a HEAD finds no object.
+type ObjectMissingError struct {
+	Region string
+	Bucket string
+	Name   string
+}
+
+func (e *ObjectMissingError) Error() string {
+	return fmt.Sprintf("Could not fetch cloud file %s/%s in region %s, status: 404", e.Bucket, e.Name, e.Region)
+}
+
+func (e *ObjectMissingError) Is(target error) bool {
+	return target == ErrObjectMissing || target == ErrNotFound
+}
+
+// ObjectInfo describes an object as reported by a HEAD request.
+type ObjectInfo struct {
+	Name              string
+	Bytes             int64
+	ETag              string
+	ContentType       string
+	ContentEncoding   string
+	LastModified      time.Time
+	StaticLargeObject bool
+	// DeleteAt is when the object expires, zero when it does not.
+	DeleteAt time.Time
+}
+
+func newObjectInfo(name string, headers http.Header) *ObjectInfo {
+	info := &ObjectInfo{
+		Name:              name,
+		ETag:              headers.Get("Etag"),
+		ContentType:       headers.Get("Content-Type"),
+		ContentEncoding:   headers.Get("Content-Encoding"),
+		StaticLargeObject: isStaticLargeObject(headers),
+	}
+
+	info.Bytes, _ = strconv.ParseInt(headers.Get("Content-Length"), 10, 64)
+	info.LastModified, _ = http.ParseTime(headers.Get("Last-Modified"))
+	if seconds, err := strconv.ParseInt(headers.Get("X-Delete-At"), 10, 64); err == nil {
+		info.DeleteAt = time.Unix(seconds, 0).UTC()
+	}
+
+	return info
+}
+
+// ListOptions select the part of a container ListObjects returns.
+type ListOptions struct {
+	// Prefix limits the listing to names beginning with it.
+	Prefix string
+
+	// Delimiter, usually "/", rolls the names that contain it after the
+	// prefix up into Subdirs, listing one level of a directory tree.
+	Delimiter string
+
+	// Only names after Marker and before EndMarker are listed when set.
+	Marker    string
+	EndMarker string
+
+	// Limit is the most objects and subdirs returned, all when zero.
+	Limit int
+}
+
+// An ObjectListing is what ListObjects found.
+type ObjectListing struct {
+	Objects []ObjectInfo
+
+	// Subdirs are the rolled up names, each ending with the delimiter.
+	Subdirs []string
+
+	// NextMarker is set when Limit was reached, pass it as Marker for the
+	// next page, which may be empty.
+	NextMarker string
+}
+
+func (entry objectEntry) info() ObjectInfo {
+	/*
+		Describe a listing entry.  Listings do not say which objects are
+		static large objects.
+	*/
+	info := ObjectInfo{
+		Name:        entry.Name,
+		Bytes:       entry.Bytes,
+		ETag:        entry.Hash,
+		ContentType: entry.ContentType,
+	}
+	info.LastModified, _ = parseListingTime(entry.LastModified)
+	return info
+}
+
+func parseListingTime(value string) (time.Time, error) {
+	/*
+		Parse a listing's last_modified, UTC with microseconds, which
+		time.Parse accepts after the seconds.
+	*/
+	return time.Parse("2006-01-02T15:04:05", value)
+}
+
+func (cf CloudFiles) StatObject(dc, bucket, filename string) (*ObjectInfo, error) {
+	/*
+		Describe an object from a HEAD request.  The error matches
+		ErrObjectMissing when there is no such object.
+		Returns a tuple of object info, error
+	*/
+	return cf.statObject(dc, bucket, filename)
+}
+
+func (cf CloudFiles) ObjectExists(dc, bucket, filename string) (bool, error) {
+	/*
+		Whether an object exists.  Only a 404 means it does not, any other
+		failure is returned as an error.
+		Returns a tuple of whether it exists, error
+	*/
+	_, err := cf.headObject(dc, bucket, filename)
+	if errors.Is(err, ErrObjectMissing) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (cf CloudFiles) ModifiedSince(dc, bucket, filename string, t time.Time) (bool, error) {
+	/*
+		Whether an object was modified after t.  Last-Modified has a
+		resolution of one second, so t is compared at that resolution too.
+		Returns a tuple of whether it was modified, error
+	*/
+	info, err := cf.statObject(dc, bucket, filename)
+	if err != nil {
+		return false, err
+	}
+	return info.LastModified.After(t.Truncate(time.Second)), nil
+}
+
+func (cf CloudFiles) ListObjects(dc, bucket string, options ListOptions) (*ObjectListing, error) {
+	/*
+		List a container in name order, following the server's pages
+		until the listing or options.Limit is exhausted.  Objects are
+		described from the listing, so no HEAD is needed, but static large
+		objects are not marked and DeleteAt is never set.
+		Returns a tuple of listing, error
+	*/
+	listing := &ObjectListing{}
+	marker := options.Marker
+	listed := 0
+
+	for {
+		query := neturl.Values{}
+		query.Set("format", "json")
+		query.Set("prefix", options.Prefix)
+		if options.Delimiter != "" {
+			query.Set("delimiter", options.Delimiter)
+		}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		if options.EndMarker != "" {
+			query.Set("end_marker", options.EndMarker)
+		}
+		if options.Limit > 0 {
+			query.Set("limit", strconv.Itoa(options.Limit-listed))
+		}
+
+		count, last, err := cf.listPage(dc, bucket, query, func(entry objectEntry) error {
+			if entry.Subdir != "" {
+				listing.Subdirs = append(listing.Subdirs, entry.Subdir)
+			} else {
+				listing.Objects = append(listing.Objects, entry.info())
+			}
+			return nil
+		})
+		if err != nil {
+			return listing, err
+		}
+
+		if count == 0 {
+			return listing, nil
+		}
+
+		listed += count
+		marker = last
+
+		if options.Limit > 0 && listed >= options.Limit {
+			listing.NextMarker = last
+			return listing, nil
+		}
+	}
+}
+
+func (cf CloudFiles) ListModifiedSince(dc, bucket, prefix string, t time.Time) ([]ObjectInfo, error) {
+	/*
+		Describe the objects beginning with prefix that were modified
+		after t, for delta syncs.  Listings report microseconds, so no
+		HEAD is needed.
+		Returns a tuple of object infos, error
+	*/
+	var infos []ObjectInfo
+	err := cf.walkObjects(dc, bucket, prefix, func(entry objectEntry) error {
+		info := entry.info()
+		if info.LastModified.After(t) {
+			infos = append(infos, info)
+		}
+		return nil
+	})
+	return infos, err
+}
+
+func (cf CloudFiles) statObject(dc, bucket, filename string) (*ObjectInfo, error) {
+	headers, err := cf.headObject(dc, bucket, filename)
+	if err != nil {
+		return nil, err
+	}
+	return newObjectInfo(filename, headers), nil
+}
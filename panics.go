@@ -0,0 +1,46 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrWorkerPanic is matched by errors.Is when a transfer worker panicked.
+var ErrWorkerPanic = errors.New("Transfer worker panicked.")
+
+// PanicError is a panic recovered in a transfer worker, returned as the
+// transfer's error instead of crashing the process.
+type PanicError struct {
+	// Object is what the worker was transferring.
+	Object string
+	// Chunk is the index of the chunk or segment, -1 for a whole object.
+	Chunk int64
+	Value interface{}
+	// Stack is the worker's stack when it panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	if e.Chunk < 0 {
+		return fmt.Sprintf("Panic while transferring %s: %v", e.Object, e.Value)
+	}
+	return fmt.Sprintf("Panic while transferring chunk %d of %s: %v", e.Chunk, e.Object, e.Value)
+}
+
+func (e *PanicError) Is(target error) bool {
+	return target == ErrWorkerPanic
+}
+
+func catchPanic(object string, chunk int64, fn func() error) (err error) {
+	/*
+		Run fn, turning a panic into a *PanicError.  Deferred cleanup in fn
+		still runs as the panic unwinds.
+	*/
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Object: object, Chunk: chunk, Value: value, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,45 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// A transport that panics on ranged reads, as a worker would on a bug.
+type panickingTransport struct{}
+
+func (panickingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Range") != "" {
+		panic("no temp file")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCopyWorkerPanic(t *testing.T) {
+	// Test a panicking chunk fails the copy instead of the process.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.SetTransport(panickingTransport{})
+
+	fs.put("src/file.bin", []byte("abcdefghi"))
+
+	err := cf.CopyFileWithOptions("TEST", "src", "file.bin", "TEST", "dst", "file.bin", &CopyOptions{ChunkSize: 4})
+	if !errors.Is(err, ErrWorkerPanic) {
+		t.Fatalf("Expected a worker panic, got %v", err)
+	}
+
+	var panicked *PanicError
+	if !errors.As(err, &panicked) || panicked.Chunk < 0 || panicked.Object != "file.bin" || len(panicked.Stack) == 0 {
+		t.Fatalf("Panic not described: %+v", panicked)
+	}
+	if _, ok := fs.get("dst/file.bin"); ok {
+		t.Fatalf("No manifest should be written.")
+	}
+
+	job := cf.StartCopy("TEST", "src", "file.bin", "TEST", "dst", "file.bin", &CopyOptions{ChunkSize: 4})
+	if err := job.Wait(); !errors.Is(err, ErrWorkerPanic) {
+		t.Fatalf("Expected the job to fail with the panic, got %v", err)
+	}
+}
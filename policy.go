@@ -0,0 +1,188 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// ErrPolicyDenied is matched by errors.Is when a request was refused by the
+// client's Policy before it was sent.
+var ErrPolicyDenied = errors.New("Request denied by policy.")
+
+// PolicyError describes a request refused by the client's Policy.
+type PolicyError struct {
+	Region    string
+	Account   string
+	Method    string
+	Container string
+	Object    string
+}
+
+func (e *PolicyError) Error() string {
+	if e.Account != "" {
+		return fmt.Sprintf("Policy does not allow %s of %s/%s in account %s in region %s.",
+			e.Method, e.Container, e.Object, e.Account, e.Region)
+	}
+	return fmt.Sprintf("Policy does not allow %s of %s/%s in region %s.",
+		e.Method, e.Container, e.Object, e.Region)
+}
+
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPolicyDenied
+}
+
+// A PolicyRule allows the requests matching all of its non-empty fields.
+// Prefix is matched against object names as a plain string prefix, and
+// Methods are HTTP verbs such as "GET", "HEAD", "PUT", "POST", "DELETE" and
+// "COPY".  Account is the other account a copy names with
+// X-Copy-From-Account or Destination-Account; unlike the other fields an
+// empty one matches only the client's own account.
+type PolicyRule struct {
+	Region    string
+	Account   string
+	Container string
+	Prefix    string
+	Methods   []string
+}
+
+// A Policy restricts what a client may touch.  A request is sent only if a
+// rule allows it.
+type Policy struct {
+	Rules []PolicyRule
+}
+
+func (cf *CloudFiles) SetPolicy(policy *Policy) {
+	/*
+		Refuse every storage request the policy does not allow, before it is
+		sent, so code embedding a client can be sandboxed.  Server side
+		copies need read access to their source and large object manifests
+		to every segment.  Pass nil to allow everything.
+	*/
+	cf.policy = policy
+}
+
+func (rule PolicyRule) allows(dc, account, method, container, name string, anyName bool) bool {
+	if rule.Region != "" && rule.Region != dc {
+		return false
+	}
+	if rule.Account != account {
+		return false
+	}
+	if rule.Container != "" && rule.Container != container {
+		return false
+	}
+	if !anyName && !strings.HasPrefix(name, rule.Prefix) {
+		return false
+	}
+	if len(rule.Methods) == 0 {
+		return true
+	}
+	for _, allowed := range rule.Methods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (policy *Policy) check(dc, account, method, container, name string, anyName bool) error {
+	for _, rule := range policy.Rules {
+		if rule.allows(dc, account, method, container, name, anyName) {
+			return nil
+		}
+	}
+	return &PolicyError{Region: dc, Account: account, Method: method, Container: container, Object: name}
+}
+
+func (cf CloudFiles) checkPolicy(dc string, req *http.Request) error {
+	/*
+		Check a request and every object it reads on the server's side
+		against the policy.
+	*/
+	if cf.policy == nil {
+		return nil
+	}
+
+	container, object := cf.requestTarget(dc, req)
+
+	// A container listing reads the objects under its prefix, and a HEAD
+	// of the container is allowed along with any of its objects.
+	name := object
+	if object == "" && req.Method == "GET" {
+		name = req.URL.Query().Get("prefix")
+	}
+	anyName := object == "" && req.Method == "HEAD"
+
+	if err := cf.policy.check(dc, "", req.Method, container, name, anyName); err != nil {
+		return err
+	}
+
+	var reads []string
+	if source := req.Header.Get("X-Copy-From"); source != "" {
+		reads = append(reads, source)
+	}
+
+	// A dynamic large object reads every object under its prefix.
+	if manifest := req.Header.Get("X-Object-Manifest"); manifest != "" {
+		parts := strings.SplitN(strings.TrimPrefix(manifest, "/"), "/", 2)
+		prefix := ""
+		if len(parts) == 2 {
+			prefix = parts[1]
+		}
+		if err := cf.policy.check(dc, "", "GET", parts[0], prefix, false); err != nil {
+			return err
+		}
+	}
+
+	if req.Method == "COPY" {
+		parts := strings.SplitN(strings.TrimPrefix(req.Header.Get("Destination"), "/"), "/", 2)
+		if len(parts) == 2 {
+			err := cf.policy.check(dc, req.Header.Get("Destination-Account"), "PUT", parts[0], parts[1], false)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if req.Method == "PUT" && req.URL.Query().Get("multipart-manifest") == "put" && req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		var items manifestList
+		if err := json.Unmarshal(body, &items); err != nil {
+			return err
+		}
+		for _, item := range items {
+			reads = append(reads, item.Path)
+		}
+	}
+
+	// A copy source may be in another account, the segments of a
+	// manifest are in the client's own.
+	sourceAccount := req.Header.Get("X-Copy-From-Account")
+	for i, read := range reads {
+		account := ""
+		if i == 0 && req.Header.Get("X-Copy-From") != "" {
+			account = sourceAccount
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(read, "/"), "/", 2)
+		if len(parts) != 2 {
+			return &PolicyError{Region: dc, Account: account, Method: "GET", Container: parts[0]}
+		}
+		if err := cf.policy.check(dc, account, "GET", parts[0], parts[1], false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPolicyRefusesRequestsBeforeSending(t *testing.T) {
+	// Test only requests a rule allows reach the server.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("shared/other/secret.txt", []byte("not yours"))
+	fs.put("public/logo.png", []byte("logo"))
+
+	cf.SetPolicy(&Policy{Rules: []PolicyRule{
+		{Region: "TEST", Container: "shared", Prefix: "tenants/acme/"},
+		{Container: "public", Methods: []string{"GET", "HEAD"}},
+	}})
+
+	if _, err := cf.PutFile("TEST", "shared", "tenants/acme/a.txt", strings.NewReader("a")); err != nil {
+		t.Fatalf("Allowed write should succeed: %s", err)
+	}
+	if _, _, err := cf.GetFileSize("TEST", "public", "logo.png"); err != nil {
+		t.Fatalf("Allowed read should succeed: %s", err)
+	}
+
+	_, err := cf.PutFile("TEST", "shared", "other/x.txt", strings.NewReader("x"))
+	var denied *PolicyError
+	if !errors.Is(err, ErrPolicyDenied) || !errors.As(err, &denied) || denied.Object != "other/x.txt" {
+		t.Fatalf("Write outside the prefix should be denied: %v", err)
+	}
+	if _, ok := fs.get("shared/other/x.txt"); ok {
+		t.Fatalf("Denied request should not be sent.")
+	}
+
+	if _, err := cf.PutFile("TEST", "public", "logo.png", strings.NewReader("x")); !errors.Is(err, ErrPolicyDenied) {
+		t.Fatalf("Write to a read only container should be denied: %v", err)
+	}
+
+	// Copies and manifests may not read objects the policy hides.
+	_, err = cf.serverCopy("TEST", "shared", "other/secret.txt", "shared", "tenants/acme/stolen.txt", nil)
+	if !errors.Is(err, ErrPolicyDenied) {
+		t.Fatalf("Copy from a hidden object should be denied: %v", err)
+	}
+	err = cf.putManifest("TEST", "shared", "tenants/acme/big.bin", manifestList{
+		{Path: "shared/other/secret.txt"},
+	}, nil)
+	if !errors.Is(err, ErrPolicyDenied) {
+		t.Fatalf("Manifest of hidden segments should be denied: %v", err)
+	}
+
+	report, err := cf.DeletePrefix("TEST", "shared", "tenants/acme/", nil)
+	if err != nil || report.Succeeded != 1 {
+		t.Fatalf("Could not delete allowed prefix: %v %v", report, err)
+	}
+	if _, err := cf.DeletePrefix("TEST", "shared", "", nil); !errors.Is(err, ErrPolicyDenied) {
+		t.Fatalf("Listing the whole container should be denied: %v", err)
+	}
+}
+
+func TestPolicyChecksIndirectReads(t *testing.T) {
+	// Test dynamic manifests and copies from other accounts are checked
+	// as reads of what they name.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("shared/tenants/acme/a.txt", []byte("hello"))
+	cf.SetPolicy(&Policy{Rules: []PolicyRule{
+		{Container: "shared", Prefix: "tenants/acme/"},
+		{Account: "AUTH_partner", Container: "exports", Methods: []string{"GET"}},
+	}})
+
+	put := func(headers map[string]string) error {
+		_, err := cf.PutFileWithOptions("TEST", "shared", "tenants/acme/b.txt", strings.NewReader(""),
+			&WriteOptions{Headers: headers})
+		return err
+	}
+
+	for _, manifest := range []string{"shared/other/", "shared", "private/tenants/acme/"} {
+		if err := put(map[string]string{"X-Object-Manifest": manifest}); !errors.Is(err, ErrPolicyDenied) {
+			t.Fatalf("Manifest of %s should be denied: %v", manifest, err)
+		}
+	}
+	if err := put(map[string]string{"X-Object-Manifest": "shared/tenants/acme/parts/"}); err != nil {
+		t.Fatalf("Manifest of an allowed prefix should succeed: %v", err)
+	}
+
+	err := put(map[string]string{"X-Copy-From": "shared/tenants/acme/a.txt", "X-Copy-From-Account": "AUTH_other"})
+	var denied *PolicyError
+	if !errors.As(err, &denied) || denied.Account != "AUTH_other" {
+		t.Fatalf("Copy from another account should be denied: %v", err)
+	}
+	err = put(map[string]string{"X-Copy-From": "exports/report.csv", "X-Copy-From-Account": "AUTH_partner"})
+	if errors.Is(err, ErrPolicyDenied) {
+		t.Fatalf("Copy from an allowed account should not be denied: %v", err)
+	}
+	if err := put(map[string]string{"X-Copy-From": "exports/report.csv"}); !errors.Is(err, ErrPolicyDenied) {
+		t.Fatalf("Account rules should not allow the client's own account: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package gocloudfiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The profile used when no name is given.
+const DefaultProfile = "default"
+
+// A Profile holds the credentials and region settings of one account.
+type Profile struct {
+	UserName string `json:"username"`
+	APIKey   string `json:"api_key"`
+	// Token authenticates by impersonation instead of username and key.
+	Token string `json:"token"`
+	// Region is the region tools built on the profile use by default.
+	Region string `json:"region"`
+	// LocalDC is passed to SetLocalDC.
+	LocalDC string `json:"local_dc"`
+	// IdentityURL is passed to SetIdentityEndpoint.
+	IdentityURL string `json:"identity_url"`
+}
+
+// Profiles are named Profiles loaded by LoadProfiles.
+type Profiles map[string]Profile
+
+func DefaultProfilesPath() string {
+	/*
+		The file named by CLOUDFILES_PROFILES, or ~/.cloudfiles.json.
+	*/
+	if path := os.Getenv("CLOUDFILES_PROFILES"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cloudfiles.json")
+}
+
+func LoadProfiles(path string) (Profiles, error) {
+	/*
+		Read profiles from a JSON file mapping names to profiles, then apply
+		the environment on top: CLOUDFILES_<NAME>_USERNAME, _API_KEY,
+		_TOKEN, _REGION, _LOCAL_DC and _IDENTITY_URL set fields of profile
+		<name> (upper cased, dashes as underscores), and the same variables
+		without a name, e.g. CLOUDFILES_USERNAME, set the default profile.
+		A missing file is not an error, so profiles may come from the
+		environment alone.
+		Returns a tuple of profiles, error
+	*/
+	profiles := Profiles{}
+
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if err == nil {
+			if err = json.Unmarshal(data, &profiles); err != nil {
+				return nil, fmt.Errorf("Could not parse profiles %s: %s", path, err)
+			}
+		}
+	}
+
+	applyProfileEnv(profiles, DefaultProfile, "CLOUDFILES_")
+	for name := range profiles {
+		prefix := "CLOUDFILES_" + strings.ToUpper(strings.Replace(name, "-", "_", -1)) + "_"
+		applyProfileEnv(profiles, name, prefix)
+	}
+
+	return profiles, nil
+}
+
+func applyProfileEnv(profiles Profiles, name, prefix string) {
+	profile, found := profiles[name]
+	set := false
+
+	fields := []struct {
+		key   string
+		field *string
+	}{
+		{"USERNAME", &profile.UserName},
+		{"API_KEY", &profile.APIKey},
+		{"TOKEN", &profile.Token},
+		{"REGION", &profile.Region},
+		{"LOCAL_DC", &profile.LocalDC},
+		{"IDENTITY_URL", &profile.IdentityURL},
+	}
+
+	for _, f := range fields {
+		if value := os.Getenv(prefix + f.key); value != "" {
+			*f.field = value
+			set = true
+		}
+	}
+
+	if found || set {
+		profiles[name] = profile
+	}
+}
+
+func (profiles Profiles) Names() []string {
+	/*
+		The names of every profile, sorted.
+	*/
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (profile Profile) NewClient() (*CloudFiles, error) {
+	/*
+		Create a cloud files object configured from the profile, without
+		authenticating it.
+	*/
+	var cf *CloudFiles
+	switch {
+	case profile.Token != "":
+		cf = NewCloudFilesImpersonation(profile.Token)
+	case profile.UserName != "" && profile.APIKey != "":
+		cf = NewCloudFiles(profile.UserName, profile.APIKey)
+	default:
+		return nil, fmt.Errorf("Profile needs a token or a username and api key.")
+	}
+
+	if profile.LocalDC != "" {
+		cf.SetLocalDC(profile.LocalDC)
+	}
+	cf.SetIdentityEndpoint(profile.IdentityURL)
+
+	return cf, nil
+}
+
+func (profiles Profiles) Client(name string) (*CloudFiles, error) {
+	/*
+		Create and authenticate the cloud files object of a profile.  An
+		empty name means the default profile.
+		Returns a tuple of cloud files, error
+	*/
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	profile, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("Could not find profile %s.", name)
+	}
+
+	cf, err := profile.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("Could not configure profile %s: %w", name, err)
+	}
+
+	if profile.Token != "" {
+		err = cf.RefreshCatalog()
+	} else {
+		err = cf.Authorize()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cf, nil
+}
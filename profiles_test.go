@@ -0,0 +1,62 @@
+package gocloudfiles
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadProfiles(t *testing.T) {
+	// Test profiles come from the file with the environment applied on top.
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	ioutil.WriteFile(path, []byte(`{
+		"prod-east": {"username": "prod", "api_key": "file-key", "region": "IAD",
+			"identity_url": "https://lon.identity.api.rackspacecloud.com/v2.0/"},
+		"backup": {"token": "tok", "local_dc": "ORD"}
+	}`), 0600)
+
+	t.Setenv("CLOUDFILES_PROD_EAST_API_KEY", "env-key")
+	t.Setenv("CLOUDFILES_USERNAME", "ci")
+	t.Setenv("CLOUDFILES_API_KEY", "ci-key")
+
+	profiles, err := LoadProfiles(path)
+	if err != nil {
+		t.Fatalf("Could not load profiles: %s", err)
+	}
+
+	if names := profiles.Names(); !reflect.DeepEqual(names, []string{"backup", "default", "prod-east"}) {
+		t.Fatalf("Unexpected profiles: %v", names)
+	}
+
+	prod := profiles["prod-east"]
+	if prod.APIKey != "env-key" || prod.UserName != "prod" || prod.Region != "IAD" {
+		t.Fatalf("Environment should override the file: %+v", prod)
+	}
+	if cf, err := prod.NewClient(); err != nil || cf.IdentityEndpoint() != UKIdentityEndpoint {
+		t.Fatalf("Profile should set the identity endpoint: %+v %v", cf, err)
+	}
+
+	cf, err := profiles["backup"].NewClient()
+	if err != nil || cf.authToken != "tok" || cf.localDC != "ORD" {
+		t.Fatalf("Token profile should impersonate: %+v %v", cf, err)
+	}
+
+	if _, err := profiles.Client("missing"); err == nil {
+		t.Fatalf("Unknown profiles should fail.")
+	}
+
+	if _, err := (Profile{UserName: "only"}).NewClient(); err == nil {
+		t.Fatalf("A profile without credentials should fail.")
+	}
+}
+
+func TestLoadProfilesMissingFile(t *testing.T) {
+	// Test profiles can come from the environment alone.
+	t.Setenv("CLOUDFILES_TOKEN", "tok")
+
+	profiles, err := LoadProfiles(filepath.Join(t.TempDir(), "none.json"))
+	if err != nil || profiles[DefaultProfile].Token != "tok" {
+		t.Fatalf("Expected a default profile from the environment: %v %v", profiles, err)
+	}
+}
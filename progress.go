@@ -0,0 +1,210 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// ProgressOptions have a copy write its progress as a TransferProgress JSON
+// document every Interval and once more when it stops, for dashboards and
+// scripts that do not link this package.  Each copy needs its own Path and
+// Object, CopyFiles and ReplicateContainer copies would overwrite each
+// other's.
+type ProgressOptions struct {
+	// Path is a local file replaced with every write.
+	Path string
+
+	// Object is a status object written in Cloud Files, such as one next to
+	// the destination.
+	Object *ObjectLocation
+
+	// Interval between writes, 10 seconds when zero.
+	Interval time.Duration
+}
+
+// The states of a chunk in a TransferProgress.
+const (
+	ChunkPending = "pending"
+	ChunkCopying = "copying"
+	ChunkDone    = "done"
+	ChunkFailed  = "failed"
+)
+
+// TransferProgress is the JSON document ProgressOptions write.  State is
+// "running", "completed" or "failed".  BytesDone counts the chunks done,
+// and the throughput and ETA are measured from them; the ETA is left out
+// until there is a throughput.
+type TransferProgress struct {
+	ID             string    `json:"id"`
+	Source         string    `json:"source"`
+	Dest           string    `json:"dest"`
+	State          string    `json:"state"`
+	Error          string    `json:"error,omitempty"`
+	BytesDone      int64     `json:"bytes_done"`
+	BytesTotal     int64     `json:"bytes_total"`
+	BytesPerSecond float64   `json:"bytes_per_second"`
+	ETASeconds     float64   `json:"eta_seconds,omitempty"`
+	Chunks         []string  `json:"chunks"`
+	Started        time.Time `json:"started"`
+	Updated        time.Time `json:"updated"`
+}
+
+// A progressTracker keeps the progress of one copy and writes it out.  A
+// nil tracker, for copies without ProgressOptions, does nothing.
+type progressTracker struct {
+	cf      CloudFiles
+	options ProgressOptions
+
+	mutex    sync.Mutex
+	progress TransferProgress
+	sizes    []int64
+
+	stop chan bool
+	wg   sync.WaitGroup
+}
+
+func (cf CloudFiles) newProgressTracker(options *ProgressOptions, plan *copyPlan) *progressTracker {
+	if options == nil || options.Path == "" && options.Object == nil {
+		return nil
+	}
+
+	chunks := plan.chunkCount
+	if plan.inline {
+		chunks = 1
+	}
+
+	pt := &progressTracker{
+		cf:      cf,
+		options: *options,
+		progress: TransferProgress{
+			ID:         plan.transferID,
+			Source:     ObjectLocation{Region: plan.sourceDC, Container: plan.sourceBucket, Object: plan.sourceFile}.String(),
+			Dest:       ObjectLocation{Region: plan.destDC, Container: plan.destBucket, Object: plan.destFile}.String(),
+			State:      "running",
+			BytesTotal: plan.size,
+			Chunks:     make([]string, chunks),
+			Started:    time.Now().UTC(),
+		},
+		sizes: make([]int64, chunks),
+		stop:  make(chan bool),
+	}
+	if pt.options.Interval <= 0 {
+		pt.options.Interval = 10 * time.Second
+	}
+
+	for i := range pt.progress.Chunks {
+		pt.progress.Chunks[i] = ChunkPending
+		pt.sizes[i] = plan.chunkSize
+		if plan.inline || int64(i) == chunks-1 {
+			pt.sizes[i] = plan.size - int64(i)*plan.chunkSize
+		}
+	}
+
+	pt.write()
+	pt.wg.Add(1)
+	go pt.loop()
+	return pt
+}
+
+func (pt *progressTracker) loop() {
+	defer pt.wg.Done()
+
+	ticker := time.NewTicker(pt.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pt.stop:
+			return
+		case <-ticker.C:
+			pt.write()
+		}
+	}
+}
+
+func (pt *progressTracker) chunk(index int64, state string) {
+	if pt == nil || index < 0 || index >= int64(len(pt.sizes)) {
+		return
+	}
+
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+
+	if state == ChunkDone && pt.progress.Chunks[index] != ChunkDone {
+		pt.progress.BytesDone += pt.sizes[index]
+	}
+	pt.progress.Chunks[index] = state
+}
+
+func (pt *progressTracker) finish(err error) {
+	/*
+		Stop the periodic writes and write the outcome.
+	*/
+	if pt == nil {
+		return
+	}
+
+	close(pt.stop)
+	pt.wg.Wait()
+
+	pt.mutex.Lock()
+	if err != nil {
+		pt.progress.State = "failed"
+		pt.progress.Error = err.Error()
+	} else {
+		pt.progress.State = "completed"
+		pt.progress.BytesDone = pt.progress.BytesTotal
+		for i := range pt.progress.Chunks {
+			pt.progress.Chunks[i] = ChunkDone
+		}
+	}
+	pt.mutex.Unlock()
+
+	pt.write()
+}
+
+func (pt *progressTracker) snapshot() TransferProgress {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+
+	progress := pt.progress
+	progress.Chunks = append([]string(nil), pt.progress.Chunks...)
+	progress.Updated = time.Now().UTC()
+
+	elapsed := progress.Updated.Sub(progress.Started).Seconds()
+	if elapsed > 0 {
+		progress.BytesPerSecond = float64(progress.BytesDone) / elapsed
+	}
+	if progress.State == "running" && progress.BytesPerSecond > 0 {
+		progress.ETASeconds = float64(progress.BytesTotal-progress.BytesDone) / progress.BytesPerSecond
+	}
+	return progress
+}
+
+func (pt *progressTracker) write() {
+	/*
+		Write the progress to the file and the status object.  A write
+		that fails is made again at the next interval, the copy goes on.
+	*/
+	data, err := json.Marshal(pt.snapshot())
+	if err != nil {
+		return
+	}
+
+	// Write then rename so readers never see half a document.
+	if pt.options.Path != "" {
+		tmpPath := pt.options.Path + ".tmp"
+		if ioutil.WriteFile(tmpPath, data, 0644) == nil {
+			os.Rename(tmpPath, pt.options.Path)
+		}
+	}
+
+	if location := pt.options.Object; location != nil {
+		pt.cf.putObject(location.Region, location.Container, location.Object, bytes.NewReader(data), "",
+			&WriteOptions{ContentType: "application/json"})
+	}
+}
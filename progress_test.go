@@ -0,0 +1,93 @@
+package gocloudfiles
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readProgress(t *testing.T, data []byte) TransferProgress {
+	var progress TransferProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		t.Fatalf("Could not parse progress %q: %s", data, err)
+	}
+	return progress
+}
+
+func TestCopyProgress(t *testing.T) {
+	// Test a copy leaves its outcome in the progress file and object.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("source/file.bin", []byte("abcdefghij"))
+	path := filepath.Join(t.TempDir(), "progress.json")
+	options := &CopyOptions{ChunkSize: 4, TransferID: "job1", Progress: &ProgressOptions{
+		Path:     path,
+		Object:   &ObjectLocation{Region: "TEST", Container: "status", Object: "job1.json"},
+		Interval: time.Millisecond,
+	}}
+
+	err := cf.CopyFileWithOptions("TEST", "source", "file.bin", "TEST", "testing", "file.bin", options)
+	if err != nil {
+		t.Fatalf("Could not copy: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read progress file: %s", err)
+	}
+	progress := readProgress(t, data)
+	if progress.State != "completed" || progress.ID != "job1" || progress.Source != "TEST:source/file.bin" ||
+		progress.BytesDone != 10 || progress.BytesTotal != 10 || len(progress.Chunks) != 3 {
+		t.Fatalf("Unexpected progress %+v", progress)
+	}
+	for i, state := range progress.Chunks {
+		if state != ChunkDone {
+			t.Fatalf("Chunk %d is %s", i, state)
+		}
+	}
+
+	stored, found := fs.get("status/job1.json")
+	if !found || readProgress(t, stored).State != "completed" {
+		t.Fatalf("Unexpected status object %q", stored)
+	}
+
+	fs.fail("source/file.bin", 100)
+	options.TransferID = "job2"
+	err = cf.CopyFileWithOptions("TEST", "source", "file.bin", "TEST", "testing", "again.bin", options)
+	if err == nil {
+		t.Fatalf("Expected the copy to fail.")
+	}
+	data, _ = ioutil.ReadFile(path)
+	if progress := readProgress(t, data); progress.State != "failed" || progress.Error == "" {
+		t.Fatalf("Unexpected progress of a failed copy %+v", progress)
+	}
+}
+
+func TestProgressSnapshot(t *testing.T) {
+	// Test the throughput and ETA come from the chunks done.
+	cf := NewCloudFilesImpersonation("token")
+	plan := &copyPlan{size: 10, chunkSize: 4, chunkCount: 3}
+	pt := cf.newProgressTracker(&ProgressOptions{Path: filepath.Join(t.TempDir(), "p.json")}, plan)
+	defer pt.finish(nil)
+
+	pt.progress.Started = time.Now().Add(-2 * time.Second)
+	pt.chunk(2, ChunkDone)
+	pt.chunk(2, ChunkDone)
+	pt.chunk(0, ChunkCopying)
+
+	progress := pt.snapshot()
+	if progress.BytesDone != 2 || progress.Chunks[0] != ChunkCopying || progress.Chunks[1] != ChunkPending {
+		t.Fatalf("Unexpected progress %+v", progress)
+	}
+	if progress.BytesPerSecond < 0.9 || progress.BytesPerSecond > 1.1 || progress.ETASeconds < 7 || progress.ETASeconds > 9 {
+		t.Fatalf("Unexpected throughput %f and ETA %f", progress.BytesPerSecond, progress.ETASeconds)
+	}
+
+	var none *progressTracker
+	none.chunk(0, ChunkDone)
+	none.finish(nil)
+}
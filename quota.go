@@ -0,0 +1,117 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ErrQuotaExceeded is matched by errors.Is when a write would go over a
+// container or account quota.
+var ErrQuotaExceeded = errors.New("Quota exceeded.")
+
+// QuotaError details a projected overage found before anything was written.
+// Scope is "container" or "account" and Unit is "bytes" or "objects".
+type QuotaError struct {
+	Scope  string
+	Name   string
+	Unit   string
+	Limit  int64
+	Used   int64
+	Needed int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("Writing %d %s would exceed the %s quota of %s: %d of %d used, %d over.",
+		e.Needed, e.Unit, e.Scope, e.Name, e.Used, e.Limit, e.Used+e.Needed-e.Limit)
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+func headerInt(headers http.Header, key string) (int64, bool) {
+	value, err := strconv.ParseInt(headers.Get(key), 10, 64)
+	return value, err == nil
+}
+
+func checkQuota(scope, name string, headers http.Header, prefix string, bytes, objects int64) error {
+	/*
+		Compare the usage a write would add against the quotas in one set
+		of container or account headers.
+	*/
+	if limit, ok := headerInt(headers, prefix+"-Meta-Quota-Bytes"); ok {
+		used, _ := headerInt(headers, prefix+"-Bytes-Used")
+		if used+bytes > limit {
+			return &QuotaError{Scope: scope, Name: name, Unit: "bytes", Limit: limit, Used: used, Needed: bytes}
+		}
+	}
+
+	if limit, ok := headerInt(headers, prefix+"-Meta-Quota-Count"); ok {
+		used, _ := headerInt(headers, prefix+"-Object-Count")
+		if used+objects > limit {
+			return &QuotaError{Scope: scope, Name: name, Unit: "objects", Limit: limit, Used: used, Needed: objects}
+		}
+	}
+
+	return nil
+}
+
+func (cf CloudFiles) headAccount(dc string) (http.Header, error) {
+	/*
+		Fetch the headers of the account in a region.
+	*/
+	endpoint, err := cf.endpoint(dc)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("HEAD", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("X-Auth-Token", cf.token())
+	resp, err := cf.do(dc, req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if !succeeded(resp) {
+		return nil, newCloudFilesError(resp, "Could not fetch account")
+	}
+
+	return resp.Header, nil
+}
+
+func (cf CloudFiles) CheckQuota(dc, bucket string, bytes, objects int64) error {
+	/*
+		Check that writing the given number of bytes and objects into the
+		container fits its quota and the account's.  The error is a
+		*QuotaError with the projected overage when it would not.
+	*/
+	headers, err := cf.headContainer(dc, bucket)
+	if err != nil {
+		return err
+	}
+
+	return cf.checkQuotas(dc, bucket, headers, bytes, objects)
+}
+
+func (cf CloudFiles) checkQuotas(dc, bucket string, containerHeaders http.Header, bytes, objects int64) error {
+	err := checkQuota("container", bucket, containerHeaders, "X-Container", bytes, objects)
+	if err != nil {
+		return err
+	}
+
+	accountHeaders, err := cf.headAccount(dc)
+	if err != nil {
+		return err
+	}
+
+	return checkQuota("account", dc, accountHeaders, "X-Account", bytes, objects)
+}
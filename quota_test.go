@@ -0,0 +1,95 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestCheckQuota(t *testing.T) {
+	// Test projected overages of container and account quotas are reported.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.headers["testing"] = http.Header{
+		"X-Container-Meta-Quota-Bytes": {"1000"},
+		"X-Container-Bytes-Used":       {"900"},
+	}
+	fs.headers[""] = http.Header{
+		"X-Account-Meta-Quota-Count": {"10"},
+		"X-Account-Object-Count":     {"9"},
+	}
+
+	if err := cf.CheckQuota("TEST", "testing", 100, 1); err != nil {
+		t.Fatalf("A write that fits should pass: %s", err)
+	}
+
+	var quotaErr *QuotaError
+	err := cf.CheckQuota("TEST", "testing", 150, 1)
+	if !errors.As(err, &quotaErr) || quotaErr.Scope != "container" || quotaErr.Unit != "bytes" {
+		t.Fatalf("Expected a container bytes overage but got: %v", err)
+	}
+
+	err = cf.CheckQuota("TEST", "testing", 10, 2)
+	if !errors.As(err, &quotaErr) || quotaErr.Scope != "account" || quotaErr.Unit != "objects" {
+		t.Fatalf("Expected an account objects overage but got: %v", err)
+	}
+
+	fs.put("src/file.bin", make([]byte, 200))
+
+	err = cf.CopyFileWithOptions("TEST", "src", "file.bin", "TEST", "testing", "file.bin",
+		&CopyOptions{CheckQuota: true})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected ErrQuotaExceeded but got: %v", err)
+	}
+
+	if _, ok := fs.get("testing/file.bin"); ok {
+		t.Fatalf("Nothing should be copied over quota.")
+	}
+}
+
+func TestCopyQuotaObjectCount(t *testing.T) {
+	// Test a copy counts exactly the objects it writes against the quota.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("src/small.bin", []byte("abc"))
+	fs.put("src/large.bin", []byte("abcdefghij"))
+
+	quota := func(used int) {
+		fs.headers["testing"] = http.Header{
+			"X-Container-Meta-Quota-Count": {"10"},
+			"X-Container-Object-Count":     {strconv.Itoa(used)},
+		}
+	}
+	attempt := func(name string, options *CopyOptions) error {
+		options.CheckQuota = true
+		return cf.CopyFileWithOptions("TEST", "src", name, "TEST", "testing", name, options)
+	}
+
+	// One object inline, two with the checksum record.
+	quota(9)
+	if err := attempt("small.bin", &CopyOptions{}); err != nil {
+		t.Fatalf("An inline copy filling the quota should pass: %s", err)
+	}
+	if err := attempt("small.bin", &CopyOptions{WriteChecksums: true}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("The checksum record should count: %v", err)
+	}
+	quota(8)
+	if err := attempt("small.bin", &CopyOptions{WriteChecksums: true}); err != nil {
+		t.Fatalf("An inline copy and its checksums filling the quota should pass: %s", err)
+	}
+
+	// Three segments and the manifest.
+	quota(6)
+	if err := attempt("large.bin", &CopyOptions{ChunkSize: 4}); err != nil {
+		t.Fatalf("A segmented copy filling the quota should pass: %s", err)
+	}
+	quota(7)
+	if err := attempt("large.bin", &CopyOptions{ChunkSize: 4}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("A segmented copy one over the quota should be refused: %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+package gocloudfiles
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// RangeReaderOptions tune a RangeReader.  Zero values take the defaults.
+type RangeReaderOptions struct {
+	// BlockSize is the unit reads are rounded out to and cached in, 64KB
+	// when zero.
+	BlockSize int64
+
+	// CacheBlocks is how many blocks are kept, the least recently read
+	// going first, 64 when zero.
+	CacheBlocks int
+}
+
+func (options RangeReaderOptions) withDefaults() RangeReaderOptions {
+	if options.BlockSize <= 0 {
+		options.BlockSize = 64 * 1024
+	}
+	if options.CacheBlocks <= 0 {
+		options.CacheBlocks = 64
+	}
+	return options
+}
+
+// RangeStats count what a RangeReader was asked for and what it fetched.
+type RangeStats struct {
+	Reads     int64
+	Requests  int64
+	CacheHits int64
+	Bytes     int64
+}
+
+// A RangeReader reads an object at random offsets, as archive/zip and
+// other io.ReaderAt users do, with far fewer requests than a ranged GET
+// per read.  Reads are rounded out to whole blocks, the blocks a read is
+// missing are fetched with one GET per adjacent run of them, and blocks
+// another read is already fetching are waited for instead of fetched
+// twice.  Recently read blocks are cached, so small reads near each other
+// cost nothing after the first.
+type RangeReader struct {
+	cf       CloudFiles
+	location ObjectLocation
+	size     int64
+	etag     string
+	options  RangeReaderOptions
+
+	mutex    sync.Mutex
+	cache    map[int64]*list.Element
+	lru      *list.List
+	fetching map[int64]*rangeFetch
+	stats    RangeStats
+}
+
+// A cached block, the value of the LRU list's elements.
+type cachedBlock struct {
+	index int64
+	data  []byte
+}
+
+// A rangeFetch is one GET of the adjacent blocks first to last, which
+// reads needing any of them wait for.
+type rangeFetch struct {
+	first int64
+	last  int64
+	data  []byte
+	err   error
+	done  chan bool
+}
+
+func (cf CloudFiles) NewRangeReader(dc, bucket, filename string, options *RangeReaderOptions) (*RangeReader, error) {
+	/*
+		Open an object for ReadAt.  Its size and etag are read first, a
+		block whose GET answers with another etag fails the read, as the
+		object changed since.  A nil options takes the defaults.
+		Returns a tuple of reader, error
+	*/
+	if options == nil {
+		options = &RangeReaderOptions{}
+	}
+
+	info, err := cf.StatObject(dc, bucket, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RangeReader{
+		cf:       cf,
+		location: ObjectLocation{Region: dc, Container: bucket, Object: filename},
+		size:     info.Bytes,
+		etag:     info.ETag,
+		options:  options.withDefaults(),
+		cache:    make(map[int64]*list.Element),
+		lru:      list.New(),
+		fetching: make(map[int64]*rangeFetch),
+	}, nil
+}
+
+func (rr *RangeReader) Size() int64 {
+	return rr.size
+}
+
+func (rr *RangeReader) Stats() RangeStats {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+
+	return rr.stats
+}
+
+func (rr *RangeReader) ReadAt(p []byte, off int64) (int, error) {
+	/*
+		Read len(p) bytes at off, as io.ReaderAt, safe for concurrent use.
+		Returns a tuple of bytes read, error
+	*/
+	if off < 0 {
+		return 0, fmt.Errorf("Cannot read %s at negative offset %d.", rr.location, off)
+	}
+
+	rr.mutex.Lock()
+	rr.stats.Reads++
+	rr.mutex.Unlock()
+
+	if off >= rr.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	end := off + int64(len(p))
+	if end > rr.size {
+		end = rr.size
+	}
+
+	blockSize := rr.options.BlockSize
+	first := off / blockSize
+	blocks, err := rr.blocks(first, (end-1)/blockSize)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for i, data := range blocks {
+		start := (first + int64(i)) * blockSize
+		from := int64(0)
+		if off > start {
+			from = off - start
+		}
+		to := int64(len(data))
+		if end < start+to {
+			to = end - start
+		}
+		n += copy(p[n:], data[from:to])
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (rr *RangeReader) blocks(first, last int64) ([][]byte, error) {
+	/*
+		The data of blocks first to last.  Cached blocks are used, blocks
+		another read is fetching are waited for and the rest are claimed
+		in runs of adjacent blocks, each fetched with one GET.
+	*/
+	blocks := make([][]byte, last-first+1)
+	var claimed []*rangeFetch
+	waiting := make(map[int64]*rangeFetch)
+
+	rr.mutex.Lock()
+	for i := first; i <= last; i++ {
+		if element, found := rr.cache[i]; found {
+			rr.lru.MoveToFront(element)
+			blocks[i-first] = element.Value.(*cachedBlock).data
+			rr.stats.CacheHits++
+			continue
+		}
+
+		fetch, found := rr.fetching[i]
+		if !found {
+			if n := len(claimed); n > 0 && claimed[n-1].last == i-1 {
+				fetch = claimed[n-1]
+				fetch.last = i
+			} else {
+				fetch = &rangeFetch{first: i, last: i, done: make(chan bool)}
+				claimed = append(claimed, fetch)
+			}
+			rr.fetching[i] = fetch
+		}
+		waiting[i] = fetch
+	}
+	rr.mutex.Unlock()
+
+	for _, fetch := range claimed {
+		rr.fetch(fetch)
+	}
+
+	for i, fetch := range waiting {
+		<-fetch.done
+		if fetch.err != nil {
+			return nil, fetch.err
+		}
+		blocks[i-first] = fetch.block(i, rr.options.BlockSize)
+	}
+	return blocks, nil
+}
+
+func (rr *RangeReader) fetch(fetch *rangeFetch) {
+	/*
+		GET the blocks of a fetch, cache them and wake the reads waiting for
+		them.
+	*/
+	blockSize := rr.options.BlockSize
+	offset := fetch.first * blockSize
+	length := (fetch.last+1)*blockSize - offset
+	if offset+length > rr.size {
+		length = rr.size - offset
+	}
+
+	fetch.data, fetch.err = rr.get(offset, length)
+
+	rr.mutex.Lock()
+	rr.stats.Requests++
+	rr.stats.Bytes += int64(len(fetch.data))
+	for i := fetch.first; i <= fetch.last; i++ {
+		delete(rr.fetching, i)
+		if fetch.err == nil {
+			rr.store(i, fetch.block(i, blockSize))
+		}
+	}
+	rr.mutex.Unlock()
+
+	close(fetch.done)
+}
+
+func (rr *RangeReader) get(offset, length int64) ([]byte, error) {
+	/*
+		A ranged GET of the object, failing when its etag is no longer the
+		one it was opened with.
+	*/
+	url, err := rr.cf.objectURL(rr.location.Region, rr.location.Container, rr.location.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	req.Header.Add("X-Auth-Token", rr.cf.token())
+	req.Header.Add("Accept-Encoding", "identity")
+	resp, err := rr.cf.do(rr.location.Region, req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, &ObjectMissingError{Region: rr.location.Region, Bucket: rr.location.Container, Name: rr.location.Object}
+	}
+	if !succeeded(resp) {
+		return nil, newCloudFilesError(resp, "Could not read %s at %d", rr.location, offset)
+	}
+	if etag := resp.Header.Get("Etag"); !sameETag(etag, rr.etag) {
+		return nil, fmt.Errorf("Object %s changed while being read: etag %s, was %s.", rr.location, etag, rr.etag)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != length {
+		return nil, fmt.Errorf("Read %d bytes of %s at %d, expected %d.", len(data), rr.location, offset, length)
+	}
+	return data, nil
+}
+
+func (rr *RangeReader) store(index int64, data []byte) {
+	/*
+		Cache a block, evicting the least recently read beyond
+		CacheBlocks.  Called with the mutex held.
+	*/
+	rr.cache[index] = rr.lru.PushFront(&cachedBlock{index: index, data: data})
+	for rr.lru.Len() > rr.options.CacheBlocks {
+		oldest := rr.lru.Back()
+		rr.lru.Remove(oldest)
+		delete(rr.cache, oldest.Value.(*cachedBlock).index)
+	}
+}
+
+func (fetch *rangeFetch) block(index, blockSize int64) []byte {
+	from := (index - fetch.first) * blockSize
+	to := from + blockSize
+	if to > int64(len(fetch.data)) {
+		to = int64(len(fetch.data))
+	}
+	return fetch.data[from:to]
+}
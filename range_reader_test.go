@@ -0,0 +1,107 @@
+package gocloudfiles
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+)
+
+func TestRangeReaderZip(t *testing.T) {
+	// Test a zip is read through the reader with requests coalesced into
+	// blocks.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for i := 0; i < 20; i++ {
+		w, _ := writer.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("file%d.txt", i), Method: zip.Store})
+		w.Write(bytes.Repeat([]byte{byte('a' + i)}, 100))
+	}
+	writer.Close()
+	fs.put("testing/archive.zip", archive.Bytes())
+
+	rr, err := cf.NewRangeReader("TEST", "testing", "archive.zip", &RangeReaderOptions{BlockSize: 1024})
+	if err != nil {
+		t.Fatalf("Could not open: %s", err)
+	}
+	reader, err := zip.NewReader(rr, rr.Size())
+	if err != nil {
+		t.Fatalf("Could not read the zip: %s", err)
+	}
+
+	for i, file := range reader.File {
+		f, err := file.Open()
+		if err != nil {
+			t.Fatalf("Could not open %s: %s", file.Name, err)
+		}
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil || !bytes.Equal(data, bytes.Repeat([]byte{byte('a' + i)}, 100)) {
+			t.Fatalf("Unexpected %s: %q %v", file.Name, data, err)
+		}
+	}
+
+	stats := rr.Stats()
+	blocks := (int64(archive.Len()) + 1023) / 1024
+	if stats.Requests > blocks || stats.Requests >= stats.Reads || stats.Bytes > int64(archive.Len()) {
+		t.Fatalf("Unexpected stats %+v for %d blocks", stats, blocks)
+	}
+}
+
+func TestRangeReaderCoalesces(t *testing.T) {
+	// Test concurrent overlapping reads share fetches, a read spanning
+	// missing blocks is one request and a changed object fails reads.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	fs.put("testing/object", data)
+
+	rr, err := cf.NewRangeReader("TEST", "testing", "object", &RangeReaderOptions{BlockSize: 100, CacheBlocks: 4})
+	if err != nil {
+		t.Fatalf("Could not open: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(offset int64) {
+			defer wg.Done()
+			p := make([]byte, 50)
+			if _, err := rr.ReadAt(p, offset); err != nil || !bytes.Equal(p, data[offset:offset+50]) {
+				t.Errorf("Unexpected read at %d: %v", offset, err)
+			}
+		}(int64(i * 10))
+	}
+	wg.Wait()
+	if stats := rr.Stats(); stats.Requests != 1 {
+		t.Fatalf("Expected overlapping reads to share one request, got %+v", stats)
+	}
+
+	p := make([]byte, 350)
+	if n, err := rr.ReadAt(p, 150); n != 350 || err != nil || !bytes.Equal(p, data[150:500]) {
+		t.Fatalf("Unexpected spanning read: %d %v", n, err)
+	}
+	if stats := rr.Stats(); stats.Requests != 2 {
+		t.Fatalf("Expected the spanning read to take one request, got %+v", stats)
+	}
+
+	if n, err := rr.ReadAt(p, 900); n != 100 || err != io.EOF || !bytes.Equal(p[:n], data[900:]) {
+		t.Fatalf("Unexpected read past the end: %d %v", n, err)
+	}
+
+	fs.put("testing/object", bytes.Repeat([]byte("x"), 1000))
+	if _, err := rr.ReadAt(p[:10], 0); err == nil {
+		t.Fatalf("Read a block of the changed object.")
+	}
+}
@@ -0,0 +1,305 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"time"
+)
+
+// The format of last_modified in JSON container listings.
+const listingTimeFormat = "2006-01-02T15:04:05.000000"
+
+// How long an object must stay missing from the source before a mirror
+// deletes it when ReplicateOptions.MirrorConfirmAfter is zero.
+const DefaultMirrorConfirmAfter = 24 * time.Hour
+
+// How far before the newest last_modified of a run the next run starts
+// looking again when ReplicateOptions.WatermarkLookback is zero.
+const DefaultWatermarkLookback = 15 * time.Minute
+
+// ReplicateOptions tune ReplicateContainer.
+type ReplicateOptions struct {
+	// Only replicate objects whose names begin with Prefix.
+	Prefix string
+
+	// Copy is used for every object copied.
+	Copy *CopyOptions
+
+	// Results receives one Result per source object when set.
+	Results chan<- Result
+
+	// Watermarks makes repeated runs proportional to churn.  A successful
+	// run records the newest last_modified it listed minus
+	// WatermarkLookback, and the next run skips objects last modified
+	// before that without consulting the destination.
+	Watermarks WatermarkStore
+
+	// WatermarkLookback re-examines objects modified this long before the
+	// newest one listed, so objects written while the previous run was
+	// listing are not missed.  Zero means DefaultWatermarkLookback.
+	WatermarkLookback time.Duration
+
+	// Mirror deletes destination objects that are gone from the source.
+	// An object is only deleted once it has been missing from the source
+	// in runs at least MirrorConfirmAfter apart, as recorded in
+	// DeletionJournal, so a glitch in one listing cannot delete anything.
+	Mirror             bool
+	DeletionJournal    DeletionJournal
+	MirrorConfirmAfter time.Duration
+	// MirrorGuard bounds the deletions of a single run, none are made
+	// when they would exceed it.
+	MirrorGuard DeleteGuard
+
+	// ContainerSettings copies the source container's metadata, quotas,
+	// CORS rules, ACLs and versioning target to the destination once its
+	// objects are copied, see CopyContainerSettings.
+	ContainerSettings bool
+}
+
+func watermarkFor(highWater string, lookback time.Duration) (string, bool) {
+	/*
+		Step a listing timestamp back by the lookback window.
+	*/
+	t, err := parseListingTime(highWater)
+	if err != nil {
+		return "", false
+	}
+	return t.Add(-lookback).Format(listingTimeFormat), true
+}
+
+func (cf CloudFiles) ReplicateContainer(sourceDC, sourceBucket, destDC, destBucket string,
+	options *ReplicateOptions) (*Report, error) {
+	/*
+		Copy every object of the source container that is missing from the
+		destination or changed since it was last copied.  Large objects are
+		copied through their manifest and their segments are not copied on
+		their own.  Objects fail
+		independently; the report counts each and the error is a
+		*MultiError when any of them failed.
+	*/
+	if options == nil {
+		options = &ReplicateOptions{}
+	}
+
+	// Listings share one timestamp format, so they compare as strings.
+	watermarkKey := sourceDC + "/" + sourceBucket + "/" + options.Prefix + ">" + destDC + "/" + destBucket
+	watermark := ""
+	if options.Watermarks != nil {
+		var err error
+		watermark, err = options.Watermarks.GetWatermark(watermarkKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Mirror && options.DeletionJournal == nil {
+		return nil, fmt.Errorf("Mirroring needs a DeletionJournal.")
+	}
+
+	report := cf.newReport()
+
+	// Destination objects the source no longer has, other than segments
+	// which go with their large object.
+	missing := make(map[string]objectEntry)
+	noteMissing := func(entry objectEntry) {
+		if !isSegment(entry.Name) {
+			missing[entry.Name] = entry
+		}
+	}
+
+	// Both listings come back in name order, so they are merged page by
+	// page instead of holding either of them in memory.
+	sources := cf.newListingCursor(sourceDC, sourceBucket, options.Prefix)
+	dests := cf.newListingCursor(destDC, destBucket, options.Prefix)
+
+	highWater := watermark
+	for !report.Aborted {
+		source, ok, err := sources.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+
+		// A mirror walks the whole destination alongside the source to see
+		// what is gone, even for objects it skips.
+		var dest objectEntry
+		var destFound bool
+		if options.Mirror {
+			dest, destFound, err = dests.findPassing(source.Name, noteMissing)
+			if err == nil && destFound {
+				// Consume the match so later names do not pass it.
+				_, _, err = dests.next()
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		result := Result{
+			Op:     "replicate",
+			DC:     destDC,
+			Bucket: destBucket,
+			Name:   source.Name,
+			Bytes:  source.Bytes,
+		}
+
+		// Segments are rewritten by copying their large object, copying
+		// them on their own would clobber the destination's segments.
+		if isSegment(source.Name) {
+			result.Status = ResultSkipped
+			result.Reason = "segment of a large object"
+			report.record(options.Results, result)
+			continue
+		}
+
+		if source.LastModified > highWater {
+			highWater = source.LastModified
+		}
+
+		if watermark != "" && source.LastModified < watermark {
+			result.Status = ResultSkipped
+			result.Reason = "unchanged since last run"
+			report.record(options.Results, result)
+			continue
+		}
+
+		if !options.Mirror {
+			dest, destFound, err = dests.find(source.Name)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if destFound && dest.Bytes == source.Bytes &&
+			(sameETag(dest.Hash, source.Hash) || dest.LastModified >= source.LastModified) {
+			result.Status = ResultSkipped
+			result.Reason = "destination is up to date"
+			report.record(options.Results, result)
+			continue
+		}
+
+		err = cf.CopyFileWithOptions(sourceDC, sourceBucket, source.Name,
+			destDC, destBucket, source.Name, options.Copy)
+
+		if err != nil {
+			result.Status = ResultFailure
+			result.Err = err
+		} else {
+			result.Status = ResultSuccess
+		}
+
+		report.record(options.Results, result)
+	}
+
+	if options.Mirror && !report.Aborted {
+		for {
+			dest, ok, err := dests.next()
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			noteMissing(dest)
+		}
+
+		err := cf.mirrorDeletes(destDC, destBucket, watermarkKey, missing, options, report)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	// Settings come last, so the destination's quota or versioning does
+	// not get in the way of the copies.
+	if options.ContainerSettings && !report.Aborted {
+		err := cf.CopyContainerSettings(sourceDC, sourceBucket, destDC, destBucket)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	// Only move the watermark once everything up to it made it across,
+	// and never backwards.
+	lookback := options.WatermarkLookback
+	if lookback <= 0 {
+		lookback = DefaultWatermarkLookback
+	}
+	next, ok := watermarkFor(highWater, lookback)
+	if options.Watermarks != nil && report.Failed == 0 && ok && next > watermark {
+		err := options.Watermarks.SetWatermark(watermarkKey, next)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, report.Err()
+}
+
+func (cf CloudFiles) mirrorDeletes(destDC, destBucket, key string, missing map[string]objectEntry,
+	options *ReplicateOptions, report *Report) error {
+	/*
+		Journal the destination objects missing from the source and delete
+		those that were already missing at least a confirmation window ago.
+		Objects back on the source drop out of the journal.
+	*/
+	confirmAfter := options.MirrorConfirmAfter
+	if confirmAfter <= 0 {
+		confirmAfter = DefaultMirrorConfirmAfter
+	}
+
+	journal, err := options.DeletionJournal.GetMissing(key)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	next := make(map[string]time.Time, len(missing))
+	var confirmed []objectEntry
+	total := int64(0)
+
+	for name, entry := range missing {
+		first, seen := journal[name]
+		if !seen {
+			first = now
+		}
+		next[name] = first
+
+		if seen && now.Sub(first) >= confirmAfter {
+			confirmed = append(confirmed, entry)
+			total += entry.Bytes
+		}
+	}
+
+	err = options.MirrorGuard.check(options.Prefix, len(confirmed), total)
+	if err != nil {
+		options.DeletionJournal.SetMissing(key, next)
+		return err
+	}
+
+	for _, entry := range confirmed {
+		if report.Aborted {
+			break
+		}
+
+		result := Result{
+			Op:     "mirror-delete",
+			DC:     destDC,
+			Bucket: destBucket,
+			Name:   entry.Name,
+			Status: ResultSuccess,
+		}
+
+		err := cf.removeObject(destDC, destBucket, entry.Name)
+		if err != nil {
+			result.Status = ResultFailure
+			result.Err = err
+		} else {
+			delete(next, entry.Name)
+		}
+
+		report.record(options.Results, result)
+	}
+
+	return options.DeletionJournal.SetMissing(key, next)
+}
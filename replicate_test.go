@@ -0,0 +1,230 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReplicateContainer(t *testing.T) {
+	// Test missing objects are copied and up to date ones are skipped.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("src/a.txt", []byte("alpha"))
+	fs.put("src/b.txt", []byte("beta"))
+	fs.put("dst/b.txt", []byte("beta"))
+
+	results := make(chan Result, 2)
+	report, err := cf.ReplicateContainer("TEST", "src", "TEST", "dst",
+		&ReplicateOptions{Results: results})
+	if err != nil {
+		t.Fatalf("Could not replicate: %s", err)
+	}
+
+	if report.Succeeded != 1 || report.Skipped != 1 || len(results) != 2 {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+
+	if data, _ := fs.get("dst/a.txt"); string(data) != "alpha" {
+		t.Fatalf("Object was not replicated: %q", data)
+	}
+}
+
+func TestReplicateContainerWatermark(t *testing.T) {
+	// Test a second run only examines objects changed since the first.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("src/a.txt", []byte("alpha"))
+	fs.clock = fs.clock.Add(time.Hour)
+	fs.put("src/b.txt", []byte("beta"))
+
+	options := &ReplicateOptions{Watermarks: NewMemoryWatermarks()}
+
+	report, err := cf.ReplicateContainer("TEST", "src", "TEST", "dst", options)
+	if err != nil || report.Succeeded != 2 {
+		t.Fatalf("First run should copy everything: %+v %v", report, err)
+	}
+
+	// Move past the lookback window before changing an object.
+	fs.clock = fs.clock.Add(time.Hour)
+	fs.put("src/b.txt", []byte("beta two"))
+
+	results := make(chan Result, 2)
+	options.Results = results
+	report, err = cf.ReplicateContainer("TEST", "src", "TEST", "dst", options)
+	if err != nil {
+		t.Fatalf("Could not replicate: %s", err)
+	}
+
+	if report.Succeeded != 1 || report.Skipped != 1 {
+		t.Fatalf("Second run should only copy the changed object: %+v", report)
+	}
+
+	for len(results) > 0 {
+		if result := <-results; result.Name == "a.txt" && result.Reason != "unchanged since last run" {
+			t.Fatalf("a.txt should be skipped by the watermark: %+v", result)
+		}
+	}
+
+	if data, _ := fs.get("dst/b.txt"); string(data) != "beta two" {
+		t.Fatalf("Changed object was not replicated: %q", data)
+	}
+}
+
+func TestReplicateLargeObject(t *testing.T) {
+	// Test a large object is copied whole and its segments are not copied alone.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	putLargeObject(fs, "src", "big.iso", segmentName("big.iso", "", 0, DefaultSegmentDigits), segmentName("big.iso", "", 1, DefaultSegmentDigits))
+
+	report, err := cf.ReplicateContainer("TEST", "src", "TEST", "dst", nil)
+	if err != nil {
+		t.Fatalf("Could not replicate: %s", err)
+	}
+
+	if report.Succeeded != 1 || report.Skipped != 2 {
+		t.Fatalf("Only the large object should be copied: %+v", report)
+	}
+
+	if data, _ := fs.get("dst/big.iso"); string(data) != "xx" {
+		t.Fatalf("Large object was not replicated: %q", data)
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if len(fs.manifests["dst/big.iso"]) != 1 {
+		t.Fatalf("The destination manifest should reference its own segments: %+v",
+			fs.manifests["dst/big.iso"])
+	}
+}
+
+func TestReplicateContainerWatermarkLookback(t *testing.T) {
+	// Test objects as old as the newest one listed are looked at again.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("src/b.txt", []byte("beta"))
+
+	options := &ReplicateOptions{Watermarks: NewMemoryWatermarks()}
+
+	report, err := cf.ReplicateContainer("TEST", "src", "TEST", "dst", options)
+	if err != nil || report.Succeeded != 1 {
+		t.Fatalf("First run should copy everything: %+v %v", report, err)
+	}
+
+	// Written while the first run was listing, listed before b.txt and
+	// sharing its timestamp.
+	fs.mutex.Lock()
+	fs.objects["src/a.txt"] = []byte("alpha")
+	fs.modified["src/a.txt"] = fs.modified["src/b.txt"]
+	fs.mutex.Unlock()
+
+	report, err = cf.ReplicateContainer("TEST", "src", "TEST", "dst", options)
+	if err != nil || report.Succeeded != 1 {
+		t.Fatalf("The late object should be copied: %+v %v", report, err)
+	}
+
+	if data, _ := fs.get("dst/a.txt"); string(data) != "alpha" {
+		t.Fatalf("Late object was not replicated: %q", data)
+	}
+}
+
+func TestReplicateContainerMergesPages(t *testing.T) {
+	// Test listings spanning several pages are merged by name.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	fs.pageSize = 2
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		fs.put("src/"+name, []byte(name))
+	}
+	for _, name := range []string{"b", "d", "z"} {
+		fs.put("dst/"+name, []byte(name))
+	}
+
+	report, err := cf.ReplicateContainer("TEST", "src", "TEST", "dst", nil)
+	if err != nil {
+		t.Fatalf("Could not replicate: %s", err)
+	}
+
+	if report.Succeeded != 3 || report.Skipped != 2 {
+		t.Fatalf("Expected a, c and e to be copied: %+v", report)
+	}
+}
+
+func TestReplicateMirrorDeletes(t *testing.T) {
+	// Test mirror deletes wait for a second run past the confirmation window.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("src/kept.txt", []byte("kept"))
+	fs.put("dst/gone.txt", []byte("gone"))
+	fs.put("dst/back.txt", []byte("back"))
+	putLargeObject(fs, "dst", "big.iso", segmentName("big.iso", "", 0, DefaultSegmentDigits))
+	fs.put("src/big.iso", []byte("x"))
+
+	journal := NewMemoryDeletionJournal()
+	options := &ReplicateOptions{Mirror: true, DeletionJournal: journal, MirrorConfirmAfter: time.Nanosecond}
+
+	if _, err := cf.ReplicateContainer("TEST", "src", "TEST", "dst", options); err != nil {
+		t.Fatalf("Could not mirror: %s", err)
+	}
+
+	if _, ok := fs.get("dst/gone.txt"); !ok {
+		t.Fatalf("The first run should only journal missing objects.")
+	}
+
+	fs.put("src/back.txt", []byte("back"))
+
+	options.MirrorGuard = DeleteGuard{MaxObjects: 1}
+	report, err := cf.ReplicateContainer("TEST", "src", "TEST", "dst", options)
+	if err != nil {
+		t.Fatalf("Could not mirror: %s", err)
+	}
+
+	if _, ok := fs.get("dst/gone.txt"); ok {
+		t.Fatalf("A confirmed missing object should be deleted: %+v", report)
+	}
+
+	for _, name := range []string{"dst/back.txt", "dst/kept.txt", "dst/big.iso"} {
+		if _, ok := fs.get(name); !ok {
+			t.Fatalf("%s should be kept.", name)
+		}
+	}
+
+	if missing, _ := journal.GetMissing("TEST/src/>TEST/dst"); len(missing) != 0 {
+		t.Fatalf("The journal should be empty once deletes are done: %v", missing)
+	}
+}
+
+func TestReplicateMirrorGuard(t *testing.T) {
+	// Test a mirror refuses to delete more than its guard allows.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("dst/a.txt", []byte("a"))
+	fs.put("dst/b.txt", []byte("b"))
+
+	options := &ReplicateOptions{Mirror: true, DeletionJournal: NewMemoryDeletionJournal(),
+		MirrorConfirmAfter: time.Nanosecond, MirrorGuard: DeleteGuard{MaxObjects: 1}}
+
+	cf.ReplicateContainer("TEST", "src", "TEST", "dst", options)
+	_, err := cf.ReplicateContainer("TEST", "src", "TEST", "dst", options)
+	if !errors.Is(err, ErrBlastRadius) {
+		t.Fatalf("Expected ErrBlastRadius but got %v", err)
+	}
+
+	if _, ok := fs.get("dst/a.txt"); !ok {
+		t.Fatalf("Nothing should be deleted past the guard.")
+	}
+}
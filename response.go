@@ -0,0 +1,174 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Errors matched by errors.Is for what the cluster answered, whatever
+// operation got the answer.
+var (
+	ErrNotFound            = errors.New("Not found.")
+	ErrUnauthorized        = errors.New("Unauthorized.")
+	ErrForbidden           = errors.New("Forbidden.")
+	ErrConflict            = errors.New("Conflict.")
+	ErrPreconditionFailed  = errors.New("Precondition failed.")
+	ErrTooLarge            = errors.New("Request entity too large.")
+	ErrRangeNotSatisfiable = errors.New("Range not satisfiable.")
+	ErrChecksumMismatch    = errors.New("Checksum does not match.")
+	ErrRateLimited         = errors.New("Rate limited.")
+	ErrUnavailable         = errors.New("Service unavailable.")
+)
+
+// ErrObjectNotFound and ErrContainerNotFound are ErrObjectMissing and
+// ErrContainerMissing under the names of the other status errors.  Both also
+// match ErrNotFound.
+var (
+	ErrObjectNotFound    = ErrObjectMissing
+	ErrContainerNotFound = ErrContainerMissing
+)
+
+// The status errors by the status codes they match.  Swift answers a PUT
+// whose ETag does not match its data with a 422.
+var statusErrors = map[int]error{
+	401: ErrUnauthorized,
+	403: ErrForbidden,
+	404: ErrNotFound,
+	409: ErrConflict,
+	412: ErrPreconditionFailed,
+	413: ErrTooLarge,
+	416: ErrRangeNotSatisfiable,
+	422: ErrChecksumMismatch,
+	429: ErrRateLimited,
+	503: ErrUnavailable,
+}
+
+// How much of an error response's body a CloudFilesError keeps.  Swift's
+// are a line of HTML or text.
+const errorBodyLimit = 1024
+
+// CloudFilesError is returned for a request the cluster or identity service
+// refused, with what is needed to find it in the provider's logs.  Match it
+// with errors.As, or its status with errors.Is and the status errors.
+type CloudFilesError struct {
+	// Message says what was attempted, such as "Could not put cloud file".
+	Message    string
+	StatusCode int
+
+	// Method and URL are of the request refused.
+	Method string
+	URL    string
+
+	// TransID is the X-Trans-Id Swift tags each request with, or the
+	// X-Openstack-Request-Id of an identity service.
+	TransID string
+
+	// Body is the start of the response, Swift's explanation.
+	Body string
+}
+
+func (e *CloudFilesError) Error() string {
+	message := fmt.Sprintf("%s, status: %d", e.Message, e.StatusCode)
+	if e.Method != "" {
+		message += fmt.Sprintf(", %s %s", e.Method, e.URL)
+	}
+	if e.TransID != "" {
+		message += ", transaction " + e.TransID
+	}
+	if e.Body != "" {
+		message += ", error: " + e.Body
+	}
+	return message
+}
+
+func (e *CloudFilesError) Is(target error) bool {
+	/*
+		Match the status error of the status code, such as ErrUnauthorized
+		for a 401.
+	*/
+	return target != nil && statusErrors[e.StatusCode] == target
+}
+
+func newCloudFilesError(resp *http.Response, format string, args ...interface{}) *CloudFilesError {
+	/*
+		The error of a refused request, reading the start of its body.  The
+		caller still closes the body.
+	*/
+	e := &CloudFilesError{
+		Message:    fmt.Sprintf(format, args...),
+		StatusCode: resp.StatusCode,
+		TransID:    resp.Header.Get("X-Trans-Id"),
+	}
+	if e.TransID == "" {
+		e.TransID = resp.Header.Get("X-Openstack-Request-Id")
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.URL = redactURL(resp.Request.URL)
+	}
+	// A compressed body is no use in a message.
+	if resp.Body != nil && resp.Header.Get("Content-Encoding") == "" {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, errorBodyLimit))
+		e.Body = strings.TrimSpace(string(body))
+	}
+	return e
+}
+
+// Query parameters left out of the URL of a CloudFilesError, as they
+// let anyone holding them at the object.
+var secretParameters = []string{"temp_url_sig", "temp_url_expires"}
+
+func redactURL(u *url.URL) string {
+	/*
+		The URL of a request fit for messages and logs: the token of a
+		/tokens/<token> path is masked and the temp URL signature dropped.
+	*/
+	redacted := *u
+	redacted.User = nil
+
+	segments := strings.Split(redacted.Path, "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] == "tokens" && segments[i+1] != "" {
+			segments[i+1] = "REDACTED"
+		}
+	}
+	redacted.Path = strings.Join(segments, "/")
+	redacted.RawPath = ""
+
+	if redacted.RawQuery != "" {
+		query := redacted.Query()
+		for _, parameter := range secretParameters {
+			query.Del(parameter)
+		}
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
+
+func succeeded(resp *http.Response) bool {
+	/*
+		Whether a response is a success.  Swift answers each request with
+		one particular 2xx code, but proxies in front of it and other
+		implementations may pick another, e.g. 204 for a HEAD or 200 for a
+		PUT, so any 2xx counts.
+	*/
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+func contentLength(headers http.Header) (int64, error) {
+	/*
+		The Content-Length of a response, whatever the casing the server
+		sent it in.
+	*/
+	size, err := strconv.ParseInt(headers.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Could not determine content length.")
+	}
+	return size, nil
+}
@@ -0,0 +1,177 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// A transport standing in for a proxy that answers with other 2xx codes.
+type proxyTransport struct{}
+
+func (proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	switch {
+	case req.Method == "HEAD" && resp.StatusCode == 200:
+		resp.StatusCode = 204
+	case resp.StatusCode == 201 || resp.StatusCode == 202 || resp.StatusCode == 204:
+		resp.StatusCode = 200
+	}
+	return resp, nil
+}
+
+func TestAnySuccessStatus(t *testing.T) {
+	// Test operations accept any 2xx a proxy answers with.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.SetTransport(proxyTransport{})
+
+	if _, err := cf.PutFile("TEST", "testing", "object", strings.NewReader("data")); err != nil {
+		t.Fatalf("Put refused: %s", err)
+	}
+
+	size, etag, err := cf.GetFileSize("TEST", "testing", "object")
+	if err != nil || size != 4 || etag == "" {
+		t.Fatalf("Unexpected size %d %q %v", size, etag, err)
+	}
+
+	var out bytes.Buffer
+	if _, _, err := cf.GetChunk("TEST", "testing", "object", &out, 0, 0); err != nil || out.String() != "data" {
+		t.Fatalf("Unexpected chunk %q %v", out.String(), err)
+	}
+
+	if err := cf.createContainer("TEST", "other", nil); err != nil {
+		t.Fatalf("Container create refused: %s", err)
+	}
+	if err := cf.deleteObject("TEST", "testing", "object"); err != nil {
+		t.Fatalf("Delete refused: %s", err)
+	}
+}
+
+func TestContentLengthMissing(t *testing.T) {
+	// Test a missing length is an error, not a panic.
+	if _, err := contentLength(http.Header{}); err == nil {
+		t.Fatalf("Expected an error for a missing length.")
+	}
+	if size, err := contentLength(http.Header{"Content-Length": {"12"}}); err != nil || size != 12 {
+		t.Fatalf("Unexpected length %d %v", size, err)
+	}
+}
+
+// A transport refusing every request with status, as a full cluster does
+// with a 507 when it is zero.
+type refusingTransport struct {
+	status int
+}
+
+func (rt refusingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status := rt.status
+	if status == 0 {
+		status = 507
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"X-Trans-Id": {"tx1234-0057"}},
+		Body:       ioutil.NopCloser(strings.NewReader("<html><h1>Insufficient Storage</h1></html>\n")),
+		Request:    req,
+	}, nil
+}
+
+func TestCloudFilesError(t *testing.T) {
+	// Test refused requests describe the exchange for the provider's logs.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.SetTransport(refusingTransport{})
+
+	_, err := cf.PutFile("TEST", "testing", "object", strings.NewReader("data"))
+	var refused *CloudFilesError
+	if !errors.As(err, &refused) {
+		t.Fatalf("Expected a CloudFilesError, got %v", err)
+	}
+	if refused.StatusCode != 507 || refused.Method != "PUT" || refused.URL != fs.server.URL+"/testing/object" ||
+		refused.TransID != "tx1234-0057" || refused.Body != "<html><h1>Insufficient Storage</h1></html>" {
+		t.Fatalf("Unexpected error %+v", refused)
+	}
+	if message := err.Error(); !strings.HasPrefix(message, "Could not put cloud file, status: 507") ||
+		!strings.Contains(message, "tx1234-0057") {
+		t.Fatalf("Unexpected message %q", message)
+	}
+
+	err = cf.putManifest("TEST", "testing", "object", manifestList{}, nil)
+	if !errors.As(err, &refused) || refused.Method != "PUT" || !strings.Contains(refused.URL, "multipart-manifest=put") {
+		t.Fatalf("Expected a CloudFilesError for the manifest, got %v", err)
+	}
+}
+
+func TestCloudFilesErrorRedacted(t *testing.T) {
+	// Test tokens and temp URL signatures stay out of refused requests'
+	// errors.
+	cf := NewCloudFilesImpersonation("secret-token-1234")
+	cf.SetIdentityEndpoint("https://identity.example.com/v2.0")
+	cf.SetTransport(refusingTransport{status: 401})
+
+	err := cf.RefreshCatalog()
+	var refused *CloudFilesError
+	if !errors.As(err, &refused) {
+		t.Fatalf("Expected a CloudFilesError, got %v", err)
+	}
+	if strings.Contains(err.Error(), "secret-token-1234") || strings.Contains(refused.URL, "secret-token-1234") {
+		t.Fatalf("Token in the error %q", err)
+	}
+	if refused.URL != "https://identity.example.com/v2.0/tokens/REDACTED/endpoints" {
+		t.Fatalf("Unexpected URL %q", refused.URL)
+	}
+
+	signed, _ := url.Parse("https://storage.example.com/v1/AUTH_1/c/o?temp_url_sig=abc123&temp_url_expires=99&inline")
+	if redacted := redactURL(signed); strings.Contains(redacted, "abc123") || strings.Contains(redacted, "temp_url_expires") ||
+		!strings.Contains(redacted, "inline") {
+		t.Fatalf("Unexpected temp URL %q", redacted)
+	}
+}
+
+func TestStatusErrors(t *testing.T) {
+	// Test every status error matches the answer it is named for and no
+	// other.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	for status, want := range statusErrors {
+		cf.SetTransport(refusingTransport{status: status})
+		_, err := cf.PutFile("TEST", "testing", "object", strings.NewReader("data"))
+		for other, unwanted := range statusErrors {
+			if errors.Is(err, unwanted) != (other == status) {
+				t.Fatalf("Status %d matching %v is %v for %v", status, unwanted, !(other == status), err)
+			}
+		}
+		if !errors.Is(fmt.Errorf("Wrapped: %w", err), want) {
+			t.Fatalf("Wrapped status %d does not match %v", status, want)
+		}
+	}
+
+	cf.SetTransport(refusingTransport{status: 404})
+	_, _, err := cf.GetFileSize("TEST", "testing", "object")
+	if !errors.Is(err, ErrObjectNotFound) || !errors.Is(err, ErrNotFound) || errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("Unexpected errors matched by %v", err)
+	}
+	_, err = cf.GetContainerMetadata("TEST", "testing")
+	if !errors.Is(err, ErrContainerNotFound) || !errors.Is(err, ErrNotFound) || errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("Unexpected errors matched by %v", err)
+	}
+
+	cf.SetTransport(refusingTransport{status: 401})
+	if _, err := cf.ListContainers("TEST"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Expected ErrUnauthorized, got %v", err)
+	}
+}
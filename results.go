@@ -0,0 +1,164 @@
+package gocloudfiles
+
+import (
+	"fmt"
+)
+
+// The outcome of a single object within a bulk operation.
+type ResultStatus int
+
+const (
+	ResultSuccess ResultStatus = iota
+	ResultFailure
+	ResultSkipped
+)
+
+func (s ResultStatus) String() string {
+	switch s {
+	case ResultSuccess:
+		return "success"
+	case ResultFailure:
+		return "failure"
+	case ResultSkipped:
+		return "skipped"
+	}
+	return "unknown"
+}
+
+// A Result is streamed on a bulk operation's results channel once for every
+// object the operation touches.  The caller owns that channel: operations
+// never close it and block on each send, so it must be drained concurrently
+// and closed by the caller once the operation returns.
+type Result struct {
+	Op     string
+	DC     string
+	Bucket string
+	Name   string
+	Status ResultStatus
+	Reason string
+	Bytes  int64
+	Err    error
+}
+
+func sendResult(results chan<- Result, result Result) {
+	/*
+		Emit a result if the caller asked for them.  A nil channel means the
+		caller is not interested in per-object events.
+	*/
+	if results != nil {
+		results <- result
+	}
+}
+
+// A Report summarizes a bulk operation so a run where one object out of a
+// thousand failed can be told apart from one where nothing worked.
+type Report struct {
+	Succeeded int
+	Failed    int
+	Skipped   int
+	Bytes     int64
+	Failures  []Result
+
+	// Aborted is set when the operation stopped early on its
+	// FailureBudget, the objects not yet started are not counted.
+	Aborted bool
+
+	budget      *FailureBudget
+	consecutive int
+	abortReason string
+}
+
+func (r *Report) Add(result Result) {
+	/*
+		Account for a single object result.
+	*/
+	switch result.Status {
+	case ResultSuccess:
+		r.Succeeded++
+		r.Bytes += result.Bytes
+		r.consecutive = 0
+	case ResultSkipped:
+		r.Skipped++
+	default:
+		r.Failed++
+		r.Failures = append(r.Failures, result)
+		r.consecutive++
+	}
+
+	if reason := r.budget.exceeded(r); reason != "" && !r.Aborted {
+		r.Aborted = true
+		r.abortReason = reason
+	}
+}
+
+func (r *Report) Total() int {
+	return r.Succeeded + r.Failed + r.Skipped
+}
+
+func (r *Report) Err() error {
+	/*
+		Returns nil if every object succeeded or was skipped, otherwise a
+		*MultiError holding each failure, wrapped in a
+		*BudgetExceededError when the operation was aborted.
+	*/
+	if r.Failed == 0 {
+		return nil
+	}
+
+	failures := &MultiError{Failures: r.Failures, Total: r.Total()}
+	if r.Aborted {
+		return &BudgetExceededError{Reason: r.abortReason, Failures: failures}
+	}
+	return failures
+}
+
+func (r *Report) record(results chan<- Result, result Result) {
+	r.Add(result)
+	sendResult(results, result)
+}
+
+func Collect(results <-chan Result) *Report {
+	/*
+		Drain a results channel until it is closed and summarize it.  Run it
+		in its own goroutine and close the channel after the operation.
+	*/
+	report := &Report{}
+	for result := range results {
+		report.Add(result)
+	}
+	return report
+}
+
+// MultiError is returned by bulk operations when some objects failed.  The
+// per-object causes are preserved so errors.Is and errors.As see through it.
+type MultiError struct {
+	Failures []Result
+	Total    int
+}
+
+func (m *MultiError) Error() string {
+	if len(m.Failures) == 0 {
+		return "No objects failed."
+	}
+
+	first := m.Failures[0]
+	return fmt.Sprintf("%d of %d objects failed, first error: %s/%s: %s",
+		len(m.Failures), m.Total, first.Bucket, first.Name, first.cause())
+}
+
+func (m *MultiError) Unwrap() []error {
+	errs := make([]error, 0, len(m.Failures))
+	for _, failure := range m.Failures {
+		if failure.Err != nil {
+			errs = append(errs, failure.Err)
+		}
+	}
+	return errs
+}
+
+func (r Result) cause() string {
+	if r.Err != nil {
+		return r.Err.Error()
+	}
+	return r.Reason
+}
@@ -0,0 +1,73 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReportAggregatesFailures(t *testing.T) {
+	// Test a partial failure is reported with its cause intact.
+	cause := errors.New("boom")
+	results := make(chan Result, 3)
+	results <- Result{Name: "a", Status: ResultSuccess, Bytes: 10}
+	results <- Result{Name: "b", Status: ResultSkipped}
+	results <- Result{Name: "c", Status: ResultFailure, Err: cause}
+	close(results)
+
+	report := Collect(results)
+
+	if report.Succeeded != 1 || report.Skipped != 1 || report.Failed != 1 {
+		t.Fatalf("Unexpected counts: %+v", report)
+	}
+
+	if report.Bytes != 10 {
+		t.Fatalf("Expected 10 bytes but got %d", report.Bytes)
+	}
+
+	err := report.Err()
+	if !errors.Is(err, cause) {
+		t.Fatalf("Expected report error to wrap cause, got: %s", err)
+	}
+
+	if (&Report{Succeeded: 5}).Err() != nil {
+		t.Fatalf("A report with no failures should not be an error.")
+	}
+}
+
+func TestResultsChannelOwnedByCaller(t *testing.T) {
+	// Test an unbuffered results channel drained by Collect sees every object.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("testing/logs/1", []byte("one"))
+	fs.put("testing/logs/2", []byte("two"))
+
+	results := make(chan Result)
+	summary := make(chan *Report)
+	go func() { summary <- Collect(results) }()
+
+	report, err := cf.DeletePrefix("TEST", "testing", "logs/", &DeleteOptions{Results: results})
+	close(results)
+	collected := <-summary
+
+	if err != nil || collected.Succeeded != report.Succeeded || collected.Total() != 2 {
+		t.Fatalf("Collected %+v but the operation reported %+v: %v", collected, report, err)
+	}
+}
+
+func TestResultStatusString(t *testing.T) {
+	// Test every status has a readable name.
+	names := map[ResultStatus]string{
+		ResultSuccess:   "success",
+		ResultFailure:   "failure",
+		ResultSkipped:   "skipped",
+		ResultStatus(9): "unknown",
+	}
+
+	for status, name := range names {
+		if status.String() != name {
+			t.Fatalf("Expected %q but got %q", name, status.String())
+		}
+	}
+}
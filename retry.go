@@ -0,0 +1,152 @@
+package gocloudfiles
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryOptions tune how SetRetries sends a request again after an overload
+// answer.  Zero values take the defaults.
+type RetryOptions struct {
+	// MaxAttempts is how many times a request is sent in all, 4 when
+	// zero.  1 turns retries off.
+	MaxAttempts int
+
+	// BaseDelay doubles after each attempt up to MaxDelay, 250ms and 30
+	// seconds when zero.  The wait is a random duration up to it, so
+	// clients that failed together do not retry together.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// MaxRetryAfter is the longest Retry-After waited for, two minutes
+	// when zero.  An answer asking for longer is returned as it is.
+	MaxRetryAfter time.Duration
+
+	// Statuses are the answers retried, 429, 502, 503 and 504 when empty.
+	Statuses []int
+}
+
+var DefaultRetryOptions = RetryOptions{
+	MaxAttempts:   4,
+	BaseDelay:     250 * time.Millisecond,
+	MaxDelay:      30 * time.Second,
+	MaxRetryAfter: 2 * time.Minute,
+	Statuses:      []int{429, 502, 503, 504},
+}
+
+func (options RetryOptions) withDefaults() RetryOptions {
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = DefaultRetryOptions.MaxAttempts
+	}
+	if options.BaseDelay <= 0 {
+		options.BaseDelay = DefaultRetryOptions.BaseDelay
+	}
+	if options.MaxDelay <= 0 {
+		options.MaxDelay = DefaultRetryOptions.MaxDelay
+	}
+	if options.MaxDelay < options.BaseDelay {
+		options.MaxDelay = options.BaseDelay
+	}
+	if options.MaxRetryAfter <= 0 {
+		options.MaxRetryAfter = DefaultRetryOptions.MaxRetryAfter
+	}
+	if len(options.Statuses) == 0 {
+		options.Statuses = DefaultRetryOptions.Statuses
+	}
+	return options
+}
+
+func (cf *CloudFiles) SetRetries(options RetryOptions) {
+	/*
+		Send idempotent storage requests, GET, HEAD, PUT and DELETE, again
+		when the cluster answers that it is overloaded, waiting as long as
+		a Retry-After header asks or backing off exponentially.  Requests
+		whose body cannot be read again, such as a download relayed to an
+		upload, are sent once.  WriteOptions.Retries retries a whole write
+		on top of this.
+	*/
+	resolved := options.withDefaults()
+	cf.retries = &resolved
+}
+
+func (options *RetryOptions) retryable(req *http.Request, resp *http.Response) bool {
+	switch req.Method {
+	case "GET", "HEAD", "PUT", "DELETE":
+	default:
+		return false
+	}
+
+	for _, status := range options.Statuses {
+		if resp.StatusCode == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (options *RetryOptions) delay(attempt int, retryAfter string) (time.Duration, bool) {
+	/*
+		How long to wait before sending a request again after its attempt-th
+		try, and whether to at all.  Retry-After is given in seconds or as
+		an HTTP date.
+	*/
+	if retryAfter != "" {
+		wait := time.Duration(-1)
+		if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		} else if at, err := http.ParseTime(retryAfter); err == nil {
+			wait = time.Until(at)
+		}
+
+		if wait > options.MaxRetryAfter {
+			return 0, false
+		}
+		if wait >= 0 {
+			return wait, true
+		}
+	}
+
+	backoff := options.MaxDelay
+	if shift := uint(attempt - 1); shift < 32 && options.BaseDelay<<shift < options.MaxDelay {
+		backoff = options.BaseDelay << shift
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1)), true
+}
+
+func (cf CloudFiles) sendRetrying(dc string, req *http.Request) (*http.Response, error) {
+	/*
+		Send a storage request, and again as SetRetries allows while it is
+		answered with an overload status.
+	*/
+	resp, err := cf.send(dc, req)
+	if cf.retries == nil {
+		return resp, err
+	}
+
+	for attempt := 1; err == nil && attempt < cf.retries.MaxAttempts && cf.retries.retryable(req, resp); attempt++ {
+		wait, ok := cf.retries.delay(attempt, resp.Header.Get("Retry-After"))
+		if !ok {
+			break
+		}
+		retry, ok := replayable(req)
+		if !ok {
+			break
+		}
+		resp.Body.Close()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+
+		req = retry
+		resp, err = cf.send(dc, req)
+	}
+
+	return resp, err
+}
@@ -0,0 +1,116 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRetriesOverloaded(t *testing.T) {
+	// Test overloaded answers are retried as Retry-After asks until they
+	// stop.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	fs.put("testing/file.txt", []byte("hello"))
+
+	faults := &FaultTransport{Rules: []*FaultRule{
+		{Method: "HEAD", Kind: FaultStatus, Status: 503, Times: 2, Header: http.Header{"Retry-After": {"0"}}},
+		{Method: "GET", Kind: FaultStatus, Status: 429, Times: 1},
+		{Method: "PUT", Kind: FaultStatus, Status: 503, Times: 1},
+	}}
+	cf.SetTransport(faults)
+	cf.SetRetries(RetryOptions{BaseDelay: time.Millisecond})
+
+	if size, _, err := cf.GetFileSize("TEST", "testing", "file.txt"); err != nil || size != 5 {
+		t.Fatalf("A retried HEAD should get past the failures: %d %v", size, err)
+	}
+
+	var out bytes.Buffer
+	if _, _, err := cf.GetChunk("TEST", "testing", "file.txt", &out, 0, 0); err != nil || out.String() != "hello" {
+		t.Fatalf("A retried GET should get past the failure: %q %v", out.String(), err)
+	}
+
+	// The upload is rewound for the second attempt.
+	data := bytes.NewReader([]byte("xxworld"))
+	data.Seek(2, io.SeekStart)
+	if _, err := cf.PutFile("TEST", "testing", "put.txt", data); err != nil {
+		t.Fatalf("A retried PUT should get past the failure: %v", err)
+	}
+	if stored, _ := fs.get("testing/put.txt"); string(stored) != "world" {
+		t.Fatalf("Unexpected upload %q", stored)
+	}
+
+	if faults.Injected() != 4 {
+		t.Fatalf("Expected 4 failures, got %d", faults.Injected())
+	}
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	// Test requests are not retried beyond the limits or when they cannot
+	// be replayed.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	fs.put("testing/file.txt", []byte("hello"))
+
+	busy := &FaultRule{Path: regexp.MustCompile(`/file\.txt$`), Kind: FaultStatus, Status: 503}
+	faults := &FaultTransport{Rules: []*FaultRule{busy}}
+	cf.SetTransport(faults)
+	cf.SetRetries(RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	if _, _, err := cf.GetFileSize("TEST", "testing", "file.txt"); err == nil || faults.Injected() != 3 {
+		t.Fatalf("Expected failure after 3 attempts: %v %d", err, faults.Injected())
+	}
+
+	busy.Header = http.Header{"Retry-After": {"3600"}}
+	started := time.Now()
+	if _, _, err := cf.GetFileSize("TEST", "testing", "file.txt"); err == nil || faults.Injected() != 4 {
+		t.Fatalf("A Retry-After over the maximum should not be waited for: %v %d", err, faults.Injected())
+	}
+	if time.Since(started) > time.Second {
+		t.Fatalf("Waited %s for a Retry-After over the maximum.", time.Since(started))
+	}
+
+	// A body that cannot be read again is sent once.
+	busy.Header = nil
+	if _, err := cf.PutFile("TEST", "testing", "file.txt", io.MultiReader(bytes.NewReader([]byte("hi")))); err == nil ||
+		faults.Injected() != 5 {
+		t.Fatalf("A streamed upload should be sent once: %v %d", err, faults.Injected())
+	}
+
+	// Other failures are not retried.
+	busy.Status = 500
+	if _, _, err := cf.GetFileSize("TEST", "testing", "file.txt"); err == nil || faults.Injected() != 6 {
+		t.Fatalf("A 500 should not be retried: %v %d", err, faults.Injected())
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	options := RetryOptions{BaseDelay: time.Second, MaxDelay: 4 * time.Second}.withDefaults()
+
+	for attempt, limit := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if wait, ok := options.delay(attempt+1, ""); !ok || wait < 0 || wait > limit {
+				t.Fatalf("Unexpected wait %s after attempt %d", wait, attempt+1)
+			}
+		}
+	}
+
+	if wait, ok := options.delay(1, "7"); !ok || wait != 7*time.Second {
+		t.Fatalf("Unexpected wait %s for Retry-After seconds", wait)
+	}
+	at := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if wait, ok := options.delay(1, at); !ok || wait < 58*time.Second || wait > time.Minute {
+		t.Fatalf("Unexpected wait %s for Retry-After date", wait)
+	}
+	if _, ok := options.delay(1, "600"); ok {
+		t.Fatalf("A Retry-After over MaxRetryAfter should not be waited for.")
+	}
+	if wait, ok := options.delay(1, "soon"); !ok || wait > time.Second {
+		t.Fatalf("An unreadable Retry-After should back off instead: %s", wait)
+	}
+}
@@ -0,0 +1,363 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A cron style schedule: minute, hour, day of month, month and day of week.
+type cronSchedule struct {
+	minute   uint64
+	hour     uint64
+	dom      uint64
+	month    uint64
+	dow      uint64
+	anyDom   bool
+	anyDow   bool
+	location *time.Location
+}
+
+var cronAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	/*
+		Parse one cron field made of comma separated values, ranges and
+		steps, e.g. "*", "5", "1-5", "0-30/10" or "0,30".
+	*/
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("Invalid step in cron field %q.", field)
+			}
+			part = part[:i]
+		}
+
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			low, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("Invalid value in cron field %q.", field)
+			}
+			high = low
+			if len(bounds) == 2 {
+				high, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, fmt.Errorf("Invalid range in cron field %q.", field)
+				}
+			} else if step > 1 {
+				high = max
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("Cron field %q is out of range %d-%d.", field, min, max)
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseCron(spec string) (*cronSchedule, error) {
+	/*
+		Parse a five field cron expression or one of the @hourly style
+		aliases.  Times are evaluated in local time.
+	*/
+	if alias, ok := cronAliases[strings.TrimSpace(spec)]; ok {
+		spec = alias
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Cron expression %q must have 5 fields.", spec)
+	}
+
+	schedule := &cronSchedule{
+		anyDom:   fields[2] == "*",
+		anyDow:   fields[4] == "*",
+		location: time.Local,
+	}
+
+	var err error
+	if schedule.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if schedule.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if schedule.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+
+	// Both 0 and 7 mean Sunday.
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+
+	return schedule, nil
+}
+
+func (cs *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := cs.dom&(1<<uint(t.Day())) != 0
+	dowMatch := cs.dow&(1<<uint(t.Weekday())) != 0
+
+	// When both day fields are restricted either one may match.
+	if !cs.anyDom && !cs.anyDow {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+func (cs *cronSchedule) next(after time.Time) time.Time {
+	/*
+		Find the first matching minute strictly after the given time, or
+		the zero time if none exists within five years.
+	*/
+	t := after.In(cs.location)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, cs.location)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if cs.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, cs.location)
+			continue
+		}
+
+		if !cs.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, cs.location)
+			continue
+		}
+
+		if cs.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, cs.location)
+			continue
+		}
+
+		if cs.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// ScheduleStatus reports on one task of a Scheduler.
+type ScheduleStatus struct {
+	Name      string
+	Spec      string
+	Running   bool
+	Next      time.Time
+	LastStart time.Time
+	LastEnd   time.Time
+	LastError error
+	Runs      int
+	Failures  int
+	// Runs that were due while the previous run was still going.
+	Skipped int
+}
+
+type scheduledTask struct {
+	status   ScheduleStatus
+	schedule *cronSchedule
+	task     func() error
+}
+
+// A Scheduler re-runs tasks such as ReplicateContainer on cron style
+// schedules.  A task that is still running when it comes due again is
+// skipped rather than started twice.
+type Scheduler struct {
+	mutex sync.Mutex
+	tasks map[string]*scheduledTask
+	stop  chan bool
+	wg    sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		tasks: make(map[string]*scheduledTask),
+	}
+}
+
+func (s *Scheduler) Add(name, spec string, task func() error) error {
+	/*
+		Register a task to run whenever the cron expression matches.
+	*/
+	schedule, err := parseCron(spec)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.tasks[name]; ok {
+		return fmt.Errorf("A task named %s is already scheduled.", name)
+	}
+
+	s.tasks[name] = &scheduledTask{
+		status: ScheduleStatus{
+			Name: name,
+			Spec: spec,
+			Next: schedule.next(time.Now()),
+		},
+		schedule: schedule,
+		task:     task,
+	}
+
+	return nil
+}
+
+func (s *Scheduler) Remove(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.tasks, name)
+}
+
+func (s *Scheduler) Start() {
+	/*
+		Begin running tasks as they come due.
+	*/
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+
+	s.stop = make(chan bool)
+	go s.loop(s.stop)
+}
+
+func (s *Scheduler) Stop() {
+	/*
+		Stop starting tasks and wait for the running ones to finish.
+	*/
+	s.mutex.Lock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(stop chan bool) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.tick(now)
+		}
+	}
+}
+
+func (s *Scheduler) tick(now time.Time) {
+	/*
+		Start every task that is due at the given time.
+	*/
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, entry := range s.tasks {
+		if entry.status.Next.IsZero() || entry.status.Next.After(now) {
+			continue
+		}
+
+		entry.status.Next = entry.schedule.next(now)
+
+		if entry.status.Running {
+			entry.status.Skipped++
+			continue
+		}
+
+		entry.status.Running = true
+		entry.status.LastStart = now
+		s.wg.Add(1)
+
+		go s.run(entry)
+	}
+}
+
+func (s *Scheduler) run(entry *scheduledTask) {
+	defer s.wg.Done()
+
+	err := entry.task()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry.status.Running = false
+	entry.status.LastEnd = time.Now()
+	entry.status.LastError = err
+	entry.status.Runs++
+	if err != nil {
+		entry.status.Failures++
+	}
+}
+
+func (s *Scheduler) Status(name string) (ScheduleStatus, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.tasks[name]
+	if !ok {
+		return ScheduleStatus{}, false
+	}
+	return entry.status, true
+}
+
+func (s *Scheduler) Statuses() []ScheduleStatus {
+	/*
+		Report on every task, sorted by name.
+	*/
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	statuses := make([]ScheduleStatus, 0, len(s.tasks))
+	for _, entry := range s.tasks {
+		statuses = append(statuses, entry.status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
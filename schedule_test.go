@@ -0,0 +1,70 @@
+package gocloudfiles
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// Test cron expressions find the next matching minute.
+	base := time.Date(2016, 3, 1, 10, 7, 30, 0, time.Local)
+
+	cases := map[string]time.Time{
+		"*/15 * * * *": time.Date(2016, 3, 1, 10, 15, 0, 0, time.Local),
+		"0 2 * * *":    time.Date(2016, 3, 2, 2, 0, 0, 0, time.Local),
+		"30 9 * * 1-5": time.Date(2016, 3, 2, 9, 30, 0, 0, time.Local),
+		"@monthly":     time.Date(2016, 4, 1, 0, 0, 0, 0, time.Local),
+		"0 0 29 2 *":   time.Date(2020, 2, 29, 0, 0, 0, 0, time.Local),
+	}
+
+	for spec, want := range cases {
+		schedule, err := parseCron(spec)
+		if err != nil {
+			t.Fatalf("Could not parse %s: %s", spec, err)
+		}
+
+		if got := schedule.next(base); !got.Equal(want) {
+			t.Fatalf("%s: expected %s but got %s", spec, want, got)
+		}
+	}
+
+	for _, spec := range []string{"* * *", "60 * * * *", "* * * * mon", "5-1 * * * *"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Fatalf("%s should not parse.", spec)
+		}
+	}
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	// Test a task still running when it comes due again is not restarted.
+	s := NewScheduler()
+	release := make(chan bool)
+	started := make(chan bool, 2)
+
+	err := s.Add("replicate", "* * * * *", func() error {
+		started <- true
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Could not add task: %s", err)
+	}
+
+	now := time.Now().Add(time.Minute)
+	s.tick(now)
+	<-started
+	s.tick(now.Add(time.Minute))
+
+	status, _ := s.Status("replicate")
+	if !status.Running || status.Skipped != 1 {
+		t.Fatalf("Expected one skipped run: %+v", status)
+	}
+
+	close(release)
+	s.Stop()
+
+	status, _ = s.Status("replicate")
+	if status.Running || status.Runs != 1 || len(started) != 0 {
+		t.Fatalf("Expected exactly one finished run: %+v", status)
+	}
+}
@@ -0,0 +1,68 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// How long DetectLocalDC waits for an internal URL when no timeout is
+// given.  ServiceNet answers within milliseconds from inside a region.
+const defaultServiceNetTimeout = 2 * time.Second
+
+func (cf *CloudFiles) DetectLocalDC(timeout time.Duration) (string, error) {
+	/*
+		Find the region the client runs in by trying every internal URL of
+		the catalog, which only answer from inside their region's network,
+		and SetLocalDC the one that answers.  When several answer the
+		fastest wins.  Call after Authorize, a zero timeout waits 2 seconds.
+		Returns a tuple of region, error
+	*/
+	if timeout <= 0 {
+		timeout = defaultServiceNetTimeout
+	}
+
+	regions := make([]string, 0, len(cf.dcsInternal))
+	for region, url := range cf.dcsInternal {
+		if url != "" && url != cf.dcs[region] {
+			regions = append(regions, region)
+		}
+	}
+	if len(regions) == 0 {
+		return "", fmt.Errorf("The service catalog has no internal URLs.")
+	}
+	sort.Strings(regions)
+
+	answered := make(chan string, len(regions))
+	client := cf.httpClient()
+	client.Timeout = timeout
+	token := cf.token()
+	for _, region := range regions {
+		go func(region, url string) {
+			req, err := http.NewRequest("HEAD", url, nil)
+			if err != nil {
+				answered <- ""
+				return
+			}
+			req.Header.Add("X-Auth-Token", token)
+
+			// Any answer, even a rejected token, means the URL is reachable.
+			resp, err := client.Do(req)
+			if err != nil {
+				answered <- ""
+				return
+			}
+			resp.Body.Close()
+			answered <- region
+		}(region, cf.dcsInternal[region])
+	}
+
+	for range regions {
+		if region := <-answered; region != "" {
+			cf.SetLocalDC(region)
+			return region, nil
+		}
+	}
+	return "", fmt.Errorf("No internal URL answered, the client is not inside a region's network.")
+}
@@ -0,0 +1,43 @@
+package gocloudfiles
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDetectLocalDC(t *testing.T) {
+	// Test the region whose internal URL answers becomes the local DC.
+	fs := newFakeSwift()
+	defer fs.Close()
+	unreachable := httptest.NewServer(nil)
+	unreachable.Close()
+
+	cf := NewCloudFilesImpersonation("token")
+	cf.dcs["DFW"] = "https://dfw.invalid/v1/AUTH_test"
+	cf.dcsInternal["DFW"] = fs.server.URL
+	cf.dcs["ORD"] = "https://ord.invalid/v1/AUTH_test"
+	cf.dcsInternal["ORD"] = unreachable.URL
+
+	region, err := cf.DetectLocalDC(time.Second)
+	if err != nil || region != "DFW" || cf.LocalDC() != "DFW" {
+		t.Fatalf("Expected DFW, got %s %v", region, err)
+	}
+	if endpoint, _ := cf.endpoint("DFW"); endpoint != fs.server.URL {
+		t.Fatalf("Local DC not reached internally: %s", endpoint)
+	}
+	if endpoint, _ := cf.endpoint("ORD"); endpoint != cf.dcs["ORD"] {
+		t.Fatalf("Other region not reached publicly: %s", endpoint)
+	}
+
+	cf.dcsInternal["DFW"] = unreachable.URL
+	if _, err := cf.DetectLocalDC(time.Second); err == nil {
+		t.Fatalf("Detected a local DC without a reachable internal URL.")
+	}
+
+	// A region without an internal URL is reached publicly even when local.
+	delete(cf.dcsInternal, "DFW")
+	if endpoint, _ := cf.endpoint("DFW"); endpoint != cf.dcs["DFW"] {
+		t.Fatalf("Expected the public URL, got %s", endpoint)
+	}
+}
@@ -0,0 +1,102 @@
+package gocloudfiles
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ClockSkew is how far a region's clock is from the local one, measured from
+// the Date header of its latest response.
+type ClockSkew struct {
+	// Offset turns local time into the server's, it is positive when the
+	// server is ahead.
+	Offset time.Duration
+
+	// Uncertainty bounds the error of Offset: half the round trip plus
+	// half a second, the resolution of the Date header.
+	Uncertainty time.Duration
+
+	// Samples is how many responses were measured, MeasuredAt the local
+	// time of the latest.
+	Samples    int
+	MeasuredAt time.Time
+}
+
+type serverClocks struct {
+	mutex sync.Mutex
+	skews map[string]ClockSkew
+}
+
+func newServerClocks() *serverClocks {
+	return &serverClocks{skews: make(map[string]ClockSkew)}
+}
+
+func (sc *serverClocks) observe(dc string, sent, received time.Time, date string) {
+	/*
+		Measure the skew from a response.  The server stamped it some time
+		between sending and receiving, so the midpoint is taken, and in the
+		second the header names, so half a second is added.
+	*/
+	if sc == nil {
+		return
+	}
+
+	stamped, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+
+	roundTrip := received.Sub(sent)
+	midpoint := sent.Add(roundTrip / 2)
+
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	skew := sc.skews[dc]
+	skew.Offset = stamped.Add(500 * time.Millisecond).Sub(midpoint)
+	skew.Uncertainty = roundTrip/2 + 500*time.Millisecond
+	skew.Samples++
+	skew.MeasuredAt = received
+	sc.skews[dc] = skew
+}
+
+func (sc *serverClocks) skew(dc string) (ClockSkew, bool) {
+	if sc == nil {
+		return ClockSkew{}, false
+	}
+
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+
+	skew, ok := sc.skews[dc]
+	return skew, ok
+}
+
+func (cf CloudFiles) ClockSkews() map[string]ClockSkew {
+	/*
+		The measured clock skew of every region a response came from, so
+		a drifting host can be spotted before its signatures go wrong.
+	*/
+	skews := make(map[string]ClockSkew)
+	if cf.clocks == nil {
+		return skews
+	}
+
+	cf.clocks.mutex.Lock()
+	defer cf.clocks.mutex.Unlock()
+
+	for dc, skew := range cf.clocks.skews {
+		skews[dc] = skew
+	}
+	return skews
+}
+
+func (cf CloudFiles) ServerTime(dc string) time.Time {
+	/*
+		The current time on a region's servers, by its measured skew.
+		Local time is returned until a response from it was seen.
+	*/
+	skew, _ := cf.clocks.skew(dc)
+	return time.Now().Add(skew.Offset)
+}
@@ -0,0 +1,135 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Swift refuses single objects larger than this.
+const maxObjectSize = 5 << 30
+
+func (cf CloudFiles) Split(dc, bucket, source, pattern string, partSize int64) ([]string, error) {
+	/*
+		Materialize an object as standalone parts of at most partSize
+		bytes, for readers that cannot follow a large object's manifest or
+		need capped object sizes.  Parts are named by formatting pattern,
+		e.g. "backup.tar.part-%04d", with their index from zero, and every
+		part but the last is exactly partSize.  A part that matches a
+		segment of the source, or the whole of a small plain object, is
+		copied server side.  Other parts are read as a range and uploaded
+		with the MD5 of that range.  The source is left in place.
+		Returns a tuple of part names, error
+	*/
+	if partSize <= 0 || partSize > maxObjectSize {
+		return nil, fmt.Errorf("Part size %d must be positive and at most %d bytes.", partSize, maxObjectSize)
+	}
+
+	first := fmt.Sprintf(pattern, 0)
+	if strings.Contains(first, "%!") || first == fmt.Sprintf(pattern, 1) {
+		return nil, fmt.Errorf("Pattern %q needs one integer verb, such as %%04d.", pattern)
+	}
+
+	headers, err := cf.headObject(dc, bucket, source)
+	if err != nil {
+		return nil, err
+	}
+	info := newObjectInfo(source, headers)
+
+	var segments []sloSegment
+	if info.StaticLargeObject {
+		if segments, err = cf.getManifest(dc, bucket, source); err != nil {
+			return nil, err
+		}
+	}
+
+	// An empty object still becomes one, empty, part.
+	count, remainder := chunkLayout(info.Bytes, partSize)
+	if count == 0 {
+		count = 1
+	}
+
+	var names []string
+	for index := int64(0); index < count; index++ {
+		name := fmt.Sprintf(pattern, index)
+		if name == source {
+			return names, fmt.Errorf("Part %d of %s would overwrite its source.", index, source)
+		}
+
+		offset, length := index*partSize, partSize
+		if index == count-1 && remainder > 0 {
+			length = remainder
+		}
+		if length > info.Bytes {
+			length = info.Bytes
+		}
+
+		if segment := alignedSegment(segments, offset, length); segment != nil {
+			container, object := segment.location()
+			etag, err := cf.serverCopy(dc, container, object, bucket, name, nil)
+			if err == nil && !sameETag(etag, segment.Hash) {
+				err = fmt.Errorf("Part %s does not match segment %s.", name, segment.Name)
+			}
+			if err != nil {
+				return names, err
+			}
+		} else if !info.StaticLargeObject && count == 1 {
+			if _, err := cf.serverCopy(dc, bucket, source, bucket, name, nil); err != nil {
+				return names, err
+			}
+		} else if err := cf.splitPart(dc, bucket, source, name, offset, length); err != nil {
+			return names, err
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+func alignedSegment(segments []sloSegment, offset, length int64) *sloSegment {
+	/*
+		The segment holding exactly the given range, if there is one.
+		Nested large objects are not copied as parts, their copy would be
+		a manifest.
+	*/
+	start := int64(0)
+	for i := range segments {
+		if start == offset && segments[i].Bytes == length && !segments[i].SubSLO {
+			return &segments[i]
+		}
+		start += segments[i].Bytes
+		if start > offset {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (cf CloudFiles) splitPart(dc, bucket, source, name string, offset, length int64) error {
+	/*
+		Stage one range of the source in a temporary file and upload it
+		as a part, checked against the MD5 of the range.
+	*/
+	tmpFile, err := ioutil.TempFile("", "")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	size, etag, err := cf.GetChunk(dc, bucket, source, tmpFile, offset, length)
+	if err != nil {
+		return err
+	}
+	if size != length {
+		return fmt.Errorf("Could not read part %s, got %d of %d bytes.", name, size, length)
+	}
+
+	tmpFile.Seek(0, io.SeekStart)
+	_, err = cf.putObject(dc, bucket, name, tmpFile, etag, nil)
+	return err
+}
@@ -0,0 +1,53 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	// Test a large object is split into standalone parts.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	data := []byte("0123456789abcdefghij")
+	if _, err := cf.UploadFile("TEST", "testing", "big", bytes.NewReader(data), int64(len(data)),
+		&UploadFileOptions{SegmentSize: 8}); err != nil {
+		t.Fatalf("Could not upload: %s", err)
+	}
+
+	for _, partSize := range []int64{8, 5, 100} {
+		names, err := cf.Split("TEST", "testing", "big", "part-%02d", partSize)
+		if err != nil {
+			t.Fatalf("Could not split in %d bytes: %s", partSize, err)
+		}
+
+		joined := []byte{}
+		for i, name := range names {
+			part, _ := fs.get("testing/" + name)
+			if _, ok := fs.manifests["testing/"+name]; ok {
+				t.Fatalf("Part %s is a manifest", name)
+			}
+			if i < len(names)-1 && int64(len(part)) != partSize {
+				t.Fatalf("Part %s has %d bytes", name, len(part))
+			}
+			joined = append(joined, part...)
+		}
+		if !bytes.Equal(joined, data) || int64(len(names)) != (int64(len(data))+partSize-1)/partSize {
+			t.Fatalf("Unexpected parts %v of %d bytes: %q", names, partSize, joined)
+		}
+	}
+
+	fs.put("testing/small", []byte("tiny"))
+	if names, err := cf.Split("TEST", "testing", "small", "small.%d", 10); err != nil || len(names) != 1 {
+		t.Fatalf("Could not split small object: %v %v", names, err)
+	}
+	if part, _ := fs.get("testing/small.0"); string(part) != "tiny" {
+		t.Fatalf("Unexpected part %q", part)
+	}
+
+	if _, err := cf.Split("TEST", "testing", "big", "fixed-name", 8); err == nil {
+		t.Fatalf("A pattern without an index should be refused.")
+	}
+}
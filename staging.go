@@ -0,0 +1,201 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Staged objects are kept under this prefix, followed by the staging's ID,
+// until they are committed.
+const StagingPrefix = ".staging/"
+
+// A Staging collects the objects of a multi-object publish, such as a
+// website release, under a temporary prefix so readers never see part of
+// the set.  Commit copies them to their final names once all are
+// uploaded, Abort deletes them.
+type Staging struct {
+	cf     CloudFiles
+	dc     string
+	bucket string
+	id     string
+}
+
+// CommitOptions tune Staging.Commit and Staging.Abort.
+type CommitOptions struct {
+	// Number of objects copied or deleted at once, defaults to 5.
+	Concurrency int
+
+	// Results receives one Result per staged object when set.
+	Results chan<- Result
+}
+
+func (cf CloudFiles) NewStaging(dc, bucket string) *Staging {
+	/*
+		Start staging objects in a container under a new ID.
+	*/
+	return cf.ResumeStaging(dc, bucket, NewTransferID())
+}
+
+func (cf CloudFiles) ResumeStaging(dc, bucket, id string) *Staging {
+	/*
+		Pick up a staging by its ID, such as one a crashed publisher left,
+		to commit or abort what it staged.
+	*/
+	return &Staging{cf: cf, dc: dc, bucket: bucket, id: id}
+}
+
+func (s *Staging) ID() string {
+	return s.id
+}
+
+func (s *Staging) Name(filename string) string {
+	/*
+		The name filename is staged under, for uploading it with something
+		other than PutFile, such as UploadFile.
+	*/
+	return StagingPrefix + s.id + "/" + filename
+}
+
+func (s *Staging) PutFile(filename string, data io.Reader) (string, error) {
+	/*
+		Stage an object to be published as filename.
+		Returns a tuple of etag, error
+	*/
+	return s.cf.PutFile(s.dc, s.bucket, s.Name(filename), data)
+}
+
+func (s *Staging) Staged() ([]string, error) {
+	/*
+		The final names of every object staged so far, from a listing, so
+		objects staged by another process count too.
+		Returns a tuple of names, error
+	*/
+	prefix := s.Name("")
+	listing, err := s.cf.ListObjects(s.dc, s.bucket, ListOptions{Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(listing.Objects))
+	for _, object := range listing.Objects {
+		names = append(names, strings.TrimPrefix(object.Name, prefix))
+	}
+	return names, nil
+}
+
+func (s *Staging) Commit(options *CommitOptions) (*Report, error) {
+	/*
+		Publish every staged object under its final name with a server-side
+		copy, then delete the staged copies.  Nothing is published until
+		everything is staged, but the copies themselves land one by one
+		over a short window, run concurrently to keep it small.  When any
+		copy fails the staged objects are kept so Commit can be run again.
+		Returns a tuple of report, error
+	*/
+	if options == nil {
+		options = &CommitOptions{}
+	}
+
+	names, err := s.Staged()
+	if err != nil {
+		return s.cf.newReport(), err
+	}
+	if len(names) == 0 {
+		return s.cf.newReport(), fmt.Errorf("Nothing is staged under %s.", s.Name(""))
+	}
+
+	report := s.each(names, "commit", options, func(name string) error {
+		_, err := s.cf.serverCopy(s.dc, s.bucket, s.Name(name), s.bucket, name, nil)
+		return err
+	})
+	if err := report.Err(); err != nil {
+		return report, err
+	}
+
+	return report, s.remove(names, options)
+}
+
+func (s *Staging) Abort(options *CommitOptions) (*Report, error) {
+	/*
+		Delete every staged object, publishing nothing.
+		Returns a tuple of report, error
+	*/
+	if options == nil {
+		options = &CommitOptions{}
+	}
+
+	names, err := s.Staged()
+	if err != nil {
+		return s.cf.newReport(), err
+	}
+
+	report := s.each(names, "abort", options, func(name string) error {
+		return s.cf.deleteObject(s.dc, s.bucket, s.Name(name))
+	})
+	return report, report.Err()
+}
+
+func (s *Staging) remove(names []string, options *CommitOptions) error {
+	/*
+		Delete the staged copies of committed objects.  They are already
+		published, so failures are only reported, as the error.
+	*/
+	cleanup := s.each(names, "unstage", &CommitOptions{Concurrency: options.Concurrency},
+		func(name string) error {
+			return s.cf.deleteObject(s.dc, s.bucket, s.Name(name))
+		})
+	if err := cleanup.Err(); err != nil {
+		return fmt.Errorf("Committed %s but could not remove its staged objects: %w", s.id, err)
+	}
+	return nil
+}
+
+func (s *Staging) each(names []string, op string, options *CommitOptions, fn func(string) error) *Report {
+	/*
+		Run fn on every name with a pool of workers.
+	*/
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	report := s.cf.newReport()
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	work := make(chan string)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				result := Result{Op: op, DC: s.dc, Bucket: s.bucket, Name: name, Status: ResultSuccess}
+				if err := fn(name); err != nil {
+					result.Status = ResultFailure
+					result.Err = err
+				}
+
+				mutex.Lock()
+				report.record(options.Results, result)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, name := range names {
+		mutex.Lock()
+		aborted := report.Aborted
+		mutex.Unlock()
+		if aborted {
+			break
+		}
+		work <- name
+	}
+	close(work)
+	wg.Wait()
+
+	return report
+}
@@ -0,0 +1,72 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStagingCommit(t *testing.T) {
+	// Test staged objects stay hidden until the commit publishes them all.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("site/index.html", []byte("old index"))
+	staging := cf.NewStaging("TEST", "site")
+	for _, name := range []string{"index.html", "css/site.css", "js/app.js"} {
+		if _, err := staging.PutFile(name, bytes.NewReader([]byte("new "+name))); err != nil {
+			t.Fatalf("Could not stage %s: %s", name, err)
+		}
+	}
+
+	if data, _ := fs.get("site/index.html"); string(data) != "old index" {
+		t.Fatalf("Staging published early: %q", data)
+	}
+	if _, ok := fs.get("site/css/site.css"); ok {
+		t.Fatalf("Staging published early.")
+	}
+
+	// A second process commits what the first staged.
+	report, err := cf.ResumeStaging("TEST", "site", staging.ID()).Commit(nil)
+	if err != nil {
+		t.Fatalf("Could not commit: %s", err)
+	}
+	if report.Succeeded != 3 {
+		t.Fatalf("Expected 3 commits, got %d", report.Succeeded)
+	}
+
+	for _, name := range []string{"index.html", "css/site.css", "js/app.js"} {
+		if data, ok := fs.get("site/" + name); !ok || string(data) != "new "+name {
+			t.Fatalf("Commit did not publish %s: %q", name, data)
+		}
+		if _, ok := fs.get("site/" + staging.Name(name)); ok {
+			t.Fatalf("Commit left the staged %s.", name)
+		}
+	}
+
+	if _, err := staging.Commit(nil); err == nil {
+		t.Fatalf("Committed an empty staging.")
+	}
+}
+
+func TestStagingAbort(t *testing.T) {
+	// Test an abort deletes the staged objects and publishes nothing.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	staging := cf.NewStaging("TEST", "site")
+	staging.PutFile("a", bytes.NewReader([]byte("a")))
+	staging.PutFile("b", bytes.NewReader([]byte("b")))
+
+	report, err := staging.Abort(nil)
+	if err != nil || report.Succeeded != 2 {
+		t.Fatalf("Could not abort: %+v %v", report, err)
+	}
+	if names, err := staging.Staged(); err != nil || len(names) != 0 {
+		t.Fatalf("Abort left %v %v", names, err)
+	}
+	if _, ok := fs.get("site/a"); ok {
+		t.Fatalf("Abort published a.")
+	}
+}
@@ -0,0 +1,82 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+// The v1 auth endpoint of a Swift all-in-one, for NewCloudFilesTempAuth.
+const SAIOAuthEndpoint = "http://127.0.0.1:8080/auth/v1.0"
+
+// Swift's v1 auth, as tempauth and swauth serve, has no service catalog.
+// It answers with the storage URL of the one cluster, which is used under
+// the region a client is created with.
+type tempAuthConfig struct {
+	region string
+}
+
+func NewCloudFilesTempAuth(endpoint, user, key, region string) *CloudFiles {
+	/*
+	   Create a new cloud files object authenticating with Swift's v1 auth
+	   at endpoint, such as SAIOAuthEndpoint, as user, for instance
+	   test:tester, with key.  The cluster is reached as region.
+	*/
+	cf := NewCloudFiles(user, key)
+	cf.tempAuth = &tempAuthConfig{region: region}
+	cf.SetIdentityEndpoint(endpoint)
+	return cf
+}
+
+func (cf CloudFiles) requestTempAuthToken() (*http.Response, error) {
+	/*
+		Ask a v1 auth endpoint for a token with X-Auth-User and X-Auth-Key.
+	*/
+	req, err := http.NewRequest("GET", cf.IdentityEndpoint(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("X-Auth-User", cf.userName)
+	req.Header.Add("X-Auth-Key", cf.apiKey)
+	client := cf.httpClient()
+	return client.Do(req)
+}
+
+func (ta tempAuthConfig) readAccess(resp *http.Response) (*accessWrapper, error) {
+	/*
+		Turn the headers of a v1 auth response into the v2.0 form the rest
+		of the client reads: a catalog of the storage URL under the
+		region, with the account as tenant.
+	*/
+	storageURL := resp.Header.Get("X-Storage-Url")
+	token := resp.Header.Get("X-Auth-Token")
+	if token == "" {
+		token = resp.Header.Get("X-Storage-Token")
+	}
+	if storageURL == "" || token == "" {
+		return nil, fmt.Errorf("Could not authenticate: response has no token or storage URL.")
+	}
+
+	var access accessWrapper
+	access.Access.Token.Id = token
+	access.Access.Token.Tenant.Id = path.Base(storageURL)
+	if seconds, err := strconv.ParseInt(resp.Header.Get("X-Auth-Token-Expires"), 10, 64); err == nil {
+		expires := time.Now().Add(time.Duration(seconds) * time.Second)
+		access.Access.Token.Expires = expires.UTC().Format(time.RFC3339)
+	}
+
+	access.Access.Catalog = []serviceCatalog{{
+		Name: "cloudFiles",
+		Type: "object-store",
+		Endpoints: []serviceEndpoints{{
+			Region:      ta.region,
+			TenantId:    access.Access.Token.Tenant.Id,
+			PublicURL:   storageURL,
+			InternalURL: storageURL,
+		}},
+	}}
+	return &access, nil
+}
@@ -0,0 +1,59 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestTempAuth(t *testing.T) {
+	// Test v1 auth headers become the region's endpoint and an expiring
+	// token, renewed when Swift rejects it.
+	fs := newFakeSwift()
+	defer fs.Close()
+
+	var mutex sync.Mutex
+	issued := 0
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/auth/v1.0" || r.Header.Get("X-Auth-User") != "test:tester" || r.Header.Get("X-Auth-Key") != "testing" {
+			w.WriteHeader(401)
+			return
+		}
+
+		mutex.Lock()
+		issued++
+		token := "token1"
+		if issued > 1 {
+			token = "token2"
+		}
+		mutex.Unlock()
+
+		w.Header().Set("X-Storage-Url", fs.server.URL+"/v1/AUTH_test")
+		w.Header().Set("X-Auth-Token", token)
+		w.Header().Set("X-Auth-Token-Expires", "86400")
+		w.WriteHeader(200)
+	}))
+	defer auth.Close()
+
+	cf := NewCloudFilesTempAuth(auth.URL+"/auth/v1.0", "test:tester", "testing", "SAIO")
+	if err := cf.Authorize(); err != nil {
+		t.Fatalf("Could not authorize: %s", err)
+	}
+	if cf.dcs["SAIO"] != fs.server.URL+"/v1/AUTH_test" || cf.tenantId != "AUTH_test" || cf.TokenExpiresAt().IsZero() {
+		t.Fatalf("Unexpected auth %v %s %s", cf.dcs, cf.tenantId, cf.TokenExpiresAt())
+	}
+
+	fs.token = "token2"
+	if _, err := cf.PutFile("SAIO", "testing", "file", bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatalf("Could not put after the token expired: %s", err)
+	}
+	if issued != 2 {
+		t.Fatalf("Expected a new token, %d were issued.", issued)
+	}
+
+	if err := NewCloudFilesTempAuth(auth.URL+"/auth/v1.0", "test:tester", "wrong", "SAIO").Authorize(); err == nil {
+		t.Fatalf("Authorized with the wrong key.")
+	}
+}
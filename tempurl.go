@@ -0,0 +1,57 @@
+package gocloudfiles
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+func (cf CloudFiles) TempURL(dc, bucket, filename, method, key string, ttl time.Duration) (string, error) {
+	/*
+		Sign a temporary URL that lets anyone holding it send method to
+		the object for ttl, with the account's X-Account-Meta-Temp-URL-Key.
+		The expiry is computed in the region's time, from the Date headers
+		of its responses, and padded by the measurement's uncertainty, so
+		the URL does not expire early when the local clock drifts.  The
+		container is looked at once when the region's clock was never
+		measured.
+		Returns a tuple of URL, error
+	*/
+	object, err := cf.objectURL(dc, bucket, filename)
+	if err != nil {
+		return "", err
+	}
+
+	if ttl <= 0 {
+		return "", fmt.Errorf("Temporary URL lifetime %s must be positive.", ttl)
+	}
+
+	skew, ok := cf.clocks.skew(dc)
+	if !ok {
+		if _, err := cf.headContainer(dc, bucket); err != nil {
+			return "", fmt.Errorf("Could not measure the clock of region %s: %w", dc, err)
+		}
+		skew, _ = cf.clocks.skew(dc)
+	}
+
+	// Rounded up, the server compares whole seconds.
+	expiry := time.Now().Add(skew.Offset + skew.Uncertainty + ttl)
+	expires := expiry.Unix()
+	if expiry.Nanosecond() > 0 {
+		expires++
+	}
+
+	parsed, err := url.Parse(object)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha1.New, []byte(key))
+	fmt.Fprintf(mac, "%s\n%d\n%s", method, expires, parsed.Path)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	return fmt.Sprintf("%s?temp_url_sig=%s&temp_url_expires=%d", object, signature, expires), nil
+}
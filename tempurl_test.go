@@ -0,0 +1,64 @@
+package gocloudfiles
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// A transport whose server clock runs ahead of the local one.
+type skewedTransport struct {
+	ahead time.Duration
+}
+
+func (st skewedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		resp.Header.Set("Date", time.Now().Add(st.ahead).UTC().Format(http.TimeFormat))
+	}
+	return resp, err
+}
+
+func TestTempURLUsesServerTime(t *testing.T) {
+	// Test temp URLs expire by the server's clock, not the local one.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.SetTransport(skewedTransport{ahead: time.Hour})
+
+	if _, ok := cf.ClockSkews()["TEST"]; ok {
+		t.Fatalf("No skew should be known before a request.")
+	}
+
+	signed, err := cf.TempURL("TEST", "testing", "report.pdf", "GET", "secret", 10*time.Minute)
+	if err != nil {
+		t.Fatalf("Could not sign: %s", err)
+	}
+
+	skew := cf.ClockSkews()["TEST"]
+	if skew.Samples != 1 || skew.Offset < 59*time.Minute || skew.Offset > 61*time.Minute {
+		t.Fatalf("Unexpected skew %+v", skew)
+	}
+	if drift := cf.ServerTime("TEST").Sub(time.Now()); drift < 59*time.Minute {
+		t.Fatalf("Server time not skewed: %s", drift)
+	}
+
+	parsed, _ := url.Parse(signed)
+	expires, _ := strconv.ParseInt(parsed.Query().Get("temp_url_expires"), 10, 64)
+	lifetime := time.Unix(expires, 0).Sub(time.Now())
+	if lifetime < 69*time.Minute || lifetime > 72*time.Minute {
+		t.Fatalf("Expiry not in server time: %s", lifetime)
+	}
+
+	mac := hmac.New(sha1.New, []byte("secret"))
+	fmt.Fprintf(mac, "GET\n%d\n/testing/report.pdf", expires)
+	if parsed.Query().Get("temp_url_sig") != hex.EncodeToString(mac.Sum(nil)) {
+		t.Fatalf("Unexpected signature in %s", signed)
+	}
+}
@@ -0,0 +1,178 @@
+package gocloudfiles
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// A BandwidthLimit caps transfer rates in bytes per second, independently in
+// each direction.  Zero leaves a direction unlimited.
+type BandwidthLimit struct {
+	Download int64
+	Upload   int64
+}
+
+// BandwidthLimits apply Default to every region without an entry in
+// Regions.  A region's entry replaces the default for both directions and
+// is budgeted separately from all other regions.
+type BandwidthLimits struct {
+	Default BandwidthLimit
+	Regions map[string]BandwidthLimit
+}
+
+type rateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	// Allow up to a second worth of data to go out in one burst.
+	return &rateLimiter{
+		rate:   float64(bytesPerSecond),
+		burst:  float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+	}
+}
+
+func newPacer(bytesPerSecond int64) *rateLimiter {
+	/*
+		A rate limiter for a single stream.  Its bucket only holds a
+		twentieth of a second, so the stream goes out evenly rather than
+		in one-second bursts.
+	*/
+	rl := newRateLimiter(bytesPerSecond)
+	if rl == nil {
+		return nil
+	}
+
+	rl.burst = rl.rate / 20
+	if rl.burst < 1 {
+		rl.burst = 1
+	}
+	rl.tokens = rl.burst
+	return rl
+}
+
+func (rl *rateLimiter) chunk() int {
+	/*
+		The largest read worth doing in a single step.
+	*/
+	size := int(rl.burst)
+	if size > 64*1024 {
+		size = 64 * 1024
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+func (rl *rateLimiter) wait(n int) {
+	/*
+		Take n bytes worth of tokens, sleeping for however long the deficit
+		takes to refill.  Tokens may go negative so concurrent callers queue
+		up fairly behind each other.
+	*/
+	rl.mutex.Lock()
+
+	now := time.Now()
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+	rl.last = now
+	rl.tokens -= float64(n)
+
+	var delay time.Duration
+	if rl.tokens < 0 {
+		delay = time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+	}
+
+	rl.mutex.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+type throttledBody struct {
+	body    io.ReadCloser
+	limiter *rateLimiter
+}
+
+func (tb *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > tb.limiter.chunk() {
+		p = p[:tb.limiter.chunk()]
+	}
+
+	n, err := tb.body.Read(p)
+	if n > 0 {
+		tb.limiter.wait(n)
+	}
+	return n, err
+}
+
+func (tb *throttledBody) Close() error {
+	return tb.body.Close()
+}
+
+type bandwidthLimiter struct {
+	download *rateLimiter
+	upload   *rateLimiter
+}
+
+type throttle struct {
+	defaults bandwidthLimiter
+	regions  map[string]bandwidthLimiter
+}
+
+func newThrottle(limits BandwidthLimits) *throttle {
+	t := &throttle{
+		defaults: bandwidthLimiter{
+			download: newRateLimiter(limits.Default.Download),
+			upload:   newRateLimiter(limits.Default.Upload),
+		},
+		regions: make(map[string]bandwidthLimiter),
+	}
+
+	for dc, limit := range limits.Regions {
+		t.regions[dc] = bandwidthLimiter{
+			download: newRateLimiter(limit.Download),
+			upload:   newRateLimiter(limit.Upload),
+		}
+	}
+
+	return t
+}
+
+func (t *throttle) limiter(dc string) bandwidthLimiter {
+	if limiter, ok := t.regions[dc]; ok {
+		return limiter
+	}
+	return t.defaults
+}
+
+func wrapBody(body io.ReadCloser, limiter *rateLimiter) io.ReadCloser {
+	if body == nil || limiter == nil {
+		return body
+	}
+	return &throttledBody{body: body, limiter: limiter}
+}
+
+func (cf *CloudFiles) SetBandwidthLimits(limits BandwidthLimits) {
+	/*
+		Cap the bandwidth used for requests to each region.  Downloads and
+		uploads are limited independently and the budget is shared by all
+		concurrent transfers.
+	*/
+	cf.throttle = newThrottle(limits)
+}
@@ -0,0 +1,41 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestDownloadThrottleIndependentOfUpload(t *testing.T) {
+	// Test downloads are paced while uploads to the same region are not.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.SetBandwidthLimits(BandwidthLimits{
+		Default: BandwidthLimit{Upload: 1},
+		Regions: map[string]BandwidthLimit{"TEST": {Download: 20000}},
+	})
+
+	data := make([]byte, 40000)
+
+	start := time.Now()
+	_, err := cf.PutFile("TEST", "testing", "file.bin", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Could not put file: %s", err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Fatalf("Upload to an overridden region should not use the default limit.")
+	}
+
+	start = time.Now()
+	_, _, err = cf.GetChunk("TEST", "testing", "file.bin", ioutil.Discard, 0, 0)
+	if err != nil {
+		t.Fatalf("Could not get file: %s", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("Download should have been throttled, took %s", elapsed)
+	}
+}
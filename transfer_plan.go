@@ -0,0 +1,278 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A TransferStrategy is a way of getting objects from one container into
+// another.
+type TransferStrategy int
+
+const (
+	// StreamCopy downloads every object through the client and uploads it
+	// to the destination, as CopyFileWithOptions does between regions.
+	StreamCopy TransferStrategy = iota
+	// ServerCopy has the cluster copy each object with a COPY, which only
+	// works within a region.
+	ServerCopy
+	// ContainerSync has the clusters' sync daemons copy the container in
+	// the background once X-Container-Sync-To is set on it.
+	ContainerSync
+)
+
+func (s TransferStrategy) String() string {
+	switch s {
+	case StreamCopy:
+		return "stream copy"
+	case ServerCopy:
+		return "server-side copy"
+	case ContainerSync:
+		return "container sync"
+	}
+	return fmt.Sprintf("strategy %d", int(s))
+}
+
+// Pricing prices the traffic of a transfer for PlanTransfer.
+type Pricing interface {
+	// EgressCost is the price of bytes leaving a region, internal when
+	// they stay on the region's internal network.
+	EgressCost(region string, bytes int64, internal bool) float64
+	// RequestCost is the price of requests made to a region.
+	RequestCost(region string, requests int64) float64
+}
+
+// FlatPricing charges the same rates in every region.
+type FlatPricing struct {
+	EgressPerGB         float64
+	InternalEgressPerGB float64
+	PerThousandRequests float64
+}
+
+func (p FlatPricing) EgressCost(region string, bytes int64, internal bool) float64 {
+	rate := p.EgressPerGB
+	if internal {
+		rate = p.InternalEgressPerGB
+	}
+	return float64(bytes) / (1024 * 1024 * 1024) * rate
+}
+
+func (p FlatPricing) RequestCost(region string, requests int64) float64 {
+	return float64(requests) / 1000 * p.PerThousandRequests
+}
+
+// PlanOptions tune PlanTransfer.
+type PlanOptions struct {
+	// ChunkSize is the CopyOptions.ChunkSize a stream copy would use.
+	ChunkSize int64
+
+	// ClientRegion is where the client copying the objects runs, the
+	// local DC when empty.  Downloads to a client in the source region
+	// stay on its internal network.
+	ClientRegion string
+
+	// ContainerSync is set when the clusters are configured to sync
+	// containers between the two regions.
+	ContainerSync bool
+
+	// Pricing prices the traffic, when nil the strategy moving the fewest
+	// bytes through the client and then making the fewest requests wins.
+	Pricing Pricing
+}
+
+// A StrategyCost is what one strategy would take to transfer the objects.
+type StrategyCost struct {
+	Strategy TransferStrategy
+
+	// Feasible is false when the strategy cannot do the transfer, Notes
+	// say why.
+	Feasible bool
+
+	Requests int64
+
+	// EgressBytes leave the source region, over its internal network when
+	// Internal is set.
+	EgressBytes int64
+	Internal    bool
+
+	// ClientBytes pass through the client, counting downloads and uploads.
+	ClientBytes int64
+
+	// Cost under the plan's Pricing, zero without one.
+	Cost float64
+
+	// Notes are the reasons and caveats behind the figures.
+	Notes []string
+}
+
+// A TransferPlan is the strategy PlanTransfer chose and why.
+type TransferPlan struct {
+	Strategy TransferStrategy
+	Objects  int
+	Bytes    int64
+
+	// Costs holds every strategy considered, feasible or not, in the
+	// order of TransferStrategy.
+	Costs []StrategyCost
+
+	// Rationale explains the choice against the other strategies.
+	Rationale string
+}
+
+func (cf CloudFiles) PlanTransfer(sourceDC, destDC string, objects []ObjectInfo,
+	options *PlanOptions) (*TransferPlan, error) {
+	/*
+		Weigh streaming the objects through the client, a server-side COPY
+		and container sync for a transfer from sourceDC to destDC, and
+		choose the cheapest that can do it.  Objects usually come from
+		ListObjects of the source.  Nothing is sent to the server.
+		Returns a tuple of plan, error
+	*/
+	if options == nil {
+		options = &PlanOptions{}
+	}
+
+	for _, dc := range []string{sourceDC, destDC} {
+		if _, err := cf.endpoint(dc); err != nil {
+			return nil, err
+		}
+	}
+
+	clientRegion := options.ClientRegion
+	if clientRegion == "" {
+		clientRegion = cf.localDC
+	}
+
+	chunkSize := (&CopyOptions{ChunkSize: options.ChunkSize}).chunkSize()
+
+	plan := &TransferPlan{Objects: len(objects)}
+	var largeObjects int
+	var streamRequests int64
+	for _, object := range objects {
+		plan.Bytes += object.Bytes
+		if object.StaticLargeObject {
+			largeObjects++
+		}
+		streamRequests += copyRequests(object.Bytes, chunkSize, object.StaticLargeObject, false)
+	}
+
+	stream := StrategyCost{
+		Strategy:    StreamCopy,
+		Feasible:    true,
+		Requests:    streamRequests,
+		EgressBytes: plan.Bytes,
+		Internal:    clientRegion == sourceDC,
+		ClientBytes: 2 * plan.Bytes,
+	}
+	if stream.Internal {
+		stream.Notes = append(stream.Notes, fmt.Sprintf(
+			"The client runs in %s, so downloads stay on its internal network.", sourceDC))
+	}
+	if clientRegion != destDC {
+		stream.Notes = append(stream.Notes, fmt.Sprintf(
+			"Uploads leave the client's region for %s.", destDC))
+	}
+
+	server := StrategyCost{Strategy: ServerCopy, Feasible: sourceDC == destDC}
+	if server.Feasible {
+		// A HEAD of the source and the destination container, then the
+		// COPY.
+		server.Requests = 3 * int64(len(objects))
+		server.Notes = append(server.Notes, fmt.Sprintf(
+			"Both containers are in %s, so no data leaves the cluster.", sourceDC))
+		if largeObjects > 0 {
+			server.Notes = append(server.Notes, fmt.Sprintf(
+				"%d large objects are copied as manifests sharing the source's segments, "+
+					"which must be kept.", largeObjects))
+		}
+	} else {
+		server.Notes = append(server.Notes, fmt.Sprintf(
+			"The server cannot copy from %s to %s.", sourceDC, destDC))
+	}
+
+	synced := StrategyCost{Strategy: ContainerSync, Feasible: options.ContainerSync && sourceDC != destDC}
+	switch {
+	case synced.Feasible:
+		// Setting X-Container-Sync-To and the key on both containers.
+		synced.Requests = 2
+		synced.EgressBytes = plan.Bytes
+		synced.Notes = append(synced.Notes,
+			"Objects arrive as the sync daemons get to them and the containers stay in sync afterwards.")
+		if largeObjects > 0 {
+			synced.Notes = append(synced.Notes, fmt.Sprintf(
+				"%d large objects need their segment containers synced too.", largeObjects))
+		}
+	case sourceDC == destDC:
+		synced.Notes = append(synced.Notes, "Both containers are in the same region.")
+	default:
+		synced.Notes = append(synced.Notes, fmt.Sprintf(
+			"The clusters of %s and %s are not configured to sync containers.", sourceDC, destDC))
+	}
+
+	if pricing := options.Pricing; pricing != nil {
+		stream.Cost = pricing.EgressCost(sourceDC, stream.EgressBytes, stream.Internal) +
+			pricing.RequestCost(destDC, stream.Requests)
+		if clientRegion != destDC {
+			stream.Cost += pricing.EgressCost(clientRegion, plan.Bytes, false)
+		}
+		if server.Feasible {
+			server.Cost = pricing.RequestCost(sourceDC, server.Requests)
+		}
+		if synced.Feasible {
+			synced.Cost = pricing.EgressCost(sourceDC, synced.EgressBytes, false) +
+				pricing.RequestCost(sourceDC, synced.Requests)
+		}
+	}
+
+	plan.Costs = []StrategyCost{stream, server, synced}
+	chosen := plan.Costs[0]
+	for _, cost := range plan.Costs[1:] {
+		if cost.Feasible && cheaper(cost, chosen) {
+			chosen = cost
+		}
+	}
+	plan.Strategy = chosen.Strategy
+	plan.Rationale = rationale(chosen, plan.Costs, options.Pricing != nil)
+	return plan, nil
+}
+
+func cheaper(a, b StrategyCost) bool {
+	/*
+		Whether a beats b, on cost, then bytes through the client, then
+		requests.
+	*/
+	switch {
+	case a.Cost != b.Cost:
+		return a.Cost < b.Cost
+	case a.ClientBytes != b.ClientBytes:
+		return a.ClientBytes < b.ClientBytes
+	default:
+		return a.Requests < b.Requests
+	}
+}
+
+func rationale(chosen StrategyCost, costs []StrategyCost, priced bool) string {
+	/*
+		Explain the chosen strategy and what the others would have taken.
+	*/
+	describe := func(cost StrategyCost) string {
+		text := fmt.Sprintf("%d requests, %d bytes through the client", cost.Requests, cost.ClientBytes)
+		if priced {
+			text += fmt.Sprintf(", cost %.2f", cost.Cost)
+		}
+		return text
+	}
+
+	lines := []string{fmt.Sprintf("Chose %s: %s.", chosen.Strategy, describe(chosen))}
+	lines = append(lines, chosen.Notes...)
+	for _, cost := range costs {
+		switch {
+		case cost.Strategy == chosen.Strategy:
+		case !cost.Feasible:
+			lines = append(lines, fmt.Sprintf("Not %s: %s", cost.Strategy, strings.Join(cost.Notes, " ")))
+		default:
+			lines = append(lines, fmt.Sprintf("Rather than %s: %s.", cost.Strategy, describe(cost)))
+		}
+	}
+	return strings.Join(lines, " ")
+}
@@ -0,0 +1,84 @@
+package gocloudfiles
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func planClient() *CloudFiles {
+	cf := NewCloudFilesImpersonation("token")
+	for _, region := range []string{"DFW", "ORD"} {
+		cf.dcs[region] = "https://storage101." + strings.ToLower(region) + "1.clouddrive.com/v1/MossoCloudFS_abc"
+		cf.dcsInternal[region] = "https://snet-storage101." + strings.ToLower(region) + "1.clouddrive.com/v1/MossoCloudFS_abc"
+	}
+	return cf
+}
+
+func TestPlanTransferWithinRegion(t *testing.T) {
+	// Test a transfer within a region is copied by the server.
+	cf := planClient()
+	objects := []ObjectInfo{{Name: "a", Bytes: 100}, {Name: "big", Bytes: 3 << 30, StaticLargeObject: true}}
+
+	plan, err := cf.PlanTransfer("DFW", "DFW", objects, nil)
+	if err != nil {
+		t.Fatalf("Could not plan: %s", err)
+	}
+	if plan.Strategy != ServerCopy || plan.Objects != 2 || plan.Bytes != 100+3<<30 {
+		t.Fatalf("Unexpected plan %+v", plan)
+	}
+	if server := plan.Costs[ServerCopy]; server.Requests != 6 || server.ClientBytes != 0 {
+		t.Fatalf("Unexpected server copy cost %+v", server)
+	}
+	// 5 requests for the small object, 2+1+2*12+1 for twelve 256MB chunks.
+	if stream := plan.Costs[StreamCopy]; stream.Requests != 5+28 || stream.ClientBytes != 2*plan.Bytes {
+		t.Fatalf("Unexpected stream copy cost %+v", stream)
+	}
+	if plan.Costs[ContainerSync].Feasible {
+		t.Fatalf("Container sync offered within a region.")
+	}
+	if !strings.Contains(plan.Rationale, "Chose server-side copy") ||
+		!strings.Contains(plan.Rationale, "sharing the source's segments") {
+		t.Fatalf("Unexpected rationale %q", plan.Rationale)
+	}
+}
+
+func TestPlanTransferBetweenRegions(t *testing.T) {
+	// Test a transfer between regions streams unless the containers can be
+	// synced, and is priced.
+	cf := planClient()
+	cf.SetLocalDC("DFW")
+	objects := []ObjectInfo{{Name: "a", Bytes: 1 << 30}}
+	pricing := FlatPricing{EgressPerGB: 0.12, PerThousandRequests: 1}
+
+	plan, err := cf.PlanTransfer("DFW", "ORD", objects, &PlanOptions{Pricing: pricing})
+	if err != nil {
+		t.Fatalf("Could not plan: %s", err)
+	}
+	stream := plan.Costs[StreamCopy]
+	if plan.Strategy != StreamCopy || !stream.Internal || plan.Costs[ServerCopy].Feasible {
+		t.Fatalf("Unexpected plan %+v", plan)
+	}
+	// Downloads in DFW are free, the upload to ORD is not.
+	if math.Abs(stream.Cost-(0.12+float64(stream.Requests)/1000)) > 1e-9 {
+		t.Fatalf("Unexpected stream cost %f", stream.Cost)
+	}
+	if !strings.Contains(plan.Rationale, "Not container sync: The clusters of DFW and ORD") {
+		t.Fatalf("Unexpected rationale %q", plan.Rationale)
+	}
+
+	plan, err = cf.PlanTransfer("DFW", "ORD", objects, &PlanOptions{Pricing: pricing, ContainerSync: true})
+	if err != nil {
+		t.Fatalf("Could not plan: %s", err)
+	}
+	if plan.Strategy != ContainerSync || plan.Costs[ContainerSync].EgressBytes != 1<<30 {
+		t.Fatalf("Unexpected plan %+v", plan)
+	}
+	if !strings.Contains(plan.Rationale, "Rather than stream copy") {
+		t.Fatalf("Unexpected rationale %q", plan.Rationale)
+	}
+
+	if _, err := cf.PlanTransfer("DFW", "SYD", objects, nil); err == nil {
+		t.Fatalf("Planned a transfer to a region not in the catalog.")
+	}
+}
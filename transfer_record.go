@@ -0,0 +1,154 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The suffix of the companion object holding an object's SignedTransfer.
+const TransferRecordSuffix = ".transfer"
+
+// ErrBadSignature is returned when a transfer record does not match its
+// signature.
+var ErrBadSignature = errors.New("Transfer record signature does not match.")
+
+// A Signer signs transfer records so later tampering can be detected.
+type Signer interface {
+	Algorithm() string
+	Sign(payload []byte) (string, error)
+	Verify(payload []byte, signature string) error
+}
+
+// HMACSigner signs with HMAC-SHA256 under a shared key.
+type HMACSigner struct {
+	Key []byte
+}
+
+func (hs HMACSigner) Algorithm() string {
+	return "hmac-sha256"
+}
+
+func (hs HMACSigner) Sign(payload []byte) (string, error) {
+	mac := hmac.New(sha256.New, hs.Key)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (hs HMACSigner) Verify(payload []byte, signature string) error {
+	expected, _ := hs.Sign(payload)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// A TransferRecord describes what a copy transferred.
+type TransferRecord struct {
+	Source      string            `json:"source"`
+	Destination string            `json:"destination"`
+	TransferID  string            `json:"transfer_id,omitempty"`
+	Size        int64             `json:"size_bytes"`
+	SHA256      string            `json:"sha256,omitempty"`
+	Segments    []SegmentChecksum `json:"segments"`
+	Subject     string            `json:"subject"`
+	Started     time.Time         `json:"started"`
+	Finished    time.Time         `json:"finished"`
+}
+
+// A SignedTransfer holds the exact bytes of a TransferRecord that were
+// signed next to their signature.
+type SignedTransfer struct {
+	Record    json.RawMessage `json:"record"`
+	Algorithm string          `json:"algorithm"`
+	Signature string          `json:"signature"`
+}
+
+func (cf CloudFiles) subject() string {
+	/*
+		Who a transfer was made as, for the record.
+	*/
+	if cf.userName != "" {
+		return cf.userName
+	}
+	return cf.tenantId
+}
+
+func (cf CloudFiles) putTransferRecord(dc, bucket, filename string, signer Signer,
+	options *WriteOptions, record *TransferRecord) error {
+	/*
+		Sign a transfer record and write it as the companion object of the
+		destination.
+	*/
+	payLoad, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	signature, err := signer.Sign(payLoad)
+	if err != nil {
+		return err
+	}
+
+	signed, err := json.Marshal(&SignedTransfer{
+		Record:    payLoad,
+		Algorithm: signer.Algorithm(),
+		Signature: signature,
+	})
+	if err != nil {
+		return err
+	}
+
+	if options != nil {
+		copied := *options
+		copied.ContentType = "application/json"
+		options = &copied
+	}
+
+	_, err = cf.putFile(dc, bucket, filename+TransferRecordSuffix, bytes.NewReader(signed), options)
+	if err != nil {
+		return fmt.Errorf("Could not write transfer record for %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+func (cf CloudFiles) GetTransferRecord(dc, bucket, filename string, signer Signer) (*TransferRecord, error) {
+	/*
+		Fetch the signed record of the copy that wrote an object and check
+		its signature.  The error matches ErrBadSignature when the record
+		was altered.
+	*/
+	var buffer bytes.Buffer
+
+	_, _, err := cf.GetChunk(dc, bucket, filename+TransferRecordSuffix, &buffer, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := &SignedTransfer{}
+	if err = json.Unmarshal(buffer.Bytes(), signed); err != nil {
+		return nil, err
+	}
+
+	if signed.Algorithm != signer.Algorithm() {
+		return nil, fmt.Errorf("Transfer record of %s/%s is signed with %s, not %s.",
+			bucket, filename, signed.Algorithm, signer.Algorithm())
+	}
+
+	if err = signer.Verify(signed.Record, signed.Signature); err != nil {
+		return nil, err
+	}
+
+	record := &TransferRecord{}
+	if err = json.Unmarshal(signed.Record, record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
@@ -0,0 +1,44 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSignedTransferRecord(t *testing.T) {
+	// Test a copy's signed record verifies and tampering is detected.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("src/file.bin", []byte("audited"))
+	signer := HMACSigner{Key: []byte("secret")}
+
+	err := cf.CopyFileWithOptions("TEST", "src", "file.bin", "TEST", "dst", "file.bin",
+		&CopyOptions{Signer: signer, WriteChecksums: true})
+	if err != nil {
+		t.Fatalf("Could not copy: %s", err)
+	}
+
+	record, err := cf.GetTransferRecord("TEST", "dst", "file.bin", signer)
+	if err != nil {
+		t.Fatalf("Could not verify transfer record: %s", err)
+	}
+
+	if record.Source != "TEST/src/file.bin" || record.Size != 7 || record.SHA256 == "" ||
+		len(record.Segments) != 1 || record.Finished.Before(record.Started) {
+		t.Fatalf("Unexpected record: %+v", record)
+	}
+
+	if _, err = cf.GetTransferRecord("TEST", "dst", "file.bin", HMACSigner{Key: []byte("other")}); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("A record checked with the wrong key should not verify: %v", err)
+	}
+
+	data, _ := fs.get("dst/file.bin" + TransferRecordSuffix)
+	fs.put("dst/file.bin"+TransferRecordSuffix, bytes.Replace(data, []byte(`"size_bytes":7`), []byte(`"size_bytes":8`), 1))
+
+	if _, err = cf.GetTransferRecord("TEST", "dst", "file.bin", signer); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("A tampered record should not verify: %v", err)
+	}
+}
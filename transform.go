@@ -0,0 +1,304 @@
+package gocloudfiles
+
+import (
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The transforms WriteOptions apply to object data on the client.  They are
+// recorded in the object's transformHeader, comma separated in the order
+// they were applied, and undone by DownloadLargeObject in reverse.
+const (
+	TransformGzip   = "gzip"
+	TransformAESGCM = "aes-gcm"
+)
+
+const transformHeader = "X-Object-Meta-Transform"
+
+// ErrDecryptionKey is matched by errors.Is when encrypted data is read
+// without a key, or with one it was not encrypted with.
+var ErrDecryptionKey = errors.New("Wrong or missing decryption key.")
+
+// Encrypted data is sealed in frames of this much plaintext, so it is
+// streamed rather than held in memory.  Every frame but the last is full.
+const encryptionFrame = 64 * 1024
+
+// The random nonce prefix of a stream; each frame's nonce appends its
+// index.
+const noncePrefixSize = 8
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func frameNonce(prefix []byte, index uint32) []byte {
+	nonce := make([]byte, noncePrefixSize+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], index)
+	return nonce
+}
+
+// The additional data of a frame tells the last one apart, so a stream cut
+// short at a frame boundary does not decrypt.
+func frameData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// Encrypts what is written to it with AES-GCM into out: a random nonce
+// prefix, then the sealed frames.  Close seals the last frame.
+type encryptWriter struct {
+	out    io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	index  uint32
+	frame  []byte
+}
+
+func newEncryptWriter(out io.Writer, key []byte) (*encryptWriter, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := out.Write(prefix); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{out: out, aead: aead, prefix: prefix, frame: make([]byte, 0, encryptionFrame)}, nil
+}
+
+func (ew *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := encryptionFrame - len(ew.frame)
+		if n > len(p) {
+			n = len(p)
+		}
+		ew.frame = append(ew.frame, p[:n]...)
+		p = p[n:]
+		written += n
+
+		if len(ew.frame) == encryptionFrame {
+			if err := ew.seal(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (ew *encryptWriter) seal(last bool) error {
+	sealed := ew.aead.Seal(nil, frameNonce(ew.prefix, ew.index), ew.frame, frameData(last))
+	ew.index++
+	ew.frame = ew.frame[:0]
+	_, err := ew.out.Write(sealed)
+	return err
+}
+
+func (ew *encryptWriter) Close() error {
+	return ew.seal(true)
+}
+
+// Decrypts the frames an encryptWriter wrote.
+type decryptReader struct {
+	in     io.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	index  uint32
+	frame  []byte
+	plain  []byte
+	last   bool
+}
+
+func newDecryptReader(in io.Reader, key []byte) (*decryptReader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := io.ReadFull(in, prefix); err != nil {
+		return nil, fmt.Errorf("Encrypted data is truncated: %w", err)
+	}
+
+	return &decryptReader{
+		in:     in,
+		aead:   aead,
+		prefix: prefix,
+		frame:  make([]byte, encryptionFrame+aead.Overhead()),
+	}, nil
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for len(dr.plain) == 0 {
+		if dr.last {
+			return 0, io.EOF
+		}
+		if err := dr.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, dr.plain)
+	dr.plain = dr.plain[n:]
+	return n, nil
+}
+
+func (dr *decryptReader) open() error {
+	/*
+		Read and open the next frame.  Only the last frame is short.
+	*/
+	n, err := io.ReadFull(dr.in, dr.frame)
+	last := false
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+
+	plain, err := dr.aead.Open(dr.frame[:0], frameNonce(dr.prefix, dr.index), dr.frame[:n], frameData(last))
+	if err != nil {
+		return fmt.Errorf("Could not decrypt frame %d, the data is damaged or truncated: %w", dr.index, ErrDecryptionKey)
+	}
+
+	dr.index++
+	dr.plain = plain
+	dr.last = last
+	return nil
+}
+
+func (options *WriteOptions) transforms() string {
+	/*
+		The transforms the options apply, in the order they are applied.
+	*/
+	if options == nil {
+		return ""
+	}
+	if options.encoded != "" {
+		return options.encoded
+	}
+
+	var transforms []string
+	if options.Compress {
+		transforms = append(transforms, TransformGzip)
+	}
+	if options.EncryptionKey != nil {
+		transforms = append(transforms, TransformAESGCM)
+	}
+	return strings.Join(transforms, ",")
+}
+
+func (options *WriteOptions) encodeTo(out io.Writer, data io.Reader) error {
+	/*
+		Compress and encrypt data into out as the options ask.
+	*/
+	sink := out
+	var closers []io.Closer
+
+	if options.EncryptionKey != nil {
+		encrypter, err := newEncryptWriter(sink, options.EncryptionKey)
+		if err != nil {
+			return err
+		}
+		sink = encrypter
+		closers = append(closers, encrypter)
+	}
+	if options.Compress {
+		compressor := gzip.NewWriter(sink)
+		sink = compressor
+		closers = append(closers, compressor)
+	}
+
+	_, err := io.Copy(sink, data)
+
+	// The outermost writer flushes into the ones below it.
+	for i := len(closers) - 1; i >= 0; i-- {
+		if closeErr := closers[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (options *WriteOptions) encoder(data io.Reader) *io.PipeReader {
+	/*
+		A stream of data as encodeTo writes it.  Close it to stop the
+		encoding early.
+	*/
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(options.encodeTo(writer, data))
+	}()
+	return reader
+}
+
+func (options *WriteOptions) asEncoded() *WriteOptions {
+	/*
+		A copy of the options for data already transformed, which is
+		written as it is with the transforms recorded.
+	*/
+	copied := *options
+	copied.encoded = options.transforms()
+	return &copied
+}
+
+func checkTransforms(transforms string, key []byte) error {
+	/*
+		Verify the transforms of an object can be undone before it is
+		downloaded.
+	*/
+	for _, transform := range strings.Split(transforms, ",") {
+		switch strings.TrimSpace(transform) {
+		case TransformGzip:
+		case TransformAESGCM:
+			if key == nil {
+				return fmt.Errorf("The object is encrypted: %w", ErrDecryptionKey)
+			}
+		default:
+			return fmt.Errorf("Unknown transform %q.", transform)
+		}
+	}
+	return nil
+}
+
+func decodeTransforms(data io.Reader, transforms string, key []byte) (io.Reader, error) {
+	/*
+		Undo the transforms of stored data, last applied first.
+	*/
+	names := strings.Split(transforms, ",")
+	for i := len(names) - 1; i >= 0; i-- {
+		var err error
+		switch strings.TrimSpace(names[i]) {
+		case TransformGzip:
+			data, err = gzip.NewReader(data)
+		case TransformAESGCM:
+			data, err = newDecryptReader(data, key)
+		default:
+			err = fmt.Errorf("Unknown transform %q.", names[i])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
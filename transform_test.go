@@ -0,0 +1,114 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestWriteTransforms(t *testing.T) {
+	// Test compressed and encrypted objects are stored transformed and
+	// read back as written.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	key := bytes.Repeat([]byte("k"), 32)
+	data := []byte(strings.Repeat("compressible text ", 10000))
+
+	for _, options := range []*WriteOptions{
+		{Compress: true},
+		{EncryptionKey: key},
+		{Compress: true, EncryptionKey: key, VerifyMD5: true},
+	} {
+		if _, err := cf.PutFileWithOptions("TEST", "testing", "file", bytes.NewReader(data), options); err != nil {
+			t.Fatalf("Could not put file: %s", err)
+		}
+		stored, _ := fs.get("testing/file")
+		if bytes.Contains(stored, []byte("compressible")) {
+			t.Fatalf("The stored data should be transformed by %s.", options.transforms())
+		}
+
+		var out bytes.Buffer
+		size, err := cf.DownloadLargeObject("TEST", "testing", "file", &out, &DownloadOptions{DecryptionKey: key})
+		if err != nil || size != int64(len(data)) || !bytes.Equal(out.Bytes(), data) {
+			t.Fatalf("Could not read back %s: %d %v", options.transforms(), size, err)
+		}
+	}
+
+	if _, err := cf.DownloadLargeObject("TEST", "testing", "file", &bytes.Buffer{}, nil); !errors.Is(err, ErrDecryptionKey) {
+		t.Fatalf("Expected ErrDecryptionKey without a key, got %v", err)
+	}
+	wrong := &DownloadOptions{DecryptionKey: bytes.Repeat([]byte("w"), 32)}
+	if _, err := cf.DownloadLargeObject("TEST", "testing", "file", &bytes.Buffer{}, wrong); !errors.Is(err, ErrDecryptionKey) {
+		t.Fatalf("Expected ErrDecryptionKey with the wrong key, got %v", err)
+	}
+
+	options := &WriteOptions{EncryptionKey: []byte("short")}
+	if _, err := cf.PutFileWithOptions("TEST", "testing", "bad", bytes.NewReader(data), options); err == nil {
+		t.Fatalf("A key of the wrong size should be refused.")
+	}
+}
+
+func TestWriteTransformsSegmented(t *testing.T) {
+	// Test segmented uploads transform the object as a whole and copies
+	// refuse to.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	key := bytes.Repeat([]byte("k"), 16)
+	// Random data, which stays as large compressed.
+	data := make([]byte, 3*encryptionFrame+5)
+	rand.New(rand.NewSource(1)).Read(data)
+	write := &WriteOptions{Compress: true, EncryptionKey: key}
+
+	_, err := cf.UploadStream("TEST", "testing", "stream", bytes.NewReader(data),
+		&UploadStreamOptions{SegmentSize: encryptionFrame, Write: write})
+	if err != nil {
+		t.Fatalf("Could not upload stream: %s", err)
+	}
+	_, err = cf.UploadFile("TEST", "testing", "file", bytes.NewReader(data), int64(len(data)),
+		&UploadFileOptions{SegmentSize: encryptionFrame, Write: write})
+	if err != nil {
+		t.Fatalf("Could not upload file: %s", err)
+	}
+
+	for _, name := range []string{"stream", "file"} {
+		if len(fs.manifests["testing/"+name]) < 2 {
+			t.Fatalf("Expected %s in segments: %+v", name, fs.manifests["testing/"+name])
+		}
+		var out bytes.Buffer
+		if _, err := cf.DownloadLargeObject("TEST", "testing", name, &out, &DownloadOptions{DecryptionKey: key}); err != nil ||
+			!bytes.Equal(out.Bytes(), data) {
+			t.Fatalf("Could not read back %s: %v", name, err)
+		}
+	}
+
+	err = cf.CopyFileWithOptions("TEST", "testing", "stream", "TEST", "testing", "copy", &CopyOptions{Write: write})
+	if err == nil {
+		t.Fatalf("A copy should refuse to transform.")
+	}
+}
+
+func TestDecryptTruncated(t *testing.T) {
+	// Test encrypted data cut short at a frame boundary does not decrypt.
+	key := bytes.Repeat([]byte("k"), 32)
+	var sealed bytes.Buffer
+	if err := (&WriteOptions{EncryptionKey: key}).encodeTo(&sealed, bytes.NewReader(make([]byte, 2*encryptionFrame))); err != nil {
+		t.Fatalf("Could not encrypt: %s", err)
+	}
+
+	frame := encryptionFrame + 16
+	for _, length := range []int{noncePrefixSize + frame, noncePrefixSize + 2*frame, sealed.Len() - 1} {
+		reader, err := decodeTransforms(bytes.NewReader(sealed.Bytes()[:length]), TransformAESGCM, key)
+		if err == nil {
+			_, err = bytes.NewBuffer(nil).ReadFrom(reader)
+		}
+		if !errors.Is(err, ErrDecryptionKey) {
+			t.Fatalf("Data cut to %d bytes should not decrypt: %v", length, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	trashOriginHeader    = "X-Object-Meta-Trash-Origin"
+	trashDeletedAtHeader = "X-Object-Meta-Trash-Deleted-At"
+)
+
+type trashConfig struct {
+	container string
+	ttl       time.Duration
+}
+
+func (cf *CloudFiles) SetTrash(container string, ttl time.Duration) {
+	/*
+		Turn on soft deletes.  Deleted objects are first copied server side
+		into the trash container of the same region, named
+		"<bucket>/<name>", and expire from it after ttl.  An empty container
+		turns soft deletes off again.
+	*/
+	if container == "" {
+		cf.trash = nil
+		return
+	}
+
+	cf.trash = &trashConfig{container: container, ttl: ttl}
+}
+
+func trashName(bucket, filename string) string {
+	return bucket + "/" + filename
+}
+
+func (cf CloudFiles) removeObject(dc, bucket, filename string) error {
+	/*
+		Delete an object on behalf of a caller, moving it to the trash
+		first when soft deletes are enabled.
+	*/
+	if cf.trash != nil && bucket != cf.trash.container {
+		headers := map[string]string{
+			trashOriginHeader:    url.PathEscape(trashName(bucket, filename)),
+			trashDeletedAtHeader: time.Now().UTC().Format(time.RFC3339),
+		}
+		if cf.trash.ttl > 0 {
+			headers["X-Delete-After"] = strconv.FormatInt(int64(cf.trash.ttl/time.Second), 10)
+		}
+
+		_, err := cf.serverCopy(dc, bucket, filename, cf.trash.container, trashName(bucket, filename), headers)
+		if err != nil {
+			return fmt.Errorf("Could not move %s/%s to trash: %w", bucket, filename, err)
+		}
+	}
+
+	return cf.deleteObject(dc, bucket, filename)
+}
+
+func (cf CloudFiles) Undelete(dc, bucket, filename string) error {
+	/*
+		Restore an object from the trash container back to where it was
+		deleted from, then remove it from the trash.
+	*/
+	if cf.trash == nil {
+		return fmt.Errorf("Cannot undelete %s/%s: no trash container configured.", bucket, filename)
+	}
+
+	// Blank values drop the trash bookkeeping from the restored object.
+	headers := map[string]string{
+		trashOriginHeader:    "",
+		trashDeletedAtHeader: "",
+	}
+
+	_, err := cf.serverCopy(dc, cf.trash.container, trashName(bucket, filename), bucket, filename, headers)
+	if err != nil {
+		return fmt.Errorf("Could not undelete %s/%s: %w", bucket, filename, err)
+	}
+
+	return cf.deleteObject(dc, cf.trash.container, trashName(bucket, filename))
+}
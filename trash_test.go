@@ -0,0 +1,91 @@
+package gocloudfiles
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrashAndUndelete(t *testing.T) {
+	// Test soft deleted objects land in the trash and can be restored.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.SetTrash("trash", 24*time.Hour)
+
+	fs.put("testing/logs/1", []byte("one"))
+
+	_, err := cf.DeletePrefix("TEST", "testing", "logs/", nil)
+	if err != nil {
+		t.Fatalf("Could not delete prefix: %s", err)
+	}
+
+	if _, ok := fs.get("testing/logs/1"); ok {
+		t.Fatalf("Object should have been deleted.")
+	}
+
+	if data, ok := fs.get("trash/testing/logs/1"); !ok || string(data) != "one" {
+		t.Fatalf("Object should be in the trash: %q", data)
+	}
+
+	if fs.headers["trash/testing/logs/1"].Get("X-Delete-At") == "" {
+		t.Fatalf("Trashed object should expire.")
+	}
+
+	err = cf.Undelete("TEST", "testing", "logs/1")
+	if err != nil {
+		t.Fatalf("Could not undelete: %s", err)
+	}
+
+	if data, ok := fs.get("testing/logs/1"); !ok || string(data) != "one" {
+		t.Fatalf("Object should be restored: %q", data)
+	}
+
+	if fs.headers["testing/logs/1"].Get(trashOriginHeader) != "" {
+		t.Fatalf("Restored object should not keep trash metadata.")
+	}
+
+	if _, ok := fs.get("trash/testing/logs/1"); ok {
+		t.Fatalf("Restored object should leave the trash.")
+	}
+}
+
+func TestTrashLargeObject(t *testing.T) {
+	// Test a large object is trashed and restored as its manifest.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.SetTrash("trash", 24*time.Hour)
+
+	putLargeObject(fs, "segments", "big.iso", "big.iso/0", "big.iso/1")
+	fs.mutex.Lock()
+	fs.objects["testing/big.iso"] = fs.objects["segments/big.iso"]
+	fs.headers["testing/big.iso"] = fs.headers["segments/big.iso"]
+	fs.manifests["testing/big.iso"] = fs.manifests["segments/big.iso"]
+	fs.mutex.Unlock()
+
+	err := cf.removeObject("TEST", "testing", "big.iso")
+	if err != nil {
+		t.Fatalf("Could not delete large object: %s", err)
+	}
+
+	fs.mutex.Lock()
+	trashed := fs.manifests["trash/testing/big.iso"]
+	fs.mutex.Unlock()
+	if len(trashed) != 2 || trashed[0].Name != "/segments/big.iso/0" {
+		t.Fatalf("The trash should hold the manifest, not a copy of the data: %+v", trashed)
+	}
+
+	err = cf.Undelete("TEST", "testing", "big.iso")
+	if err != nil {
+		t.Fatalf("Could not undelete large object: %s", err)
+	}
+
+	headers, err := cf.headObject("TEST", "testing", "big.iso")
+	if err != nil || !isStaticLargeObject(headers) {
+		t.Fatalf("The restored object should be a static large object: %v %v", headers, err)
+	}
+
+	if _, ok := fs.get("segments/big.iso/1"); !ok {
+		t.Fatalf("Segments should be left in place.")
+	}
+}
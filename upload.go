@@ -0,0 +1,339 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// UploadStreamOptions tune UploadStream.
+type UploadStreamOptions struct {
+	// SegmentSize is how much of the stream each segment holds, 256MB when
+	// zero.
+	SegmentSize int64
+
+	// TransferID is included in segment names, see CopyOptions.TransferID.
+	TransferID string
+
+	// Write applies to the segments and manifest.  Its IfExists policy is
+	// checked once before anything is uploaded.
+	Write *WriteOptions
+}
+
+// UploadFileOptions tune UploadFile.
+type UploadFileOptions struct {
+	// SegmentSize is how much of the file each segment holds, 256MB when
+	// zero.
+	SegmentSize int64
+
+	// Concurrency is how many segments are uploaded at once, defaults
+	// to 5.
+	Concurrency int
+
+	// TransferID is included in segment names, see CopyOptions.TransferID.
+	TransferID string
+
+	// Write applies to the segments and manifest.  Its IfExists policy is
+	// checked once before anything is uploaded.
+	Write *WriteOptions
+}
+
+func (cf CloudFiles) UploadFile(dc, bucket, filename string, data io.ReaderAt, size int64,
+	options *UploadFileOptions) (int64, error) {
+	/*
+		Upload size bytes of data, such as an *os.File, as a static large
+		object.  Segments are read at their own offsets and uploaded in
+		parallel, so a huge local file is not limited to a single stream,
+		and the manifest is written once all of them are in.  A file no
+		larger than one segment becomes a plain object.
+
+		Like CopyFile, an upload resumes where an interrupted one under the
+		same TransferID stopped: segments already there whose size and MD5
+		match the file's are not uploaded again.
+		Returns a tuple of bytes uploaded, error
+	*/
+	if options == nil {
+		options = &UploadFileOptions{}
+	}
+
+	if !validTransferID(options.TransferID) {
+		return 0, fmt.Errorf("Invalid transfer ID %q, use only letters, digits and underscores.",
+			options.TransferID)
+	}
+
+	segmentSize := options.SegmentSize
+	if segmentSize <= 0 {
+		segmentSize = defaultChunkSize
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	whole := io.NewSectionReader(data, 0, size)
+
+	// Compressed or encrypted data has a size of its own, known only once
+	// it is written, so it is uploaded as a stream.
+	if options.Write.transforms() != "" {
+		return cf.UploadStream(dc, bucket, filename, whole, &UploadStreamOptions{
+			SegmentSize: segmentSize,
+			TransferID:  options.TransferID,
+			Write:       options.Write,
+		})
+	}
+
+	_, kept, err := cf.keepUpload(dc, bucket, filename, whole, options.Write)
+	if err != nil || kept {
+		return 0, err
+	}
+
+	if size <= segmentSize {
+		whole.Seek(0, io.SeekStart)
+		_, err = cf.putFile(dc, bucket, filename, whole, options.Write)
+		if err != nil {
+			return 0, err
+		}
+		return size, nil
+	}
+
+	// Segments are always written, the policy applies to the object.
+	write := options.Write
+	if write != nil && write.IfExists != OverwriteExisting {
+		copied := *write
+		copied.IfExists = OverwriteExisting
+		write = &copied
+	}
+
+	count, remainder := chunkLayout(size, segmentSize)
+	items := make(manifestList, count)
+
+	// Segments a previous upload under the same transfer ID left behind,
+	// listed once.  An unreadable listing only costs the recovery.
+	existing := make(map[string]objectEntry)
+	cf.walkObjects(dc, bucket, segmentPrefix(filename, options.TransferID), func(entry objectEntry) error {
+		existing[entry.Name] = entry
+		return nil
+	})
+
+	sem := make(chan bool, concurrency)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var uploadErr error
+
+	failed := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return uploadErr != nil
+	}
+
+	for index := int64(0); index < count && !failed(); index++ {
+		length := segmentSize
+		if index == count-1 && remainder > 0 {
+			length = remainder
+		}
+
+		sem <- true
+		wg.Add(1)
+
+		go func(index, length int64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			segment := segmentName(filename, options.TransferID, index, DefaultSegmentDigits)
+			var etag string
+			err := catchPanic(filename, index, func() (err error) {
+				etag, err = cf.uploadFileSegment(dc, bucket, segment,
+					io.NewSectionReader(data, index*segmentSize, length), existing[segment], write)
+				return err
+			})
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				if uploadErr == nil {
+					uploadErr = err
+				}
+				return
+			}
+
+			items[index] = manifestItem{
+				Path: fmt.Sprintf("%s/%s", bucket, segment),
+				ETag: etag,
+				Size: length,
+			}
+		}(index, length)
+	}
+
+	wg.Wait()
+
+	if uploadErr != nil {
+		return 0, uploadErr
+	}
+
+	err = cf.putManifest(dc, bucket, filename, items, options.Write)
+	if err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+func (cf CloudFiles) uploadFileSegment(dc, bucket, segment string, data *io.SectionReader,
+	existing objectEntry, write *WriteOptions) (string, error) {
+	/*
+		Upload one segment of a file unless a segment with its size and
+		MD5 is already there.  The MD5, once computed, is also sent for
+		the server to check.
+	*/
+	if existing.Name == "" || existing.Bytes != data.Size() {
+		return cf.putFile(dc, bucket, segment, data, write)
+	}
+
+	expected, err := md5Of(data)
+	if err != nil {
+		return "", err
+	}
+
+	if sameETag(existing.Hash, expected) {
+		return existing.Hash, nil
+	}
+
+	return cf.putObject(dc, bucket, segment, data, expected, write)
+}
+
+func (cf CloudFiles) UploadStream(dc, bucket, filename string, data io.Reader,
+	options *UploadStreamOptions) (int64, error) {
+	/*
+		Upload a stream of unknown length, such as a pipe, as a static
+		large object.  Up to one segment of the stream is staged in a
+		temporary file at a time and uploaded, and the manifest is written
+		once the stream ends.  A stream shorter than one segment becomes a
+		plain object.
+		Returns a tuple of bytes uploaded, error
+	*/
+	if options == nil {
+		options = &UploadStreamOptions{}
+	}
+
+	if !validTransferID(options.TransferID) {
+		return 0, fmt.Errorf("Invalid transfer ID %q, use only letters, digits and underscores.",
+			options.TransferID)
+	}
+
+	segmentSize := options.SegmentSize
+	if segmentSize <= 0 {
+		segmentSize = defaultChunkSize
+	}
+
+	_, kept, err := cf.keepUpload(dc, bucket, filename, data, options.Write)
+	if err != nil || kept {
+		return 0, err
+	}
+
+	// The stream is compressed and encrypted as a whole and then split, so
+	// its segments join back into one stream to decode.
+	if options.Write.transforms() != "" && options.Write.encoded == "" {
+		encoder := options.Write.encoder(data)
+		defer encoder.Close()
+
+		transformed := *options
+		transformed.Write = options.Write.asEncoded()
+		data, options = encoder, &transformed
+	}
+
+	// Segments are always written, the policy applies to the object.
+	write := options.Write
+	if write != nil && write.IfExists != OverwriteExisting {
+		copied := *write
+		copied.IfExists = OverwriteExisting
+		write = &copied
+	}
+
+	var items manifestList
+	total := int64(0)
+
+	for index := int64(0); ; index++ {
+		item, size, err := cf.uploadStreamSegment(dc, bucket, filename, data, index, segmentSize, options, write)
+		total += size
+		if err != nil {
+			return total, err
+		}
+
+		// A stream that fit in its first segment was written as the
+		// object itself.
+		if item == nil && index == 0 {
+			return total, nil
+		}
+
+		if item == nil {
+			break
+		}
+
+		items = append(items, *item)
+		if size < segmentSize {
+			break
+		}
+	}
+
+	err = cf.putManifest(dc, bucket, filename, items, options.Write)
+	if err != nil {
+		return total, err
+	}
+
+	return total, nil
+}
+
+func (cf CloudFiles) uploadStreamSegment(dc, bucket, filename string, data io.Reader,
+	index, segmentSize int64, options *UploadStreamOptions, write *WriteOptions) (*manifestItem, int64, error) {
+	/*
+		Stage the next segment of the stream and upload it.  Returns no
+		item at the end of the stream, or when the first segment holds the
+		whole stream and was uploaded as the object itself.
+	*/
+	tmpFile, err := ioutil.TempFile("", "")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	size, err := io.CopyN(tmpFile, data, segmentSize)
+	if err != nil && err != io.EOF {
+		return nil, size, err
+	}
+
+	if size == 0 && index > 0 {
+		return nil, 0, nil
+	}
+
+	tmpFile.Seek(0, 0)
+
+	if index == 0 && size < segmentSize {
+		_, err = cf.putFile(dc, bucket, filename, tmpFile, options.Write)
+		return nil, size, err
+	}
+
+	expected := ""
+	if write != nil && write.VerifyMD5 {
+		if expected, err = md5Of(tmpFile); err != nil {
+			return nil, size, err
+		}
+	}
+
+	segment := segmentName(filename, options.TransferID, index, DefaultSegmentDigits)
+	etag, err := cf.putObject(dc, bucket, segment, tmpFile, expected, write)
+	if err != nil {
+		return nil, size, err
+	}
+
+	return &manifestItem{
+		Path: fmt.Sprintf("%s/%s", bucket, segment),
+		ETag: etag,
+		Size: size,
+	}, size, nil
+}
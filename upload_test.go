@@ -0,0 +1,129 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestUploadStream(t *testing.T) {
+	// Test a stream is split into segments joined by a manifest.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	data := []byte("a stream of unknown length")
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write(data[:10])
+		writer.Write(data[10:])
+		writer.Close()
+	}()
+
+	size, err := cf.UploadStream("TEST", "testing", "dump.sql", reader,
+		&UploadStreamOptions{SegmentSize: 10, Write: &WriteOptions{VerifyMD5: true}})
+	if err != nil || size != int64(len(data)) {
+		t.Fatalf("Could not upload stream: %d %v", size, err)
+	}
+
+	if stored, _ := fs.get("testing/dump.sql"); !bytes.Equal(stored, data) {
+		t.Fatalf("Unexpected object %q", stored)
+	}
+
+	if segments := fs.manifests["testing/dump.sql"]; len(segments) != 3 {
+		t.Fatalf("Expected 3 segments: %+v", segments)
+	}
+}
+
+func TestUploadStreamShort(t *testing.T) {
+	// Test streams of one segment or less become plain objects.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	for _, data := range []string{"", "short"} {
+		_, err := cf.UploadStream("TEST", "testing", "small.txt", bytes.NewBufferString(data),
+			&UploadStreamOptions{SegmentSize: 10})
+		if err != nil {
+			t.Fatalf("Could not upload stream: %s", err)
+		}
+
+		if stored, ok := fs.get("testing/small.txt"); !ok || string(stored) != data {
+			t.Fatalf("Unexpected object %q", stored)
+		}
+
+		if _, ok := fs.manifests["testing/small.txt"]; ok {
+			t.Fatalf("A short stream should not get a manifest.")
+		}
+	}
+
+	// Exactly one segment still needs a manifest, the end is only seen
+	// after it is full.
+	_, err := cf.UploadStream("TEST", "testing", "exact.txt", bytes.NewBufferString("0123456789"),
+		&UploadStreamOptions{SegmentSize: 10})
+	if stored, _ := fs.get("testing/exact.txt"); err != nil || string(stored) != "0123456789" {
+		t.Fatalf("Unexpected object %q %v", stored, err)
+	}
+}
+
+func TestUploadFileParallelSegments(t *testing.T) {
+	// Test a file is uploaded as segments read at their own offsets.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	size, err := cf.UploadFile("TEST", "testing", "big.bin", bytes.NewReader(data), int64(len(data)),
+		&UploadFileOptions{SegmentSize: 12, Concurrency: 3, Write: &WriteOptions{VerifyMD5: true}})
+	if err != nil || size != int64(len(data)) {
+		t.Fatalf("Could not upload file: %d %v", size, err)
+	}
+
+	if stored, _ := fs.get("testing/big.bin"); !bytes.Equal(stored, data) {
+		t.Fatalf("Unexpected object %q", stored)
+	}
+
+	// An exact multiple of the segment size has no empty last segment.
+	if segments := fs.manifests["testing/big.bin"]; len(segments) != 3 || segments[2].Bytes != 12 {
+		t.Fatalf("Expected 3 full segments: %+v", segments)
+	}
+
+	_, err = cf.UploadFile("TEST", "testing", "small.bin", bytes.NewReader(data), 5,
+		&UploadFileOptions{SegmentSize: 12})
+	if stored, _ := fs.get("testing/small.bin"); err != nil || string(stored) != "01234" {
+		t.Fatalf("Unexpected small object %q %v", stored, err)
+	}
+	if _, ok := fs.manifests["testing/small.bin"]; ok {
+		t.Fatalf("A file of one segment should not get a manifest.")
+	}
+}
+
+func TestUploadFileResumes(t *testing.T) {
+	// Test segments already uploaded with the right MD5 are not uploaded again.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	options := &UploadFileOptions{SegmentSize: 12, TransferID: "resume"}
+	segment := func(index int64) string {
+		return "testing/" + segmentName("big.bin", "resume", index, DefaultSegmentDigits)
+	}
+
+	// An interrupted upload left a good first segment and a damaged second.
+	fs.put(segment(0), data[:12])
+	fs.put(segment(1), []byte("XXXXXXXXXXXX"))
+	written := fs.modified[segment(0)]
+
+	size, err := cf.UploadFile("TEST", "testing", "big.bin", bytes.NewReader(data), int64(len(data)), options)
+	if err != nil || size != int64(len(data)) {
+		t.Fatalf("Could not upload file: %d %v", size, err)
+	}
+
+	if !fs.modified[segment(0)].Equal(written) {
+		t.Fatalf("Matching segment was uploaded again.")
+	}
+	if stored, _ := fs.get("testing/big.bin"); !bytes.Equal(stored, data) {
+		t.Fatalf("Unexpected object %q", stored)
+	}
+}
@@ -0,0 +1,172 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// An ObjectLocation names an object, or a container when Object is empty.
+type ObjectLocation struct {
+	Region    string
+	Container string
+	Object    string
+}
+
+func (l ObjectLocation) String() string {
+	/*
+		The location in the region:container/object form ParseObjectURL
+		reads back.
+	*/
+	if l.Object == "" {
+		return fmt.Sprintf("%s:%s", l.Region, l.Container)
+	}
+	return fmt.Sprintf("%s:%s/%s", l.Region, l.Container, l.Object)
+}
+
+// ErrNotCDNEnabled is returned by CDNURL for containers that are not
+// published on the CDN.
+var ErrNotCDNEnabled = errors.New("Container is not CDN enabled.")
+
+func escapePath(name string) string {
+	/*
+		Escape an object name for a URL path, keeping the slashes that
+		look like directories.
+	*/
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		parts[i] = neturl.PathEscape(parts[i])
+	}
+	return strings.Join(parts, "/")
+}
+
+func (cf CloudFiles) containerURL(dc, bucket string) (string, error) {
+	/*
+		The URL requests for a container are sent to.
+	*/
+	endpoint, err := cf.endpoint(dc)
+	if err != nil {
+		return "", err
+	}
+	return endpoint + "/" + neturl.PathEscape(bucket), nil
+}
+
+func (cf CloudFiles) objectURL(dc, bucket, filename string) (string, error) {
+	/*
+		The URL requests for an object are sent to, through the internal
+		endpoint in the local DC.
+	*/
+	container, err := cf.containerURL(dc, bucket)
+	if err != nil {
+		return "", err
+	}
+	return container + "/" + escapePath(filename), nil
+}
+
+func (cf CloudFiles) PublicURL(dc, bucket, filename string) (string, error) {
+	/*
+		The object's URL on the region's public storage endpoint, escaped,
+		even when the region is the local DC.  Reading it needs a token
+		unless the container is public, see TempURL and CDNURL.
+		Returns a tuple of URL, error
+	*/
+	endpoint := cf.dcs[dc]
+	if endpoint == "" {
+		return "", fmt.Errorf("Could not find region %s in service catalog.", dc)
+	}
+	return cf.onAccount(endpoint) + "/" + neturl.PathEscape(bucket) + "/" + escapePath(filename), nil
+}
+
+func (cf CloudFiles) CDNURL(dc, bucket, filename string) (string, error) {
+	/*
+		The object's HTTPS URL on the CDN, for containers published there.
+		The container's CDN address is looked up on the region's CDN
+		endpoint.  Returns an error matching ErrNotCDNEnabled when the
+		container is not published.
+		Returns a tuple of URL, error
+	*/
+	endpoint := cf.cdns[dc]
+	if endpoint == "" {
+		return "", fmt.Errorf("Could not find region %s in the CDN service catalog.", dc)
+	}
+
+	req, err := http.NewRequest("HEAD", endpoint+"/"+neturl.PathEscape(bucket), nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Add("X-Auth-Token", cf.token())
+
+	resp, err := cf.do(dc, req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	uri := resp.Header.Get("X-Cdn-Ssl-Uri")
+	switch {
+	case resp.StatusCode == 404 || (succeeded(resp) && !strings.EqualFold(resp.Header.Get("X-Cdn-Enabled"), "true")):
+		return "", fmt.Errorf("Could not build CDN URL of %s: %w", bucket, ErrNotCDNEnabled)
+	case !succeeded(resp):
+		return "", newCloudFilesError(resp, "Could not look up CDN of container %s", bucket)
+	case uri == "":
+		return "", fmt.Errorf("CDN of container %s has no SSL URI.", bucket)
+	}
+
+	return strings.TrimSuffix(uri, "/") + "/" + escapePath(filename), nil
+}
+
+func (cf CloudFiles) ParseObjectURL(raw string) (*ObjectLocation, error) {
+	/*
+		Read an object's location back from either the short
+		region:container/object form or a full URL on one of the account's
+		storage endpoints, public or internal.  Query strings, such as
+		those of a temporary URL, are ignored.
+		Returns a tuple of location, error
+	*/
+	if !strings.Contains(raw, "://") {
+		colon := strings.Index(raw, ":")
+		if colon <= 0 {
+			return nil, fmt.Errorf("Location %q is not region:container/object.", raw)
+		}
+		location := &ObjectLocation{Region: raw[:colon]}
+		parts := strings.SplitN(raw[colon+1:], "/", 2)
+		location.Container = parts[0]
+		if len(parts) == 2 {
+			location.Object = parts[1]
+		}
+		if location.Container == "" {
+			return nil, fmt.Errorf("Location %q has no container.", raw)
+		}
+		return location, nil
+	}
+
+	u, err := neturl.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, endpoints := range []map[string]string{cf.dcs, cf.dcsInternal} {
+		for region, endpoint := range endpoints {
+			base, err := neturl.Parse(cf.onAccount(endpoint))
+			if err != nil || base.Host != u.Host || !strings.HasPrefix(u.Path, base.Path+"/") {
+				continue
+			}
+
+			parts := strings.SplitN(strings.TrimPrefix(u.Path, base.Path+"/"), "/", 2)
+			location := &ObjectLocation{Region: region, Container: parts[0]}
+			if len(parts) == 2 {
+				location.Object = parts[1]
+			}
+			if location.Container == "" {
+				return nil, fmt.Errorf("URL %s has no container.", raw)
+			}
+			return location, nil
+		}
+	}
+
+	return nil, fmt.Errorf("URL %s is not on a storage endpoint of the account.", raw)
+}
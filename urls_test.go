@@ -0,0 +1,102 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestObjectURLEscapesNames(t *testing.T) {
+	// Test names needing escapes reach the server whole.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	name := "reports/2016 #1?final%.txt"
+	if _, err := cf.PutFile("TEST", "testing", name, bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatalf("Could not put: %s", err)
+	}
+	if _, ok := fs.get("testing/" + name); !ok {
+		t.Fatalf("Object stored under the wrong name: %v", fs.objects)
+	}
+
+	public, err := cf.PublicURL("TEST", "testing", name)
+	if err != nil {
+		t.Fatalf("Could not build URL: %s", err)
+	}
+	if public != fs.server.URL+"/testing/reports/2016%20%231%3Ffinal%25.txt" {
+		t.Fatalf("Unexpected URL %s", public)
+	}
+
+	location, err := cf.ParseObjectURL(public)
+	if err != nil {
+		t.Fatalf("Could not parse: %s", err)
+	}
+	if *location != (ObjectLocation{Region: "TEST", Container: "testing", Object: name}) {
+		t.Fatalf("Unexpected location %+v", location)
+	}
+}
+
+func TestParseObjectURL(t *testing.T) {
+	cf := NewCloudFilesImpersonation("token")
+	cf.dcs["DFW"] = "https://storage101.dfw1.clouddrive.com/v1/MossoCloudFS_abc"
+	cf.dcsInternal["DFW"] = "https://snet-storage101.dfw1.clouddrive.com/v1/MossoCloudFS_abc"
+
+	cases := map[string]ObjectLocation{
+		"DFW:backups/2016/db.tar": {Region: "DFW", Container: "backups", Object: "2016/db.tar"},
+		"DFW:backups":             {Region: "DFW", Container: "backups"},
+		"https://storage101.dfw1.clouddrive.com/v1/MossoCloudFS_abc/backups/db.tar?temp_url_sig=x": {
+			Region: "DFW", Container: "backups", Object: "db.tar"},
+		"https://snet-storage101.dfw1.clouddrive.com/v1/MossoCloudFS_abc/backups/a%20b": {
+			Region: "DFW", Container: "backups", Object: "a b"},
+	}
+	for raw, expected := range cases {
+		location, err := cf.ParseObjectURL(raw)
+		if err != nil {
+			t.Fatalf("Could not parse %s: %s", raw, err)
+		}
+		if *location != expected {
+			t.Fatalf("Parsed %s as %+v", raw, location)
+		}
+		if !strings.Contains(raw, "://") && location.String() != raw {
+			t.Fatalf("Location %s printed as %s", raw, location)
+		}
+	}
+
+	for _, raw := range []string{"backups/db.tar", "DFW:", "https://example.com/v1/other/backups/db.tar",
+		"https://storage101.dfw1.clouddrive.com/v1/MossoCloudFS_abc/"} {
+		if _, err := cf.ParseObjectURL(raw); err == nil {
+			t.Fatalf("Parsed invalid location %s", raw)
+		}
+	}
+}
+
+func TestCDNURL(t *testing.T) {
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+	cf.cdns["TEST"] = fs.server.URL
+
+	fs.headers["public"] = http.Header{
+		"X-Cdn-Enabled": {"True"},
+		"X-Cdn-Ssl-Uri": {"https://abc123.ssl.cf1.rackcdn.com"},
+	}
+	fs.headers["private"] = http.Header{"X-Cdn-Enabled": {"False"}}
+
+	cdn, err := cf.CDNURL("TEST", "public", "img/logo one.png")
+	if err != nil {
+		t.Fatalf("Could not build CDN URL: %s", err)
+	}
+	if cdn != "https://abc123.ssl.cf1.rackcdn.com/img/logo%20one.png" {
+		t.Fatalf("Unexpected CDN URL %s", cdn)
+	}
+
+	if _, err := cf.CDNURL("TEST", "private", "logo.png"); !errors.Is(err, ErrNotCDNEnabled) {
+		t.Fatalf("Expected ErrNotCDNEnabled, got %v", err)
+	}
+	if _, err := cf.CDNURL("ORD", "public", "logo.png"); err == nil {
+		t.Fatalf("Built a CDN URL for a region without a CDN endpoint.")
+	}
+}
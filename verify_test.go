@@ -0,0 +1,42 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVerifyCopySample(t *testing.T) {
+	// Test sampled ranges match a good copy and catch a damaged one.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	fs.put("src/file.bin", data)
+	fs.put("dst/file.bin", data)
+
+	options := &VerifyOptions{Samples: 20, RangeSize: 100, Seed: 1}
+
+	report, err := cf.VerifyCopySample("TEST", "src", "file.bin", "TEST", "dst", "file.bin", options)
+	if err != nil || len(report.Ranges) != 20 {
+		t.Fatalf("A good copy should verify: %+v %v", report, err)
+	}
+
+	damaged := append([]byte(nil), data...)
+	for i := range damaged {
+		damaged[i] = 'x'
+	}
+	fs.put("dst/file.bin", damaged)
+
+	report, err = cf.VerifyCopySample("TEST", "src", "file.bin", "TEST", "dst", "file.bin", options)
+	if err == nil || report.Mismatches != 20 {
+		t.Fatalf("A damaged copy should not verify: %+v", report)
+	}
+
+	fs.put("dst/file.bin", data[:10])
+
+	_, err = cf.VerifyCopySample("TEST", "src", "file.bin", "TEST", "dst", "file.bin", options)
+	if err == nil {
+		t.Fatalf("A truncated copy should not verify.")
+	}
+}
@@ -0,0 +1,135 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An ObjectVersion is an archived copy of an object kept by container
+// versioning.
+type ObjectVersion struct {
+	// The archive timestamp, usable with RestoreObjectVersion.
+	ID        string
+	Timestamp time.Time
+	Bytes     int64
+	Hash      string
+	// Where the archived copy lives.
+	Container string
+	Name      string
+}
+
+func versionPrefix(filename string) string {
+	/*
+		Versioned copies are named with the hex length of the object name,
+		the name and then the archive timestamp.
+	*/
+	return fmt.Sprintf("%03x%s/", len(filename), filename)
+}
+
+func parseSwiftTimestamp(value string) (time.Time, error) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid timestamp %q.", value)
+	}
+
+	whole := int64(seconds)
+	return time.Unix(whole, int64((seconds-float64(whole))*1e9)).UTC(), nil
+}
+
+func (cf CloudFiles) versionsContainer(dc, bucket string) (string, error) {
+	headers, err := cf.headContainer(dc, bucket)
+	if err != nil {
+		return "", err
+	}
+
+	for _, header := range []string{"X-Versions-Location", "X-History-Location"} {
+		if location := headers.Get(header); location != "" {
+			return location, nil
+		}
+	}
+
+	return "", fmt.Errorf("Container %s does not have versioning enabled.", bucket)
+}
+
+func (cf CloudFiles) ListObjectHistory(dc, bucket, filename string) ([]ObjectVersion, error) {
+	/*
+		List the archived versions of an object, newest first.
+	*/
+	container, err := cf.versionsContainer(dc, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := versionPrefix(filename)
+	versions := make([]ObjectVersion, 0)
+
+	err = cf.walkObjects(dc, container, prefix, func(entry objectEntry) error {
+		id := strings.TrimPrefix(entry.Name, prefix)
+		timestamp, err := parseSwiftTimestamp(id)
+		if err != nil {
+			return nil
+		}
+
+		versions = append(versions, ObjectVersion{
+			ID:        id,
+			Timestamp: timestamp,
+			Bytes:     entry.Bytes,
+			Hash:      entry.Hash,
+			Container: container,
+			Name:      entry.Name,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Timestamp.After(versions[j].Timestamp)
+	})
+
+	return versions, nil
+}
+
+func (cf CloudFiles) RestoreObjectVersion(dc, bucket, filename, versionID string) error {
+	/*
+		Copy an archived version back over the current object.  With
+		versioning still enabled the current object is archived in turn.
+		Archived large objects are restored as their manifest.
+	*/
+	versions, err := cf.ListObjectHistory(dc, bucket, filename)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		if version.ID == versionID {
+			_, err = cf.serverCopy(dc, version.Container, version.Name, bucket, filename, nil)
+			return err
+		}
+	}
+
+	return fmt.Errorf("Version %s of %s/%s not found.", versionID, bucket, filename)
+}
+
+func (cf CloudFiles) RestoreObjectVersionAt(dc, bucket, filename string, at time.Time) error {
+	/*
+		Restore the newest version archived at or before the given time.
+	*/
+	versions, err := cf.ListObjectHistory(dc, bucket, filename)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		if !version.Timestamp.After(at) {
+			_, err = cf.serverCopy(dc, version.Container, version.Name, bucket, filename, nil)
+			return err
+		}
+	}
+
+	return fmt.Errorf("No version of %s/%s archived before %s.", bucket, filename, at.Format(time.RFC3339))
+}
@@ -0,0 +1,70 @@
+package gocloudfiles
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRestoreObjectVersion(t *testing.T) {
+	// Test archived versions are listed newest first and can be restored.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.headers["testing"] = http.Header{"X-Versions-Location": []string{"versions"}}
+	fs.put("testing/doc.txt", []byte("current"))
+	fs.put("versions/007doc.txt/1451606400.00000", []byte("first"))
+	fs.put("versions/007doc.txt/1451692800.50000", []byte("second"))
+	fs.put("versions/008doc.txtx/1451692900.00000", []byte("other"))
+
+	versions, err := cf.ListObjectHistory("TEST", "testing", "doc.txt")
+	if err != nil {
+		t.Fatalf("Could not list history: %s", err)
+	}
+
+	if len(versions) != 2 || versions[0].ID != "1451692800.50000" {
+		t.Fatalf("Unexpected versions: %+v", versions)
+	}
+
+	err = cf.RestoreObjectVersion("TEST", "testing", "doc.txt", "1451606400.00000")
+	if err != nil {
+		t.Fatalf("Could not restore version: %s", err)
+	}
+
+	if data, _ := fs.get("testing/doc.txt"); string(data) != "first" {
+		t.Fatalf("Expected the first version but got %q", data)
+	}
+
+	err = cf.RestoreObjectVersionAt("TEST", "testing", "doc.txt", time.Unix(1451700000, 0))
+	if err != nil {
+		t.Fatalf("Could not restore version: %s", err)
+	}
+
+	if data, _ := fs.get("testing/doc.txt"); string(data) != "second" {
+		t.Fatalf("Expected the second version but got %q", data)
+	}
+}
+
+func TestRestoreLargeObjectVersion(t *testing.T) {
+	// Test an archived large object is restored as its manifest.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.headers["testing"] = http.Header{"X-Versions-Location": []string{"versions"}}
+	fs.put("testing/big.iso", []byte("current"))
+	putLargeObject(fs, "versions", "007big.iso/1451606400.00000", "segments/0", "segments/1")
+
+	err := cf.RestoreObjectVersion("TEST", "testing", "big.iso", "1451606400.00000")
+	if err != nil {
+		t.Fatalf("Could not restore version: %s", err)
+	}
+
+	fs.mutex.Lock()
+	restored := fs.manifests["testing/big.iso"]
+	fs.mutex.Unlock()
+	if len(restored) != 2 || restored[1].Name != "/versions/segments/1" {
+		t.Fatalf("The restored object should reference the archived segments: %+v", restored)
+	}
+}
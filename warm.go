@@ -0,0 +1,110 @@
+package gocloudfiles
+
+import (
+	"io/ioutil"
+	"sync"
+)
+
+// WarmOptions tune Warm.
+type WarmOptions struct {
+	// Number of objects warmed at once, defaults to 5.
+	Concurrency int
+
+	// FirstBytes also reads this many leading bytes of each object when
+	// positive, so the first range of a later read is served hot.
+	FirstBytes int64
+
+	// Results receives one Result per object when set.
+	Results chan<- Result
+}
+
+func (cf CloudFiles) Warm(dc, bucket string, names []string,
+	options *WarmOptions) (map[string]*ObjectInfo, *Report, error) {
+	/*
+		Prime the server's caches before a latency sensitive batch of
+		reads by fetching the metadata, and optionally the first bytes, of
+		every object with a pool of workers.  The metadata of the objects
+		found is returned so the reads that follow need no HEAD of their
+		own.
+		Returns a tuple of object info by name, report, error
+	*/
+	if options == nil {
+		options = &WarmOptions{}
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	infos := make(map[string]*ObjectInfo, len(names))
+	report := cf.newReport()
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	work := make(chan string)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				info, result := cf.warmObject(dc, bucket, name, options)
+
+				mutex.Lock()
+				if info != nil {
+					infos[name] = info
+				}
+				report.record(options.Results, result)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, name := range names {
+		mutex.Lock()
+		aborted := report.Aborted
+		mutex.Unlock()
+		if aborted {
+			break
+		}
+		work <- name
+	}
+	close(work)
+	wg.Wait()
+
+	return infos, report, report.Err()
+}
+
+func (cf CloudFiles) warmObject(dc, bucket, name string, options *WarmOptions) (*ObjectInfo, Result) {
+	result := Result{
+		Op:     "warm",
+		DC:     dc,
+		Bucket: bucket,
+		Name:   name,
+		Status: ResultFailure,
+	}
+
+	info, err := cf.statObject(dc, bucket, name)
+	if err != nil {
+		result.Err = err
+		return nil, result
+	}
+
+	// An empty object has no range to read.
+	length := options.FirstBytes
+	if length > info.Bytes {
+		length = info.Bytes
+	}
+
+	if length > 0 {
+		result.Bytes, _, err = cf.GetChunk(dc, bucket, name, ioutil.Discard, 0, length)
+		if err != nil {
+			result.Err = err
+			return info, result
+		}
+	}
+
+	result.Status = ResultSuccess
+	return info, result
+}
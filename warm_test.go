@@ -0,0 +1,39 @@
+package gocloudfiles
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWarmReadsMetadataAndFirstBytes(t *testing.T) {
+	// Test every object is HEADed, its leading bytes read and missing ones reported.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("media/a.mp4", []byte("0123456789"))
+	fs.put("media/b.mp4", []byte("012"))
+	fs.put("media/empty.mp4", []byte{})
+
+	meter := NewUsageMeter()
+	cf.SetAccounting(meter.Record)
+
+	infos, report, err := cf.Warm("TEST", "media", []string{"a.mp4", "b.mp4", "empty.mp4", "gone.mp4"},
+		&WarmOptions{FirstBytes: 4, Concurrency: 1})
+
+	if !errors.Is(err, ErrObjectMissing) || report.Succeeded != 3 || report.Failed != 1 {
+		t.Fatalf("Unexpected warm report %+v: %v", report, err)
+	}
+
+	if len(infos) != 3 || infos["a.mp4"].Bytes != 10 || infos["empty.mp4"].Bytes != 0 {
+		t.Fatalf("Unexpected metadata %+v", infos)
+	}
+
+	received := int64(0)
+	for _, u := range meter.Totals() {
+		received += u.Egress
+	}
+	if received != 4+3 {
+		t.Fatalf("Only the first bytes should be read, got %d", received)
+	}
+}
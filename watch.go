@@ -0,0 +1,364 @@
+package gocloudfiles
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// What a WatchEvent reports happened to an object.
+type WatchEventType int
+
+const (
+	WatchCreated WatchEventType = iota
+	WatchModified
+	WatchDeleted
+)
+
+func (t WatchEventType) String() string {
+	switch t {
+	case WatchCreated:
+		return "created"
+	case WatchModified:
+		return "modified"
+	case WatchDeleted:
+		return "deleted"
+	}
+	return fmt.Sprintf("event %d", int(t))
+}
+
+// A WatchEvent is a change a Watcher found between two listings.  Object
+// is the object as listed, its last listing for WatchDeleted.
+type WatchEvent struct {
+	Type   WatchEventType
+	DC     string
+	Bucket string
+	Object ObjectInfo
+}
+
+// WatchOptions tune a Watcher.  Zero values take the defaults.
+type WatchOptions struct {
+	// Prefix limits the watch to names beginning with it.
+	Prefix string
+
+	// Interval between polls of each container, a minute when zero.
+	Interval time.Duration
+
+	// Jitter moves each poll by up to this fraction of Interval either
+	// way, so containers watched together drift apart instead of polling
+	// in bursts.  0.1 when zero, negative for none.
+	Jitter float64
+
+	// Every FullListing-th poll lists the container even when its HEAD
+	// shows no change, catching an object overwritten with one of the
+	// same size, 10 when zero.  Negative only lists on a change.
+	FullListing int
+
+	// RequestsPerSecond caps the HEADs and listings of every container the
+	// Watcher polls together, no cap when zero.
+	RequestsPerSecond float64
+
+	// Events receives every change found.  Like a Result channel, it is
+	// owned by the caller and must be drained while the Watcher runs.
+	Events chan<- WatchEvent
+}
+
+func (options WatchOptions) withDefaults() WatchOptions {
+	if options.Interval <= 0 {
+		options.Interval = time.Minute
+	}
+	if options.Jitter == 0 {
+		options.Jitter = 0.1
+	}
+	if options.Jitter < 0 {
+		options.Jitter = 0
+	}
+	if options.Jitter > 1 {
+		options.Jitter = 1
+	}
+	if options.FullListing == 0 {
+		options.FullListing = 10
+	}
+	return options
+}
+
+// WatchStats count the requests a Watcher made.  Skipped polls are the
+// ones whose HEAD saved a listing.
+type WatchStats struct {
+	Polls    int64
+	Heads    int64
+	Listings int64
+	Skipped  int64
+	Events   int64
+	Errors   int64
+	LastErr  error
+}
+
+// A Watcher polls containers for created, modified and deleted objects.
+// A HEAD of a container is a single cheap request reporting its object
+// count and bytes, so the Watcher only lists a container when those
+// changed, or every FullListing polls.  The first poll of a container
+// records what is there without reporting it.
+type Watcher struct {
+	cf      CloudFiles
+	options WatchOptions
+	limiter *rateLimiter
+
+	mutex      sync.Mutex
+	containers map[ObjectLocation]*watchedContainer
+	stats      WatchStats
+	stop       chan bool
+	wg         sync.WaitGroup
+}
+
+// The state of one watched container, guarded by its mutex so a Poll and
+// the background loop take turns.
+type watchedContainer struct {
+	location ObjectLocation
+
+	mutex sync.Mutex
+	// objects is nil until the first listing.
+	objects map[string]ObjectInfo
+	count   int64
+	bytes   int64
+	// unlisted counts the polls since the last listing.
+	unlisted int
+}
+
+func (cf CloudFiles) NewWatcher(options *WatchOptions) *Watcher {
+	/*
+		Create a Watcher for the containers added with Watch.  A nil
+		options takes the defaults, but without Events nothing is reported.
+	*/
+	if options == nil {
+		options = &WatchOptions{}
+	}
+	resolved := options.withDefaults()
+
+	w := &Watcher{
+		cf:         cf,
+		options:    resolved,
+		containers: make(map[ObjectLocation]*watchedContainer),
+	}
+	if resolved.RequestsPerSecond > 0 {
+		// A bucket of one request spreads them out evenly.
+		w.limiter = &rateLimiter{rate: resolved.RequestsPerSecond, burst: 1, tokens: 1, last: time.Now()}
+	}
+	return w
+}
+
+func (w *Watcher) Watch(dc, bucket string) {
+	/*
+		Add a container, polled in the background from now on when the
+		Watcher is started.
+	*/
+	location := ObjectLocation{Region: dc, Container: bucket}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, found := w.containers[location]; found {
+		return
+	}
+
+	wc := &watchedContainer{location: location}
+	w.containers[location] = wc
+	if w.stop != nil {
+		w.wg.Add(1)
+		go w.loop(wc, w.stop)
+	}
+}
+
+func (w *Watcher) Start() {
+	/*
+		Poll every watched container in the background until Stop.  Each
+		container's first poll is at a random point of the first interval,
+		so many containers do not all poll at once.
+	*/
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.stop != nil {
+		return
+	}
+
+	w.stop = make(chan bool)
+	for _, wc := range w.containers {
+		w.wg.Add(1)
+		go w.loop(wc, w.stop)
+	}
+}
+
+func (w *Watcher) Stop() {
+	/*
+		Stop polling and wait for polls in flight to finish.
+	*/
+	w.mutex.Lock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+	w.mutex.Unlock()
+
+	w.wg.Wait()
+}
+
+func (w *Watcher) Poll(dc, bucket string) error {
+	/*
+		Poll a container now, adding it when it is not watched yet, and
+		send the changes found before returning.
+	*/
+	w.Watch(dc, bucket)
+
+	w.mutex.Lock()
+	wc := w.containers[ObjectLocation{Region: dc, Container: bucket}]
+	w.mutex.Unlock()
+
+	return w.poll(wc, nil)
+}
+
+func (w *Watcher) Stats() WatchStats {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.stats
+}
+
+func (w *Watcher) loop(wc *watchedContainer, stop chan bool) {
+	defer w.wg.Done()
+
+	delay := time.Duration(rand.Float64() * float64(w.options.Interval))
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// Errors are counted in the stats, the next poll tries again.
+		w.poll(wc, stop)
+		delay = w.next()
+	}
+}
+
+func (w *Watcher) next() time.Duration {
+	/*
+		The interval until a container's next poll, with jitter.
+	*/
+	jitter := w.options.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(float64(w.options.Interval) * (1 + jitter))
+}
+
+func (w *Watcher) poll(wc *watchedContainer, stop chan bool) error {
+	/*
+		HEAD the container and list it when the HEAD shows a change, it was
+		not listed yet or FullListing polls went by.
+	*/
+	wc.mutex.Lock()
+	defer wc.mutex.Unlock()
+
+	w.wait()
+	metadata, err := w.cf.GetContainerMetadata(wc.location.Region, wc.location.Container)
+	w.count(func(stats *WatchStats) { stats.Polls++; stats.Heads++ })
+	if err != nil {
+		return w.failed(err)
+	}
+
+	changed := wc.objects == nil || metadata.Objects != wc.count || metadata.Bytes != wc.bytes
+	due := w.options.FullListing > 0 && wc.unlisted+1 >= w.options.FullListing
+	if !changed && !due {
+		wc.unlisted++
+		w.count(func(stats *WatchStats) { stats.Skipped++ })
+		return nil
+	}
+
+	w.wait()
+	listing, err := w.cf.ListObjects(wc.location.Region, wc.location.Container, ListOptions{Prefix: w.options.Prefix})
+	w.count(func(stats *WatchStats) { stats.Listings++ })
+	if err != nil {
+		return w.failed(err)
+	}
+
+	objects := make(map[string]ObjectInfo, len(listing.Objects))
+	for _, object := range listing.Objects {
+		objects[object.Name] = object
+	}
+
+	var events []WatchEvent
+	if wc.objects != nil {
+		events = diffListings(wc.location, wc.objects, objects)
+	}
+
+	wc.objects = objects
+	wc.count = metadata.Objects
+	wc.bytes = metadata.Bytes
+	wc.unlisted = 0
+
+	for _, event := range events {
+		if w.options.Events == nil {
+			break
+		}
+		select {
+		case w.options.Events <- event:
+			w.count(func(stats *WatchStats) { stats.Events++ })
+		case <-stop:
+			return nil
+		}
+	}
+	return nil
+}
+
+func diffListings(location ObjectLocation, before, after map[string]ObjectInfo) []WatchEvent {
+	/*
+		The events that turn one listing into the next.  An object counts as
+		modified when its etag or last modified time changed.
+	*/
+	var events []WatchEvent
+	event := func(kind WatchEventType, object ObjectInfo) {
+		events = append(events, WatchEvent{Type: kind, DC: location.Region, Bucket: location.Container, Object: object})
+	}
+
+	for name, object := range after {
+		previous, found := before[name]
+		switch {
+		case !found:
+			event(WatchCreated, object)
+		case !sameETag(previous.ETag, object.ETag) || !previous.LastModified.Equal(object.LastModified):
+			event(WatchModified, object)
+		}
+	}
+	for name, object := range before {
+		if _, found := after[name]; !found {
+			event(WatchDeleted, object)
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Object.Name < events[j].Object.Name
+	})
+	return events
+}
+
+func (w *Watcher) wait() {
+	if w.limiter != nil {
+		w.limiter.wait(1)
+	}
+}
+
+func (w *Watcher) count(update func(stats *WatchStats)) {
+	w.mutex.Lock()
+	update(&w.stats)
+	w.mutex.Unlock()
+}
+
+func (w *Watcher) failed(err error) error {
+	w.count(func(stats *WatchStats) {
+		stats.Errors++
+		stats.LastErr = err
+	})
+	return err
+}
@@ -0,0 +1,93 @@
+package gocloudfiles
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatcherEvents(t *testing.T) {
+	// Test changes are reported and unchanged containers are not listed.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.put("media/keep", []byte("keep"))
+	fs.put("media/gone", []byte("gone"))
+	fs.put("media/edit", []byte("before"))
+
+	events := make(chan WatchEvent, 10)
+	watcher := cf.NewWatcher(&WatchOptions{FullListing: 3, Events: events})
+	if err := watcher.Poll("TEST", "media"); err != nil {
+		t.Fatalf("Could not poll: %s", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("The first poll reported existing objects.")
+	}
+
+	// The overwrite keeps the HEAD unchanged, the third poll after the
+	// listing lists anyway.
+	fs.put("media/edit", []byte("after!"))
+	for i := 0; i < 3; i++ {
+		watcher.Poll("TEST", "media")
+	}
+	if stats := watcher.Stats(); stats.Listings != 2 || stats.Skipped != 2 || len(events) != 1 {
+		t.Fatalf("Unexpected stats %+v with %d events", stats, len(events))
+	}
+	if event := <-events; event.Type != WatchModified || event.Object.Name != "edit" {
+		t.Fatalf("Unexpected event %s %s", event.Type, event.Object.Name)
+	}
+
+	fs.put("media/new", []byte("new"))
+	fs.mutex.Lock()
+	delete(fs.objects, "media/gone")
+	fs.mutex.Unlock()
+	watcher.Poll("TEST", "media")
+
+	expected := []struct {
+		kind WatchEventType
+		name string
+	}{{WatchDeleted, "gone"}, {WatchCreated, "new"}}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(events))
+	}
+	for _, e := range expected {
+		if event := <-events; event.Type != e.kind || event.Object.Name != e.name || event.Bucket != "media" {
+			t.Fatalf("Expected %s %s, got %+v", e.kind, e.name, event)
+		}
+	}
+}
+
+func TestWatcherBackground(t *testing.T) {
+	// Test started watchers poll each container with a shared rate cap.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	events := make(chan WatchEvent, 10)
+	watcher := cf.NewWatcher(&WatchOptions{Interval: 10 * time.Millisecond, RequestsPerSecond: 100, Events: events})
+	watcher.Watch("TEST", "one")
+	watcher.Watch("TEST", "two")
+	watcher.Start()
+	defer watcher.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for watcher.Stats().Listings < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	fs.put("two/file", []byte("data"))
+
+	select {
+	case event := <-events:
+		if event.Type != WatchCreated || event.Bucket != "two" {
+			t.Fatalf("Unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No event from the background watcher.")
+	}
+
+	watcher.Stop()
+	stats := watcher.Stats()
+	if elapsed := time.Since(deadline.Add(-5 * time.Second)); float64(stats.Heads+stats.Listings) > elapsed.Seconds()*100+2 {
+		t.Fatalf("%d requests in %s exceed the rate cap.", stats.Heads+stats.Listings, elapsed)
+	}
+}
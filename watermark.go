@@ -0,0 +1,102 @@
+package gocloudfiles
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// A WatermarkStore remembers, per replicated container pair, the
+// last_modified timestamp from which the next run must look at objects
+// again.
+type WatermarkStore interface {
+	GetWatermark(key string) (string, error)
+	SetWatermark(key, value string) error
+}
+
+// MemoryWatermarks keeps watermarks for the life of the process.
+type MemoryWatermarks struct {
+	mutex sync.Mutex
+	marks map[string]string
+}
+
+func NewMemoryWatermarks() *MemoryWatermarks {
+	return &MemoryWatermarks{marks: make(map[string]string)}
+}
+
+func (mw *MemoryWatermarks) GetWatermark(key string) (string, error) {
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+
+	return mw.marks[key], nil
+}
+
+func (mw *MemoryWatermarks) SetWatermark(key, value string) error {
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+
+	mw.marks[key] = value
+	return nil
+}
+
+// FileWatermarks persists watermarks as a JSON object in a local file so
+// they survive restarts between scheduled runs.
+type FileWatermarks struct {
+	mutex sync.Mutex
+	path  string
+}
+
+func NewFileWatermarks(path string) *FileWatermarks {
+	return &FileWatermarks{path: path}
+}
+
+func (fw *FileWatermarks) load() (map[string]string, error) {
+	marks := make(map[string]string)
+
+	data, err := ioutil.ReadFile(fw.path)
+	if os.IsNotExist(err) {
+		return marks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &marks)
+	return marks, err
+}
+
+func (fw *FileWatermarks) GetWatermark(key string) (string, error) {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	marks, err := fw.load()
+	if err != nil {
+		return "", err
+	}
+	return marks[key], nil
+}
+
+func (fw *FileWatermarks) SetWatermark(key, value string) error {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	marks, err := fw.load()
+	if err != nil {
+		return err
+	}
+	marks[key] = value
+
+	data, err := json.MarshalIndent(marks, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so a crash never leaves a truncated file.
+	tmpPath := fw.path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, fw.path)
+}
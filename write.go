@@ -0,0 +1,174 @@
+package gocloudfiles
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WriteOptions apply to every request that writes object data: PutFile,
+// the segments and manifest written by CopyFile and checksum records.  A
+// new write option added here reaches all of them.
+type WriteOptions struct {
+	// ContentType of the new object, "application/octet-stream" for data
+	// when empty.
+	ContentType string
+
+	// Headers are added to every write, e.g. X-Object-Meta-* metadata.
+	Headers map[string]string
+
+	// DeleteAfter or DeleteAt make the written objects expire.
+	DeleteAfter time.Duration
+	DeleteAt    time.Time
+
+	// VerifyMD5 sends the MD5 of the data as the request ETag so the
+	// server rejects an upload corrupted on the way.  Data that is not an
+	// io.ReadSeeker is sent without one.
+	VerifyMD5 bool
+
+	// Retries is how many times a failed write is retried, RetryDelay
+	// apart.  Only data that is an io.ReadSeeker can be retried.
+	Retries    int
+	RetryDelay time.Duration
+
+	// IfExists decides what PutFileWithOptions does when the object is
+	// already there.  Copies use CopyOptions.IfExists instead and ignore
+	// it.
+	IfExists ExistsPolicy
+
+	// Pace smooths each write request to this many bytes per second, so a
+	// single large upload does not saturate the uplink in bursts.  It
+	// applies per request, on top of any SetBandwidthLimits budget.
+	Pace int64
+
+	// Mirror duplicates every write to a secondary region.
+	Mirror *Mirror
+
+	// Compress gzips the data and EncryptionKey, an AES key of 16, 24 or
+	// 32 bytes, then encrypts it with AES-GCM before it leaves the client.
+	// DownloadLargeObject undoes both, given the key in DownloadOptions.
+	// They apply to PutFile, UploadFile and UploadStream; copies keep the
+	// source's bytes and refuse them.
+	Compress      bool
+	EncryptionKey []byte
+
+	// The transforms already applied to the data being written.
+	encoded string
+}
+
+func (options *WriteOptions) apply(req *http.Request) {
+	/*
+		Set the headers every write carries.  A nil options adds nothing.
+	*/
+	options.set(req.Header)
+}
+
+func (options *WriteOptions) headers() map[string]string {
+	/*
+		The headers every write carries, for server side copies.
+	*/
+	header := http.Header{}
+	options.set(header)
+
+	headers := make(map[string]string, len(header))
+	for key := range header {
+		headers[key] = header.Get(key)
+	}
+	return headers
+}
+
+func (options *WriteOptions) set(header http.Header) {
+	if options == nil {
+		return
+	}
+
+	if options.ContentType != "" {
+		header.Set("Content-Type", options.ContentType)
+	}
+
+	for key, value := range options.Headers {
+		header.Set(key, value)
+	}
+
+	if options.encoded != "" {
+		header.Set(transformHeader, options.encoded)
+	}
+
+	if options.DeleteAfter > 0 {
+		header.Set("X-Delete-After", strconv.FormatInt(int64(options.DeleteAfter/time.Second), 10))
+	}
+
+	if !options.DeleteAt.IsZero() {
+		header.Set("X-Delete-At", strconv.FormatInt(options.DeleteAt.Unix(), 10))
+	}
+
+	// The server refuses the write when the object exists.
+	if options.IfExists == FailIfExists {
+		header.Set("If-None-Match", "*")
+	}
+}
+
+func (options *WriteOptions) pace(body io.ReadCloser) io.ReadCloser {
+	/*
+		Wrap a request body in its own token bucket when pacing is on.
+	*/
+	if options == nil {
+		return body
+	}
+	return wrapBody(body, newPacer(options.Pace))
+}
+
+func (options *WriteOptions) retry(data io.Reader, write func() error) error {
+	/*
+		Run a write, rewinding data and running it again after a failure
+		for as many retries as the options allow.
+	*/
+	retries := 0
+	delay := time.Duration(0)
+	if options != nil {
+		retries, delay = options.Retries, options.RetryDelay
+	}
+
+	seeker, seekable := data.(io.Seeker)
+	start := int64(0)
+	if seekable {
+		start, _ = seeker.Seek(0, io.SeekCurrent)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := write()
+		if err == nil || attempt >= retries || !seekable {
+			return err
+		}
+
+		time.Sleep(delay)
+
+		if _, seekErr := seeker.Seek(start, io.SeekStart); seekErr != nil {
+			return err
+		}
+	}
+}
+
+func md5Of(data io.ReadSeeker) (string, error) {
+	/*
+		Hash the rest of data and rewind it to where it was.
+	*/
+	start, err := data.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+
+	hash := md5.New()
+	if _, err = io.Copy(hash, data); err != nil {
+		return "", err
+	}
+
+	if _, err = data.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
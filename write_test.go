@@ -0,0 +1,92 @@
+package gocloudfiles
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPutFileWithOptions(t *testing.T) {
+	// Test write options reach the request and failed writes are retried.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	fs.fail("testing/file.txt", 1)
+
+	options := &WriteOptions{
+		ContentType: "text/plain",
+		Headers:     map[string]string{"X-Object-Meta-Owner": "ops"},
+		DeleteAfter: time.Hour,
+		VerifyMD5:   true,
+		Retries:     1,
+	}
+
+	_, err := cf.PutFileWithOptions("TEST", "testing", "file.txt", bytes.NewReader([]byte("hello")), options)
+	if err != nil {
+		t.Fatalf("Could not put file: %s", err)
+	}
+
+	if data, _ := fs.get("testing/file.txt"); string(data) != "hello" {
+		t.Fatalf("The retried upload should send the whole body: %q", data)
+	}
+
+	headers := fs.headers["testing/file.txt"]
+	if headers.Get("Content-Type") != "text/plain" || headers.Get("X-Object-Meta-Owner") != "ops" ||
+		headers.Get("X-Delete-At") == "" {
+		t.Fatalf("Write options were not applied: %v", headers)
+	}
+
+	_, err = cf.putObject("TEST", "testing", "file.txt", bytes.NewReader([]byte("hello")),
+		"00000000000000000000000000000000", nil)
+	if err == nil {
+		t.Fatalf("An upload not matching its MD5 should be rejected.")
+	}
+}
+
+func TestCopyFileWriteOptions(t *testing.T) {
+	// Test a copy applies its write options to the segments and manifest.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	putLargeObject(fs, "src", "file.bin", "part")
+
+	err := cf.CopyFileWithOptions("TEST", "src", "file.bin", "TEST", "dst", "file.bin", &CopyOptions{
+		Write: &WriteOptions{Headers: map[string]string{"X-Object-Meta-Tier": "cold"}, VerifyMD5: true},
+	})
+	if err != nil {
+		t.Fatalf("Could not copy: %s", err)
+	}
+
+	for _, name := range []string{"dst/file.bin", "dst/" + segmentName("file.bin", "", 0, DefaultSegmentDigits)} {
+		if fs.headers[name].Get("X-Object-Meta-Tier") != "cold" {
+			t.Fatalf("Write options were not applied to %s: %v", name, fs.headers[name])
+		}
+	}
+}
+
+func TestPutFilePaced(t *testing.T) {
+	// Test a paced upload is spread out to the target rate.
+	fs := newFakeSwift()
+	defer fs.Close()
+	cf := fs.client()
+
+	data := bytes.Repeat([]byte("p"), 4000)
+	started := time.Now()
+
+	// 4000 bytes at 20000 bytes per second, less a 1000 byte bucket.
+	_, err := cf.PutFileWithOptions("TEST", "testing", "paced.bin", bytes.NewReader(data),
+		&WriteOptions{Pace: 20000})
+	if err != nil {
+		t.Fatalf("Could not put file: %s", err)
+	}
+
+	if elapsed := time.Since(started); elapsed < 140*time.Millisecond {
+		t.Fatalf("Paced upload finished too soon: %s", elapsed)
+	}
+
+	if stored, _ := fs.get("testing/paced.bin"); !bytes.Equal(stored, data) {
+		t.Fatalf("Paced upload was damaged.")
+	}
+}